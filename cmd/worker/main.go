@@ -0,0 +1,28 @@
+// Command worker runs the background jobs outside the API server, start the
+// server with -jobs=false when it does.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/harundarat/be-socialtask/internal/app"
+)
+
+func main() {
+	app, err := app.NewApplication()
+	if err != nil {
+		panic(err)
+	}
+	defer app.DB.Close()
+
+	// SIGINT and SIGTERM stop claiming jobs and drain the running ones
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app.Logger.Println("Running background jobs")
+	app.Jobs.Run(ctx)
+	app.Logger.Println("Background jobs drained")
+}
@@ -0,0 +1,113 @@
+package achievements
+
+import (
+	"log"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type EventType string
+
+const (
+	EventRewardGranted EventType = "reward_granted"
+	EventTaskJoined    EventType = "task_joined"
+)
+
+// Event is something a user did that may earn them a badge.
+type Event struct {
+	Type   EventType
+	UserID int64
+}
+
+// Engine evaluates the badge rules defined by admins against a user's activity
+// and awards every badge whose rule is satisfied.
+type Engine struct {
+	badgeStore store.BadgeStore
+	logger     *log.Logger
+}
+
+func NewEngine(badgeStore store.BadgeStore, logger *log.Logger) *Engine {
+	return &Engine{
+		badgeStore: badgeStore,
+		logger:     logger,
+	}
+}
+
+// RuleSatisfied reports whether the stats meet the rule's threshold.
+func RuleSatisfied(rule store.BadgeRule, stats *store.UserActivityStats) bool {
+	switch rule.Kind {
+	case store.BadgeRuleTasksCompleted:
+		return float64(stats.TasksCompleted) >= rule.Threshold
+	case store.BadgeRuleActionCompleted:
+		return float64(stats.ActionsCompleted[rule.ActionType]) >= rule.Threshold
+	case store.BadgeRuleStreakDays:
+		return float64(stats.LongestStreak) >= rule.Threshold
+	case store.BadgeRuleTotalEarnings:
+		return stats.TotalEarnings >= rule.Threshold
+	case store.BadgeRuleTasksJoined:
+		return float64(stats.TasksJoined) >= rule.Threshold
+	case store.BadgeRuleCheckInStreak:
+		return float64(stats.LongestCheckInStreak) >= rule.Threshold
+	default:
+		return false
+	}
+}
+
+// Handle evaluates every badge for the user behind the event and returns the
+// badges that were newly awarded.
+func (e *Engine) Handle(event Event) ([]store.Badge, error) {
+	badges, err := e.allBadges()
+	if err != nil {
+		return nil, err
+	}
+
+	owned, err := e.badgeStore.GetUserBadges(event.UserID)
+	if err != nil {
+		return nil, err
+	}
+	ownedIDs := make(map[int64]bool, len(owned))
+	for _, b := range owned {
+		ownedIDs[b.ID] = true
+	}
+
+	stats, err := e.badgeStore.GetUserActivityStats(event.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	awarded := []store.Badge{}
+	for _, badge := range badges {
+		if ownedIDs[badge.ID] || !RuleSatisfied(badge.Rule, stats) {
+			continue
+		}
+
+		ok, err := e.badgeStore.AwardBadge(event.UserID, badge.ID)
+		if err != nil {
+			return awarded, err
+		}
+		if ok {
+			e.logger.Printf("INFO: user %d earned badge %q", event.UserID, badge.Code)
+			awarded = append(awarded, badge)
+		}
+	}
+
+	return awarded, nil
+}
+
+// allBadges reads every badge, a page at a time.
+func (e *Engine) allBadges() ([]store.Badge, error) {
+	var badges []store.Badge
+	page := utils.PageParams{Sort: "id", Limit: utils.MaxPageLimit}
+	for {
+		batch, bounds, err := e.badgeStore.GetBadges(page)
+		if err != nil {
+			return nil, err
+		}
+		badges = append(badges, batch...)
+		if !bounds.HasMore {
+			return badges, nil
+		}
+		page.Cursor = bounds.Last
+	}
+}
@@ -0,0 +1,127 @@
+package achievements
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBadgeStore struct {
+	store.BadgeStore
+	badges  []store.Badge
+	awarded map[int64]bool
+	stats   store.UserActivityStats
+}
+
+func (f *fakeBadgeStore) GetBadges(page utils.PageParams) ([]store.Badge, utils.PageBounds, error) {
+	var badges []store.Badge
+	for _, b := range f.badges {
+		if page.Cursor == nil || b.ID > page.Cursor.ID {
+			badges = append(badges, b)
+		}
+	}
+
+	var bounds utils.PageBounds
+	if int64(len(badges)) > page.Limit {
+		badges, bounds.HasMore = badges[:page.Limit], true
+	}
+	if len(badges) > 0 {
+		bounds.Last = &utils.Cursor{Sort: page.Sort, ID: badges[len(badges)-1].ID}
+	}
+	return badges, bounds, nil
+}
+
+func (f *fakeBadgeStore) GetUserBadges(userID int64) ([]store.UserBadge, error) {
+	var owned []store.UserBadge
+	for _, b := range f.badges {
+		if f.awarded[b.ID] {
+			owned = append(owned, store.UserBadge{Badge: b})
+		}
+	}
+	return owned, nil
+}
+
+func (f *fakeBadgeStore) AwardBadge(userID, badgeID int64) (bool, error) {
+	if f.awarded[badgeID] {
+		return false, nil
+	}
+	f.awarded[badgeID] = true
+	return true, nil
+}
+
+func (f *fakeBadgeStore) GetUserActivityStats(userID int64) (*store.UserActivityStats, error) {
+	return &f.stats, nil
+}
+
+func TestRuleSatisfied(t *testing.T) {
+	stats := &store.UserActivityStats{
+		TasksCompleted:       3,
+		ActionsCompleted:     map[store.TypeAction]int64{store.Type1: 2},
+		LongestStreak:        7,
+		TotalEarnings:        4.5,
+		TasksJoined:          5,
+		LongestCheckInStreak: 3,
+	}
+
+	tests := []struct {
+		name string
+		rule store.BadgeRule
+		want bool
+	}{
+		{"tasks met", store.BadgeRule{Kind: store.BadgeRuleTasksCompleted, Threshold: 3}, true},
+		{"tasks not met", store.BadgeRule{Kind: store.BadgeRuleTasksCompleted, Threshold: 4}, false},
+		{"action met", store.BadgeRule{Kind: store.BadgeRuleActionCompleted, Threshold: 2, ActionType: store.Type1}, true},
+		{"other action", store.BadgeRule{Kind: store.BadgeRuleActionCompleted, Threshold: 1, ActionType: store.Type2}, false},
+		{"streak met", store.BadgeRule{Kind: store.BadgeRuleStreakDays, Threshold: 7}, true},
+		{"earnings not met", store.BadgeRule{Kind: store.BadgeRuleTotalEarnings, Threshold: 5}, false},
+		{"joins met", store.BadgeRule{Kind: store.BadgeRuleTasksJoined, Threshold: 5}, true},
+		{"check-in streak not met", store.BadgeRule{Kind: store.BadgeRuleCheckInStreak, Threshold: 7}, false},
+		{"unknown kind", store.BadgeRule{Kind: "unknown", Threshold: 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RuleSatisfied(tt.rule, stats))
+		})
+	}
+}
+
+func TestEngineAwardsOnce(t *testing.T) {
+	badgeStore := &fakeBadgeStore{
+		badges: []store.Badge{
+			{ID: 1, Code: "first_task", Rule: store.BadgeRule{Kind: store.BadgeRuleTasksCompleted, Threshold: 1}},
+			{ID: 2, Code: "ten_tasks", Rule: store.BadgeRule{Kind: store.BadgeRuleTasksCompleted, Threshold: 10}},
+		},
+		awarded: map[int64]bool{},
+		stats:   store.UserActivityStats{TasksCompleted: 1},
+	}
+	engine := NewEngine(badgeStore, log.New(io.Discard, "", 0))
+
+	awarded, err := engine.Handle(Event{Type: EventRewardGranted, UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, awarded, 1)
+	assert.Equal(t, "first_task", awarded[0].Code)
+
+	awarded, err = engine.Handle(Event{Type: EventRewardGranted, UserID: 1})
+	require.NoError(t, err)
+	assert.Empty(t, awarded)
+}
+
+func TestEngineReadsEveryBadgePage(t *testing.T) {
+	badgeStore := &fakeBadgeStore{awarded: map[int64]bool{}, stats: store.UserActivityStats{TasksCompleted: 1}}
+	for id := int64(1); id <= utils.MaxPageLimit+1; id++ {
+		badgeStore.badges = append(badgeStore.badges, store.Badge{ID: id, Rule: store.BadgeRule{Kind: store.BadgeRuleTasksCompleted, Threshold: 10}})
+	}
+	badgeStore.badges[utils.MaxPageLimit].Rule.Threshold = 1
+	engine := NewEngine(badgeStore, log.New(io.Discard, "", 0))
+
+	awarded, err := engine.Handle(Event{Type: EventRewardGranted, UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, awarded, 1)
+	assert.Equal(t, utils.MaxPageLimit+1, awarded[0].ID)
+}
@@ -18,12 +18,14 @@ var validTaskTypes = map[store.TypeAction]bool{
 
 type ActionHandler struct {
 	actionStore store.TaskActionStore
+	cursors     *utils.CursorCodec
 	logger      *log.Logger
 }
 
-func NewActionHandler(actionStore store.TaskActionStore, logger *log.Logger) *ActionHandler {
+func NewActionHandler(actionStore store.TaskActionStore, cursors *utils.CursorCodec, logger *log.Logger) *ActionHandler {
 	return &ActionHandler{
 		actionStore: actionStore,
+		cursors:     cursors,
 		logger:      logger,
 	}
 }
@@ -71,14 +73,20 @@ func (th *ActionHandler) HandleGetActionByID(w http.ResponseWriter, r *http.Requ
 }
 
 func (th *ActionHandler) HandleGetAllAction(w http.ResponseWriter, r *http.Request) {
-	action, err := th.actionStore.GetAction()
+	page, err := th.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	action, bounds, err := th.actionStore.GetAction(page)
 	if err != nil {
 		th.logger.Printf("ERROR: getAllTask: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, utils.Envelope{"action": action}, nil)
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, th.cursors.PageEnvelope(utils.Envelope{"action": action}, page, bounds), nil)
 }
 
 func (th *ActionHandler) HandleEditAction(w http.ResponseWriter, r *http.Request) {
@@ -113,7 +113,7 @@ func (h *AuthHandler) HandleTwitterCallback(w http.ResponseWriter, r *http.Reque
 
 	// use token to get user data from X
 	client := h.oauthConf.Client(context.Background(), token)
-	response, err := client.Get("https://api.twitter.com/2/users/me?user.fields=id,name,username,profile_image_url")
+	response, err := client.Get("https://api.twitter.com/2/users/me?user.fields=id,name,username,profile_image_url,created_at,public_metrics")
 	if err != nil {
 		h.logger.Println("ERROR: Failed to get user from X:", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageOAuthFailed, http.StatusInternalServerError, nil, nil)
@@ -134,6 +134,11 @@ func (h *AuthHandler) HandleTwitterCallback(w http.ResponseWriter, r *http.Reque
 			Name     string `json:"name"`
 			Username string `json:"username"`
 			Email    string `json:"email"`
+			// CreatedAt and PublicMetrics feed the task eligibility rules
+			CreatedAt     time.Time `json:"created_at"`
+			PublicMetrics struct {
+				FollowersCount int64 `json:"followers_count"`
+			} `json:"public_metrics"`
 		} `json:"data"`
 		Errors []struct {
 			Message string `json:"message"`
@@ -216,6 +221,17 @@ func (h *AuthHandler) HandleTwitterCallback(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// a stale X profile only affects eligibility checks, it should not block the login
+	err = h.userStore.UpdateXProfile(user.ID, store.XProfile{
+		ID:        twitterUser.Data.ID,
+		Username:  twitterUser.Data.Username,
+		CreatedAt: twitterUser.Data.CreatedAt,
+		Followers: twitterUser.Data.PublicMetrics.FollowersCount,
+	})
+	if err != nil {
+		h.logger.Printf("ERROR: updateXProfile: %v", err)
+	}
+
 	// Generate a JWT for the user
 	jwtToken, err := auth.GenerateJWTToken(user.ID, auth.RoleUser, utils.GetEnv("JWT_SECRET"))
 	if err != nil {
@@ -268,6 +284,12 @@ func (h *AuthHandler) CallbackAuthenticationGooogle(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if userInfo.VerifiedEmail {
+		if err := h.userStore.MarkEmailVerified(user.ID); err != nil {
+			h.logger.Printf("ERROR: markEmailVerified: %v", err)
+		}
+	}
+
 	jwtToken, err := auth.GenerateJWTToken(user.ID, auth.RoleUser, utils.GetEnv("JWT_SECRET"))
 	if err != nil {
 		h.logger.Printf("ERROR: generating token: %v", err)
@@ -324,6 +346,12 @@ func (h *AuthHandler) HandleGoogleLoginAndroid(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if verified, _ := payload.Claims["email_verified"].(bool); verified {
+		if err := h.userStore.MarkEmailVerified(user.ID); err != nil {
+			h.logger.Printf("ERROR: markEmailVerified: %v", err)
+		}
+	}
+
 	jwtToken, err := auth.GenerateJWTToken(user.ID, auth.RoleUser, utils.GetEnv("JWT_SECRET"))
 	if err != nil {
 		h.logger.Printf("ERROR: generating token: %v", err)
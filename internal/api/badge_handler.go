@@ -0,0 +1,207 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var validBadgeRuleKinds = map[store.BadgeRuleKind]bool{
+	store.BadgeRuleTasksCompleted:  true,
+	store.BadgeRuleActionCompleted: true,
+	store.BadgeRuleStreakDays:      true,
+	store.BadgeRuleTotalEarnings:   true,
+	store.BadgeRuleTasksJoined:     true,
+	store.BadgeRuleCheckInStreak:   true,
+}
+
+type BadgeHandler struct {
+	badgeStore store.BadgeStore
+	cursors    *utils.CursorCodec
+	logger     *log.Logger
+}
+
+func NewBadgeHandler(badgeStore store.BadgeStore, cursors *utils.CursorCodec, logger *log.Logger) *BadgeHandler {
+	return &BadgeHandler{
+		badgeStore: badgeStore,
+		cursors:    cursors,
+		logger:     logger,
+	}
+}
+
+func (bh *BadgeHandler) validateBadge(badge *store.Badge) error {
+	if badge.Code == "" {
+		return errors.New("code is required")
+	}
+	if len(badge.Code) > 50 {
+		return errors.New("code must be less than 50 characters")
+	}
+	if badge.Name == "" {
+		return errors.New("name is required")
+	}
+	if !validBadgeRuleKinds[badge.Rule.Kind] {
+		return errors.New("rule.kind must be one of tasks_completed, action_completed, streak_days, total_earnings, tasks_joined or check_in_streak")
+	}
+	if badge.Rule.Threshold <= 0 {
+		return errors.New("rule.threshold must be greater than zero")
+	}
+	if badge.Rule.Kind == store.BadgeRuleActionCompleted && !validTaskTypes[badge.Rule.ActionType] {
+		return errors.New("rule.action_type must be a valid action type")
+	}
+
+	return nil
+}
+
+func (bh *BadgeHandler) HandleCreateBadge(w http.ResponseWriter, r *http.Request) {
+	var badge store.Badge
+	err := json.NewDecoder(r.Body).Decode(&badge)
+	if err != nil {
+		bh.logger.Printf("ERROR: decodingCreateBadge: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = bh.validateBadge(&badge)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	createdBadge, err := bh.badgeStore.CreateBadge(&badge)
+	if err == store.ErrBadgeCodeTaken {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusConflict, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		bh.logger.Printf("ERROR: createBadge: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgeCreated, http.StatusCreated, utils.Envelope{"badge": createdBadge}, nil)
+}
+
+func (bh *BadgeHandler) HandleGetAllBadge(w http.ResponseWriter, r *http.Request) {
+	page, err := bh.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	badges, bounds, err := bh.badgeStore.GetBadges(page)
+	if err != nil {
+		bh.logger.Printf("ERROR: getBadges: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgesFetched, http.StatusOK, bh.cursors.PageEnvelope(utils.Envelope{"badges": badges}, page, bounds), nil)
+}
+
+func (bh *BadgeHandler) HandleGetBadgeByID(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		bh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	badge, err := bh.badgeStore.GetBadgeByID(id)
+	if err != nil {
+		bh.logger.Printf("ERROR: getBadgeByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if badge == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgeRetrieved, http.StatusOK, utils.Envelope{"badge": badge}, nil)
+}
+
+func (bh *BadgeHandler) HandleEditBadge(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		bh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var badge store.Badge
+	err = json.NewDecoder(r.Body).Decode(&badge)
+	if err != nil {
+		bh.logger.Printf("ERROR: decodingEditBadge: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+	badge.ID = id
+
+	err = bh.validateBadge(&badge)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	err = bh.badgeStore.EditBadge(&badge)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+			return
+		}
+		if err == store.ErrBadgeCodeTaken {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusConflict, nil, []string{err.Error()})
+			return
+		}
+		bh.logger.Printf("ERROR: editBadge: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgeUpdated, http.StatusOK, nil, nil)
+}
+
+func (bh *BadgeHandler) HandleDeleteBadge(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		bh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = bh.badgeStore.DeleteBadge(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+			return
+		}
+		bh.logger.Printf("ERROR: deleteBadge: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgeDeleted, http.StatusOK, nil, nil)
+}
+
+func (bh *BadgeHandler) HandleGetUserBadges(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		bh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	badges, err := bh.badgeStore.GetUserBadges(id)
+	if err != nil {
+		bh.logger.Printf("ERROR: getUserBadges: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageBadgesFetched, http.StatusOK, utils.Envelope{"badges": badges}, nil)
+}
@@ -0,0 +1,419 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type addCampaignTasksRequest struct {
+	TaskIDs []int64 `json:"task_ids"`
+}
+
+func (req addCampaignTasksRequest) validate() error {
+	if len(req.TaskIDs) == 0 || len(req.TaskIDs) > store.MaxCampaignTasks {
+		return fmt.Errorf("task_ids must hold between 1 and %d task ids", store.MaxCampaignTasks)
+	}
+
+	seen := make(map[int64]bool, len(req.TaskIDs))
+	for _, id := range req.TaskIDs {
+		if id <= 0 {
+			return errors.New("task_ids must only contain valid task ids")
+		}
+		if seen[id] {
+			return errors.New("task_ids must not contain the same task twice")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+type CampaignHandler struct {
+	campaignStore  store.CampaignStore
+	taskStore      store.TaskStore
+	orgStore       store.OrganizationStore
+	analyticsStore store.AnalyticsStore
+	cursors        *utils.CursorCodec
+	logger         *log.Logger
+}
+
+func NewCampaignHandler(campaignStore store.CampaignStore, taskStore store.TaskStore, orgStore store.OrganizationStore, analyticsStore store.AnalyticsStore, cursors *utils.CursorCodec, logger *log.Logger) *CampaignHandler {
+	return &CampaignHandler{
+		campaignStore:  campaignStore,
+		taskStore:      taskStore,
+		orgStore:       orgStore,
+		analyticsStore: analyticsStore,
+		cursors:        cursors,
+		logger:         logger,
+	}
+}
+
+func (ch *CampaignHandler) HandleCreateCampaign(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var campaign store.Campaign
+	err := json.NewDecoder(r.Body).Decode(&campaign)
+	if err != nil {
+		ch.logger.Printf("ERROR: decodingCreateCampaign: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = campaign.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	// only managers create campaigns for their organization
+	if campaign.OrganizationID != nil {
+		role, err := ch.orgStore.GetMemberRole(*campaign.OrganizationID, user.ID)
+		if err != nil {
+			ch.logger.Printf("ERROR: getMemberRole: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		if !role.Can(store.OrgRoleManager) {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, []string{"only managers of the organization can create its campaigns"})
+			return
+		}
+	}
+
+	campaign.UserID = user.ID
+	created, err := ch.campaignStore.CreateCampaign(&campaign)
+	if err == store.ErrCampaignAssetNotFound {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: createCampaign: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignCreated, http.StatusCreated, utils.Envelope{"campaign": created}, nil)
+}
+
+// HandleGetCampaigns lists the caller's own campaigns, or those of one of
+// their organizations, newest first.
+func (ch *CampaignHandler) HandleGetCampaigns(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	orgID, err := readOrganizationParam(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	page, err := ch.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	if orgID != 0 && !user.IsModerator() {
+		role, err := ch.orgStore.GetMemberRole(orgID, user.ID)
+		if err != nil {
+			ch.logger.Printf("ERROR: getMemberRole: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		if role == "" {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+			return
+		}
+	}
+
+	campaigns, bounds, err := ch.campaignStore.GetCampaigns(user.ID, orgID, page)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCampaigns: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignsFetched, http.StatusOK, ch.cursors.PageEnvelope(utils.Envelope{"campaigns": campaigns}, page, bounds), nil)
+}
+
+// loadCampaign reads the campaign in the URL and checks the caller's role
+// on it allows need, like tasks. Moderators can also read every campaign.
+// It writes the error response itself and returns nil when the request can
+// not proceed.
+func (ch *CampaignHandler) loadCampaign(w http.ResponseWriter, r *http.Request, need store.OrgRole) *store.Campaign {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		ch.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	campaign, err := ch.campaignStore.GetCampaignByID(id)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCampaignByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if campaign == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	role, err := resourceRole(ch.orgStore, user, campaign.UserID, campaign.OrganizationID)
+	if err != nil {
+		ch.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if !role.Can(need) && (need != store.OrgRoleViewer || !user.IsModerator()) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+
+	return campaign
+}
+
+// HandleGetCampaign returns the campaign with its aggregate stats.
+func (ch *CampaignHandler) HandleGetCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleViewer)
+	if campaign == nil {
+		return
+	}
+
+	stats, err := ch.campaignStore.GetCampaignStats(campaign.ID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCampaignStats: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignRetrieved, http.StatusOK, utils.Envelope{
+		"campaign": campaign,
+		"stats":    stats,
+	}, nil)
+}
+
+// HandleUpdateCampaign changes the fields present in the body and keeps the
+// rest.
+func (ch *CampaignHandler) HandleUpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleManager)
+	if campaign == nil {
+		return
+	}
+	id, userID := campaign.ID, campaign.UserID
+
+	err := json.NewDecoder(r.Body).Decode(campaign)
+	if err != nil {
+		ch.logger.Printf("ERROR: decodingUpdateCampaign: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+	campaign.ID, campaign.UserID = id, userID
+
+	err = campaign.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	err = ch.campaignStore.UpdateCampaign(campaign)
+	if err == store.ErrCampaignAssetNotFound {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: updateCampaign: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	// read back, the store keeps fields a campaign can not change such as its
+	// organization
+	updated, err := ch.campaignStore.GetCampaignByID(id)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCampaignByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if updated == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignUpdated, http.StatusOK, utils.Envelope{"campaign": updated}, nil)
+}
+
+func (ch *CampaignHandler) HandleDeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleManager)
+	if campaign == nil {
+		return
+	}
+
+	err := ch.campaignStore.DeleteCampaign(campaign.ID)
+	if err != nil {
+		ch.logger.Printf("ERROR: deleteCampaign: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignDeleted, http.StatusOK, nil, nil)
+}
+
+// HandleGetCampaignTasks lists the campaign's tasks, drafts included, newest
+// first.
+func (ch *CampaignHandler) HandleGetCampaignTasks(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleViewer)
+	if campaign == nil {
+		return
+	}
+
+	filter := store.TaskFilter{
+		CampaignID:    campaign.ID,
+		IncludeDrafts: true,
+		Sort:          store.TaskSortNewest,
+	}
+
+	var err error
+	filter.Page, err = ch.cursors.ReadPageParams(r, string(filter.Sort))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	tasks, bounds, total, err := ch.taskStore.GetAllTask(filter)
+	if err != nil {
+		ch.logger.Printf("ERROR: getAllTask: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, ch.cursors.PageEnvelope(utils.Envelope{
+		"tasks": tasks,
+		"meta": map[string]int64{
+			"limit": filter.Page.Limit,
+			"total": total,
+		},
+	}, filter.Page, bounds), nil)
+}
+
+// HandleAddCampaignTasks moves tasks with the campaign's owner into the
+// campaign.
+func (ch *CampaignHandler) HandleAddCampaignTasks(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleManager)
+	if campaign == nil {
+		return
+	}
+
+	var req addCampaignTasksRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ch.logger.Printf("ERROR: decodingAddCampaignTasks: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = req.validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	err = ch.campaignStore.AddCampaignTasks(campaign.ID, req.TaskIDs)
+	if errors.Is(err, store.ErrCampaignTaskNotFound) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: addCampaignTasks: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignTasksAdded, http.StatusOK, nil, nil)
+}
+
+func (ch *CampaignHandler) HandleRemoveCampaignTask(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleManager)
+	if campaign == nil {
+		return
+	}
+
+	taskID, err := utils.ReadNamedIDParam(r, "taskId")
+	if err != nil {
+		ch.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = ch.campaignStore.RemoveCampaignTask(campaign.ID, taskID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: removeCampaignTask: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageCampaignTaskRemoved, http.StatusOK, nil, nil)
+}
+
+// HandleGetCampaignParticipants lists the distinct users who joined any of
+// the campaign's tasks.
+func (ch *CampaignHandler) HandleGetCampaignParticipants(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleViewer)
+	if campaign == nil {
+		return
+	}
+
+	page, err := ch.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	participants, bounds, err := ch.campaignStore.GetCampaignParticipants(campaign.ID, page)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCampaignParticipants: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageParticipantsFetched, http.StatusOK, ch.cursors.PageEnvelope(utils.Envelope{"participants": participants}, page, bounds), nil)
+}
+
+// HandleGetCampaignAnalytics adds up the analytics of the campaign's tasks.
+func (ch *CampaignHandler) HandleGetCampaignAnalytics(w http.ResponseWriter, r *http.Request) {
+	campaign := ch.loadCampaign(w, r, store.OrgRoleViewer)
+	if campaign == nil {
+		return
+	}
+
+	filter, err := readAnalyticsFilter(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	filter.CampaignID = campaign.ID
+	analytics, err := ch.analyticsStore.GetTaskAnalytics(filter)
+	if err == store.ErrAnalyticsRange {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: getTaskAnalytics: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageAnalyticsFetched, http.StatusOK, utils.Envelope{"analytics": analytics}, nil)
+}
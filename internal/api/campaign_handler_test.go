@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCampaignStore struct {
+	store.CampaignStore
+	campaigns map[int64]*store.Campaign
+	added     [][]int64
+}
+
+func (f *fakeCampaignStore) GetCampaignByID(id int64) (*store.Campaign, error) {
+	c, ok := f.campaigns[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *c
+	return &copied, nil
+}
+
+// UpdateCampaign keeps the organization, like the Postgres store.
+func (f *fakeCampaignStore) UpdateCampaign(c *store.Campaign) error {
+	updated := *c
+	updated.OrganizationID = f.campaigns[c.ID].OrganizationID
+	f.campaigns[c.ID] = &updated
+	return nil
+}
+
+func (f *fakeCampaignStore) GetCampaignStats(campaignID int64) (*store.CampaignStats, error) {
+	return &store.CampaignStats{}, nil
+}
+
+func (f *fakeCampaignStore) AddCampaignTasks(campaignID int64, taskIDs []int64) error {
+	f.added = append(f.added, taskIDs)
+	return nil
+}
+
+func TestCampaignAccess(t *testing.T) {
+	start := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	orgID := int64(3)
+	campaignStore := &fakeCampaignStore{campaigns: map[int64]*store.Campaign{
+		4: {ID: 4, UserID: 1, Name: "Launch", StartsAt: start, EndsAt: start.AddDate(0, 1, 0)},
+		6: {ID: 6, UserID: 1, OrganizationID: &orgID, Name: "Team launch", StartsAt: start, EndsAt: start.AddDate(0, 1, 0)},
+	}}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {2: store.OrgRoleManager, 5: store.OrgRoleReviewer},
+	}}
+	ch := NewCampaignHandler(campaignStore, &fakeTaskStore{}, orgStore, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	serve := func(method, path string, user *store.User, body string) int {
+		r := chi.NewRouter()
+		r.Get("/campaigns/{id}", func(w http.ResponseWriter, req *http.Request) {
+			ch.HandleGetCampaign(w, middleware.SetUser(req, user))
+		})
+		r.Post("/campaigns/{id}/tasks", func(w http.ResponseWriter, req *http.Request) {
+			ch.HandleAddCampaignTasks(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	moderator := &store.User{ID: 3, Role: store.UserRoleModerator}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   *store.User
+		body   string
+		want   int
+	}{
+		{"owner reads", http.MethodGet, "/campaigns/4", &store.User{ID: 1}, "", http.StatusOK},
+		{"moderator reads", http.MethodGet, "/campaigns/4", moderator, "", http.StatusOK},
+		{"other user can not read", http.MethodGet, "/campaigns/4", &store.User{ID: 2}, "", http.StatusForbidden},
+		{"missing campaign", http.MethodGet, "/campaigns/5", &store.User{ID: 1}, "", http.StatusNotFound},
+		{"owner adds tasks", http.MethodPost, "/campaigns/4/tasks", &store.User{ID: 1}, `{"task_ids": [7, 8]}`, http.StatusOK},
+		{"moderator can not add tasks", http.MethodPost, "/campaigns/4/tasks", moderator, `{"task_ids": [7]}`, http.StatusForbidden},
+		{"empty task list", http.MethodPost, "/campaigns/4/tasks", &store.User{ID: 1}, `{"task_ids": []}`, http.StatusBadRequest},
+		{"duplicate task", http.MethodPost, "/campaigns/4/tasks", &store.User{ID: 1}, `{"task_ids": [7, 7]}`, http.StatusBadRequest},
+		{"organization reviewer reads", http.MethodGet, "/campaigns/6", &store.User{ID: 5}, "", http.StatusOK},
+		{"creator who left the organization", http.MethodGet, "/campaigns/6", &store.User{ID: 1}, "", http.StatusForbidden},
+		{"organization manager adds tasks", http.MethodPost, "/campaigns/6/tasks", &store.User{ID: 2}, `{"task_ids": [9]}`, http.StatusOK},
+		{"organization reviewer can not add tasks", http.MethodPost, "/campaigns/6/tasks", &store.User{ID: 5}, `{"task_ids": [9]}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.method, tt.path, tt.user, tt.body))
+		})
+	}
+
+	assert.Equal(t, [][]int64{{7, 8}, {9}}, campaignStore.added)
+}
+
+func TestHandleUpdateCampaign(t *testing.T) {
+	start := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	campaignStore := &fakeCampaignStore{campaigns: map[int64]*store.Campaign{
+		4: {ID: 4, UserID: 1, Name: "Launch", StartsAt: start, EndsAt: start.AddDate(0, 1, 0)},
+	}}
+	ch := NewCampaignHandler(campaignStore, &fakeTaskStore{}, &fakeOrganizationStore{}, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	r := chi.NewRouter()
+	r.Put("/campaigns/{id}", func(w http.ResponseWriter, req *http.Request) {
+		ch.HandleUpdateCampaign(w, middleware.SetUser(req, &store.User{ID: 1}))
+	})
+
+	t.Run("the response shows the stored campaign", func(t *testing.T) {
+		body := `{"name": "Relaunch", "starts_at": "2025-11-01T00:00:00Z", "ends_at": "2025-12-01T00:00:00Z", "organization_id": 9}`
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/campaigns/4", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp struct {
+			Data struct {
+				Campaign store.Campaign `json:"campaign"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "Relaunch", resp.Data.Campaign.Name)
+		assert.Nil(t, resp.Data.Campaign.OrganizationID)
+	})
+}
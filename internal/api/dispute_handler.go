@@ -0,0 +1,293 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/achievements"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var validDisputeStatuses = map[store.DisputeStatus]bool{
+	store.DisputeAwaitingCreator:   true,
+	store.DisputeAwaitingModerator: true,
+	store.DisputeUpheld:            true,
+	store.DisputeOverturned:        true,
+}
+
+type respondDisputeRequest struct {
+	Message string `json:"message"`
+}
+
+type resolveDisputeRequest struct {
+	Decision   store.DisputeDecision `json:"decision"`
+	Resolution string                `json:"resolution"`
+}
+
+type DisputeHandler struct {
+	disputeStore store.DisputeStore
+	auditStore   store.AuditStore
+	orgStore     store.OrganizationStore
+	achievements *achievements.Engine
+	cursors      *utils.CursorCodec
+	logger       *log.Logger
+}
+
+func NewDisputeHandler(disputeStore store.DisputeStore, auditStore store.AuditStore, orgStore store.OrganizationStore, achievements *achievements.Engine, cursors *utils.CursorCodec, logger *log.Logger) *DisputeHandler {
+	return &DisputeHandler{
+		disputeStore: disputeStore,
+		auditStore:   auditStore,
+		orgStore:     orgStore,
+		achievements: achievements,
+		cursors:      cursors,
+		logger:       logger,
+	}
+}
+
+// writeDisputeError maps the store's dispute errors to responses.
+func (dh *DisputeHandler) writeDisputeError(w http.ResponseWriter, err error, op string) {
+	switch err {
+	case sql.ErrNoRows:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+	case store.ErrEvidenceAssetMissing:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+	case store.ErrNotRejected, store.ErrAppealWindowClosed, store.ErrAwaitingCreator:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageDisputeFailed, http.StatusUnprocessableEntity, nil, []string{err.Error()})
+	case store.ErrAlreadyDisputed, store.ErrDisputeClosed, store.ErrDisputeResponded, store.ErrNotReviewable:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageDisputeFailed, http.StatusConflict, nil, []string{err.Error()})
+	default:
+		dh.logger.Printf("ERROR: %s: %v", op, err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+	}
+}
+
+// HandleOpenDispute appeals the rejection of the caller's participation.
+func (dh *DisputeHandler) HandleOpenDispute(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	participationID, err := utils.ReadIDParam(r)
+	if err != nil {
+		dh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var appeal store.Appeal
+	err = json.NewDecoder(r.Body).Decode(&appeal)
+	if err != nil {
+		dh.logger.Printf("ERROR: decodingOpenDispute: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = appeal.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	dispute, err := dh.disputeStore.OpenDispute(participationID, user.ID, appeal)
+	if err != nil {
+		dh.writeDisputeError(w, err, "openDispute")
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDisputeOpened, http.StatusCreated, utils.Envelope{"dispute": dispute}, nil)
+}
+
+// HandleGetDisputes lists disputes for moderators, oldest first. overdue=true
+// narrows it to open disputes past their decision deadline.
+func (dh *DisputeHandler) HandleGetDisputes(w http.ResponseWriter, r *http.Request) {
+	status := store.DisputeStatus(r.URL.Query().Get("status"))
+	if status != "" && !validDisputeStatuses[status] {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"status must be one of awaiting_creator, awaiting_moderator, upheld or overturned"})
+		return
+	}
+
+	overdue := false
+	if raw := r.URL.Query().Get("overdue"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"overdue must be true or false"})
+			return
+		}
+		overdue = parsed
+	}
+
+	page, err := dh.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	disputes, bounds, err := dh.disputeStore.GetDisputes(status, overdue, page)
+	if err != nil {
+		dh.logger.Printf("ERROR: getDisputes: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDisputesFetched, http.StatusOK, dh.cursors.PageEnvelope(utils.Envelope{"disputes": disputes}, page, bounds), nil)
+}
+
+// loadDispute reads the dispute in the URL and checks the caller is one of
+// its parties, has a role on its task or is a moderator. It returns the
+// caller's role on the task alongside. It writes the error response itself
+// and returns nil when the request can not proceed.
+func (dh *DisputeHandler) loadDispute(w http.ResponseWriter, r *http.Request) (*store.Dispute, store.OrgRole) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		dh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil, ""
+	}
+
+	dispute, err := dh.disputeStore.GetDisputeByID(id)
+	if err != nil {
+		dh.logger.Printf("ERROR: getDisputeByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil, ""
+	}
+	if dispute == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil, ""
+	}
+
+	role, err := dh.orgStore.GetTaskRole(dispute.TaskID, user.ID)
+	if err != nil {
+		dh.logger.Printf("ERROR: getTaskRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil, ""
+	}
+	if dispute.UserID != user.ID && role == "" && !user.IsModerator() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil, ""
+	}
+
+	return dispute, role
+}
+
+func (dh *DisputeHandler) HandleGetDispute(w http.ResponseWriter, r *http.Request) {
+	dispute, _ := dh.loadDispute(w, r)
+	if dispute == nil {
+		return
+	}
+
+	page, err := dh.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	history, bounds, err := dh.auditStore.GetAuditEntries(store.AuditEntityDispute, dispute.ID, page)
+	if err != nil {
+		dh.logger.Printf("ERROR: getAuditEntries: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	// the cursors page through the history
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDisputeRetrieved, http.StatusOK, dh.cursors.PageEnvelope(utils.Envelope{
+		"dispute": dispute,
+		"history": history,
+	}, page, bounds), nil)
+}
+
+// HandleRespondToDispute records the task creator's side of the dispute. In
+// an organization, any of its reviewers may answer for it.
+func (dh *DisputeHandler) HandleRespondToDispute(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	dispute, role := dh.loadDispute(w, r)
+	if dispute == nil {
+		return
+	}
+	if dispute.UserID == user.ID || !role.Can(store.OrgRoleReviewer) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+
+	var req respondDisputeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		dh.logger.Printf("ERROR: decodingRespondDispute: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = store.ValidateDisputeMessage(req.Message)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	responded, err := dh.disputeStore.RespondToDispute(dispute.ID, user.ID, req.Message)
+	if err != nil {
+		dh.writeDisputeError(w, err, "respondToDispute")
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDisputeResponded, http.StatusOK, utils.Envelope{"dispute": responded}, nil)
+}
+
+// HandleResolveDispute is a moderator's final decision. Moderators who are a
+// party to the dispute, or have any role on its task, can not decide it.
+func (dh *DisputeHandler) HandleResolveDispute(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	dispute, role := dh.loadDispute(w, r)
+	if dispute == nil {
+		return
+	}
+	if !user.IsModerator() || dispute.UserID == user.ID || role != "" {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+
+	var req resolveDisputeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		dh.logger.Printf("ERROR: decodingResolveDispute: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	if !req.Decision.IsValid() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"decision must be uphold or overturn"})
+		return
+	}
+	err = store.ValidateDisputeMessage(req.Resolution)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	resolved, reward, quests, err := dh.disputeStore.ResolveDispute(dispute.ID, user.ID, req.Decision, req.Resolution)
+	if err != nil {
+		dh.writeDisputeError(w, err, "resolveDispute")
+		return
+	}
+
+	var badges []store.Badge
+	if reward != nil {
+		// badges are a side effect, a failure here must not fail the decision
+		badges, err = dh.achievements.Handle(achievements.Event{Type: achievements.EventRewardGranted, UserID: reward.UserID})
+		if err != nil {
+			dh.logger.Printf("ERROR: evaluating achievements: %v", err)
+		}
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDisputeResolved, http.StatusOK, utils.Envelope{
+		"dispute":        resolved,
+		"reward":         reward,
+		"quests":         quests,
+		"badges_awarded": badges,
+	}, nil)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDisputeStore struct {
+	store.DisputeStore
+	disputes map[int64]*store.Dispute
+	resolved []int64
+}
+
+func (f *fakeDisputeStore) GetDisputeByID(id int64) (*store.Dispute, error) {
+	return f.disputes[id], nil
+}
+
+func (f *fakeDisputeStore) ResolveDispute(id, moderatorID int64, decision store.DisputeDecision, resolution string) (*store.Dispute, *store.Reward, []store.QuestProgress, error) {
+	f.resolved = append(f.resolved, moderatorID)
+	d := *f.disputes[id]
+	d.Status = store.DisputeUpheld
+	return &d, nil, nil, nil
+}
+
+func TestHandleResolveDispute(t *testing.T) {
+	disputeStore := &fakeDisputeStore{disputes: map[int64]*store.Dispute{
+		4: {ID: 4, TaskID: 7, UserID: 2, TaskOwnerID: 1, Status: store.DisputeAwaitingModerator},
+	}}
+	orgStore := &fakeOrganizationStore{taskRoles: map[int64]map[int64]store.OrgRole{
+		7: {1: store.OrgRoleOwner, 5: store.OrgRoleViewer},
+	}}
+	dh := NewDisputeHandler(disputeStore, nil, orgStore, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	resolve := func(user *store.User, body string) int {
+		r := chi.NewRouter()
+		r.Post("/disputes/{id}/resolve", func(w http.ResponseWriter, req *http.Request) {
+			dh.HandleResolveDispute(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/disputes/4/resolve", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	const uphold = `{"decision": "uphold", "resolution": "the proof does not show the post"}`
+	moderator := store.UserRoleModerator
+
+	tests := []struct {
+		name string
+		user *store.User
+		body string
+		want int
+	}{
+		{name: "creator can not decide", user: &store.User{ID: 1}, body: uphold, want: http.StatusForbidden},
+		{name: "moderator who owns the task", user: &store.User{ID: 1, Role: moderator}, body: uphold, want: http.StatusForbidden},
+		{name: "moderator in the task's organization", user: &store.User{ID: 5, Role: moderator}, body: uphold, want: http.StatusForbidden},
+		{name: "moderator who appealed", user: &store.User{ID: 2, Role: moderator}, body: uphold, want: http.StatusForbidden},
+		{name: "unknown decision", user: &store.User{ID: 9, Role: moderator}, body: `{"decision": "maybe", "resolution": "x"}`, want: http.StatusBadRequest},
+		{name: "missing resolution", user: &store.User{ID: 9, Role: moderator}, body: `{"decision": "uphold"}`, want: http.StatusBadRequest},
+		{name: "independent moderator", user: &store.User{ID: 9, Role: moderator}, body: uphold, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolve(tt.user, tt.body))
+		})
+	}
+
+	assert.Equal(t, []int64{9}, disputeStore.resolved)
+}
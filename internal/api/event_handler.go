@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/events"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+const (
+	// MaxEventBatch is the most events one request can send.
+	MaxEventBatch = 100
+	// maxSessionIDLength bounds the session IDs clients choose.
+	maxSessionIDLength = 128
+	// sessionHeader carries the anonymous session ID on other requests.
+	sessionHeader = "X-Session-ID"
+)
+
+// recordEventsRequest is a batch of events a client saw. Anonymous clients
+// identify their session with a random ID of their own, it is only stored
+// anonymized.
+type recordEventsRequest struct {
+	SessionID string        `json:"session_id"`
+	Events    []clientEvent `json:"events"`
+}
+
+type clientEvent struct {
+	Type   store.TaskEventType `json:"type"`
+	TaskID int64               `json:"task_id"`
+}
+
+func (req *recordEventsRequest) validate() []string {
+	var errs []string
+	if len(req.Events) == 0 || len(req.Events) > MaxEventBatch {
+		errs = append(errs, fmt.Sprintf("events must hold between 1 and %d events", MaxEventBatch))
+	}
+	if len(req.SessionID) > maxSessionIDLength {
+		errs = append(errs, fmt.Sprintf("session_id can be at most %d characters", maxSessionIDLength))
+	}
+	for i, e := range req.Events {
+		if !e.Type.IsClientEvent() {
+			errs = append(errs, fmt.Sprintf("events[%d].type must be impression or click", i))
+		}
+		if e.TaskID <= 0 {
+			errs = append(errs, fmt.Sprintf("events[%d].task_id must be a valid task id", i))
+		}
+	}
+	return errs
+}
+
+type EventHandler struct {
+	eventWriter *events.Writer
+	limiter     *events.Limiter
+	logger      *log.Logger
+}
+
+func NewEventHandler(eventWriter *events.Writer, limiter *events.Limiter, logger *log.Logger) *EventHandler {
+	return &EventHandler{
+		eventWriter: eventWriter,
+		limiter:     limiter,
+		logger:      logger,
+	}
+}
+
+// sessionID is the anonymous session a request without a signed in user
+// belongs to, if the client sent one.
+func sessionID(r *http.Request) string {
+	id := r.Header.Get(sessionHeader)
+	if len(id) > maxSessionIDLength {
+		return ""
+	}
+	return id
+}
+
+// limitKeys names the client a batch of events counts against: the user
+// when signed in, otherwise its address and, if it sent one, its session.
+func limitKeys(r *http.Request, userID int64, session string) []string {
+	if userID != 0 {
+		return []string{"user:" + strconv.FormatInt(userID, 10)}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	keys := []string{"ip:" + host}
+	if session != "" {
+		keys = append(keys, "session:"+session)
+	}
+	return keys
+}
+
+// HandleRecordEvents queues the impressions and clicks a client batched up.
+// Events are written in the background, so unknown tasks are only skipped
+// there and the response just reports what was queued. Events over the
+// client's limit are dropped.
+func (eh *EventHandler) HandleRecordEvents(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var req recordEventsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		eh.logger.Printf("ERROR: decodingRecordEvents: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	if errs := req.validate(); len(errs) > 0 {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	// beacons can not set headers, so the body may carry the session instead
+	session := req.SessionID
+	if session == "" {
+		session = sessionID(r)
+	}
+	var userID int64
+	if !user.IsAnonymous() {
+		userID = user.ID
+	}
+
+	allowed := eh.limiter.Take(len(req.Events), limitKeys(r, userID, session)...)
+	if allowed == 0 {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageTooManyRequests, http.StatusTooManyRequests, nil, []string{"too many events, try again later"})
+		return
+	}
+
+	batch := make([]store.TaskEvent, allowed)
+	for i, e := range req.Events[:allowed] {
+		batch[i] = store.TaskEvent{TaskID: e.TaskID, UserID: userID, SessionID: session, Type: e.Type}
+	}
+	accepted := eh.eventWriter.Record(batch...)
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageEventsRecorded, http.StatusAccepted, utils.Envelope{"accepted": accepted}, nil)
+}
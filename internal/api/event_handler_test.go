@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/events"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRecordEvents(t *testing.T) {
+	analyticsStore := &fakeAnalyticsStore{}
+	eventWriter := events.NewWriter(analyticsStore, "test", 4, time.Hour, time.Now, log.New(io.Discard, "", 0))
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	limiter := events.NewLimiter(6, time.Minute, func() time.Time { return now })
+	eh := NewEventHandler(eventWriter, limiter, log.New(io.Discard, "", 0))
+
+	serveFrom := func(addr, body string, user *store.User, header string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.RemoteAddr = addr
+		if header != "" {
+			req.Header.Set("X-Session-ID", header)
+		}
+		rec := httptest.NewRecorder()
+		eh.HandleRecordEvents(rec, middleware.SetUser(req, user))
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec, resp
+	}
+	// each case starts a fresh limit window
+	serve := func(body string, user *store.User, header string) (*httptest.ResponseRecorder, map[string]any) {
+		now = now.Add(time.Minute)
+		return serveFrom("192.0.2.1:4000", body, user, header)
+	}
+
+	t.Run("anonymous events carry the session", func(t *testing.T) {
+		rec, resp := serve(`{"session_id": "tab-1", "events": [{"type": "impression", "task_id": 7}, {"type": "click", "task_id": 7}]}`, store.AnonymousUser, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, float64(2), resp["data"].(map[string]any)["accepted"])
+
+		eventWriter.Flush()
+		require.Len(t, analyticsStore.events, 2)
+		assert.Equal(t, store.TaskEvent{TaskID: 7, SessionID: eventWriter.Anonymize("tab-1"), Type: store.TaskEventClick}, withoutTime(analyticsStore.events[1]))
+	})
+
+	t.Run("the header is used without a session in the body", func(t *testing.T) {
+		analyticsStore.events = nil
+		rec, _ := serve(`{"events": [{"type": "click", "task_id": 3}]}`, store.AnonymousUser, "tab-2")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		eventWriter.Flush()
+		require.Len(t, analyticsStore.events, 1)
+		assert.Equal(t, eventWriter.Anonymize("tab-2"), analyticsStore.events[0].SessionID)
+	})
+
+	t.Run("signed in events carry the user", func(t *testing.T) {
+		analyticsStore.events = nil
+		rec, _ := serve(`{"session_id": "tab-1", "events": [{"type": "impression", "task_id": 3}]}`, &store.User{ID: 5}, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		eventWriter.Flush()
+		require.Len(t, analyticsStore.events, 1)
+		assert.Equal(t, store.TaskEvent{TaskID: 3, UserID: 5, Type: store.TaskEventImpression}, withoutTime(analyticsStore.events[0]))
+	})
+
+	t.Run("a full buffer only accepts what fits", func(t *testing.T) {
+		rec, resp := serve(`{"events": [{"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}]}`, store.AnonymousUser, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, float64(4), resp["data"].(map[string]any)["accepted"])
+		eventWriter.Flush()
+	})
+
+	t.Run("clients over their limit are turned away", func(t *testing.T) {
+		analyticsStore.events = nil
+		now = now.Add(time.Minute)
+		four := `{"session_id": "tab-3", "events": [{"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}, {"type": "click", "task_id": 1}]}`
+
+		rec, resp := serveFrom("192.0.2.1:4000", four, store.AnonymousUser, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, float64(4), resp["data"].(map[string]any)["accepted"])
+		eventWriter.Flush()
+
+		// a new session from the same address shares the address's limit
+		rec, resp = serveFrom("192.0.2.1:4001", strings.Replace(four, "tab-3", "tab-4", 1), store.AnonymousUser, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, float64(2), resp["data"].(map[string]any)["accepted"])
+		eventWriter.Flush()
+
+		rec, _ = serveFrom("192.0.2.1:4000", four, store.AnonymousUser, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		rec, _ = serveFrom("192.0.2.9:4000", four, &store.User{ID: 5}, "")
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		eventWriter.Flush()
+		assert.Len(t, analyticsStore.events, 10)
+	})
+
+	invalid := map[string]string{
+		"server only type": `{"events": [{"type": "verified", "task_id": 1}]}`,
+		"missing task":     `{"events": [{"type": "click"}]}`,
+		"empty batch":      `{"events": []}`,
+		"long session":     `{"session_id": "` + strings.Repeat("x", 129) + `", "events": [{"type": "click", "task_id": 1}]}`,
+		"too many events":  `{"events": [` + strings.TrimSuffix(strings.Repeat(`{"type": "click", "task_id": 1},`, MaxEventBatch+1), ",") + `]}`,
+	}
+	for name, body := range invalid {
+		t.Run(name, func(t *testing.T) {
+			rec, _ := serve(body, store.AnonymousUser, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
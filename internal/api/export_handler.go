@@ -0,0 +1,298 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/export"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// MaxStreamedExportRows is the largest export streamed right away, larger
+// ones could outlast the server's write timeout and have to be queued.
+const MaxStreamedExportRows = 10000
+
+type ExportHandler struct {
+	exportStore   store.ExportStore
+	taskStore     store.TaskStore
+	campaignStore store.CampaignStore
+	orgStore      store.OrganizationStore
+	blobs         blob.BlobStore
+	logger        *log.Logger
+}
+
+func NewExportHandler(exportStore store.ExportStore, taskStore store.TaskStore, campaignStore store.CampaignStore, orgStore store.OrganizationStore, blobs blob.BlobStore, logger *log.Logger) *ExportHandler {
+	return &ExportHandler{
+		exportStore:   exportStore,
+		taskStore:     taskStore,
+		campaignStore: campaignStore,
+		orgStore:      orgStore,
+		blobs:         blobs,
+		logger:        logger,
+	}
+}
+
+// readExportFormat reads the format query parameter, csv by default.
+func readExportFormat(value string) (store.ExportFormat, error) {
+	format := store.ExportFormat(strings.ToLower(value))
+	if format == "" {
+		return store.ExportCSV, nil
+	}
+	if !format.IsValid() {
+		return "", fmt.Errorf("format must be csv or xlsx")
+	}
+	return format, nil
+}
+
+// loadTaskScope checks the caller has a role on the task in the URL, like
+// for its participation list. It writes the error response itself and
+// returns false when the request can not proceed.
+func (eh *ExportHandler) loadTaskScope(w http.ResponseWriter, r *http.Request) (store.ExportScope, bool) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	task, err := eh.taskStore.GetTaskByID(id)
+	if err != nil {
+		eh.logger.Printf("ERROR: getTaskByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return store.ExportScope{}, false
+	}
+	if task == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	role, err := resourceRole(eh.orgStore, user, task.UserID, task.OrganizationID)
+	if err != nil {
+		eh.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return store.ExportScope{}, false
+	}
+	if role == "" {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	return store.ExportScope{TaskID: id}, true
+}
+
+// loadCampaignScope checks the caller can read the campaign in the URL, like
+// for its participant list. It writes the error response itself and
+// returns false when the request can not proceed.
+func (eh *ExportHandler) loadCampaignScope(w http.ResponseWriter, r *http.Request) (store.ExportScope, bool) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	campaign, err := eh.campaignStore.GetCampaignByID(id)
+	if err != nil {
+		eh.logger.Printf("ERROR: getCampaignByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return store.ExportScope{}, false
+	}
+	if campaign == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	role, err := resourceRole(eh.orgStore, user, campaign.UserID, campaign.OrganizationID)
+	if err != nil {
+		eh.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return store.ExportScope{}, false
+	}
+	if !role.Can(store.OrgRoleViewer) && !user.IsModerator() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return store.ExportScope{}, false
+	}
+
+	return store.ExportScope{CampaignID: id}, true
+}
+
+func (eh *ExportHandler) HandleExportTaskParticipants(w http.ResponseWriter, r *http.Request) {
+	if scope, ok := eh.loadTaskScope(w, r); ok {
+		eh.streamExport(w, r, scope)
+	}
+}
+
+func (eh *ExportHandler) HandleExportCampaignParticipants(w http.ResponseWriter, r *http.Request) {
+	if scope, ok := eh.loadCampaignScope(w, r); ok {
+		eh.streamExport(w, r, scope)
+	}
+}
+
+func (eh *ExportHandler) HandleQueueTaskExport(w http.ResponseWriter, r *http.Request) {
+	if scope, ok := eh.loadTaskScope(w, r); ok {
+		eh.queueExport(w, r, scope)
+	}
+}
+
+func (eh *ExportHandler) HandleQueueCampaignExport(w http.ResponseWriter, r *http.Request) {
+	if scope, ok := eh.loadCampaignScope(w, r); ok {
+		eh.queueExport(w, r, scope)
+	}
+}
+
+// streamExport writes the export as the response while it is read from the
+// database. Once the first row is sent the status can not change anymore,
+// so a failure halfway only shows as a cut off file.
+func (eh *ExportHandler) streamExport(w http.ResponseWriter, r *http.Request, scope store.ExportScope) {
+	format, err := readExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	count, err := eh.exportStore.CountParticipants(scope)
+	if err != nil {
+		eh.logger.Printf("ERROR: countParticipants: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if count > MaxStreamedExportRows {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{
+			fmt.Sprintf("exports of more than %d rows run in the background, POST to this URL to queue one", MaxStreamedExportRows),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(scope, format)))
+
+	_, err = export.Participants(w, format, eh.exportStore, scope)
+	if err != nil {
+		eh.logger.Printf("ERROR: exportParticipants: %v", err)
+	}
+}
+
+// queueExport queues an export of any size for the background runner, its
+// status and download link are read with HandleGetExport.
+func (eh *ExportHandler) queueExport(w http.ResponseWriter, r *http.Request, scope store.ExportScope) {
+	user, _ := middleware.GetUser(r)
+
+	var req struct {
+		Format string `json:"format"`
+	}
+	// the body is optional
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		eh.logger.Printf("ERROR: decodingQueueExport: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	format, err := readExportFormat(req.Format)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	job := &store.ExportJob{UserID: user.ID, Format: format}
+	if scope.CampaignID != 0 {
+		job.CampaignID = &scope.CampaignID
+	} else {
+		job.TaskID = &scope.TaskID
+	}
+
+	job, err = eh.exportStore.CreateExportJob(job)
+	if err != nil {
+		eh.logger.Printf("ERROR: createExportJob: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageExportQueued, http.StatusAccepted, exportEnvelope(job), nil)
+}
+
+// exportEnvelope is the job with its download link once it is ready.
+func exportEnvelope(job *store.ExportJob) utils.Envelope {
+	var downloadURL *string
+	if job.Status == store.ExportCompleted {
+		url := fmt.Sprintf("/exports/%d/download", job.ID)
+		downloadURL = &url
+	}
+	return utils.Envelope{"export": job, "download_url": downloadURL}
+}
+
+// loadExport reads the export in the URL, only its creator can see it. It
+// writes the error response itself and returns nil when the request can not
+// proceed.
+func (eh *ExportHandler) loadExport(w http.ResponseWriter, r *http.Request) *store.ExportJob {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	job, err := eh.exportStore.GetExportJob(id)
+	if err != nil {
+		eh.logger.Printf("ERROR: getExportJob: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if job == nil || job.UserID != user.ID {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	return job
+}
+
+func (eh *ExportHandler) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	job := eh.loadExport(w, r)
+	if job == nil {
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageExportRetrieved, http.StatusOK, exportEnvelope(job), nil)
+}
+
+func (eh *ExportHandler) HandleDownloadExport(w http.ResponseWriter, r *http.Request) {
+	job := eh.loadExport(w, r)
+	if job == nil {
+		return
+	}
+	if job.Status != store.ExportCompleted {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageExportNotReady, http.StatusConflict, utils.Envelope{"export": job}, nil)
+		return
+	}
+
+	body, contentType, err := eh.blobs.Get(r.Context(), job.BlobKey)
+	if err == blob.ErrNotFound {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	if err != nil {
+		eh.logger.Printf("ERROR: getBlob: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(job.Scope(), job.Format)))
+	if _, err := io.Copy(w, body); err != nil {
+		eh.logger.Printf("ERROR: downloadExport: %v", err)
+	}
+}
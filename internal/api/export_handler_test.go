@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExportStore struct {
+	store.ExportStore
+	count int64
+	jobs  map[int64]*store.ExportJob
+}
+
+func (f *fakeExportStore) CountParticipants(scope store.ExportScope) (int64, error) {
+	return f.count, nil
+}
+
+func (f *fakeExportStore) EachParticipant(scope store.ExportScope, fn func(*store.ExportParticipant) error) error {
+	for i := int64(1); i <= f.count; i++ {
+		err := fn(&store.ExportParticipant{ParticipationID: i, TaskID: scope.TaskID, Username: "user", XHandle: "user_x", Status: store.ParticipationJoined})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeExportStore) CreateExportJob(job *store.ExportJob) (*store.ExportJob, error) {
+	job.ID = int64(len(f.jobs) + 1)
+	job.Status = store.ExportPending
+	f.jobs[job.ID] = job
+	return job, nil
+}
+
+func (f *fakeExportStore) GetExportJob(id int64) (*store.ExportJob, error) {
+	return f.jobs[id], nil
+}
+
+func TestExportHandler(t *testing.T) {
+	orgID := int64(4)
+	taskStore := &fakeTaskStore{tasks: map[int64]*store.Task{
+		7: {ID: 7, UserID: 1},
+		9: {ID: 9, UserID: 1, OrganizationID: &orgID},
+	}}
+	campaignStore := &fakeCampaignStore{campaigns: map[int64]*store.Campaign{
+		3: {ID: 3, UserID: 1},
+	}}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {2: store.OrgRoleViewer},
+	}}
+	exportStore := &fakeExportStore{count: 2, jobs: map[int64]*store.ExportJob{}}
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	eh := NewExportHandler(exportStore, taskStore, campaignStore, orgStore, blobs, log.New(io.Discard, "", 0))
+
+	r := chi.NewRouter()
+	r.Get("/tasks/{id}/export", eh.HandleExportTaskParticipants)
+	r.Post("/tasks/{id}/export", eh.HandleQueueTaskExport)
+	r.Get("/campaigns/{id}/export", eh.HandleExportCampaignParticipants)
+	r.Get("/exports/{id}", eh.HandleGetExport)
+	r.Get("/exports/{id}/download", eh.HandleDownloadExport)
+
+	serve := func(method, path, body string, user *store.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, middleware.SetUser(req, user))
+		return rec
+	}
+
+	t.Run("streams a csv to the creator", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/tasks/7/export", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="task-7-participants.csv"`, rec.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, "x_handle", records[0][5])
+		assert.Equal(t, "user_x", records[1][5])
+	})
+
+	t.Run("streams xlsx", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/campaigns/3/export?format=xlsx", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename="campaign-3-participants.xlsx"`, rec.Header().Get("Content-Disposition"))
+		assert.True(t, strings.HasPrefix(rec.Body.String(), "PK"))
+	})
+
+	t.Run("access follows the participation list", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/tasks/7/export", "", &store.User{ID: 2}).Code)
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/tasks/9/export", "", &store.User{ID: 2}).Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/campaigns/3/export", "", &store.User{ID: 2}).Code)
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/campaigns/3/export", "", &store.User{ID: 5, Role: store.UserRoleModerator}).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/tasks/8/export", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/tasks/7/export?format=pdf", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("large exports have to be queued", func(t *testing.T) {
+		exportStore.count = MaxStreamedExportRows + 1
+		defer func() { exportStore.count = 2 }()
+
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/tasks/7/export", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("queued exports are downloaded once completed", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/tasks/7/export", `{"format": "xlsx"}`, &store.User{ID: 1})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		data := resp["data"].(map[string]any)
+		assert.Equal(t, "pending", data["export"].(map[string]any)["status"])
+		assert.Nil(t, data["download_url"])
+
+		job := exportStore.jobs[1]
+		assert.Equal(t, int64(7), *job.TaskID)
+		assert.Equal(t, store.ExportXLSX, job.Format)
+
+		assert.Equal(t, http.StatusConflict, serve(http.MethodGet, "/exports/1/download", "", &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/exports/1", "", &store.User{ID: 2}).Code)
+
+		require.NoError(t, blobs.Put(context.Background(), "exports/1/1-task-7-participants.xlsx", strings.NewReader("PK"), 2, "application/zip"))
+		job.Status = store.ExportCompleted
+		job.BlobKey = "exports/1/1-task-7-participants.xlsx"
+
+		rec = serve(http.MethodGet, "/exports/1", "", &store.User{ID: 1})
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "/exports/1/download", resp["data"].(map[string]any)["download_url"])
+
+		rec = serve(http.MethodGet, "/exports/1/download", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "PK", rec.Body.String())
+		assert.Equal(t, `attachment; filename="task-7-participants.xlsx"`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("the body of a queued export is optional", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/tasks/7/export", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, store.ExportCSV, exportStore.jobs[2].Format)
+	})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/feed"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+)
+
+type FeedHandler struct {
+	feedStore store.FeedStore
+	weights   feed.Weights
+	now       store.Clock
+	logger    *log.Logger
+}
+
+func NewFeedHandler(feedStore store.FeedStore, weights feed.Weights, clock store.Clock, logger *log.Logger) *FeedHandler {
+	return &FeedHandler{
+		feedStore: feedStore,
+		weights:   weights,
+		now:       clock,
+		logger:    logger,
+	}
+}
+
+func (fh *FeedHandler) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	limit := defaultFeedLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > maxFeedLimit {
+			err = errors.New("limit must be between 1 and 100")
+			utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+			return
+		}
+		limit = l
+	}
+
+	candidates, err := fh.feedStore.GetFeedCandidates(user.ID)
+	if err != nil {
+		fh.logger.Printf("ERROR: getFeedCandidates: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	profile, err := fh.feedStore.GetFeedProfile(user.ID)
+	if err != nil {
+		fh.logger.Printf("ERROR: getFeedProfile: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	items := feed.Rank(candidates, profile, fh.weights, fh.now(), limit)
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageFeedFetched, http.StatusOK, utils.Envelope{"feed": items}, nil)
+}
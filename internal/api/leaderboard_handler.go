@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+var validLeaderboardWindows = map[store.LeaderboardWindow]bool{
+	store.LeaderboardDaily:   true,
+	store.LeaderboardWeekly:  true,
+	store.LeaderboardAllTime: true,
+}
+
+var validLeaderboardSorts = map[store.LeaderboardSort]bool{
+	store.LeaderboardByPoints:   true,
+	store.LeaderboardByEarnings: true,
+}
+
+type LeaderboardHandler struct {
+	leaderboardStore store.LeaderboardStore
+	logger           *log.Logger
+}
+
+func NewLeaderboardHandler(leaderboardStore store.LeaderboardStore, logger *log.Logger) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		leaderboardStore: leaderboardStore,
+		logger:           logger,
+	}
+}
+
+func (lh *LeaderboardHandler) readLeaderboardQuery(r *http.Request) (store.LeaderboardQuery, error) {
+	q := store.LeaderboardQuery{
+		Window: store.LeaderboardAllTime,
+		SortBy: store.LeaderboardByPoints,
+		Limit:  defaultLeaderboardLimit,
+	}
+
+	if window := r.URL.Query().Get("window"); window != "" {
+		q.Window = store.LeaderboardWindow(window)
+		if !validLeaderboardWindows[q.Window] {
+			return q, errors.New("window must be one of daily, weekly or all_time")
+		}
+	}
+
+	if by := r.URL.Query().Get("by"); by != "" {
+		q.SortBy = store.LeaderboardSort(by)
+		if !validLeaderboardSorts[q.SortBy] {
+			return q, errors.New("by must be one of points or earnings")
+		}
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || l < 1 || l > maxLeaderboardLimit {
+			return q, errors.New("limit must be between 1 and 100")
+		}
+		q.Limit = l
+	}
+
+	return q, nil
+}
+
+func (lh *LeaderboardHandler) writeLeaderboard(w http.ResponseWriter, r *http.Request, q store.LeaderboardQuery) {
+	entries, err := lh.leaderboardStore.GetLeaderboard(q)
+	if err != nil {
+		lh.logger.Printf("ERROR: getLeaderboard: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	// the caller's own rank is only known for signed in users
+	var me *store.LeaderboardEntry
+	user, _ := middleware.GetUser(r)
+	if !user.IsAnonymous() {
+		me, err = lh.leaderboardStore.GetUserRank(q, user.ID)
+		if err != nil {
+			lh.logger.Printf("ERROR: getUserRank: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageLeaderboardFetched, http.StatusOK, utils.Envelope{
+		"leaderboard": entries,
+		"me":          me,
+		"meta": map[string]any{
+			"window": q.Window,
+			"by":     q.SortBy,
+			"limit":  q.Limit,
+		},
+	}, nil)
+}
+
+func (lh *LeaderboardHandler) HandleGetGlobalLeaderboard(w http.ResponseWriter, r *http.Request) {
+	q, err := lh.readLeaderboardQuery(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	q.Scope = store.LeaderboardScopeGlobal
+	lh.writeLeaderboard(w, r, q)
+}
+
+func (lh *LeaderboardHandler) HandleGetTaskLeaderboard(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		lh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	q, err := lh.readLeaderboardQuery(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	q.Scope = store.LeaderboardScopeTask
+	q.ScopeID = id
+	lh.writeLeaderboard(w, r, q)
+}
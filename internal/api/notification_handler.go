@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type NotificationHandler struct {
+	notificationStore store.NotificationStore
+	cursors           *utils.CursorCodec
+	logger            *log.Logger
+}
+
+func NewNotificationHandler(notificationStore store.NotificationStore, cursors *utils.CursorCodec, logger *log.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		notificationStore: notificationStore,
+		cursors:           cursors,
+		logger:            logger,
+	}
+}
+
+// readNotificationCategory reads the optional category query parameter.
+func readNotificationCategory(r *http.Request) (store.NotificationCategory, error) {
+	category := store.NotificationCategory(r.URL.Query().Get("category"))
+	if category != "" && !category.IsValid() {
+		return "", fmt.Errorf("category must be one of submissions, rewards or reminders")
+	}
+	return category, nil
+}
+
+// HandleGetNotifications lists the caller's notifications, newest first,
+// along with their unread counts. unread=true leaves out the read ones.
+func (nh *NotificationHandler) HandleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	category, err := readNotificationCategory(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	filter := store.NotificationFilter{Category: category}
+	if raw := r.URL.Query().Get("unread"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"unread must be true or false"})
+			return
+		}
+		filter.UnreadOnly = parsed
+	}
+
+	page, err := nh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	notifications, bounds, err := nh.notificationStore.GetNotifications(user.ID, filter, page)
+	if err != nil {
+		nh.logger.Printf("ERROR: getNotifications: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	unread, err := nh.notificationStore.GetUnreadCounts(user.ID)
+	if err != nil {
+		nh.logger.Printf("ERROR: getUnreadCounts: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	env := utils.Envelope{"notifications": notifications, "unread": unread}
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageNotificationsFetched, http.StatusOK, nh.cursors.PageEnvelope(env, page, bounds), nil)
+}
+
+// HandleMarkNotificationRead marks one of the caller's notifications as read.
+func (nh *NotificationHandler) HandleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		nh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	notification, err := nh.notificationStore.MarkNotificationRead(id, user.ID)
+	if err != nil {
+		nh.logger.Printf("ERROR: markNotificationRead: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if notification == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageNotificationRead, http.StatusOK, utils.Envelope{"notification": notification}, nil)
+}
+
+// HandleMarkAllNotificationsRead marks the caller's unread notifications as
+// read, only those of the category when one is given.
+func (nh *NotificationHandler) HandleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	category, err := readNotificationCategory(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	marked, err := nh.notificationStore.MarkAllNotificationsRead(user.ID, category)
+	if err != nil {
+		nh.logger.Printf("ERROR: markAllNotificationsRead: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageNotificationsRead, http.StatusOK, utils.Envelope{"marked": marked}, nil)
+}
+
+// HandleGetNotificationPreferences returns whether the caller is notified of
+// each category.
+func (nh *NotificationHandler) HandleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	prefs, err := nh.notificationStore.GetNotificationPreferences(user.ID)
+	if err != nil {
+		nh.logger.Printf("ERROR: getNotificationPreferences: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessagePreferencesRetrieved, http.StatusOK, utils.Envelope{"preferences": prefs}, nil)
+}
+
+// HandleUpdateNotificationPreferences turns categories on or off, the
+// categories left out of the body keep their setting.
+func (nh *NotificationHandler) HandleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var prefs store.NotificationPreferences
+	err := json.NewDecoder(r.Body).Decode(&prefs)
+	if err != nil {
+		nh.logger.Printf("ERROR: decodingNotificationPreferences: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var errs []string
+	for category := range prefs {
+		if !category.IsValid() {
+			errs = append(errs, fmt.Sprintf("unknown category %q", category))
+		}
+	}
+	if len(errs) > 0 {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	err = nh.notificationStore.SetNotificationPreferences(user.ID, prefs)
+	if err != nil {
+		nh.logger.Printf("ERROR: setNotificationPreferences: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	updated, err := nh.notificationStore.GetNotificationPreferences(user.ID)
+	if err != nil {
+		nh.logger.Printf("ERROR: getNotificationPreferences: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessagePreferencesUpdated, http.StatusOK, utils.Envelope{"preferences": updated}, nil)
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNotificationStore struct {
+	store.NotificationStore
+	notifications []*store.Notification
+	prefs         map[int64]store.NotificationPreferences
+}
+
+func (f *fakeNotificationStore) GetNotifications(userID int64, filter store.NotificationFilter, page utils.PageParams) ([]store.Notification, utils.PageBounds, error) {
+	var notifications []store.Notification
+	for _, n := range f.notifications {
+		if n.UserID != userID || (filter.Category != "" && n.Category != filter.Category) || (filter.UnreadOnly && n.ReadAt != nil) {
+			continue
+		}
+		notifications = append(notifications, *n)
+	}
+	return notifications, utils.PageBounds{}, nil
+}
+
+func (f *fakeNotificationStore) GetUnreadCounts(userID int64) (*store.UnreadCounts, error) {
+	counts := &store.UnreadCounts{ByCategory: map[store.NotificationCategory]int64{}}
+	for _, n := range f.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			counts.ByCategory[n.Category]++
+			counts.Total++
+		}
+	}
+	return counts, nil
+}
+
+func (f *fakeNotificationStore) MarkNotificationRead(id, userID int64) (*store.Notification, error) {
+	for _, n := range f.notifications {
+		if n.ID == id && n.UserID == userID {
+			now := time.Now()
+			n.ReadAt = &now
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeNotificationStore) MarkAllNotificationsRead(userID int64, category store.NotificationCategory) (int64, error) {
+	var marked int64
+	for _, n := range f.notifications {
+		if n.UserID == userID && n.ReadAt == nil && (category == "" || n.Category == category) {
+			now := time.Now()
+			n.ReadAt = &now
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (f *fakeNotificationStore) GetNotificationPreferences(userID int64) (store.NotificationPreferences, error) {
+	prefs := store.NotificationPreferences{}
+	for _, category := range store.NotificationCategories {
+		prefs[category] = true
+	}
+	for category, enabled := range f.prefs[userID] {
+		prefs[category] = enabled
+	}
+	return prefs, nil
+}
+
+func (f *fakeNotificationStore) SetNotificationPreferences(userID int64, prefs store.NotificationPreferences) error {
+	if f.prefs[userID] == nil {
+		f.prefs[userID] = store.NotificationPreferences{}
+	}
+	for category, enabled := range prefs {
+		f.prefs[userID][category] = enabled
+	}
+	return nil
+}
+
+func TestNotificationHandler(t *testing.T) {
+	notificationStore := &fakeNotificationStore{
+		notifications: []*store.Notification{
+			{ID: 1, UserID: 1, Category: store.NotificationSubmissions, Type: store.NotificationSubmissionApproved, Title: "Submission approved"},
+			{ID: 2, UserID: 1, Category: store.NotificationRewards, Type: store.NotificationRewardReceived, Title: "Reward received"},
+			{ID: 3, UserID: 1, Category: store.NotificationRewards, Type: store.NotificationRewardReceived, Title: "Reward received"},
+			{ID: 4, UserID: 2, Category: store.NotificationReminders, Type: store.NotificationTaskExpiring, Title: "Task ending soon"},
+		},
+		prefs: map[int64]store.NotificationPreferences{},
+	}
+	nh := NewNotificationHandler(notificationStore, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	r := chi.NewRouter()
+	r.Get("/notifications", nh.HandleGetNotifications)
+	r.Post("/notifications/read-all", nh.HandleMarkAllNotificationsRead)
+	r.Get("/notifications/preferences", nh.HandleGetNotificationPreferences)
+	r.Put("/notifications/preferences", nh.HandleUpdateNotificationPreferences)
+	r.Post("/notifications/{id}/read", nh.HandleMarkNotificationRead)
+
+	serve := func(method, path, body string, user *store.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, middleware.SetUser(req, user))
+		return rec
+	}
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp.Data
+	}
+
+	t.Run("lists the caller's notifications with unread counts", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/notifications", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		data := decode(t, rec)
+		assert.Len(t, data["notifications"], 3)
+		unread := data["unread"].(map[string]any)
+		assert.Equal(t, float64(3), unread["total"])
+		assert.Equal(t, float64(2), unread["by_category"].(map[string]any)["rewards"])
+
+		rec = serve(http.MethodGet, "/notifications?category=rewards", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, decode(t, rec)["notifications"], 2)
+	})
+
+	t.Run("rejects invalid filters", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/notifications?category=news", "", &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/notifications?unread=maybe", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("marks one notification as read", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/notifications/1/read", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotNil(t, decode(t, rec)["notification"].(map[string]any)["read_at"])
+
+		rec = serve(http.MethodGet, "/notifications?unread=true", "", &store.User{ID: 1})
+		assert.Len(t, decode(t, rec)["notifications"], 2)
+	})
+
+	t.Run("others' notifications are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/notifications/4/read", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("marks all notifications of a category as read", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/notifications/read-all?category=rewards", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, float64(2), decode(t, rec)["marked"])
+		assert.Nil(t, notificationStore.notifications[3].ReadAt)
+	})
+
+	t.Run("updates preferences partially", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/notifications/preferences", `{"reminders":false}`, &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		prefs := decode(t, rec)["preferences"].(map[string]any)
+		assert.Equal(t, false, prefs["reminders"])
+		assert.Equal(t, true, prefs["rewards"])
+
+		rec = serve(http.MethodGet, "/notifications/preferences", "", &store.User{ID: 2})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, true, decode(t, rec)["preferences"].(map[string]any)["reminders"])
+	})
+
+	t.Run("rejects unknown categories", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/notifications/preferences", `{"news":true}`, &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/notifications/preferences", `{"rewards":"yes"}`, &store.User{ID: 1}).Code)
+	})
+}
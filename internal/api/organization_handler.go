@@ -0,0 +1,510 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var invitationEmailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// resourceRole is the user's role on a task or campaign: owner of what they
+// created on their own, their member role on what an organization owns and
+// empty otherwise.
+func resourceRole(orgStore store.OrganizationStore, user *store.User, ownerID int64, orgID *int64) (store.OrgRole, error) {
+	if user.IsAnonymous() {
+		return "", nil
+	}
+	if orgID == nil {
+		if ownerID == user.ID {
+			return store.OrgRoleOwner, nil
+		}
+		return "", nil
+	}
+	return orgStore.GetMemberRole(*orgID, user.ID)
+}
+
+// readOrganizationParam reads the optional organization_id query parameter
+// of the list endpoints.
+func readOrganizationParam(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get("organization_id")
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("organization_id must be a valid organization id")
+	}
+	return id, nil
+}
+
+type setMemberRoleRequest struct {
+	Role store.OrgRole `json:"role"`
+}
+
+type createInvitationRequest struct {
+	Username string        `json:"username"`
+	Email    string        `json:"email"`
+	Role     store.OrgRole `json:"role"`
+}
+
+func (req *createInvitationRequest) validate() error {
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+
+	if (req.Username == "") == (req.Email == "") {
+		return errors.New("either username or email is required")
+	}
+	if req.Email != "" && (len(req.Email) > 255 || !invitationEmailPattern.MatchString(req.Email)) {
+		return errors.New("invalid email format")
+	}
+	if !req.Role.IsValid() {
+		return errors.New("role must be one of owner, manager, reviewer or viewer")
+	}
+	return nil
+}
+
+type OrganizationHandler struct {
+	orgStore store.OrganizationStore
+	cursors  *utils.CursorCodec
+	logger   *log.Logger
+}
+
+func NewOrganizationHandler(orgStore store.OrganizationStore, cursors *utils.CursorCodec, logger *log.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgStore: orgStore,
+		cursors:  cursors,
+		logger:   logger,
+	}
+}
+
+func (oh *OrganizationHandler) HandleCreateOrganization(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var org store.Organization
+	err := json.NewDecoder(r.Body).Decode(&org)
+	if err != nil {
+		oh.logger.Printf("ERROR: decodingCreateOrganization: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = org.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	created, err := oh.orgStore.CreateOrganization(&org, user.ID)
+	if err != nil {
+		oh.logger.Printf("ERROR: createOrganization: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageOrganizationCreated, http.StatusCreated, utils.Envelope{"organization": created}, nil)
+}
+
+// HandleGetOrganizations lists the organizations the caller is a member of.
+func (oh *OrganizationHandler) HandleGetOrganizations(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	page, err := oh.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	orgs, bounds, err := oh.orgStore.GetUserOrganizations(user.ID, page)
+	if err != nil {
+		oh.logger.Printf("ERROR: getUserOrganizations: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageOrganizationsFetched, http.StatusOK, oh.cursors.PageEnvelope(utils.Envelope{"organizations": orgs}, page, bounds), nil)
+}
+
+// loadOrganization reads the organization in the URL with the caller's role.
+// Organizations do not exist for anyone but their members, members whose
+// role does not allow need are forbidden. It writes the error response
+// itself and returns nil when the request can not proceed.
+func (oh *OrganizationHandler) loadOrganization(w http.ResponseWriter, r *http.Request, need store.OrgRole) *store.Organization {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		oh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	role, err := oh.orgStore.GetMemberRole(id, user.ID)
+	if err != nil {
+		oh.logger.Printf("ERROR: getMemberRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if role == "" {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+	if !role.Can(need) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+
+	org, err := oh.orgStore.GetOrganizationByID(id)
+	if err != nil {
+		oh.logger.Printf("ERROR: getOrganizationByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if org == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	org.Role = role
+	return org
+}
+
+func (oh *OrganizationHandler) HandleGetOrganization(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleViewer)
+	if org == nil {
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageOrganizationRetrieved, http.StatusOK, utils.Envelope{"organization": org}, nil)
+}
+
+// HandleUpdateOrganization renames the organization.
+func (oh *OrganizationHandler) HandleUpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	var req store.Organization
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		oh.logger.Printf("ERROR: decodingUpdateOrganization: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = req.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	org.Name = req.Name
+	err = oh.orgStore.UpdateOrganization(org)
+	if err != nil {
+		oh.logger.Printf("ERROR: updateOrganization: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageOrganizationUpdated, http.StatusOK, utils.Envelope{"organization": org}, nil)
+}
+
+func (oh *OrganizationHandler) HandleDeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	err := oh.orgStore.DeleteOrganization(org.ID)
+	if err != nil {
+		oh.logger.Printf("ERROR: deleteOrganization: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageOrganizationDeleted, http.StatusOK, nil, nil)
+}
+
+func (oh *OrganizationHandler) HandleGetMembers(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleViewer)
+	if org == nil {
+		return
+	}
+
+	page, err := oh.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	members, bounds, err := oh.orgStore.GetMembers(org.ID, page)
+	if err != nil {
+		oh.logger.Printf("ERROR: getMembers: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageMembersFetched, http.StatusOK, oh.cursors.PageEnvelope(utils.Envelope{"members": members}, page, bounds), nil)
+}
+
+// writeMemberError maps the store's membership errors to responses.
+func (oh *OrganizationHandler) writeMemberError(w http.ResponseWriter, err error, op string) {
+	switch err {
+	case sql.ErrNoRows:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+	case store.ErrLastOrgOwner:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageMemberUpdateFailed, http.StatusConflict, nil, []string{err.Error()})
+	default:
+		oh.logger.Printf("ERROR: %s: %v", op, err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+	}
+}
+
+// HandleSetMemberRole lets an owner change a member's role.
+func (oh *OrganizationHandler) HandleSetMemberRole(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	userID, err := utils.ReadNamedIDParam(r, "userId")
+	if err != nil {
+		oh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var req setMemberRoleRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		oh.logger.Printf("ERROR: decodingSetMemberRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+	if !req.Role.IsValid() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"role must be one of owner, manager, reviewer or viewer"})
+		return
+	}
+
+	err = oh.orgStore.SetMemberRole(org.ID, userID, req.Role)
+	if err != nil {
+		oh.writeMemberError(w, err, "setMemberRole")
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageMemberUpdated, http.StatusOK, utils.Envelope{"user_id": userID, "role": req.Role}, nil)
+}
+
+// HandleRemoveMember lets an owner remove a member, and any member leave.
+func (oh *OrganizationHandler) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	userID, err := utils.ReadNamedIDParam(r, "userId")
+	if err != nil {
+		oh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	need := store.OrgRoleOwner
+	if userID == user.ID {
+		need = store.OrgRoleViewer
+	}
+	org := oh.loadOrganization(w, r, need)
+	if org == nil {
+		return
+	}
+
+	err = oh.orgStore.RemoveMember(org.ID, userID)
+	if err != nil {
+		oh.writeMemberError(w, err, "removeMember")
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageMemberRemoved, http.StatusOK, nil, nil)
+}
+
+// HandleCreateInvitation invites a user by username or email address.
+func (oh *OrganizationHandler) HandleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	var req createInvitationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		oh.logger.Printf("ERROR: decodingCreateInvitation: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = req.validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	invitation, err := oh.orgStore.CreateInvitation(&store.OrgInvitation{
+		OrganizationID: org.ID,
+		Username:       req.Username,
+		Email:          req.Email,
+		Role:           req.Role,
+		InvitedBy:      &user.ID,
+	})
+	switch err {
+	case nil:
+	case store.ErrInviteeNotFound:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	case store.ErrAlreadyMember, store.ErrInvitationExists:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvitationFailed, http.StatusConflict, nil, []string{err.Error()})
+		return
+	default:
+		oh.logger.Printf("ERROR: createInvitation: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageInvitationCreated, http.StatusCreated, utils.Envelope{"invitation": invitation}, nil)
+}
+
+// HandleGetOrganizationInvitations lists the organization's open invitations.
+func (oh *OrganizationHandler) HandleGetOrganizationInvitations(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	page, err := oh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	invitations, bounds, err := oh.orgStore.GetOrganizationInvitations(org.ID, page)
+	if err != nil {
+		oh.logger.Printf("ERROR: getOrganizationInvitations: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageInvitationsFetched, http.StatusOK, oh.cursors.PageEnvelope(utils.Envelope{"invitations": invitations}, page, bounds), nil)
+}
+
+func (oh *OrganizationHandler) HandleRevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	org := oh.loadOrganization(w, r, store.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	id, err := utils.ReadNamedIDParam(r, "invitationId")
+	if err != nil {
+		oh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	invitation, err := oh.orgStore.GetInvitationByID(id)
+	if err != nil {
+		oh.logger.Printf("ERROR: getInvitationByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if invitation == nil || invitation.OrganizationID != org.ID {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	err = oh.orgStore.RevokeInvitation(id)
+	if err == store.ErrInvitationClosed {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvitationFailed, http.StatusConflict, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		oh.logger.Printf("ERROR: revokeInvitation: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageInvitationRevoked, http.StatusOK, nil, nil)
+}
+
+// HandleGetMyInvitations lists the open invitations addressed to the caller.
+func (oh *OrganizationHandler) HandleGetMyInvitations(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	page, err := oh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	invitations, bounds, err := oh.orgStore.GetUserInvitations(user.ID, page)
+	if err != nil {
+		oh.logger.Printf("ERROR: getUserInvitations: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageInvitationsFetched, http.StatusOK, oh.cursors.PageEnvelope(utils.Envelope{"invitations": invitations}, page, bounds), nil)
+}
+
+func (oh *OrganizationHandler) HandleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	oh.respondToInvitation(w, r, true)
+}
+
+func (oh *OrganizationHandler) HandleDeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	oh.respondToInvitation(w, r, false)
+}
+
+func (oh *OrganizationHandler) respondToInvitation(w http.ResponseWriter, r *http.Request, accept bool) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		oh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	invitation, err := oh.orgStore.RespondToInvitation(id, user.ID, accept)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	case store.ErrEmailNotVerified:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, []string{err.Error()})
+		return
+	case store.ErrInvitationClosed:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvitationFailed, http.StatusConflict, nil, []string{err.Error()})
+		return
+	case store.ErrInvitationExpired:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvitationFailed, http.StatusUnprocessableEntity, nil, []string{err.Error()})
+		return
+	default:
+		oh.logger.Printf("ERROR: respondToInvitation: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	message := utils.MessageInvitationDeclined
+	if accept {
+		message = utils.MessageInvitationAccepted
+	}
+	utils.WriteJSON(w, utils.StatusSuccess, message, http.StatusOK, utils.Envelope{"invitation": invitation}, nil)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOrganizationStore answers role lookups from maps, a missing entry is no
+// role at all.
+type fakeOrganizationStore struct {
+	store.OrganizationStore
+	orgs        map[int64]*store.Organization
+	members     map[int64]map[int64]store.OrgRole // organization, user
+	taskRoles   map[int64]map[int64]store.OrgRole // task, user
+	removed     []int64
+	invitations []*store.OrgInvitation
+}
+
+func (f *fakeOrganizationStore) GetOrganizationByID(id int64) (*store.Organization, error) {
+	return f.orgs[id], nil
+}
+
+func (f *fakeOrganizationStore) GetMemberRole(orgID, userID int64) (store.OrgRole, error) {
+	return f.members[orgID][userID], nil
+}
+
+func (f *fakeOrganizationStore) GetTaskRole(taskID, userID int64) (store.OrgRole, error) {
+	return f.taskRoles[taskID][userID], nil
+}
+
+func (f *fakeOrganizationStore) RemoveMember(orgID, userID int64) error {
+	f.removed = append(f.removed, userID)
+	return nil
+}
+
+func (f *fakeOrganizationStore) CreateInvitation(inv *store.OrgInvitation) (*store.OrgInvitation, error) {
+	f.invitations = append(f.invitations, inv)
+	return inv, nil
+}
+
+func TestOrganizationAccess(t *testing.T) {
+	orgStore := &fakeOrganizationStore{
+		orgs: map[int64]*store.Organization{3: {ID: 3, Name: "Acme"}},
+		members: map[int64]map[int64]store.OrgRole{
+			3: {1: store.OrgRoleOwner, 2: store.OrgRoleManager, 4: store.OrgRoleViewer},
+		},
+	}
+	oh := NewOrganizationHandler(orgStore, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	serve := func(method, path string, user *store.User, body string) int {
+		r := chi.NewRouter()
+		r.Get("/organizations/{id}", func(w http.ResponseWriter, req *http.Request) {
+			oh.HandleGetOrganization(w, middleware.SetUser(req, user))
+		})
+		r.Delete("/organizations/{id}/members/{userId}", func(w http.ResponseWriter, req *http.Request) {
+			oh.HandleRemoveMember(w, middleware.SetUser(req, user))
+		})
+		r.Post("/organizations/{id}/invitations", func(w http.ResponseWriter, req *http.Request) {
+			oh.HandleCreateInvitation(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   *store.User
+		body   string
+		want   int
+	}{
+		{"viewer reads", http.MethodGet, "/organizations/3", &store.User{ID: 4}, "", http.StatusOK},
+		{"non-member does not see it", http.MethodGet, "/organizations/3", &store.User{ID: 5}, "", http.StatusNotFound},
+		{"manager can not remove members", http.MethodDelete, "/organizations/3/members/4", &store.User{ID: 2}, "", http.StatusForbidden},
+		{"viewer leaves", http.MethodDelete, "/organizations/3/members/4", &store.User{ID: 4}, "", http.StatusOK},
+		{"owner removes a manager", http.MethodDelete, "/organizations/3/members/2", &store.User{ID: 1}, "", http.StatusOK},
+		{"owner invites by email", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 1}, `{"email": "jane@example.com", "role": "reviewer"}`, http.StatusCreated},
+		{"owner invites by username", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 1}, `{"username": "jane", "role": "viewer"}`, http.StatusCreated},
+		{"username or email, not both", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 1}, `{"username": "jane", "email": "jane@example.com", "role": "viewer"}`, http.StatusBadRequest},
+		{"invalid email", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 1}, `{"email": "jane", "role": "viewer"}`, http.StatusBadRequest},
+		{"unknown role", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 1}, `{"email": "jane@example.com", "role": "admin"}`, http.StatusBadRequest},
+		{"manager can not invite", http.MethodPost, "/organizations/3/invitations", &store.User{ID: 2}, `{"email": "jane@example.com", "role": "viewer"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.method, tt.path, tt.user, tt.body))
+		})
+	}
+
+	assert.Equal(t, []int64{4, 2}, orgStore.removed)
+	if assert.Len(t, orgStore.invitations, 2) {
+		assert.Equal(t, int64(1), *orgStore.invitations[0].InvitedBy)
+		assert.Equal(t, "jane", orgStore.invitations[1].Username)
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/achievements"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var validParticipationStatuses = map[store.ParticipationStatus]bool{
+	store.ParticipationJoined:    true,
+	store.ParticipationSubmitted: true,
+	store.ParticipationVerified:  true,
+	store.ParticipationRejected:  true,
+}
+
+type ParticipationHandler struct {
+	participationStore store.ParticipationStore
+	taskStore          store.TaskStore
+	orgStore           store.OrganizationStore
+	achievements       *achievements.Engine
+	cursors            *utils.CursorCodec
+	logger             *log.Logger
+}
+
+func NewParticipationHandler(participationStore store.ParticipationStore, taskStore store.TaskStore, orgStore store.OrganizationStore, achievements *achievements.Engine, cursors *utils.CursorCodec, logger *log.Logger) *ParticipationHandler {
+	return &ParticipationHandler{
+		participationStore: participationStore,
+		taskStore:          taskStore,
+		orgStore:           orgStore,
+		achievements:       achievements,
+		cursors:            cursors,
+		logger:             logger,
+	}
+}
+
+func (ph *ParticipationHandler) HandleJoinTask(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	taskID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	participation, streak, err := ph.participationStore.Join(taskID, user.ID)
+	if failures, ok := store.IsIneligible(err); ok {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotEligible, http.StatusForbidden, utils.Envelope{"failed_rules": failures}, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		case store.ErrAlreadyJoined, store.ErrTaskFull:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageTaskJoinFailed, http.StatusConflict, nil, []string{err.Error()})
+		case store.ErrTaskClosed:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageTaskJoinFailed, http.StatusUnprocessableEntity, nil, []string{err.Error()})
+		case store.ErrStepLocked:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageTaskJoinFailed, http.StatusForbidden, nil, []string{err.Error()})
+		default:
+			ph.logger.Printf("ERROR: joinTask: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+
+	// badges are a side effect, a failure here must not fail the join
+	badges, err := ph.achievements.Handle(achievements.Event{Type: achievements.EventTaskJoined, UserID: user.ID})
+	if err != nil {
+		ph.logger.Printf("ERROR: evaluating achievements: %v", err)
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTaskJoined, http.StatusCreated, utils.Envelope{
+		"participation":  participation,
+		"streak":         streak,
+		"badges_awarded": badges,
+	}, nil)
+}
+
+func (ph *ParticipationHandler) HandleGetMyParticipation(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	taskID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	participation, err := ph.participationStore.GetCurrentParticipation(taskID, user.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR: getCurrentParticipation: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	streak, err := ph.participationStore.GetStreak(taskID, user.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR: getStreak: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageParticipationRetrieved, http.StatusOK, utils.Envelope{
+		"participation": participation,
+		"streak":        streak,
+	}, nil)
+}
+
+// HandleGetTaskParticipations lists a task's participations for everyone with
+// a role on it, oldest first, optionally filtered by status.
+func (ph *ParticipationHandler) HandleGetTaskParticipations(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	taskID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	status := store.ParticipationStatus(r.URL.Query().Get("status"))
+	if status != "" && !validParticipationStatuses[status] {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"status must be one of joined, submitted, verified or rejected"})
+		return
+	}
+
+	page, err := ph.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	task, err := ph.taskStore.GetTaskByID(taskID)
+	if err != nil {
+		ph.logger.Printf("ERROR: getTaskByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if task == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	role, err := resourceRole(ph.orgStore, user, task.UserID, task.OrganizationID)
+	if err != nil {
+		ph.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if role == "" {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+
+	participations, bounds, err := ph.participationStore.GetTaskParticipations(taskID, status, page)
+	if err != nil {
+		ph.logger.Printf("ERROR: getTaskParticipations: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageParticipationsFetched, http.StatusOK, ph.cursors.PageEnvelope(utils.Envelope{"participations": participations}, page, bounds), nil)
+}
+
+// loadReviewableParticipation reads the participation in the URL and checks
+// the caller may review it, see canReview. It writes the error response
+// itself and returns nil when the request can not proceed.
+func (ph *ParticipationHandler) loadReviewableParticipation(w http.ResponseWriter, r *http.Request) *store.Participation {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	participation, err := ph.participationStore.GetParticipationByID(id)
+	if err != nil {
+		ph.logger.Printf("ERROR: getParticipationByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if participation == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	role, err := ph.orgStore.GetTaskRole(participation.TaskID, user.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR: getTaskRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if !canReview(user, role, participation.UserID) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+	if participation.Status == store.ParticipationVerified {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{store.ErrNotReviewable.Error()})
+		return nil
+	}
+
+	return participation
+}
+
+func (ph *ParticipationHandler) HandleVerifyParticipation(w http.ResponseWriter, r *http.Request) {
+	participation := ph.loadReviewableParticipation(w, r)
+	if participation == nil {
+		return
+	}
+
+	user, _ := middleware.GetUser(r)
+
+	reward, quests, err := ph.participationStore.VerifyParticipation(participation.ID, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotReviewable:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{err.Error()})
+		default:
+			ph.logger.Printf("ERROR: verifyParticipation: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+
+	// badges are a side effect, a failure here must not fail the verification
+	badges, err := ph.achievements.Handle(achievements.Event{Type: achievements.EventRewardGranted, UserID: reward.UserID})
+	if err != nil {
+		ph.logger.Printf("ERROR: evaluating achievements: %v", err)
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageParticipationVerified, http.StatusOK, utils.Envelope{
+		"reward":         reward,
+		"quests":         quests,
+		"badges_awarded": badges,
+	}, nil)
+}
+
+func (ph *ParticipationHandler) HandleRejectParticipation(w http.ResponseWriter, r *http.Request) {
+	participation := ph.loadReviewableParticipation(w, r)
+	if participation == nil {
+		return
+	}
+
+	user, _ := middleware.GetUser(r)
+
+	err := ph.participationStore.RejectParticipation(participation.ID, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotReviewable:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{err.Error()})
+		default:
+			ph.logger.Printf("ERROR: rejectParticipation: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+	participation.Status = store.ParticipationRejected
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageParticipationRejected, http.StatusOK, utils.Envelope{"participation": participation}, nil)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type createQuestRequest struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	RewardUSDT  float64 `json:"reward_usdt"`
+	// TaskIDs lists the quest's steps in the order they must be completed.
+	TaskIDs []int64 `json:"task_ids"`
+}
+
+type QuestHandler struct {
+	questStore store.QuestStore
+	cursors    *utils.CursorCodec
+	logger     *log.Logger
+}
+
+func NewQuestHandler(questStore store.QuestStore, cursors *utils.CursorCodec, logger *log.Logger) *QuestHandler {
+	return &QuestHandler{
+		questStore: questStore,
+		cursors:    cursors,
+		logger:     logger,
+	}
+}
+
+func (qh *QuestHandler) validateCreateQuestRequest(req *createQuestRequest) error {
+	if req.Title == "" {
+		return errors.New("title is required")
+	}
+	if len(req.Title) > 255 {
+		return errors.New("title must be less than 255 characters")
+	}
+	if req.RewardUSDT < 0 {
+		return errors.New("reward_usdt can not be negative")
+	}
+	if len(req.TaskIDs) == 0 {
+		return errors.New("task_ids must contain at least one task")
+	}
+
+	seen := make(map[int64]bool, len(req.TaskIDs))
+	for _, id := range req.TaskIDs {
+		if id <= 0 {
+			return errors.New("task_ids must only contain valid task ids")
+		}
+		if seen[id] {
+			return errors.New("task_ids must not contain the same task twice")
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+func (qh *QuestHandler) HandleCreateQuest(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var req createQuestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		qh.logger.Printf("ERROR: decodingCreateQuest: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = qh.validateCreateQuestRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	quest := &store.Quest{
+		UserID:      user.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		RewardUSDT:  req.RewardUSDT,
+	}
+	for _, taskID := range req.TaskIDs {
+		quest.Steps = append(quest.Steps, store.QuestStep{TaskID: taskID})
+	}
+
+	createdQuest, err := qh.questStore.CreateQuest(quest)
+	if errors.Is(err, store.ErrQuestTaskNotFound) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		qh.logger.Printf("ERROR: createQuest: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageQuestCreated, http.StatusCreated, utils.Envelope{"quest": createdQuest}, nil)
+}
+
+func (qh *QuestHandler) HandleGetAllQuest(w http.ResponseWriter, r *http.Request) {
+	page, err := qh.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	quests, bounds, err := qh.questStore.GetQuests(page)
+	if err != nil {
+		qh.logger.Printf("ERROR: getQuests: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageQuestsFetched, http.StatusOK, qh.cursors.PageEnvelope(utils.Envelope{"quests": quests}, page, bounds), nil)
+}
+
+func (qh *QuestHandler) HandleGetQuestByID(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		qh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	quest, err := qh.questStore.GetQuestByID(id)
+	if err != nil {
+		qh.logger.Printf("ERROR: getQuestByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if quest == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageQuestRetrieved, http.StatusOK, utils.Envelope{"quest": quest}, nil)
+}
+
+func (qh *QuestHandler) HandleDeleteQuest(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		qh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	quest, err := qh.questStore.GetQuestByID(id)
+	if err != nil {
+		qh.logger.Printf("ERROR: getQuestByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if quest == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	if quest.UserID != user.ID {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+
+	err = qh.questStore.DeleteQuest(id)
+	if err != nil {
+		qh.logger.Printf("ERROR: deleteQuest: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageQuestDeleted, http.StatusOK, nil, nil)
+}
+
+func (qh *QuestHandler) HandleGetQuestProgress(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		qh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	progress, err := qh.questStore.GetQuestProgress(id, user.ID)
+	if err != nil {
+		qh.logger.Printf("ERROR: getQuestProgress: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if progress == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageQuestProgressRetrieved, http.StatusOK, utils.Envelope{"progress": progress}, nil)
+}
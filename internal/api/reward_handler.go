@@ -18,12 +18,14 @@ var validRewardTypes = map[store.JenisCategory]bool{
 
 type RewardHandler struct {
 	rewardStore store.TaskRewardStore
+	cursors     *utils.CursorCodec
 	logger      *log.Logger
 }
 
-func NewRewardHandler(rewardStore store.TaskRewardStore, logger *log.Logger) *RewardHandler {
+func NewRewardHandler(rewardStore store.TaskRewardStore, cursors *utils.CursorCodec, logger *log.Logger) *RewardHandler {
 	return &RewardHandler{
 		rewardStore: rewardStore,
+		cursors:     cursors,
 		logger:      logger,
 	}
 }
@@ -75,14 +77,20 @@ func (rh *RewardHandler) HandleGetRewardByID(w http.ResponseWriter, r *http.Requ
 }
 
 func (rh *RewardHandler) HandleGetAllReward(w http.ResponseWriter, r *http.Request) {
-	rewards, err := rh.rewardStore.GetReward()
+	page, err := rh.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	rewards, bounds, err := rh.rewardStore.GetReward(page)
 	if err != nil {
 		rh.logger.Printf("ERROR: getAllReward: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageRewardsFetched, http.StatusOK, utils.Envelope{"rewards": rewards}, nil)
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageRewardsFetched, http.StatusOK, rh.cursors.PageEnvelope(utils.Envelope{"rewards": rewards}, page, bounds), nil)
 }
 
 func (rh *RewardHandler) HandleEditReward(w http.ResponseWriter, r *http.Request) {
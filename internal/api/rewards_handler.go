@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/harundarat/be-socialtask/internal/achievements"
 	"github.com/harundarat/be-socialtask/internal/store"
 	"github.com/harundarat/be-socialtask/internal/utils"
 )
@@ -17,12 +18,14 @@ type createRewardRequest struct {
 
 type RewardsHandler struct {
 	rewardsStore store.RewardsStore
+	achievements *achievements.Engine
 	logger       *log.Logger
 }
 
-func NewRewardsHandler(rewardsStore store.RewardsStore, logger *log.Logger) *RewardsHandler {
+func NewRewardsHandler(rewardsStore store.RewardsStore, achievements *achievements.Engine, logger *log.Logger) *RewardsHandler {
 	return &RewardsHandler{
 		rewardsStore: rewardsStore,
+		achievements: achievements,
 		logger:       logger,
 	}
 }
@@ -66,5 +69,11 @@ func (rh *RewardsHandler) HandleCreateReward(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, "Reward created successfully", http.StatusCreated, utils.Envelope{"reward": reward}, nil)
+	// badges are a side effect, a failure here must not fail the reward
+	badges, err := rh.achievements.Handle(achievements.Event{Type: achievements.EventRewardGranted, UserID: reward.UserID})
+	if err != nil {
+		rh.logger.Printf("ERROR: evaluating achievements: %v", err)
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, "Reward created successfully", http.StatusCreated, utils.Envelope{"reward": reward, "badges_awarded": badges}, nil)
 }
@@ -0,0 +1,359 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/achievements"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var validSubmissionStatuses = map[store.SubmissionStatus]bool{
+	store.SubmissionPending:  true,
+	store.SubmissionApproved: true,
+	store.SubmissionRejected: true,
+}
+
+type reviewSubmissionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type SubmissionHandler struct {
+	submissionStore store.SubmissionStore
+	taskStore       store.TaskStore
+	auditStore      store.AuditStore
+	orgStore        store.OrganizationStore
+	achievements    *achievements.Engine
+	cursors         *utils.CursorCodec
+	logger          *log.Logger
+}
+
+func NewSubmissionHandler(submissionStore store.SubmissionStore, taskStore store.TaskStore, auditStore store.AuditStore, orgStore store.OrganizationStore, achievements *achievements.Engine, cursors *utils.CursorCodec, logger *log.Logger) *SubmissionHandler {
+	return &SubmissionHandler{
+		submissionStore: submissionStore,
+		taskStore:       taskStore,
+		auditStore:      auditStore,
+		orgStore:        orgStore,
+		achievements:    achievements,
+		cursors:         cursors,
+		logger:          logger,
+	}
+}
+
+// canReview reports whether user, with role on the task, may decide on a
+// participant's work for it. The task's owner and reviewers can, and so can
+// moderators, but nobody reviews their own work.
+func canReview(user *store.User, role store.OrgRole, participantID int64) bool {
+	if participantID == user.ID {
+		return false
+	}
+	return role.Can(store.OrgRoleReviewer) || user.IsModerator()
+}
+
+func (sh *SubmissionHandler) HandleCreateSubmission(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	taskID, err := utils.ReadIDParam(r)
+	if err != nil {
+		sh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var proof store.Proof
+	err = json.NewDecoder(r.Body).Decode(&proof)
+	if err != nil {
+		sh.logger.Printf("ERROR: decodingCreateSubmission: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	err = proof.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	submission, err := sh.submissionStore.CreateSubmission(taskID, user.ID, proof)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		case store.ErrProofAssetNotFound:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		case store.ErrNotJoined:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageSubmissionFailed, http.StatusUnprocessableEntity, nil, []string{err.Error()})
+		case store.ErrSubmissionPending, store.ErrAlreadyVerified, store.ErrDisputeOpen:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageSubmissionFailed, http.StatusConflict, nil, []string{err.Error()})
+		default:
+			sh.logger.Printf("ERROR: createSubmission: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionCreated, http.StatusCreated, utils.Envelope{"submission": submission}, nil)
+}
+
+// readStatusFilter reads the optional status query parameter of the queue
+// endpoints. It writes the error response itself.
+func (sh *SubmissionHandler) readStatusFilter(w http.ResponseWriter, r *http.Request) (store.SubmissionStatus, bool) {
+	status := store.SubmissionStatus(r.URL.Query().Get("status"))
+	if status != "" && !validSubmissionStatuses[status] {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"status must be one of pending, approved or rejected"})
+		return "", false
+	}
+	return status, true
+}
+
+// HandleGetTaskSubmissions is the review queue of one task, for everyone with
+// a role on it and moderators, oldest first.
+func (sh *SubmissionHandler) HandleGetTaskSubmissions(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	taskID, err := utils.ReadIDParam(r)
+	if err != nil {
+		sh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	status, ok := sh.readStatusFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := sh.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	task, err := sh.taskStore.GetTaskByID(taskID)
+	if err != nil {
+		sh.logger.Printf("ERROR: getTaskByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if task == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	role, err := resourceRole(sh.orgStore, user, task.UserID, task.OrganizationID)
+	if err != nil {
+		sh.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if role == "" && !user.IsModerator() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+
+	submissions, bounds, err := sh.submissionStore.GetSubmissions(taskID, status, page)
+	if err != nil {
+		sh.logger.Printf("ERROR: getSubmissions: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionsFetched, http.StatusOK, sh.cursors.PageEnvelope(utils.Envelope{"submissions": submissions}, page, bounds), nil)
+}
+
+// HandleGetSubmissions is the review queue across every task, for moderators.
+func (sh *SubmissionHandler) HandleGetSubmissions(w http.ResponseWriter, r *http.Request) {
+	status, ok := sh.readStatusFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := sh.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	submissions, bounds, err := sh.submissionStore.GetSubmissions(0, status, page)
+	if err != nil {
+		sh.logger.Printf("ERROR: getSubmissions: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionsFetched, http.StatusOK, sh.cursors.PageEnvelope(utils.Envelope{"submissions": submissions}, page, bounds), nil)
+}
+
+// loadSubmission reads the submission in the URL. It writes the error
+// response itself and returns nil when the request can not proceed.
+func (sh *SubmissionHandler) loadSubmission(w http.ResponseWriter, r *http.Request) *store.Submission {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		sh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	submission, err := sh.submissionStore.GetSubmissionByID(id)
+	if err != nil {
+		sh.logger.Printf("ERROR: getSubmissionByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if submission == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	return submission
+}
+
+// HandleGetSubmission returns a submission and its audit trail to the
+// submitter, everyone with a role on the task and moderators.
+func (sh *SubmissionHandler) HandleGetSubmission(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	submission := sh.loadSubmission(w, r)
+	if submission == nil {
+		return
+	}
+	if submission.UserID != user.ID && !user.IsModerator() {
+		role, err := sh.orgStore.GetTaskRole(submission.TaskID, user.ID)
+		if err != nil {
+			sh.logger.Printf("ERROR: getTaskRole: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		if role == "" {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+			return
+		}
+	}
+
+	page, err := sh.cursors.ReadPageParams(r, string(store.TaskSortOldest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	history, bounds, err := sh.auditStore.GetAuditEntries(store.AuditEntitySubmission, submission.ID, page)
+	if err != nil {
+		sh.logger.Printf("ERROR: getAuditEntries: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	// the cursors page through the history
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionRetrieved, http.StatusOK, sh.cursors.PageEnvelope(utils.Envelope{
+		"submission": submission,
+		"history":    history,
+	}, page, bounds), nil)
+}
+
+// loadReviewableSubmission reads the submission in the URL and the review
+// request, and checks the caller may review it. It writes the error response
+// itself and returns nil when the request can not proceed.
+func (sh *SubmissionHandler) loadReviewableSubmission(w http.ResponseWriter, r *http.Request, reasonRequired bool) (*store.Submission, string) {
+	user, _ := middleware.GetUser(r)
+
+	submission := sh.loadSubmission(w, r)
+	if submission == nil {
+		return nil, ""
+	}
+
+	role, err := sh.orgStore.GetTaskRole(submission.TaskID, user.ID)
+	if err != nil {
+		sh.logger.Printf("ERROR: getTaskRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil, ""
+	}
+	if !canReview(user, role, submission.UserID) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil, ""
+	}
+
+	// the body is optional when approving
+	var req reviewSubmissionRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		sh.logger.Printf("ERROR: decodingReviewSubmission: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil, ""
+	}
+
+	err = store.ValidateReviewReason(req.Reason, reasonRequired)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return nil, ""
+	}
+
+	if submission.Status != store.SubmissionPending {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{store.ErrSubmissionReviewed.Error()})
+		return nil, ""
+	}
+
+	return submission, req.Reason
+}
+
+func (sh *SubmissionHandler) HandleApproveSubmission(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	submission, reason := sh.loadReviewableSubmission(w, r, false)
+	if submission == nil {
+		return
+	}
+
+	approved, reward, quests, err := sh.submissionStore.ApproveSubmission(submission.ID, user.ID, reason)
+	if err != nil {
+		switch err {
+		case store.ErrSubmissionReviewed, store.ErrNotReviewable:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{err.Error()})
+		default:
+			sh.logger.Printf("ERROR: approveSubmission: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+
+	// badges are a side effect, a failure here must not fail the approval
+	badges, err := sh.achievements.Handle(achievements.Event{Type: achievements.EventRewardGranted, UserID: reward.UserID})
+	if err != nil {
+		sh.logger.Printf("ERROR: evaluating achievements: %v", err)
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionApproved, http.StatusOK, utils.Envelope{
+		"submission":     approved,
+		"reward":         reward,
+		"quests":         quests,
+		"badges_awarded": badges,
+	}, nil)
+}
+
+func (sh *SubmissionHandler) HandleRejectSubmission(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	submission, reason := sh.loadReviewableSubmission(w, r, true)
+	if submission == nil {
+		return
+	}
+
+	rejected, err := sh.submissionStore.RejectSubmission(submission.ID, user.ID, reason)
+	if err != nil {
+		switch err {
+		case store.ErrSubmissionReviewed:
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusConflict, nil, []string{err.Error()})
+		default:
+			sh.logger.Printf("ERROR: rejectSubmission: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		}
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageSubmissionRejected, http.StatusOK, utils.Envelope{"submission": rejected}, nil)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSubmissionStore struct {
+	store.SubmissionStore
+	submissions map[int64]*store.Submission
+	rejected    []int64
+}
+
+func (f *fakeSubmissionStore) GetSubmissionByID(id int64) (*store.Submission, error) {
+	return f.submissions[id], nil
+}
+
+func (f *fakeSubmissionStore) RejectSubmission(id, reviewerID int64, reason string) (*store.Submission, error) {
+	f.rejected = append(f.rejected, reviewerID)
+	s := *f.submissions[id]
+	s.Status = store.SubmissionRejected
+	s.Reason = reason
+	return &s, nil
+}
+
+func TestHandleRejectSubmission(t *testing.T) {
+	submissionStore := &fakeSubmissionStore{submissions: map[int64]*store.Submission{
+		5: {ID: 5, TaskID: 7, UserID: 2, TaskOwnerID: 1, Status: store.SubmissionPending},
+		6: {ID: 6, TaskID: 7, UserID: 3, TaskOwnerID: 1, Status: store.SubmissionApproved},
+	}}
+	orgStore := &fakeOrganizationStore{taskRoles: map[int64]map[int64]store.OrgRole{
+		7: {1: store.OrgRoleOwner, 4: store.OrgRoleReviewer, 5: store.OrgRoleViewer},
+	}}
+	sh := NewSubmissionHandler(submissionStore, nil, nil, orgStore, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	reject := func(id string, user *store.User, body string) int {
+		r := chi.NewRouter()
+		r.Post("/submissions/{id}/reject", func(w http.ResponseWriter, req *http.Request) {
+			sh.HandleRejectSubmission(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/submissions/"+id+"/reject", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		user *store.User
+		body string
+		want int
+	}{
+		{name: "task owner", id: "5", user: &store.User{ID: 1}, body: `{"reason": "link is broken"}`, want: http.StatusOK},
+		{name: "moderator", id: "5", user: &store.User{ID: 9, Role: store.UserRoleModerator}, body: `{"reason": "spam"}`, want: http.StatusOK},
+		{name: "organization reviewer", id: "5", user: &store.User{ID: 4}, body: `{"reason": "wrong account"}`, want: http.StatusOK},
+		{name: "organization viewer", id: "5", user: &store.User{ID: 5}, body: `{"reason": "spam"}`, want: http.StatusForbidden},
+		{name: "other user", id: "5", user: &store.User{ID: 3}, body: `{"reason": "spam"}`, want: http.StatusForbidden},
+		{name: "moderator reviewing own proof", id: "5", user: &store.User{ID: 2, Role: store.UserRoleModerator}, body: `{"reason": "spam"}`, want: http.StatusForbidden},
+		{name: "missing reason", id: "5", user: &store.User{ID: 1}, body: `{}`, want: http.StatusBadRequest},
+		{name: "already reviewed", id: "6", user: &store.User{ID: 1}, body: `{"reason": "changed my mind"}`, want: http.StatusConflict},
+		{name: "unknown submission", id: "8", user: &store.User{ID: 1}, body: `{"reason": "spam"}`, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, reject(tt.id, tt.user, tt.body))
+		})
+	}
+
+	assert.Equal(t, []int64{1, 9, 4}, submissionStore.rejected)
+}
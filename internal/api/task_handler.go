@@ -1,27 +1,143 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/harundarat/be-socialtask/internal/events"
+	"github.com/harundarat/be-socialtask/internal/importer"
 	"github.com/harundarat/be-socialtask/internal/middleware"
 	"github.com/harundarat/be-socialtask/internal/store"
 	"github.com/harundarat/be-socialtask/internal/utils"
 )
 
 type TaskHandler struct {
-	taskStore store.TaskStore
-	logger    *log.Logger
+	taskStore      store.TaskStore
+	feedStore      store.FeedStore
+	orgStore       store.OrganizationStore
+	analyticsStore store.AnalyticsStore
+	eventWriter    *events.Writer
+	cursors        *utils.CursorCodec
+	logger         *log.Logger
 }
 
-func NewTaskHandler(taskStore store.TaskStore, logger *log.Logger) *TaskHandler {
+func NewTaskHandler(taskStore store.TaskStore, feedStore store.FeedStore, orgStore store.OrganizationStore, analyticsStore store.AnalyticsStore, eventWriter *events.Writer, cursors *utils.CursorCodec, logger *log.Logger) *TaskHandler {
 	return &TaskHandler{
-		taskStore: taskStore,
-		logger:    logger,
+		taskStore:      taskStore,
+		feedStore:      feedStore,
+		orgStore:       orgStore,
+		analyticsStore: analyticsStore,
+		eventWriter:    eventWriter,
+		cursors:        cursors,
+		logger:         logger,
 	}
 }
 
+// MaxImportBytes is the largest file HandleImportTasks reads.
+const MaxImportBytes = 5 << 20
+
+var validTaskStatuses = map[store.TaskStatus]bool{
+	store.TaskStatusPending:   true,
+	store.TaskStatusActive:    true,
+	store.TaskStatusCompleted: true,
+	store.TaskStatusDraft:     true,
+}
+
+var validTaskSorts = map[store.TaskSort]bool{
+	store.TaskSortNewest:     true,
+	store.TaskSortOldest:     true,
+	store.TaskSortRewardHigh: true,
+	store.TaskSortRewardLow:  true,
+	store.TaskSortDueSoon:    true,
+	store.TaskSortRelevance:  true,
+}
+
+var validRecurrences = map[store.Recurrence]bool{
+	store.RecurrenceNone:   true,
+	store.RecurrenceDaily:  true,
+	store.RecurrenceWeekly: true,
+}
+
+func validateTaskRecurrence(task *store.Task) error {
+	if task.Recurrence != "" && !validRecurrences[task.Recurrence] {
+		return errors.New("recurrence must be one of none, daily or weekly")
+	}
+	if task.RecurrenceTimezone != "" {
+		if _, err := time.LoadLocation(task.RecurrenceTimezone); err != nil {
+			return errors.New("recurrence_timezone must be a valid IANA timezone")
+		}
+	}
+
+	return nil
+}
+
+// validateTaskPublishAt checks a scheduled publish time lies ahead, a draft
+// due in the past is published by hand instead.
+func validateTaskPublishAt(task *store.Task) error {
+	if task.PublishAt != nil && !task.PublishAt.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+
+	return nil
+}
+
+func validateTaskLinks(task *store.Task) error {
+	seen := make(map[int]bool, len(task.ActionIDs))
+	for _, id := range task.ActionIDs {
+		if id <= 0 {
+			return errors.New("action_ids must only contain valid action ids")
+		}
+		if seen[id] {
+			return errors.New("action_ids must not contain the same action twice")
+		}
+		seen[id] = true
+	}
+
+	seen = make(map[int]bool, len(task.RewardIDs))
+	for _, id := range task.RewardIDs {
+		if id <= 0 {
+			return errors.New("reward_ids must only contain valid reward ids")
+		}
+		if seen[id] {
+			return errors.New("reward_ids must not contain the same reward twice")
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// validateTask runs every check a new task has to pass before it reaches
+// the store.
+func validateTask(task *store.Task) error {
+	err := validateTaskRecurrence(task)
+	if err == nil {
+		err = validateTaskPublishAt(task)
+	}
+	if err == nil {
+		err = validateTaskLinks(task)
+	}
+	if err == nil {
+		err = task.Eligibility.Validate()
+	}
+	return err
+}
+
+// isTaskLinkError reports whether err comes from an action, reward or image
+// id that does not exist, which is the caller's mistake rather than ours.
+func isTaskLinkError(err error) bool {
+	return errors.Is(err, store.ErrActionNotFound) || errors.Is(err, store.ErrRewardNotFound) || errors.Is(err, store.ErrImageNotFound)
+}
+
 func (th *TaskHandler) HandleCreateTask(w http.ResponseWriter, r *http.Request) {
 	users, ok := middleware.GetUser(r)
 	if !ok {
@@ -37,8 +153,32 @@ func (th *TaskHandler) HandleCreateTask(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	err = validateTask(&task)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	// only managers create tasks for their organization
+	if task.OrganizationID != nil {
+		role, err := th.orgStore.GetMemberRole(*task.OrganizationID, users.ID)
+		if err != nil {
+			th.logger.Printf("ERROR: getMemberRole: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		if !role.Can(store.OrgRoleManager) {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, []string{"only managers of the organization can create its tasks"})
+			return
+		}
+	}
+
 	task.UserID = users.ID
 	createdTask, err := th.taskStore.CreateTask(&task)
+	if isTaskLinkError(err) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
 	if err != nil {
 		th.logger.Printf("ERROR: createTask: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
@@ -57,58 +197,251 @@ func (th *TaskHandler) HandleGetTaskByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	task, err := th.taskStore.GetTaskByID(id)
+	// anonymous viewers get the task without their own participation
+	user, _ := middleware.GetUser(r)
+	var viewerID int64
+	if !user.IsAnonymous() {
+		viewerID = user.ID
+	}
+
+	task, err := th.taskStore.GetTaskDetail(id, viewerID)
 	if err != nil {
-		th.logger.Printf("ERROR: getTaskByID: %v", err)
+		th.logger.Printf("ERROR: getTaskDetail: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
+	if task == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	// someone else's draft does not exist as far as the viewer can tell
+	if task.Status == store.TaskStatusDraft {
+		role, err := resourceRole(th.orgStore, user, task.UserID, task.OrganizationID)
+		if err != nil {
+			th.logger.Printf("ERROR: resourceRole: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		if role == "" {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+			return
+		}
+	}
+
+	// the view only demotes the task in the viewer's feed, losing it is not
+	// worth failing the request
+	if viewerID != 0 {
+		if err := th.feedStore.RecordTaskView(viewerID, id); err != nil {
+			th.logger.Printf("ERROR: recordTaskView: %v", err)
+		}
+	}
+	th.eventWriter.Record(store.TaskEvent{TaskID: id, UserID: viewerID, SessionID: sessionID(r), Type: store.TaskEventView})
 
 	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTaskRetrieved, http.StatusOK, utils.Envelope{"task": task}, nil)
 }
 
+func readRewardParam(r *http.Request, name string) (*float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	reward, err := strconv.ParseFloat(value, 64)
+	if err != nil || reward < 0 {
+		return nil, errors.New(name + " must be a non-negative number")
+	}
+	return &reward, nil
+}
+
+func readBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New(name + " must be true or false")
+	}
+	return parsed, nil
+}
+
+func readTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// readDateParam reads a day like 2025-11-01, the zero time when it is not
+// set.
+func readDateParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be a date like 2025-11-01")
+	}
+	return t, nil
+}
+
+// readAnalyticsFilter reads the optional from and to days of the analytics
+// endpoints, the store fills in the defaults.
+func readAnalyticsFilter(r *http.Request) (store.AnalyticsFilter, error) {
+	var filter store.AnalyticsFilter
+	var err error
+	if filter.From, err = readDateParam(r, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = readDateParam(r, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func (th *TaskHandler) readTaskFilter(r *http.Request) (store.TaskFilter, error) {
+	query := r.URL.Query()
+	filter := store.TaskFilter{
+		Status:     store.TaskStatus(query.Get("status")),
+		ActionType: store.TypeAction(query.Get("action_type")),
+		RewardType: store.JenisCategory(query.Get("reward_type")),
+		Search:     query.Get("q"),
+		Sort:       store.TaskSort(query.Get("sort")),
+	}
+
+	if filter.Status != "" && !validTaskStatuses[filter.Status] {
+		return filter, errors.New("status must be one of PENDING, ACTIVE, COMPLETED or DRAFT")
+	}
+	if filter.ActionType != "" && !validTaskTypes[filter.ActionType] {
+		return filter, errors.New("action_type must be a valid action type")
+	}
+	if filter.RewardType != "" && !validRewardTypes[filter.RewardType] {
+		return filter, errors.New("reward_type must be a valid reward type")
+	}
+	if len(filter.Search) > 200 {
+		return filter, errors.New("q must be less than 200 characters")
+	}
+
+	// searches rank by relevance unless the caller asks otherwise
+	if filter.Sort == "" && filter.Search != "" {
+		filter.Sort = store.TaskSortRelevance
+	}
+	if filter.Sort == "" {
+		filter.Sort = store.TaskSortNewest
+	}
+	if !validTaskSorts[filter.Sort] {
+		return filter, errors.New("sort must be one of newest, oldest, reward_high, reward_low, due_soon or relevance")
+	}
+	if filter.Sort == store.TaskSortRelevance && filter.Search == "" {
+		return filter, errors.New("sort=relevance requires q")
+	}
+
+	if creator := query.Get("creator_id"); creator != "" {
+		id, err := strconv.ParseInt(creator, 10, 64)
+		if err != nil || id <= 0 {
+			return filter, errors.New("creator_id must be a valid user id")
+		}
+		filter.CreatorID = id
+	}
+
+	var err error
+	if filter.OrganizationID, err = readOrganizationParam(r); err != nil {
+		return filter, err
+	}
+	if filter.MinReward, err = readRewardParam(r, "min_reward"); err != nil {
+		return filter, err
+	}
+	if filter.MaxReward, err = readRewardParam(r, "max_reward"); err != nil {
+		return filter, err
+	}
+	if filter.MinReward != nil && filter.MaxReward != nil && *filter.MinReward > *filter.MaxReward {
+		return filter, errors.New("min_reward must not be greater than max_reward")
+	}
+
+	if filter.DueBefore, err = readTimeParam(r, "due_before"); err != nil {
+		return filter, err
+	}
+	if filter.DueAfter, err = readTimeParam(r, "due_after"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
 func (th *TaskHandler) HandleGetAllTask(w http.ResponseWriter, r *http.Request) {
-	var (
-		limit int64 = 10
-	)
-	page := utils.ReadPageParam(r)
-	offset := (page - 1) * limit
-	tasks, totalPages, err := th.taskStore.GetAllTask(limit, offset)
+	filter, err := th.readTaskFilter(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	filter.Page, err = th.cursors.ReadPageParams(r, string(filter.Sort))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	// signed in users also see their own drafts and their organizations'
+	if user, _ := middleware.GetUser(r); !user.IsAnonymous() {
+		filter.ViewerID = user.ID
+	}
+
+	tasks, bounds, total, err := th.taskStore.GetAllTask(filter)
 	if err != nil {
 		th.logger.Printf("ERROR: getAllTask: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, utils.Envelope{
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, th.cursors.PageEnvelope(utils.Envelope{
 		"tasks": tasks,
 		"meta": map[string]int64{
-			"page":  page,
-			"limit": limit,
-			"total": totalPages,
+			"limit": filter.Page.Limit,
+			"total": total,
 		},
-	}, nil)
+	}, filter.Page, bounds), nil)
 }
 
 func (th *TaskHandler) HandleEditTask(w http.ResponseWriter, r *http.Request) {
-	id, err := utils.ReadIDParam(r)
-	if err != nil {
-		th.logger.Printf("ERROR: readIdParam: %v", err)
-		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+	current := th.loadTask(w, r, store.OrgRoleManager)
+	if current == nil {
 		return
 	}
 
 	var task store.Task
-	task.ID = int(id)
-
-	err = json.NewDecoder(r.Body).Decode(&task)
+	err := json.NewDecoder(r.Body).Decode(&task)
 	if err != nil {
 		th.logger.Printf("ERROR: decodingEditTask: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
 		return
 	}
 
-	err = th.taskStore.EditTask(&task)
+	err = validateTask(&task)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	user, _ := middleware.GetUser(r)
+	task.ID = current.ID
+	err = th.taskStore.EditTask(&task, user.ID)
+	if isTaskLinkError(err) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err == store.ErrTaskNotDraft {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageTaskNotDraft, http.StatusConflict, nil, []string{"publish_at can only be changed on drafts"})
+		return
+	}
 	if err != nil {
 		th.logger.Printf("ERROR: getTaskByID: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
@@ -119,19 +452,313 @@ func (th *TaskHandler) HandleEditTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func (th *TaskHandler) HandleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	task := th.loadTask(w, r, store.OrgRoleManager)
+	if task == nil {
+		return
+	}
+
+	err := th.taskStore.DeleteTask(int64(task.ID))
+	if err != nil {
+		th.logger.Printf("ERROR: deleteTask: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksDeleted, http.StatusOK, nil, nil)
+}
+
+// loadTask reads the task in the URL and checks the caller's role on it
+// allows need: the creator of a task without organization can do anything,
+// on an organization's task the caller's member role counts. It writes the
+// error response itself and returns nil when the request can not proceed.
+func (th *TaskHandler) loadTask(w http.ResponseWriter, r *http.Request, need store.OrgRole) *store.Task {
+	user, _ := middleware.GetUser(r)
+
 	id, err := utils.ReadIDParam(r)
 	if err != nil {
 		th.logger.Printf("ERROR: readIdParam: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	task, err := th.taskStore.GetTaskByID(id)
+	if err != nil {
+		th.logger.Printf("ERROR: getTaskByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if task == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	role, err := resourceRole(th.orgStore, user, task.UserID, task.OrganizationID)
+	if err != nil {
+		th.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	// drafts stay hidden from anyone without a role on them
+	if task.Status == store.TaskStatusDraft && role == "" {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+	if !role.Can(need) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+
+	return task
+}
+
+// HandlePublishTask publishes a draft right away, without waiting for its
+// publish_at.
+func (th *TaskHandler) HandlePublishTask(w http.ResponseWriter, r *http.Request) {
+	task := th.loadTask(w, r, store.OrgRoleManager)
+	if task == nil {
 		return
 	}
 
-	err = th.taskStore.DeleteTask(id)
+	err := th.taskStore.PublishTask(int64(task.ID))
+	if err == store.ErrTaskNotDraft {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageTaskNotDraft, http.StatusConflict, nil, []string{err.Error()})
+		return
+	}
 	if err != nil {
-		th.logger.Printf("ERROR: deleteTask: %v", err)
+		th.logger.Printf("ERROR: publishTask: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksDeleted, http.StatusOK, nil, nil)
+	published, err := th.taskStore.GetTaskByID(int64(task.ID))
+	if err != nil {
+		th.logger.Printf("ERROR: getTaskByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTaskPublished, http.StatusOK, utils.Envelope{"task": published}, nil)
+}
+
+// HandleCloneTask copies a task, with its actions, rewards and eligibility
+// rules, into a new draft.
+func (th *TaskHandler) HandleCloneTask(w http.ResponseWriter, r *http.Request) {
+	task := th.loadTask(w, r, store.OrgRoleManager)
+	if task == nil {
+		return
+	}
+
+	clone, err := th.taskStore.CloneTask(int64(task.ID))
+	if err != nil {
+		th.logger.Printf("ERROR: cloneTask: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if clone == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTaskCloned, http.StatusCreated, utils.Envelope{"task": clone}, nil)
+}
+
+// importRowErrors are the mistakes found in one task of an import, Row
+// counts the tasks of the file from 1.
+type importRowErrors struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+// readImportFile returns the file of a task import and its format. It is
+// either the "file" field of a multipart form or the whole body, with the
+// format taken from the file name or the content type.
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, importer.Format, int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportBytes+multipartOverhead)
+
+	contentType, filename := r.Header.Get("Content-Type"), ""
+	var body io.Reader = r.Body
+	if mr, err := r.MultipartReader(); err == nil {
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, "", http.StatusBadRequest, errors.New("file is required")
+			}
+			if err != nil {
+				return nil, "", http.StatusBadRequest, errors.New("request is not a valid multipart form")
+			}
+			if part.FormName() == "file" {
+				defer part.Close()
+				contentType, filename, body = part.Header.Get("Content-Type"), part.FileName(), part
+				break
+			}
+			part.Close()
+		}
+	}
+
+	format := importer.DetectFormat(contentType, filename)
+	if format == "" {
+		return nil, "", http.StatusBadRequest, errors.New("file must be a .csv or .json file")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxImportBytes+1))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || len(data) > MaxImportBytes {
+		return nil, "", http.StatusRequestEntityTooLarge, errors.New("file must be at most 5 MB")
+	}
+	if err != nil {
+		return nil, "", http.StatusBadRequest, errors.New("file could not be read")
+	}
+	return data, format, 0, nil
+}
+
+// HandleImportTasks creates every task of a CSV or JSON file at once. Each
+// task is checked like one sent to HandleCreateTask and the tasks are only
+// created when none of them has a mistake. dry_run=true stops after the
+// checks and draft=true makes every task a draft.
+func (th *TaskHandler) HandleImportTasks(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	dryRun, err := readBoolParam(r, "dry_run")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	drafts, err := readBoolParam(r, "draft")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	data, format, status, err := readImportFile(w, r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, status, nil, []string{err.Error()})
+		return
+	}
+
+	rows, err := importer.ReadTasks(bytes.NewReader(data), format)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"file must hold at least one task"})
+		return
+	}
+
+	// every organization is asked about once, however many of its tasks
+	// the file holds
+	orgRoles := map[int64]store.OrgRole{}
+	tasks := make([]*store.Task, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		task := &row.Task
+		tasks[i] = task
+
+		if strings.TrimSpace(task.Title) == "" {
+			row.Errors = append(row.Errors, "title is required")
+		}
+		if err := validateTask(task); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		if task.OrganizationID != nil {
+			role, seen := orgRoles[*task.OrganizationID]
+			if !seen {
+				role, err = th.orgStore.GetMemberRole(*task.OrganizationID, user.ID)
+				if err != nil {
+					th.logger.Printf("ERROR: getMemberRole: %v", err)
+					utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+					return
+				}
+				orgRoles[*task.OrganizationID] = role
+			}
+			if !role.Can(store.OrgRoleManager) {
+				row.Errors = append(row.Errors, "only managers of the organization can create its tasks")
+			}
+		}
+
+		task.UserID = user.ID
+		if drafts {
+			task.Status = store.TaskStatusDraft
+		}
+	}
+
+	invalid := slices.ContainsFunc(rows, func(row importer.Row) bool { return len(row.Errors) > 0 })
+	// the store checks the links of every task before it writes anything,
+	// a file with mistakes is only checked so that all of them are reported
+	created, err := th.taskStore.CreateTasks(tasks, dryRun || invalid)
+	var batchErrs store.TaskBatchErrors
+	if !errors.As(err, &batchErrs) {
+		var batchErr *store.TaskBatchError
+		if errors.As(err, &batchErr) && isTaskLinkError(batchErr.Err) {
+			batchErrs = store.TaskBatchErrors{batchErr}
+		}
+	}
+	if err != nil && batchErrs == nil {
+		th.logger.Printf("ERROR: createTasks: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	for _, batchErr := range batchErrs {
+		rows[batchErr.Index].Errors = append(rows[batchErr.Index].Errors, batchErr.Err.Error())
+		invalid = true
+	}
+
+	if invalid {
+		var failed []importRowErrors
+		for _, row := range rows {
+			if len(row.Errors) > 0 {
+				failed = append(failed, importRowErrors{Row: row.Number, Errors: row.Errors})
+			}
+		}
+		writeImportErrors(w, failed)
+		return
+	}
+
+	if dryRun {
+		utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksValidated, http.StatusOK, utils.Envelope{"tasks": created, "count": len(created), "dry_run": true}, nil)
+		return
+	}
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksImported, http.StatusCreated, utils.Envelope{"tasks": created, "count": len(created), "dry_run": false}, nil)
+}
+
+// writeImportErrors answers an import with the mistakes of every task that
+// has one, none of the tasks are created.
+func writeImportErrors(w http.ResponseWriter, failed []importRowErrors) {
+	errs := make([]string, 0, len(failed))
+	for _, row := range failed {
+		for _, msg := range row.Errors {
+			errs = append(errs, fmt.Sprintf("row %d: %s", row.Row, msg))
+		}
+	}
+	utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, utils.Envelope{"rows": failed}, errs)
+}
+
+// HandleGetTaskAnalytics reports how the task performs, for everyone with a
+// role on it.
+func (th *TaskHandler) HandleGetTaskAnalytics(w http.ResponseWriter, r *http.Request) {
+	task := th.loadTask(w, r, store.OrgRoleViewer)
+	if task == nil {
+		return
+	}
+
+	filter, err := readAnalyticsFilter(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	filter.TaskID = int64(task.ID)
+	analytics, err := th.analyticsStore.GetTaskAnalytics(filter)
+	if err == store.ErrAnalyticsRange {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: getTaskAnalytics: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageAnalyticsFetched, http.StatusOK, utils.Envelope{"analytics": analytics}, nil)
 }
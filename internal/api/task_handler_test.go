@@ -0,0 +1,521 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/events"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskStore only implements what the handlers under test call, anything
+// else panics through the nil embedded interface.
+type fakeTaskStore struct {
+	store.TaskStore
+	details   map[int64]*store.TaskDetail
+	viewers   []int64
+	tasks     map[int64]*store.Task
+	published []int64
+	created   []*store.Task
+}
+
+func (f *fakeTaskStore) CreateTask(task *store.Task) (*store.Task, error) {
+	task.ID = len(f.created) + 1
+	f.created = append(f.created, task)
+	return task, nil
+}
+
+// CreateTasks fails every task that links the action 404, like the store
+// does for actions that do not exist.
+func (f *fakeTaskStore) CreateTasks(tasks []*store.Task, dryRun bool) ([]*store.Task, error) {
+	var failed store.TaskBatchErrors
+	for i, task := range tasks {
+		for _, id := range task.ActionIDs {
+			if id == 404 {
+				failed = append(failed, &store.TaskBatchError{Index: i, Err: store.ErrActionNotFound})
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed
+	}
+	if dryRun {
+		return tasks, nil
+	}
+	for _, task := range tasks {
+		f.CreateTask(task)
+	}
+	return tasks, nil
+}
+
+func (f *fakeTaskStore) GetTaskByID(id int64) (*store.Task, error) {
+	return f.tasks[id], nil
+}
+
+func (f *fakeTaskStore) PublishTask(id int64) error {
+	if f.tasks[id].Status != store.TaskStatusDraft {
+		return store.ErrTaskNotDraft
+	}
+	f.published = append(f.published, id)
+	f.tasks[id].Status = store.TaskStatusActive
+	return nil
+}
+
+func (f *fakeTaskStore) CloneTask(id int64) (*store.Task, error) {
+	clone := *f.tasks[id]
+	clone.ID = len(f.tasks) + 100
+	clone.Status = store.TaskStatusDraft
+	clone.PublishAt = nil
+	return &clone, nil
+}
+
+func (f *fakeTaskStore) GetTaskDetail(id, viewerID int64) (*store.TaskDetail, error) {
+	f.viewers = append(f.viewers, viewerID)
+	return f.details[id], nil
+}
+
+type fakeFeedStore struct {
+	store.FeedStore
+	views map[int64][]int64
+}
+
+func (f *fakeFeedStore) RecordTaskView(userID, taskID int64) error {
+	f.views[userID] = append(f.views[userID], taskID)
+	return nil
+}
+
+func serveTaskDetail(t *testing.T, th *TaskHandler, id string, user *store.User) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	r := chi.NewRouter()
+	r.Get("/tasks/{id}", func(w http.ResponseWriter, req *http.Request) {
+		th.HandleGetTaskByID(w, middleware.SetUser(req, user))
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+id, nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec, body
+}
+
+func TestHandleGetTaskByID(t *testing.T) {
+	taskStore := &fakeTaskStore{details: map[int64]*store.TaskDetail{
+		7: {
+			Task:             store.Task{ID: 7, Title: "Follow us", UserID: 1},
+			Creator:          store.TaskCreator{ID: 1, Username: "creator"},
+			ParticipantCount: 3,
+			MyParticipation:  &store.Participation{ID: 11, TaskID: 7, UserID: 2, Status: store.ParticipationJoined},
+		},
+	}}
+	feedStore := &fakeFeedStore{views: map[int64][]int64{}}
+	analyticsStore := &fakeAnalyticsStore{}
+	eventWriter := events.NewWriter(analyticsStore, "test", 10, time.Hour, time.Now, log.New(io.Discard, "", 0))
+	th := NewTaskHandler(taskStore, feedStore, &fakeOrganizationStore{}, nil, eventWriter, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	t.Run("returns the detail for a signed in viewer", func(t *testing.T) {
+		rec, body := serveTaskDetail(t, th, "7", &store.User{ID: 2})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		task := body["data"].(map[string]any)["task"].(map[string]any)
+		assert.Equal(t, "Follow us", task["title"])
+		assert.Equal(t, "creator", task["creator"].(map[string]any)["username"])
+		assert.Equal(t, float64(3), task["participant_count"])
+		assert.Equal(t, "joined", task["my_participation"].(map[string]any)["status"])
+		assert.Equal(t, int64(2), taskStore.viewers[len(taskStore.viewers)-1])
+		assert.Equal(t, []int64{7}, feedStore.views[2])
+	})
+
+	t.Run("anonymous viewers are passed as zero", func(t *testing.T) {
+		rec, _ := serveTaskDetail(t, th, "7", store.AnonymousUser)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(0), taskStore.viewers[len(taskStore.viewers)-1])
+		assert.Empty(t, feedStore.views[0])
+	})
+
+	t.Run("views are recorded as events", func(t *testing.T) {
+		eventWriter.Flush()
+		analyticsStore.events = nil
+		serveTaskDetail(t, th, "7", &store.User{ID: 2})
+
+		req := httptest.NewRequest(http.MethodGet, "/tasks/7", nil)
+		req.Header.Set("X-Session-ID", "browser-session")
+		r := chi.NewRouter()
+		r.Get("/tasks/{id}", func(w http.ResponseWriter, req *http.Request) {
+			th.HandleGetTaskByID(w, middleware.SetUser(req, store.AnonymousUser))
+		})
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		eventWriter.Flush()
+		require.Len(t, analyticsStore.events, 2)
+		assert.Equal(t, store.TaskEvent{TaskID: 7, UserID: 2, Type: store.TaskEventView}, withoutTime(analyticsStore.events[0]))
+		assert.Equal(t, store.TaskEvent{TaskID: 7, SessionID: eventWriter.Anonymize("browser-session"), Type: store.TaskEventView}, withoutTime(analyticsStore.events[1]))
+	})
+
+	t.Run("drafts are only visible to their owner", func(t *testing.T) {
+		taskStore.details[9] = &store.TaskDetail{Task: store.Task{ID: 9, UserID: 1, Status: store.TaskStatusDraft}}
+
+		rec, _ := serveTaskDetail(t, th, "9", &store.User{ID: 2})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec, _ = serveTaskDetail(t, th, "9", store.AnonymousUser)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec, _ = serveTaskDetail(t, th, "9", &store.User{ID: 1})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("missing task is not found", func(t *testing.T) {
+		rec, _ := serveTaskDetail(t, th, "8", store.AnonymousUser)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid id is a bad request", func(t *testing.T) {
+		rec, _ := serveTaskDetail(t, th, "abc", store.AnonymousUser)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestReadTaskFilter(t *testing.T) {
+	th := NewTaskHandler(&fakeTaskStore{}, &fakeFeedStore{}, &fakeOrganizationStore{}, nil, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	t.Run("defaults", func(t *testing.T) {
+		filter, err := th.readTaskFilter(httptest.NewRequest(http.MethodGet, "/tasks", nil))
+		require.NoError(t, err)
+		assert.Equal(t, store.TaskSortNewest, filter.Sort)
+	})
+
+	t.Run("search sorts by relevance", func(t *testing.T) {
+		filter, err := th.readTaskFilter(httptest.NewRequest(http.MethodGet, "/tasks?q=discord", nil))
+		require.NoError(t, err)
+		assert.Equal(t, store.TaskSortRelevance, filter.Sort)
+	})
+
+	t.Run("all filters", func(t *testing.T) {
+		url := "/tasks?status=ACTIVE&action_type=type_1&reward_type=crypto_usdt_1&creator_id=4" +
+			"&min_reward=1.5&max_reward=10&due_before=2025-11-01T00:00:00Z&due_after=2025-10-01T00:00:00Z&sort=due_soon"
+		filter, err := th.readTaskFilter(httptest.NewRequest(http.MethodGet, url, nil))
+		require.NoError(t, err)
+		assert.Equal(t, store.TaskStatusActive, filter.Status)
+		assert.Equal(t, store.Type1, filter.ActionType)
+		assert.Equal(t, store.CryptoUsdt1, filter.RewardType)
+		assert.Equal(t, int64(4), filter.CreatorID)
+		assert.Equal(t, 1.5, *filter.MinReward)
+		assert.Equal(t, 10.0, *filter.MaxReward)
+		assert.Equal(t, 2025, filter.DueBefore.Year())
+		assert.Equal(t, time.October, filter.DueAfter.Month())
+		assert.Equal(t, store.TaskSortDueSoon, filter.Sort)
+	})
+
+	t.Run("drafts can be listed", func(t *testing.T) {
+		filter, err := th.readTaskFilter(httptest.NewRequest(http.MethodGet, "/tasks?status=DRAFT", nil))
+		require.NoError(t, err)
+		assert.Equal(t, store.TaskStatusDraft, filter.Status)
+	})
+
+	invalid := []string{
+		"/tasks?status=DONE",
+		"/tasks?action_type=like",
+		"/tasks?sort=random",
+		"/tasks?sort=relevance",
+		"/tasks?min_reward=5&max_reward=1",
+		"/tasks?min_reward=-1",
+		"/tasks?due_before=tomorrow",
+		"/tasks?creator_id=abc",
+	}
+	for _, url := range invalid {
+		t.Run(url, func(t *testing.T) {
+			_, err := th.readTaskFilter(httptest.NewRequest(http.MethodGet, url, nil))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func serveTaskAction(t *testing.T, handler http.HandlerFunc, path string, user *store.User) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	r := chi.NewRouter()
+	r.Post("/tasks/{id}/*", func(w http.ResponseWriter, req *http.Request) {
+		handler(w, middleware.SetUser(req, user))
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec, body
+}
+
+func TestHandlePublishTask(t *testing.T) {
+	orgID := int64(4)
+	taskStore := &fakeTaskStore{tasks: map[int64]*store.Task{
+		7: {ID: 7, UserID: 1, Status: store.TaskStatusDraft},
+		8: {ID: 8, UserID: 1, Status: store.TaskStatusActive},
+		9: {ID: 9, UserID: 1, OrganizationID: &orgID, Status: store.TaskStatusDraft},
+	}}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {2: store.OrgRoleManager, 3: store.OrgRoleReviewer},
+	}}
+	th := NewTaskHandler(taskStore, &fakeFeedStore{}, orgStore, nil, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	t.Run("someone else's draft is not found", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandlePublishTask, "/tasks/7/publish", &store.User{ID: 2})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, taskStore.published)
+	})
+
+	t.Run("the owner publishes a draft", func(t *testing.T) {
+		rec, body := serveTaskAction(t, th.HandlePublishTask, "/tasks/7/publish", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []int64{7}, taskStore.published)
+		assert.Equal(t, "ACTIVE", body["data"].(map[string]any)["task"].(map[string]any)["status"])
+	})
+
+	t.Run("published tasks conflict", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandlePublishTask, "/tasks/8/publish", &store.User{ID: 1})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("only the owner can publish", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandlePublishTask, "/tasks/8/publish", &store.User{ID: 2})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("organization drafts need a manager", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandlePublishTask, "/tasks/9/publish", &store.User{ID: 1})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec, _ = serveTaskAction(t, th.HandlePublishTask, "/tasks/9/publish", &store.User{ID: 3})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec, _ = serveTaskAction(t, th.HandlePublishTask, "/tasks/9/publish", &store.User{ID: 2})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []int64{7, 9}, taskStore.published)
+	})
+}
+
+func TestHandleCloneTask(t *testing.T) {
+	publishAt := time.Date(2025, time.November, 1, 9, 0, 0, 0, time.UTC)
+	taskStore := &fakeTaskStore{tasks: map[int64]*store.Task{
+		7: {ID: 7, UserID: 1, Title: "Follow us", Status: store.TaskStatusActive, PublishAt: &publishAt},
+	}}
+	th := NewTaskHandler(taskStore, &fakeFeedStore{}, &fakeOrganizationStore{}, nil, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	t.Run("the owner gets an unscheduled draft", func(t *testing.T) {
+		rec, body := serveTaskAction(t, th.HandleCloneTask, "/tasks/7/clone", &store.User{ID: 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		task := body["data"].(map[string]any)["task"].(map[string]any)
+		assert.Equal(t, "Follow us", task["title"])
+		assert.Equal(t, "DRAFT", task["status"])
+		assert.NotContains(t, task, "publish_at")
+	})
+
+	t.Run("other users can not clone", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandleCloneTask, "/tasks/7/clone", &store.User{ID: 2})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("missing task is not found", func(t *testing.T) {
+		rec, _ := serveTaskAction(t, th.HandleCloneTask, "/tasks/9/clone", &store.User{ID: 1})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestHandleImportTasks(t *testing.T) {
+	orgID := int64(4)
+	taskStore := &fakeTaskStore{}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {1: store.OrgRoleManager, 2: store.OrgRoleReviewer},
+	}}
+	th := NewTaskHandler(taskStore, &fakeFeedStore{}, orgStore, nil, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	serve := func(query, contentType, body string, user *store.User) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/tasks/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		th.HandleImportTasks(rec, middleware.SetUser(req, user))
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec, resp
+	}
+
+	csvFile := "title,reward_usdt,organization_id\nFollow us,2,\nRepost,3,4\n"
+
+	t.Run("a dry run creates nothing", func(t *testing.T) {
+		rec, resp := serve("?dry_run=true", "text/csv", csvFile, &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		data := resp["data"].(map[string]any)
+		assert.Equal(t, true, data["dry_run"])
+		assert.Equal(t, 2.0, data["count"])
+		assert.Empty(t, taskStore.created)
+	})
+
+	t.Run("every task is created for the caller", func(t *testing.T) {
+		rec, _ := serve("", "text/csv", csvFile, &store.User{ID: 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		require.Len(t, taskStore.created, 2)
+		assert.Equal(t, int64(1), taskStore.created[1].UserID)
+		assert.Equal(t, orgID, *taskStore.created[1].OrganizationID)
+		assert.Empty(t, taskStore.created[0].Status)
+	})
+
+	t.Run("drafts", func(t *testing.T) {
+		taskStore.created = nil
+		rec, _ := serve("?draft=true", "application/json", `[{"title": "Follow us"}]`, &store.User{ID: 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, store.TaskStatusDraft, taskStore.created[0].Status)
+	})
+
+	t.Run("multipart files", func(t *testing.T) {
+		taskStore.created = nil
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "tasks.csv")
+		require.NoError(t, err)
+		fw.Write([]byte(csvFile))
+		require.NoError(t, mw.Close())
+
+		rec, _ := serve("", mw.FormDataContentType(), body.String(), &store.User{ID: 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Len(t, taskStore.created, 2)
+	})
+
+	t.Run("every row's mistakes are reported", func(t *testing.T) {
+		taskStore.created = nil
+		file := "title,reward_usdt,recurrence,organization_id\n,2,,\nFollow us,x,hourly,\nRepost,1,,4\n"
+		rec, resp := serve("", "text/csv", file, &store.User{ID: 2})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, taskStore.created)
+
+		assert.Equal(t, []any{
+			"row 1: title is required",
+			"row 2: reward_usdt must be a number",
+			"row 2: recurrence must be one of none, daily or weekly",
+			"row 3: only managers of the organization can create its tasks",
+		}, resp["errors"])
+		rows := resp["data"].(map[string]any)["rows"].([]any)
+		assert.Len(t, rows, 3)
+	})
+
+	t.Run("links that do not exist fail their row", func(t *testing.T) {
+		created := len(taskStore.created)
+		rec, resp := serve("", "application/json", `[{"title": "a"}, {"title": "b", "action_ids": [404]}, {"title": "c", "action_ids": [404]}]`, &store.User{ID: 1})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []any{"row 2: action not found", "row 3: action not found"}, resp["errors"])
+
+		rec, resp = serve("?dry_run=true", "application/json", `[{"title": "a", "action_ids": [404]}]`, &store.User{ID: 1})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []any{"row 1: action not found"}, resp["errors"])
+
+		rec, resp = serve("", "application/json", `[{"title": ""}, {"title": "b", "action_ids": [404]}]`, &store.User{ID: 1})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []any{"row 1: title is required", "row 2: action not found"}, resp["errors"])
+		assert.Len(t, taskStore.created, created)
+	})
+
+	t.Run("unreadable files", func(t *testing.T) {
+		rec, _ := serve("", "text/plain", "title\nx\n", &store.User{ID: 1})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = serve("", "text/csv", "title\n", &store.User{ID: 1})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = serve("?dry_run=maybe", "text/csv", csvFile, &store.User{ID: 1})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+type fakeAnalyticsStore struct {
+	store.AnalyticsStore
+	filters []store.AnalyticsFilter
+	events  []store.TaskEvent
+}
+
+func (f *fakeAnalyticsStore) InsertTaskEvents(events []store.TaskEvent) (int64, error) {
+	f.events = append(f.events, events...)
+	return int64(len(events)), nil
+}
+
+func withoutTime(e store.TaskEvent) store.TaskEvent {
+	e.OccurredAt = time.Time{}
+	return e
+}
+
+func (f *fakeAnalyticsStore) GetTaskAnalytics(filter store.AnalyticsFilter) (*store.TaskAnalytics, error) {
+	if !filter.From.IsZero() && filter.From.After(filter.To) {
+		return nil, store.ErrAnalyticsRange
+	}
+	f.filters = append(f.filters, filter)
+	return &store.TaskAnalytics{}, nil
+}
+
+func TestHandleGetTaskAnalytics(t *testing.T) {
+	orgID := int64(4)
+	taskStore := &fakeTaskStore{tasks: map[int64]*store.Task{
+		7: {ID: 7, UserID: 1, Status: store.TaskStatusActive},
+		9: {ID: 9, UserID: 1, OrganizationID: &orgID, Status: store.TaskStatusActive},
+	}}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {3: store.OrgRoleViewer},
+	}}
+	analyticsStore := &fakeAnalyticsStore{}
+	th := NewTaskHandler(taskStore, &fakeFeedStore{}, orgStore, analyticsStore, nil, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	serve := func(path string, user *store.User) int {
+		r := chi.NewRouter()
+		r.Get("/tasks/{id}/analytics", func(w http.ResponseWriter, req *http.Request) {
+			th.HandleGetTaskAnalytics(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name string
+		path string
+		user *store.User
+		want int
+	}{
+		{"owner", "/tasks/7/analytics?from=2025-11-01&to=2025-11-30", &store.User{ID: 1}, http.StatusOK},
+		{"other user", "/tasks/7/analytics", &store.User{ID: 2}, http.StatusForbidden},
+		{"organization viewer", "/tasks/9/analytics", &store.User{ID: 3}, http.StatusOK},
+		{"creator outside the organization", "/tasks/9/analytics", &store.User{ID: 1}, http.StatusForbidden},
+		{"invalid date", "/tasks/7/analytics?from=11/01/2025", &store.User{ID: 1}, http.StatusBadRequest},
+		{"from after to", "/tasks/7/analytics?from=2025-12-01&to=2025-11-01", &store.User{ID: 1}, http.StatusBadRequest},
+		{"missing task", "/tasks/8/analytics", &store.User{ID: 1}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.path, tt.user))
+		})
+	}
+
+	require.Len(t, analyticsStore.filters, 2)
+	assert.Equal(t, int64(7), analyticsStore.filters[0].TaskID)
+	assert.Equal(t, time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC), analyticsStore.filters[0].From)
+	assert.Equal(t, 30, analyticsStore.filters[0].To.Day())
+	assert.True(t, analyticsStore.filters[1].From.IsZero())
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+var validTemplateScopes = map[store.TemplateScope]bool{
+	store.TemplateScopeAll:    true,
+	store.TemplateScopeSystem: true,
+	store.TemplateScopeMine:   true,
+}
+
+// createFromTemplateRequest holds the variable values and the fields that
+// differ for every task made from the template.
+type createFromTemplateRequest struct {
+	Variables      map[string]string `json:"variables"`
+	DueDate        time.Time         `json:"due_date"`
+	TaskImage      string            `json:"task_image"`
+	RewardUSDT     *float64          `json:"reward_usdt"`
+	MaxParticipant string            `json:"max_participant"`
+	Status         store.TaskStatus  `json:"status"`
+	PublishAt      *time.Time        `json:"publish_at"`
+}
+
+type TemplateHandler struct {
+	templateStore store.TaskTemplateStore
+	taskStore     store.TaskStore
+	cursors       *utils.CursorCodec
+	logger        *log.Logger
+}
+
+func NewTemplateHandler(templateStore store.TaskTemplateStore, taskStore store.TaskStore, cursors *utils.CursorCodec, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore: templateStore,
+		taskStore:     taskStore,
+		cursors:       cursors,
+		logger:        logger,
+	}
+}
+
+// isTemplateLinkError reports whether err comes from an action or reward the
+// template links to that does not exist or is of an unknown kind.
+func isTemplateLinkError(err error) bool {
+	return errors.Is(err, store.ErrTemplateActionKind) || isTaskLinkError(err)
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var template store.TaskTemplate
+	err := json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		th.logger.Printf("ERROR: decodingCreateTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	// system templates are offered to everyone, only admins curate them
+	if template.System && !user.IsAdmin() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return
+	}
+	template.UserID = nil
+	if !template.System {
+		template.UserID = &user.ID
+	}
+
+	err = template.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	created, err := th.templateStore.CreateTemplate(&template)
+	if isTemplateLinkError(err) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: createTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTemplateCreated, http.StatusCreated, utils.Envelope{"template": created}, nil)
+}
+
+// HandleGetTemplates lists the system templates and the caller's own.
+// scope=system or scope=mine narrows it down to one of them.
+func (th *TemplateHandler) HandleGetTemplates(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	scope := store.TemplateScope(r.URL.Query().Get("scope"))
+	if !validTemplateScopes[scope] {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"scope must be system or mine"})
+		return
+	}
+
+	page, err := th.cursors.ReadPageParams(r, "id")
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	templates, bounds, err := th.templateStore.GetTemplates(user.ID, scope, page)
+	if err != nil {
+		th.logger.Printf("ERROR: getTemplates: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTemplatesFetched, http.StatusOK, th.cursors.PageEnvelope(utils.Envelope{"templates": templates}, page, bounds), nil)
+}
+
+// loadTemplate reads the template in the URL. Other creators' templates are
+// not found, and with write set system templates are only for admins. It
+// writes the error response itself and returns nil when the request can not
+// proceed.
+func (th *TemplateHandler) loadTemplate(w http.ResponseWriter, r *http.Request, write bool) *store.TaskTemplate {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	template, err := th.templateStore.GetTemplateByID(id)
+	if err != nil {
+		th.logger.Printf("ERROR: getTemplateByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if template == nil || (!template.System && *template.UserID != user.ID) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+	if write && template.System && !user.IsAdmin() {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+
+	return template
+}
+
+func (th *TemplateHandler) HandleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	template := th.loadTemplate(w, r, false)
+	if template == nil {
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTemplateRetrieved, http.StatusOK, utils.Envelope{"template": template}, nil)
+}
+
+// HandleUpdateTemplate changes the fields present in the body and keeps the
+// rest.
+func (th *TemplateHandler) HandleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	template := th.loadTemplate(w, r, true)
+	if template == nil {
+		return
+	}
+	id, userID := template.ID, template.UserID
+
+	err := json.NewDecoder(r.Body).Decode(template)
+	if err != nil {
+		th.logger.Printf("ERROR: decodingUpdateTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+	// the template can not move to another owner or in and out of the system set
+	template.ID, template.UserID = id, userID
+
+	err = template.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	err = th.templateStore.UpdateTemplate(template)
+	if isTemplateLinkError(err) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: updateTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTemplateUpdated, http.StatusOK, utils.Envelope{"template": template}, nil)
+}
+
+func (th *TemplateHandler) HandleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template := th.loadTemplate(w, r, true)
+	if template == nil {
+		return
+	}
+
+	err := th.templateStore.DeleteTemplate(template.ID)
+	if err != nil {
+		th.logger.Printf("ERROR: deleteTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTemplateDeleted, http.StatusOK, nil, nil)
+}
+
+// HandleCreateTaskFromTemplate fills in the template's variables and creates
+// the task for the caller.
+func (th *TemplateHandler) HandleCreateTaskFromTemplate(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	template := th.loadTemplate(w, r, false)
+	if template == nil {
+		return
+	}
+
+	var req createFromTemplateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.logger.Printf("ERROR: decodingCreateFromTemplate: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	task, err := template.Render(req.Variables)
+	if err == nil && req.RewardUSDT != nil && *req.RewardUSDT < 0 {
+		err = errors.New("reward_usdt must not be negative")
+	}
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	task.UserID = user.ID
+	task.DueDate = req.DueDate
+	task.TaskImage = req.TaskImage
+	task.Status = req.Status
+	task.PublishAt = req.PublishAt
+	if req.RewardUSDT != nil {
+		task.RewardUSDT = *req.RewardUSDT
+	}
+	if req.MaxParticipant != "" {
+		task.MaxParticipant = req.MaxParticipant
+	}
+
+	// the same rules as a task created by hand
+	err = validateTask(task)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	created, err := th.taskStore.CreateTask(task)
+	if isTaskLinkError(err) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: createTask: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTaskCreated, http.StatusCreated, utils.Envelope{"task": created}, nil)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTemplateStore struct {
+	store.TaskTemplateStore
+	templates map[int64]*store.TaskTemplate
+	created   []*store.TaskTemplate
+}
+
+func (f *fakeTemplateStore) GetTemplateByID(id int64) (*store.TaskTemplate, error) {
+	t, ok := f.templates[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (f *fakeTemplateStore) CreateTemplate(t *store.TaskTemplate) (*store.TaskTemplate, error) {
+	f.created = append(f.created, t)
+	return t, nil
+}
+
+func TestHandleCreateTaskFromTemplate(t *testing.T) {
+	ownerID := int64(1)
+	templateStore := &fakeTemplateStore{templates: map[int64]*store.TaskTemplate{
+		3: {ID: 3, System: true, Name: "Follow", Title: "Follow @{{handle}}", ActionIDs: store.TemplateLinks{4}, RewardUSDT: 2},
+		5: {ID: 5, UserID: &ownerID, Name: "Mine", Title: "Repost {{tweet_url}}"},
+	}}
+	taskStore := &fakeTaskStore{}
+	th := NewTemplateHandler(templateStore, taskStore, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	create := func(user *store.User, id, body string) (*httptest.ResponseRecorder, map[string]any) {
+		r := chi.NewRouter()
+		r.Post("/templates/{id}/tasks", func(w http.ResponseWriter, req *http.Request) {
+			th.HandleCreateTaskFromTemplate(w, middleware.SetUser(req, user))
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/"+id+"/tasks", strings.NewReader(body)))
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec, resp
+	}
+
+	t.Run("system templates are filled in for any creator", func(t *testing.T) {
+		rec, body := create(&store.User{ID: 2}, "3", `{"variables": {"handle": "@socialtask"}, "reward_usdt": 10, "status": "DRAFT"}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		task := body["data"].(map[string]any)["task"].(map[string]any)
+		assert.Equal(t, "Follow @socialtask", task["title"])
+		assert.Equal(t, float64(10), task["reward_usdt"])
+
+		created := taskStore.created[len(taskStore.created)-1]
+		assert.Equal(t, int64(2), created.UserID)
+		assert.Equal(t, []int{4}, created.ActionIDs)
+		assert.Equal(t, store.TaskStatusDraft, created.Status)
+	})
+
+	t.Run("missing variables fail validation", func(t *testing.T) {
+		rec, body := create(&store.User{ID: 2}, "3", `{"variables": {}}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []any{"variables is missing handle"}, body["errors"])
+	})
+
+	t.Run("created tasks pass the task validation", func(t *testing.T) {
+		rec, body := create(&store.User{ID: 2}, "3", `{"variables": {"handle": "@socialtask"}, "publish_at": "2020-01-01T00:00:00Z"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []any{"publish_at must be in the future"}, body["errors"])
+	})
+
+	t.Run("saved templates are private to their creator", func(t *testing.T) {
+		rec, _ := create(&store.User{ID: 2}, "5", `{"variables": {"tweet_url": "https://x.com/a/status/1"}}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec, _ = create(&store.User{ID: 1}, "5", `{"variables": {"tweet_url": "https://x.com/a/status/1"}}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
+
+func TestHandleCreateTemplate(t *testing.T) {
+	templateStore := &fakeTemplateStore{}
+	th := NewTemplateHandler(templateStore, &fakeTaskStore{}, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	create := func(user *store.User, body string) int {
+		rec := httptest.NewRecorder()
+		th.HandleCreateTemplate(rec, middleware.SetUser(httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(body)), user))
+		return rec.Code
+	}
+
+	t.Run("creators save their own templates", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, create(&store.User{ID: 2}, `{"name": "Mine", "title": "Follow @{{handle}}"}`))
+		saved := templateStore.created[len(templateStore.created)-1]
+		require.NotNil(t, saved.UserID)
+		assert.Equal(t, int64(2), *saved.UserID)
+	})
+
+	t.Run("only admins add system templates", func(t *testing.T) {
+		const body = `{"name": "Follow", "title": "Follow @{{handle}}", "system": true}`
+		assert.Equal(t, http.StatusForbidden, create(&store.User{ID: 2}, body))
+
+		require.Equal(t, http.StatusCreated, create(&store.User{ID: 1, Role: store.UserRoleAdmin}, body))
+		assert.Nil(t, templateStore.created[len(templateStore.created)-1].UserID)
+	})
+
+	t.Run("malformed placeholders fail validation", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, create(&store.User{ID: 2}, `{"name": "Mine", "title": "Follow @{{handle"}`))
+	})
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/media"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// multipartOverhead leaves room for the form's boundaries and headers on top
+// of the file itself.
+const multipartOverhead = 1 << 20
+
+type UploadHandler struct {
+	assetStore store.AssetStore
+	blobs      blob.BlobStore
+	logger     *log.Logger
+}
+
+func NewUploadHandler(assetStore store.AssetStore, blobs blob.BlobStore, logger *log.Logger) *UploadHandler {
+	return &UploadHandler{
+		assetStore: assetStore,
+		blobs:      blobs,
+		logger:     logger,
+	}
+}
+
+// readUploadedFile returns the contents of the form's "file" field, at most
+// media.MaxUploadBytes of it.
+func readUploadedFile(w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+multipartOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("request must be multipart/form-data")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, http.StatusBadRequest, errors.New("file is required")
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("file must be at most 10 MB")
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("request is not a valid multipart form")
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, media.MaxUploadBytes+1))
+		part.Close()
+		if errors.As(err, &maxBytesErr) || len(data) > media.MaxUploadBytes {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("file must be at most 10 MB")
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("request is not a valid multipart form")
+		}
+		return data, 0, nil
+	}
+}
+
+func (uh *UploadHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	data, status, err := readUploadedFile(w, r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, status, nil, []string{err.Error()})
+		return
+	}
+
+	img, err := media.Process(data)
+	switch err {
+	case nil:
+	case media.ErrUnsupportedType:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusUnsupportedMediaType, nil, []string{err.Error()})
+		return
+	case media.ErrInvalidImage, media.ErrTooManyPixels:
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusUnprocessableEntity, nil, []string{err.Error()})
+		return
+	default:
+		uh.logger.Printf("ERROR: processImage: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	id, err := utils.GenerateSecureRandomString(16)
+	if err != nil {
+		uh.logger.Printf("ERROR: generateAssetID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	asset := &store.Asset{
+		ID:           id,
+		UserID:       user.ID,
+		ContentType:  img.ContentType,
+		SizeBytes:    int64(len(img.Data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		BlobKey:      "assets/" + id + "/original",
+		ThumbnailKey: "assets/" + id + "/thumbnail",
+	}
+
+	ctx := r.Context()
+	err = uh.blobs.Put(ctx, asset.BlobKey, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType)
+	if err == nil {
+		err = uh.blobs.Put(ctx, asset.ThumbnailKey, bytes.NewReader(img.Thumbnail), int64(len(img.Thumbnail)), img.ContentType)
+	}
+	var created *store.Asset
+	if err == nil {
+		created, err = uh.assetStore.CreateAsset(asset)
+	}
+	if err != nil {
+		uh.logger.Printf("ERROR: storeUpload: %v", err)
+		uh.removeBlobs(asset.BlobKey, asset.ThumbnailKey)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageUploadCreated, http.StatusCreated, utils.Envelope{"asset": created}, nil)
+}
+
+// removeBlobs cleans up after a failed upload. It does not use the request's
+// context, which may be the reason the upload failed.
+func (uh *UploadHandler) removeBlobs(keys ...string) {
+	for _, key := range keys {
+		if err := uh.blobs.Delete(context.Background(), key); err != nil {
+			uh.logger.Printf("ERROR: deleteBlob: %v", err)
+		}
+	}
+}
+
+func (uh *UploadHandler) serveAsset(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	asset, err := uh.assetStore.GetAssetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		uh.logger.Printf("ERROR: getAssetByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if asset == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	key := asset.BlobKey
+	if thumbnail {
+		key = asset.ThumbnailKey
+	}
+
+	body, contentType, err := uh.blobs.Get(r.Context(), key)
+	if err == blob.ErrNotFound {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	if err != nil {
+		uh.logger.Printf("ERROR: getBlob: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	defer body.Close()
+
+	// assets never change once uploaded
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		uh.logger.Printf("ERROR: serveAsset: %v", err)
+	}
+}
+
+func (uh *UploadHandler) HandleGetUpload(w http.ResponseWriter, r *http.Request) {
+	uh.serveAsset(w, r, false)
+}
+
+func (uh *UploadHandler) HandleGetUploadThumbnail(w http.ResponseWriter, r *http.Request) {
+	uh.serveAsset(w, r, true)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/media"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAssetStore struct {
+	assets map[string]*store.Asset
+}
+
+func (f *fakeAssetStore) CreateAsset(asset *store.Asset) (*store.Asset, error) {
+	f.assets[asset.ID] = asset
+	return asset, nil
+}
+
+func (f *fakeAssetStore) GetAssetByID(id string) (*store.Asset, error) {
+	return f.assets[id], nil
+}
+
+func newUploadRouter(t *testing.T) (*chi.Mux, *fakeAssetStore) {
+	t.Helper()
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	assets := &fakeAssetStore{assets: map[string]*store.Asset{}}
+	uh := NewUploadHandler(assets, blobs, log.New(io.Discard, "", 0))
+
+	r := chi.NewRouter()
+	r.Post("/uploads", func(w http.ResponseWriter, req *http.Request) {
+		uh.HandleUpload(w, middleware.SetUser(req, &store.User{ID: 3}))
+	})
+	r.Get("/uploads/{id}", uh.HandleGetUpload)
+	r.Get("/uploads/{id}/thumbnail", uh.HandleGetUploadThumbnail)
+	return r, assets
+}
+
+func multipartFile(t *testing.T, field string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, "image.png")
+	require.NoError(t, err)
+	fw.Write(data)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestHandleUpload(t *testing.T) {
+	router, assets := newUploadRouter(t)
+
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 800, 400))))
+
+	t.Run("stores the image and its thumbnail", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, multipartFile(t, "file", img.Bytes()))
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var body struct {
+			Data struct {
+				Asset store.Asset `json:"asset"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		asset := body.Data.Asset
+		assert.Equal(t, int64(3), asset.UserID)
+		assert.Equal(t, "image/png", asset.ContentType)
+		assert.Equal(t, 800, asset.Width)
+		require.Contains(t, assets.assets, asset.ID)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/"+asset.ID, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/"+asset.ID+"/thumbnail", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		thumb, err := png.Decode(rec.Body)
+		require.NoError(t, err)
+		assert.Equal(t, media.ThumbnailSize, thumb.Bounds().Dx())
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			req    *http.Request
+			status int
+		}{
+			{"non image", multipartFile(t, "file", []byte("just text")), http.StatusUnsupportedMediaType},
+			{"missing file", multipartFile(t, "other", img.Bytes()), http.StatusBadRequest},
+			{"too large", multipartFile(t, "file", make([]byte, media.MaxUploadBytes+1)), http.StatusRequestEntityTooLarge},
+			{"not multipart", httptest.NewRequest(http.MethodPost, "/uploads", bytes.NewReader(img.Bytes())), http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, tt.req)
+				assert.Equal(t, tt.status, rec.Code)
+			})
+		}
+	})
+
+	t.Run("unknown asset", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/missing", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -30,17 +30,23 @@ type googleUserInfo struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+	// VerifiedEmail is Google's confirmation that the user owns Email
+	VerifiedEmail bool `json:"verified_email"`
 }
 
 type UserHandler struct {
-	userStore store.UserStore
-	logger    *log.Logger
+	userStore  store.UserStore
+	badgeStore store.BadgeStore
+	cursors    *utils.CursorCodec
+	logger     *log.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, badgeStore store.BadgeStore, cursors *utils.CursorCodec, logger *log.Logger) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
-		logger:    logger,
+		userStore:  userStore,
+		badgeStore: badgeStore,
+		cursors:    cursors,
+		logger:     logger,
 	}
 }
 
@@ -146,7 +152,14 @@ func (uh *UserHandler) HandleGetCurrentUser(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageUserRetrieved, http.StatusOK, utils.Envelope{"user": user}, nil)
+	badges, err := uh.badgeStore.GetUserBadges(currentUser.ID)
+	if err != nil {
+		uh.logger.Printf("ERROR: getting user badges: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageUserRetrieved, http.StatusOK, utils.Envelope{"user": user, "badges": badges}, nil)
 }
 
 func (uh *UserHandler) HandleLoginUser(w http.ResponseWriter, r *http.Request) {
@@ -204,12 +217,65 @@ func (uh *UserHandler) HandleGetUserTasks(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tasks, err := uh.userStore.GetUserTasks(id)
+	page, err := uh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	tasks, bounds, err := uh.userStore.GetUserTasks(id, page)
 	if err != nil {
 		uh.logger.Printf("ERROR: getting user tasks: %v", err)
 		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
 		return
 	}
 
-	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, utils.Envelope{"tasks": tasks}, nil)
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageTasksFetched, http.StatusOK, uh.cursors.PageEnvelope(utils.Envelope{"tasks": tasks}, page, bounds), nil)
+}
+
+type setUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// HandleSetUserRole lets an admin grant or take away the moderator and
+// admin roles.
+func (uh *UserHandler) HandleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	admin, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		uh.logger.Printf("ERROR: reading id param: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	var req setUserRoleRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		uh.logger.Printf("ERROR: decoding set user role: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	if !store.IsValidUserRole(req.Role) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"role must be one of user, moderator or admin"})
+		return
+	}
+	if id == admin.ID {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{"you can not change your own role"})
+		return
+	}
+
+	err = uh.userStore.SetUserRole(id, req.Role, admin.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+	if err != nil {
+		uh.logger.Printf("ERROR: setting user role: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageUserRoleUpdated, http.StatusOK, utils.Envelope{"user_id": id, "role": req.Role}, nil)
 }
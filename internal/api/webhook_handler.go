@@ -0,0 +1,327 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// webhookRequest holds the fields of a webhook a caller can set, Active is
+// a pointer so that leaving it out keeps the current value.
+type webhookRequest struct {
+	URL            *string                  `json:"url"`
+	Secret         string                   `json:"secret"`
+	EventTypes     *store.WebhookEventTypes `json:"event_types"`
+	Active         *bool                    `json:"active"`
+	OrganizationID *int64                   `json:"organization_id"`
+}
+
+// apply copies the fields present in the request onto w.
+func (req *webhookRequest) apply(w *store.Webhook) {
+	if req.URL != nil {
+		w.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		w.EventTypes = *req.EventTypes
+	}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+	w.Secret = req.Secret
+}
+
+type WebhookHandler struct {
+	webhookStore store.WebhookStore
+	orgStore     store.OrganizationStore
+	cursors      *utils.CursorCodec
+	logger       *log.Logger
+}
+
+func NewWebhookHandler(webhookStore store.WebhookStore, orgStore store.OrganizationStore, cursors *utils.CursorCodec, logger *log.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhookStore: webhookStore,
+		orgStore:     orgStore,
+		cursors:      cursors,
+		logger:       logger,
+	}
+}
+
+// requireOrgManager checks the caller manages the organization, for the
+// webhooks of an organization. It writes the error response itself and
+// returns false when the request can not proceed.
+func (wh *WebhookHandler) requireOrgManager(w http.ResponseWriter, orgID, userID int64) bool {
+	role, err := wh.orgStore.GetMemberRole(orgID, userID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getMemberRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return false
+	}
+	if !role.Can(store.OrgRoleManager) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, []string{"only managers of the organization can manage its webhooks"})
+		return false
+	}
+	return true
+}
+
+// HandleCreateWebhook subscribes a URL to the events of the caller's tasks,
+// or of an organization's tasks. The secret is generated when the body does
+// not set one, the response is the only place it is shown.
+func (wh *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	var req webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.logger.Printf("ERROR: decodingCreateWebhook: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+
+	webhook := &store.Webhook{UserID: user.ID, OrganizationID: req.OrganizationID, Active: true}
+	req.apply(webhook)
+
+	err = webhook.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	if webhook.OrganizationID != nil && !wh.requireOrgManager(w, *webhook.OrganizationID, user.ID) {
+		return
+	}
+
+	if webhook.Secret == "" {
+		secret, err := utils.GenerateSecureRandomString(24)
+		if err != nil {
+			wh.logger.Printf("ERROR: generateWebhookSecret: %v", err)
+			utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+			return
+		}
+		webhook.Secret = "whsec_" + secret
+	}
+
+	created, err := wh.webhookStore.CreateWebhook(webhook)
+	if err != nil {
+		wh.logger.Printf("ERROR: createWebhook: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageWebhookCreated, http.StatusCreated, utils.Envelope{"webhook": created}, nil)
+}
+
+// HandleGetWebhooks lists the caller's own webhooks, or those of an
+// organization they manage, newest first.
+func (wh *WebhookHandler) HandleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUser(r)
+
+	orgID, err := readOrganizationParam(r)
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	page, err := wh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	if orgID != 0 && !wh.requireOrgManager(w, orgID, user.ID) {
+		return
+	}
+
+	webhooks, bounds, err := wh.webhookStore.GetWebhooks(user.ID, orgID, page)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWebhooks: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageWebhooksFetched, http.StatusOK, wh.cursors.PageEnvelope(utils.Envelope{"webhooks": webhooks}, page, bounds), nil)
+}
+
+// loadWebhook reads the webhook in the URL, only its owner or the managers
+// of its organization can see it. It writes the error response itself and
+// returns nil when the request can not proceed.
+func (wh *WebhookHandler) loadWebhook(w http.ResponseWriter, r *http.Request) *store.Webhook {
+	user, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("ERROR: readIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	webhook, err := wh.webhookStore.GetWebhookByID(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWebhookByID: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if webhook == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	role, err := resourceRole(wh.orgStore, user, webhook.UserID, webhook.OrganizationID)
+	if err != nil {
+		wh.logger.Printf("ERROR: resourceRole: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if !role.Can(store.OrgRoleManager) {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+		return nil
+	}
+
+	return webhook
+}
+
+func (wh *WebhookHandler) HandleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := wh.loadWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageWebhookRetrieved, http.StatusOK, utils.Envelope{"webhook": webhook}, nil)
+}
+
+// HandleUpdateWebhook changes the fields present in the body and keeps the
+// rest. Setting a secret rotates it, deliveries are signed with the new one
+// from then on.
+func (wh *WebhookHandler) HandleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := wh.loadWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	var req webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.logger.Printf("ERROR: decodingUpdateWebhook: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return
+	}
+	req.apply(webhook)
+
+	err = webhook.Validate()
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	err = wh.webhookStore.UpdateWebhook(webhook)
+	if err != nil {
+		wh.logger.Printf("ERROR: updateWebhook: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageWebhookUpdated, http.StatusOK, utils.Envelope{"webhook": webhook}, nil)
+}
+
+func (wh *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := wh.loadWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	err := wh.webhookStore.DeleteWebhook(webhook.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: deleteWebhook: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageWebhookDeleted, http.StatusOK, nil, nil)
+}
+
+// HandleGetWebhookDeliveries lists the webhook's delivery log, newest first.
+func (wh *WebhookHandler) HandleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook := wh.loadWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	page, err := wh.cursors.ReadPageParams(r, string(store.TaskSortNewest))
+	if err != nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageValidationFailed, http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	deliveries, bounds, err := wh.webhookStore.GetWebhookDeliveries(webhook.ID, page)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWebhookDeliveries: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDeliveriesFetched, http.StatusOK, wh.cursors.PageEnvelope(utils.Envelope{"deliveries": deliveries}, page, bounds), nil)
+}
+
+// loadDelivery reads the delivery in the URL, which has to belong to the
+// webhook in the URL. It writes the error response itself and returns nil
+// when the request can not proceed.
+func (wh *WebhookHandler) loadDelivery(w http.ResponseWriter, r *http.Request) *store.WebhookDelivery {
+	webhook := wh.loadWebhook(w, r)
+	if webhook == nil {
+		return nil
+	}
+
+	id, err := utils.ReadNamedIDParam(r, "deliveryId")
+	if err != nil {
+		wh.logger.Printf("ERROR: readDeliveryIdParam: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInvalidRequest, http.StatusBadRequest, nil, nil)
+		return nil
+	}
+
+	delivery, err := wh.webhookStore.GetWebhookDelivery(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWebhookDelivery: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return nil
+	}
+	if delivery == nil || delivery.WebhookID != webhook.ID {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return nil
+	}
+
+	return delivery
+}
+
+func (wh *WebhookHandler) HandleGetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery := wh.loadDelivery(w, r)
+	if delivery == nil {
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDeliveryRetrieved, http.StatusOK, utils.Envelope{"delivery": delivery}, nil)
+}
+
+// HandleRedeliverWebhookDelivery sends the delivery's event again as a new
+// delivery with the same event id, whatever became of the original.
+func (wh *WebhookHandler) HandleRedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery := wh.loadDelivery(w, r)
+	if delivery == nil {
+		return
+	}
+
+	redelivery, err := wh.webhookStore.RedeliverWebhookDelivery(delivery.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: redeliverWebhookDelivery: %v", err)
+		utils.WriteJSON(w, utils.StatusError, utils.MessageInternalError, http.StatusInternalServerError, nil, nil)
+		return
+	}
+	if redelivery == nil {
+		utils.WriteJSON(w, utils.StatusError, utils.MessageNotFound, http.StatusNotFound, nil, nil)
+		return
+	}
+
+	utils.WriteJSON(w, utils.StatusSuccess, utils.MessageDeliveryQueued, http.StatusAccepted, utils.Envelope{"delivery": redelivery}, nil)
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWebhookStore struct {
+	store.WebhookStore
+	webhooks   map[int64]*store.Webhook
+	deliveries map[int64]*store.WebhookDelivery
+}
+
+func (f *fakeWebhookStore) CreateWebhook(w *store.Webhook) (*store.Webhook, error) {
+	w.ID = int64(len(f.webhooks) + 1)
+	f.webhooks[w.ID] = w
+	return w, nil
+}
+
+func (f *fakeWebhookStore) GetWebhookByID(id int64) (*store.Webhook, error) {
+	w, ok := f.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *w
+	copied.Secret = ""
+	return &copied, nil
+}
+
+func (f *fakeWebhookStore) UpdateWebhook(w *store.Webhook) error {
+	if w.Secret == "" {
+		w.Secret = f.webhooks[w.ID].Secret
+	}
+	f.webhooks[w.ID] = w
+	return nil
+}
+
+func (f *fakeWebhookStore) GetWebhookDelivery(id int64) (*store.WebhookDelivery, error) {
+	return f.deliveries[id], nil
+}
+
+func (f *fakeWebhookStore) RedeliverWebhookDelivery(id int64) (*store.WebhookDelivery, error) {
+	original := f.deliveries[id]
+	redelivery := &store.WebhookDelivery{
+		ID:           int64(len(f.deliveries) + 1),
+		WebhookID:    original.WebhookID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		Status:       store.WebhookDeliveryPending,
+		RedeliveryOf: &original.ID,
+	}
+	f.deliveries[redelivery.ID] = redelivery
+	return redelivery, nil
+}
+
+func TestWebhookHandler(t *testing.T) {
+	orgID := int64(4)
+	webhookStore := &fakeWebhookStore{
+		webhooks: map[int64]*store.Webhook{
+			1: {ID: 1, UserID: 1, URL: "https://example.com/hook", Secret: "0123456789abcdef", EventTypes: store.WebhookEventTypes{store.WebhookTaskJoined}, Active: true},
+			2: {ID: 2, UserID: 1, OrganizationID: &orgID, URL: "https://example.com/org", Secret: "0123456789abcdef", EventTypes: store.WebhookEventTypes{store.WebhookRewardCreated}, Active: true},
+		},
+		deliveries: map[int64]*store.WebhookDelivery{
+			1: {ID: 1, WebhookID: 1, EventID: 40, EventType: store.WebhookTaskJoined, Status: store.WebhookDeliveryFailed},
+		},
+	}
+	orgStore := &fakeOrganizationStore{members: map[int64]map[int64]store.OrgRole{
+		orgID: {2: store.OrgRoleManager, 3: store.OrgRoleReviewer},
+	}}
+	wh := NewWebhookHandler(webhookStore, orgStore, utils.NewCursorCodec("test"), log.New(io.Discard, "", 0))
+
+	r := chi.NewRouter()
+	r.Post("/webhooks", wh.HandleCreateWebhook)
+	r.Get("/webhooks/{id}", wh.HandleGetWebhook)
+	r.Put("/webhooks/{id}", wh.HandleUpdateWebhook)
+	r.Get("/webhooks/{id}/deliveries/{deliveryId}", wh.HandleGetWebhookDelivery)
+	r.Post("/webhooks/{id}/deliveries/{deliveryId}/redeliver", wh.HandleRedeliverWebhookDelivery)
+
+	serve := func(method, path, body string, user *store.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, middleware.SetUser(req, user))
+		return rec
+	}
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp.Data
+	}
+
+	t.Run("creates a webhook with a generated secret", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/webhooks", `{"url":"https://example.com/new","event_types":["task.joined","task.completed"]}`, &store.User{ID: 1})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		webhook := decode(t, rec)["webhook"].(map[string]any)
+		assert.True(t, strings.HasPrefix(webhook["secret"].(string), "whsec_"))
+		assert.Equal(t, true, webhook["active"])
+	})
+
+	t.Run("rejects invalid webhooks", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/webhooks", `{"url":"ftp://example.com","event_types":["task.joined"]}`, &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/webhooks", `{"url":"https://example.com","event_types":["task.deleted"]}`, &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/webhooks", `{"url":"https://example.com","event_types":["task.joined"],"secret":"short"}`, &store.User{ID: 1}).Code)
+	})
+
+	t.Run("organization webhooks need a manager", func(t *testing.T) {
+		body := `{"url":"https://example.com/org","event_types":["task.joined"],"organization_id":4}`
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/webhooks", body, &store.User{ID: 3}).Code)
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/webhooks", body, &store.User{ID: 2}).Code)
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/webhooks/2", "", &store.User{ID: 2}).Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/webhooks/2", "", &store.User{ID: 3}).Code)
+	})
+
+	t.Run("only the owner sees a personal webhook", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/webhooks/1", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, decode(t, rec)["webhook"], "secret")
+
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/webhooks/1", "", &store.User{ID: 2}).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/webhooks/99", "", &store.User{ID: 1}).Code)
+	})
+
+	t.Run("updates keep the fields left out", func(t *testing.T) {
+		rec := serve(http.MethodPut, "/webhooks/1", `{"active":false}`, &store.User{ID: 1})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		updated := webhookStore.webhooks[1]
+		assert.False(t, updated.Active)
+		assert.Equal(t, "https://example.com/hook", updated.URL)
+		assert.Equal(t, "0123456789abcdef", updated.Secret)
+
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/webhooks/1", `{"event_types":[]}`, &store.User{ID: 1}).Code)
+	})
+
+	t.Run("redelivers a delivery of the webhook", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/webhooks/1/deliveries/1", "", &store.User{ID: 1}).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/webhooks/2/deliveries/1", "", &store.User{ID: 2}).Code)
+
+		rec := serve(http.MethodPost, "/webhooks/1/deliveries/1/redeliver", "", &store.User{ID: 1})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		delivery := decode(t, rec)["delivery"].(map[string]any)
+		assert.Equal(t, float64(40), delivery["event_id"])
+		assert.Equal(t, float64(1), delivery["redelivery_of"])
+		assert.Equal(t, "pending", delivery["status"])
+	})
+}
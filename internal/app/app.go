@@ -6,27 +6,60 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/harundarat/be-socialtask/internal/achievements"
 	"github.com/harundarat/be-socialtask/internal/api"
 	auth "github.com/harundarat/be-socialtask/internal/auth/google"
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/events"
+	"github.com/harundarat/be-socialtask/internal/export"
+	"github.com/harundarat/be-socialtask/internal/feed"
+	"github.com/harundarat/be-socialtask/internal/jobs"
 	"github.com/harundarat/be-socialtask/internal/middleware"
+	"github.com/harundarat/be-socialtask/internal/notifications"
+	"github.com/harundarat/be-socialtask/internal/outbox"
+	"github.com/harundarat/be-socialtask/internal/scheduler"
 	"github.com/harundarat/be-socialtask/internal/store"
 	"github.com/harundarat/be-socialtask/internal/utils"
+	"github.com/harundarat/be-socialtask/internal/webhooks"
 	"github.com/harundarat/be-socialtask/migrations"
 	"golang.org/x/oauth2"
 )
 
 type Application struct {
-	Logger         *log.Logger
-	TaskHandler    *api.TaskHandler
-	UserHandler    *api.UserHandler
-	AuthHandler    *api.AuthHandler
-	ActionHandler  *api.ActionHandler
-	RewardHandler  *api.RewardHandler
-	RewardsHandler *api.RewardsHandler
-	UserMiddleware *middleware.UserMiddleware
-	DB             *sql.DB
-	GoogleApp      *oauth2.Config
+	Logger               *log.Logger
+	TaskHandler          *api.TaskHandler
+	UserHandler          *api.UserHandler
+	AuthHandler          *api.AuthHandler
+	ActionHandler        *api.ActionHandler
+	RewardHandler        *api.RewardHandler
+	RewardsHandler       *api.RewardsHandler
+	LeaderboardHandler   *api.LeaderboardHandler
+	BadgeHandler         *api.BadgeHandler
+	ParticipationHandler *api.ParticipationHandler
+	QuestHandler         *api.QuestHandler
+	FeedHandler          *api.FeedHandler
+	UploadHandler        *api.UploadHandler
+	SubmissionHandler    *api.SubmissionHandler
+	DisputeHandler       *api.DisputeHandler
+	TemplateHandler      *api.TemplateHandler
+	CampaignHandler      *api.CampaignHandler
+	OrganizationHandler  *api.OrganizationHandler
+	EventHandler         *api.EventHandler
+	ExportHandler        *api.ExportHandler
+	WebhookHandler       *api.WebhookHandler
+	NotificationHandler  *api.NotificationHandler
+	UserMiddleware       *middleware.UserMiddleware
+	Scheduler            *scheduler.Scheduler
+	Rollup               *scheduler.Rollup
+	Events               *events.Writer
+	Bus                  *outbox.Bus
+	Outbox               *outbox.Dispatcher
+	JobStore             store.JobStore
+	Jobs                 *jobs.Worker
+	DB                   *sql.DB
+	GoogleApp            *oauth2.Config
 }
 
 func NewApplication() (*Application, error) {
@@ -56,32 +89,141 @@ func NewApplication() (*Application, error) {
 	oauthConfGl := auth.NewGoogleAuth()
 
 	// stores
-	taskStore := store.NewPostgresTaskStore(pgDB)
+	taskStore := store.NewPostgresTaskStore(pgDB, time.Now)
 	userStore := store.NewPostgresUserStore(pgDB)
 	taskActionStore := store.NewPostgresTaskActionStore(pgDB)
 	taskRewardStore := store.NewPostgresTaskRewardStore(pgDB)
 	rewardsStore := store.NewPostgresRewardsStore(pgDB)
+	leaderboardStore := store.NewPostgresLeaderboardStore(pgDB)
+	badgeStore := store.NewPostgresBadgeStore(pgDB)
+	participationStore := store.NewPostgresParticipationStore(pgDB, time.Now)
+	questStore := store.NewPostgresQuestStore(pgDB)
+	feedStore := store.NewPostgresFeedStore(pgDB, time.Now)
+	assetStore := store.NewPostgresAssetStore(pgDB)
+	submissionStore := store.NewPostgresSubmissionStore(pgDB, time.Now)
+	auditStore := store.NewPostgresAuditStore(pgDB)
+	disputeStore := store.NewPostgresDisputeStore(pgDB, time.Now)
+	templateStore := store.NewPostgresTaskTemplateStore(pgDB)
+	campaignStore := store.NewPostgresCampaignStore(pgDB, time.Now)
+	organizationStore := store.NewPostgresOrganizationStore(pgDB, time.Now)
+	analyticsStore := store.NewPostgresAnalyticsStore(pgDB, time.Now)
+	exportStore := store.NewPostgresExportStore(pgDB, time.Now)
+	webhookStore := store.NewPostgresWebhookStore(pgDB, time.Now)
+	outboxStore := store.NewPostgresOutboxStore(pgDB, time.Now)
+	jobStore := store.NewPostgresJobStore(pgDB, time.Now)
+	notificationStore := store.NewPostgresNotificationStore(pgDB, time.Now)
+
+	// uploaded files, on local disk unless BLOB_STORE=s3
+	blobStore, err := blob.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// list cursors are signed with a key derived from the JWT secret
+	cursors := utils.NewCursorCodec(utils.GetEnv("JWT_SECRET"))
+
+	// feed ranking weights, overridable through FEED_WEIGHT_* variables
+	feedWeights, err := feed.WeightsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// engagement events are buffered and written in the background, session
+	// IDs are anonymized with a key derived from the JWT secret
+	eventWriter := events.NewWriter(analyticsStore, utils.GetEnv("JWT_SECRET"), events.DefaultBufferSize, events.DefaultFlushInterval, time.Now, logger)
+
+	// achievements
+	achievementsEngine := achievements.NewEngine(badgeStore, logger)
 
 	// handlers
-	taskHandler := api.NewTaskHandler(taskStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
+	taskHandler := api.NewTaskHandler(taskStore, feedStore, organizationStore, analyticsStore, eventWriter, cursors, logger)
+	userHandler := api.NewUserHandler(userStore, badgeStore, cursors, logger)
 	authHandler := api.NewAuthHandler(logger, userStore, oauthConfGl, oauthConf)
-	taskActionHandler := api.NewActionHandler(taskActionStore, logger)
-	taskRewardHandler := api.NewRewardHandler(taskRewardStore, logger)
-	rewardsHandler := api.NewRewardsHandler(rewardsStore, logger)
+	taskActionHandler := api.NewActionHandler(taskActionStore, cursors, logger)
+	taskRewardHandler := api.NewRewardHandler(taskRewardStore, cursors, logger)
+	rewardsHandler := api.NewRewardsHandler(rewardsStore, achievementsEngine, logger)
+	leaderboardHandler := api.NewLeaderboardHandler(leaderboardStore, logger)
+	badgeHandler := api.NewBadgeHandler(badgeStore, cursors, logger)
+	participationHandler := api.NewParticipationHandler(participationStore, taskStore, organizationStore, achievementsEngine, cursors, logger)
+	questHandler := api.NewQuestHandler(questStore, cursors, logger)
+	feedHandler := api.NewFeedHandler(feedStore, feedWeights, time.Now, logger)
+	uploadHandler := api.NewUploadHandler(assetStore, blobStore, logger)
+	submissionHandler := api.NewSubmissionHandler(submissionStore, taskStore, auditStore, organizationStore, achievementsEngine, cursors, logger)
+	disputeHandler := api.NewDisputeHandler(disputeStore, auditStore, organizationStore, achievementsEngine, cursors, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, taskStore, cursors, logger)
+	campaignHandler := api.NewCampaignHandler(campaignStore, taskStore, organizationStore, analyticsStore, cursors, logger)
+	organizationHandler := api.NewOrganizationHandler(organizationStore, cursors, logger)
+	eventHandler := api.NewEventHandler(eventWriter, events.NewLimiter(events.DefaultClientLimit, events.DefaultLimitWindow, time.Now), logger)
+	exportHandler := api.NewExportHandler(exportStore, taskStore, campaignStore, organizationStore, blobStore, logger)
+	webhookHandler := api.NewWebhookHandler(webhookStore, organizationStore, cursors, logger)
+	notificationHandler := api.NewNotificationHandler(notificationStore, cursors, logger)
+	// publishes scheduled drafts in the background
+	taskScheduler := scheduler.NewScheduler(taskStore, scheduler.DefaultInterval, time.Now, logger)
+	// folds analytics events into daily totals in the background
+	analyticsRollup := scheduler.NewRollup(analyticsStore, scheduler.DefaultRollupInterval, logger)
+	// publishes the events the stores write to the outbox to the bus's
+	// subscribers, which have to subscribe before it runs
+	eventBus := outbox.NewBus()
+	outboxDispatcher := outbox.NewDispatcher(outboxStore, eventBus, outbox.DefaultInterval, time.Now, logger)
+	// notifies participants of their reviews and rewards
+	notifications.NewNotifier(notificationStore, taskStore, logger).Subscribe(eventBus)
+	// writes the server's events to analytics through the buffered writer
+	eventWriter.Subscribe(eventBus)
+	// runs queued background jobs, in this process or in cmd/worker, job
+	// kinds register their handlers here
+	jobWorker := jobs.NewWorker(jobStore, jobs.DefaultInterval, jobs.DefaultDrainTimeout, time.Now, logger)
+	// generates queued exports into the blob store
+	err = export.NewRunner(exportStore, blobStore, logger).Register(jobWorker)
+	if err != nil {
+		return nil, err
+	}
+	// sends queued webhook deliveries, private addresses are refused unless
+	// WEBHOOK_ALLOW_PRIVATE_URLS=true for local development
+	err = webhooks.NewDispatcher(webhookStore, webhooks.NewClient(os.Getenv("WEBHOOK_ALLOW_PRIVATE_URLS") == "true"), time.Now, logger).Register(jobWorker)
+	if err != nil {
+		return nil, err
+	}
+	// reminds participants of joined tasks about to end
+	err = notifications.NewReminder(notificationStore, notifications.DefaultReminderInterval, time.Now).Register(jobWorker)
+	if err != nil {
+		return nil, err
+	}
+
 	// middleware
 	userMiddleware := middleware.NewUserMiddleware(userStore, utils.GetEnv("JWT_SECRET"))
 	app := &Application{
-		Logger:         logger,
-		TaskHandler:    taskHandler,
-		UserHandler:    userHandler,
-		AuthHandler:    authHandler,
-		UserMiddleware: userMiddleware,
-		ActionHandler:  taskActionHandler,
-		RewardHandler:  taskRewardHandler,
-		RewardsHandler: rewardsHandler,
-		DB:             pgDB,
-		GoogleApp:      oauthConfGl,
+		Logger:               logger,
+		TaskHandler:          taskHandler,
+		UserHandler:          userHandler,
+		AuthHandler:          authHandler,
+		UserMiddleware:       userMiddleware,
+		Scheduler:            taskScheduler,
+		Rollup:               analyticsRollup,
+		Events:               eventWriter,
+		Bus:                  eventBus,
+		Outbox:               outboxDispatcher,
+		JobStore:             jobStore,
+		Jobs:                 jobWorker,
+		ActionHandler:        taskActionHandler,
+		RewardHandler:        taskRewardHandler,
+		RewardsHandler:       rewardsHandler,
+		LeaderboardHandler:   leaderboardHandler,
+		BadgeHandler:         badgeHandler,
+		ParticipationHandler: participationHandler,
+		QuestHandler:         questHandler,
+		FeedHandler:          feedHandler,
+		UploadHandler:        uploadHandler,
+		SubmissionHandler:    submissionHandler,
+		DisputeHandler:       disputeHandler,
+		TemplateHandler:      templateHandler,
+		CampaignHandler:      campaignHandler,
+		OrganizationHandler:  organizationHandler,
+		EventHandler:         eventHandler,
+		ExportHandler:        exportHandler,
+		WebhookHandler:       webhookHandler,
+		NotificationHandler:  notificationHandler,
+		DB:                   pgDB,
+		GoogleApp:            oauthConfGl,
 	}
 
 	return app, nil
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultClientLimit is how many events one client can record per
+	// DefaultLimitWindow.
+	DefaultClientLimit = 600
+	// DefaultLimitWindow is the window client limits are counted over.
+	DefaultLimitWindow = time.Minute
+)
+
+// Limiter caps the events each client records per window, so that one
+// client can not fill the writer's buffer and crowd out everyone else's.
+// Counts start over every window, which keeps the memory bounded by the
+// clients seen in one window.
+type Limiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	now         store.Clock
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func NewLimiter(limit int, window time.Duration, clock store.Clock) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		now:    clock,
+		counts: make(map[string]int),
+	}
+}
+
+// Take reserves up to n events for a client known by all of keys, for
+// example its address and its session, and returns how many it may record.
+// The key with the least left decides.
+func (l *Limiter) Take(n int, keys ...string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		clear(l.counts)
+	}
+
+	allowed := n
+	for _, key := range keys {
+		allowed = min(allowed, l.limit-l.counts[key])
+	}
+	if allowed <= 0 {
+		return 0
+	}
+	for _, key := range keys {
+		l.counts[key] += allowed
+	}
+	return allowed
+}
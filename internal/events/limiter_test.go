@@ -0,0 +1,21 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(5, time.Minute, func() time.Time { return now })
+
+	assert.Equal(t, 3, limiter.Take(3, "ip:a", "session:1"))
+	assert.Equal(t, 2, limiter.Take(3, "ip:a", "session:2"), "the address has 2 left")
+	assert.Equal(t, 0, limiter.Take(1, "ip:a"))
+	assert.Equal(t, 1, limiter.Take(1, "ip:b", "session:1"), "the session still has 2 left")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 5, limiter.Take(10, "ip:a"), "a new window starts over")
+}
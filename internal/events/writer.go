@@ -0,0 +1,230 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/outbox"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultBufferSize is how many events can wait to be written. Events
+	// recorded while the buffer is full are dropped.
+	DefaultBufferSize = 10000
+	// DefaultFlushInterval is the longest an event waits in the buffer.
+	DefaultFlushInterval = time.Second
+	// BatchSize is the most events written by one statement.
+	BatchSize = 500
+)
+
+// Writer buffers task events in memory and writes them in batches in the
+// background, so recording one never waits on the database. Recorded events
+// are only counted for analytics and may be lost on a crash or a full
+// buffer. The server's own events come from the outbox and are written with
+// Write, which waits for them to be stored.
+type Writer struct {
+	analyticsStore store.AnalyticsStore
+	key            []byte
+	events         chan pending
+	interval       time.Duration
+	now            store.Clock
+	logger         *log.Logger
+	dropped        atomic.Int64
+}
+
+// NewWriter returns a writer anonymizing session IDs with a key derived from
+// secret.
+func NewWriter(analyticsStore store.AnalyticsStore, secret string, bufferSize int, interval time.Duration, clock store.Clock, logger *log.Logger) *Writer {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("event-session"))
+
+	return &Writer{
+		analyticsStore: analyticsStore,
+		key:            mac.Sum(nil),
+		events:         make(chan pending, bufferSize),
+		interval:       interval,
+		now:            clock,
+		logger:         logger,
+	}
+}
+
+// Anonymize turns a session ID chosen by a client into the one stored: the
+// same session always maps to the same ID, which can not be traced back to
+// it.
+func (w *Writer) Anonymize(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, w.key)
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// pending is a buffered event. done receives the outcome of its batch when
+// someone waits for it.
+type pending struct {
+	event store.TaskEvent
+	done  chan<- error
+}
+
+// prepare anonymizes the event's session ID, events of signed in users drop
+// theirs, and has events without a time happen now.
+func (w *Writer) prepare(e store.TaskEvent) store.TaskEvent {
+	if e.UserID != 0 {
+		e.SessionID = ""
+	} else {
+		e.SessionID = w.Anonymize(e.SessionID)
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = w.now()
+	}
+	return e
+}
+
+// Record queues events without blocking and returns how many were queued.
+func (w *Writer) Record(events ...store.TaskEvent) int {
+	queued := 0
+	for _, e := range events {
+		select {
+		case w.events <- pending{event: w.prepare(e)}:
+			queued++
+		default:
+			w.dropped.Add(1)
+		}
+	}
+	return queued
+}
+
+// Write queues events and waits until they are written, for events that
+// must not be lost. It waits for room in a full buffer rather than dropping
+// them, and returns the error of a failed batch so the caller can retry.
+// The batch is written as soon as nothing else is waiting in the buffer.
+func (w *Writer) Write(ctx context.Context, events ...store.TaskEvent) error {
+	done := make(chan error, len(events))
+	for _, e := range events {
+		select {
+		case w.events <- pending{event: w.prepare(e), done: done}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var errs []error
+	for range events {
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe has the writer write the server's events, which the stores put
+// in the outbox in the transaction of the change they count.
+func (w *Writer) Subscribe(bus *outbox.Bus) {
+	bus.Subscribe("analytics", w.HandleEvent, store.OutboxTaskJoined, store.OutboxTaskSubmitted, store.OutboxTaskCompleted, store.OutboxTaskRejected, store.OutboxRewardCreated)
+}
+
+// HandleEvent writes the analytics event of an outbox event. Events are
+// published at least once, the outbox event id makes sure it is written
+// once.
+func (w *Writer) HandleEvent(ctx context.Context, e *store.OutboxEvent) error {
+	event, err := store.TaskEventFromOutbox(e)
+	if err != nil {
+		// publishing it again will not help
+		w.logger.Printf("ERROR: decodeAnalyticsEvent: %v", err)
+		return nil
+	}
+	if event == nil {
+		return nil
+	}
+	return w.Write(ctx, *event)
+}
+
+// Run writes buffered events every interval, or sooner once a batch is
+// full or someone waits for it, until ctx is done. It then writes what is
+// still buffered.
+func (w *Writer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]pending, 0, BatchSize)
+	waited := false
+	for {
+		select {
+		case <-ctx.Done():
+			w.write(batch)
+			w.Flush()
+			return
+		case e := <-w.events:
+			batch = append(batch, e)
+			waited = waited || e.done != nil
+			if len(batch) < BatchSize && (!waited || len(w.events) > 0) {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		w.write(batch)
+		batch = batch[:0]
+		waited = false
+	}
+}
+
+// Flush writes every event buffered right now.
+func (w *Writer) Flush() {
+	batch := make([]pending, 0, BatchSize)
+	for {
+		select {
+		case e := <-w.events:
+			batch = append(batch, e)
+			if len(batch) < BatchSize {
+				continue
+			}
+		default:
+			w.write(batch)
+			return
+		}
+
+		w.write(batch)
+		batch = batch[:0]
+	}
+}
+
+// write stores a batch and tells the waiting callers how it went. A failed
+// batch is logged and lost, retrying would only let the buffer fill up
+// behind it, the callers of Write retry their own events.
+func (w *Writer) write(batch []pending) {
+	if dropped := w.dropped.Swap(0); dropped > 0 {
+		w.logger.Printf("ERROR: event buffer full, dropped %d events", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	events := make([]store.TaskEvent, len(batch))
+	for i, p := range batch {
+		events[i] = p.event
+	}
+	_, err := w.analyticsStore.InsertTaskEvents(events)
+	if err != nil {
+		w.logger.Printf("ERROR: insertTaskEvents: %v", err)
+	}
+
+	for _, p := range batch {
+		if p.done != nil {
+			p.done <- err
+		}
+	}
+}
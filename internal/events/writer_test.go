@@ -0,0 +1,189 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAnalyticsStore struct {
+	store.AnalyticsStore
+	mu      sync.Mutex
+	batches [][]store.TaskEvent
+	err     error
+}
+
+func (f *fakeAnalyticsStore) InsertTaskEvents(events []store.TaskEvent) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.batches = append(f.batches, append([]store.TaskEvent(nil), events...))
+	return int64(len(events)), nil
+}
+
+func (f *fakeAnalyticsStore) written() []store.TaskEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var all []store.TaskEvent
+	for _, b := range f.batches {
+		all = append(all, b...)
+	}
+	return all
+}
+
+func newTestWriter(analytics store.AnalyticsStore, bufferSize int, logs *bytes.Buffer) *Writer {
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	return NewWriter(analytics, "test", bufferSize, time.Hour, func() time.Time { return now }, log.New(logs, "", 0))
+}
+
+func TestWriterRecord(t *testing.T) {
+	analytics := &fakeAnalyticsStore{}
+	w := newTestWriter(analytics, 10, &bytes.Buffer{})
+
+	at := time.Date(2025, time.November, 9, 0, 0, 0, 0, time.UTC)
+	queued := w.Record(
+		store.TaskEvent{TaskID: 1, SessionID: "browser-session-1", Type: store.TaskEventView},
+		store.TaskEvent{TaskID: 1, UserID: 5, SessionID: "browser-session-1", Type: store.TaskEventClick, OccurredAt: at},
+	)
+	assert.Equal(t, 2, queued)
+	w.Flush()
+
+	events := analytics.written()
+	require.Len(t, events, 2)
+	assert.Equal(t, w.Anonymize("browser-session-1"), events[0].SessionID)
+	assert.NotContains(t, events[0].SessionID, "browser-session-1")
+	assert.Len(t, events[0].SessionID, 32)
+	assert.Equal(t, time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC), events[0].OccurredAt)
+	assert.Empty(t, events[1].SessionID)
+	assert.Equal(t, at, events[1].OccurredAt)
+
+	assert.Empty(t, w.Anonymize(""))
+	assert.NotEqual(t, w.Anonymize("a"), w.Anonymize("b"))
+	assert.NotEqual(t, w.Anonymize("a"), NewWriter(analytics, "other", 1, time.Hour, time.Now, nil).Anonymize("a"))
+}
+
+func TestWriterBuffer(t *testing.T) {
+	t.Run("drops events once the buffer is full", func(t *testing.T) {
+		var logs bytes.Buffer
+		analytics := &fakeAnalyticsStore{}
+		w := newTestWriter(analytics, 2, &logs)
+
+		assert.Equal(t, 2, w.Record(store.TaskEvent{TaskID: 1}, store.TaskEvent{TaskID: 2}, store.TaskEvent{TaskID: 3}))
+		w.Flush()
+
+		assert.Len(t, analytics.written(), 2)
+		assert.Contains(t, logs.String(), "dropped 1 events")
+	})
+
+	t.Run("flushes in batches", func(t *testing.T) {
+		analytics := &fakeAnalyticsStore{}
+		w := newTestWriter(analytics, 2*BatchSize, &bytes.Buffer{})
+
+		for i := 0; i < BatchSize+1; i++ {
+			w.Record(store.TaskEvent{TaskID: int64(i + 1)})
+		}
+		w.Flush()
+
+		require.Len(t, analytics.batches, 2)
+		assert.Len(t, analytics.batches[0], BatchSize)
+		assert.Len(t, analytics.batches[1], 1)
+	})
+
+	t.Run("failed batches are logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		analytics := &fakeAnalyticsStore{err: errors.New("connection refused")}
+		w := newTestWriter(analytics, 2, &logs)
+
+		w.Record(store.TaskEvent{TaskID: 1})
+		w.Flush()
+		assert.Contains(t, logs.String(), "connection refused")
+	})
+}
+
+func TestWriterRun(t *testing.T) {
+	analytics := &fakeAnalyticsStore{}
+	w := newTestWriter(analytics, 10, &bytes.Buffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	w.Record(store.TaskEvent{TaskID: 1}, store.TaskEvent{TaskID: 2})
+	cancel()
+	<-done
+
+	assert.Len(t, analytics.written(), 2)
+}
+
+func TestWriterWrite(t *testing.T) {
+	analytics := &fakeAnalyticsStore{}
+	w := newTestWriter(analytics, 10, &bytes.Buffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	t.Run("waits until the events are written", func(t *testing.T) {
+		// the flush interval is an hour, only the waiting caller flushes
+		require.NoError(t, w.Write(context.Background(), store.TaskEvent{TaskID: 1, UserID: 5, Type: store.TaskEventJoin}))
+		assert.Len(t, analytics.written(), 1)
+	})
+
+	t.Run("failed batches are returned", func(t *testing.T) {
+		analytics.mu.Lock()
+		analytics.err = errors.New("connection refused")
+		analytics.mu.Unlock()
+		defer func() {
+			analytics.mu.Lock()
+			analytics.err = nil
+			analytics.mu.Unlock()
+		}()
+
+		assert.ErrorContains(t, w.Write(context.Background(), store.TaskEvent{TaskID: 1}), "connection refused")
+	})
+
+	t.Run("outbox events are written as analytics events", func(t *testing.T) {
+		at := time.Date(2025, time.November, 9, 0, 0, 0, 0, time.UTC)
+		payload, err := json.Marshal(store.OutboxTaskData{TaskID: 7, UserID: 5, RewardUSDT: 2.5, DurationSeconds: 90})
+		require.NoError(t, err)
+		err = w.HandleEvent(context.Background(), &store.OutboxEvent{ID: 40, Type: store.OutboxTaskCompleted, Payload: payload, CreatedAt: at})
+		require.NoError(t, err)
+
+		events := analytics.written()
+		assert.Equal(t, store.TaskEvent{
+			TaskID:        7,
+			UserID:        5,
+			Type:          store.TaskEventVerified,
+			RewardUSDT:    2.5,
+			Duration:      90 * time.Second,
+			OccurredAt:    at,
+			OutboxEventID: 40,
+		}, events[len(events)-1])
+
+		// lifecycle events are not analytics events
+		err = w.HandleEvent(context.Background(), &store.OutboxEvent{ID: 41, Type: store.OutboxTaskCreated, Payload: payload})
+		require.NoError(t, err)
+		assert.Len(t, analytics.written(), len(events))
+	})
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// ParticipantColumns heads the participant exports.
+var ParticipantColumns = []any{
+	"participation_id",
+	"task_id",
+	"task_title",
+	"user_id",
+	"username",
+	"x_handle",
+	"wallet_address",
+	"status",
+	"reward_usdt",
+	"joined_at",
+	"updated_at",
+}
+
+func participantRow(p *store.ExportParticipant) []any {
+	return []any{
+		p.ParticipationID,
+		p.TaskID,
+		p.TaskTitle,
+		p.UserID,
+		p.Username,
+		p.XHandle,
+		p.WalletAddress,
+		string(p.Status),
+		p.RewardUSDT,
+		p.JoinedAt,
+		p.UpdatedAt,
+	}
+}
+
+// Filename is the name an export of the scope is downloaded as.
+func Filename(scope store.ExportScope, format store.ExportFormat) string {
+	if scope.CampaignID != 0 {
+		return fmt.Sprintf("campaign-%d-participants.%s", scope.CampaignID, format)
+	}
+	return fmt.Sprintf("task-%d-participants.%s", scope.TaskID, format)
+}
+
+// Participants streams the participants of the scope from the store to w
+// and returns how many rows it wrote.
+func Participants(w io.Writer, format store.ExportFormat, exportStore store.ExportStore, scope store.ExportScope) (int64, error) {
+	out, err := NewWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	err = out.WriteRow(ParticipantColumns)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = exportStore.EachParticipant(scope, func(p *store.ExportParticipant) error {
+		rows++
+		return out.WriteRow(participantRow(p))
+	})
+	if err != nil {
+		return rows, err
+	}
+
+	return rows, out.Close()
+}
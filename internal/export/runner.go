@@ -0,0 +1,119 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// DeleteExpiredInterval is how often expired exports are deleted.
+const DeleteExpiredInterval = time.Hour
+
+var generate = jobs.Kind[store.GenerateExportJobPayload]{
+	Name:        store.GenerateExportJob,
+	Queue:       store.ExportQueue,
+	MaxAttempts: store.ExportMaxAttempts,
+}
+
+// Runner generates queued exports as jobs and stores the files in the blob
+// store.
+type Runner struct {
+	exportStore store.ExportStore
+	blobs       blob.BlobStore
+	logger      *log.Logger
+}
+
+func NewRunner(exportStore store.ExportStore, blobs blob.BlobStore, logger *log.Logger) *Runner {
+	return &Runner{
+		exportStore: exportStore,
+		blobs:       blobs,
+		logger:      logger,
+	}
+}
+
+// Register makes w generate the exports and delete the expired ones every
+// DeleteExpiredInterval.
+func (r *Runner) Register(w *jobs.Worker) error {
+	jobs.Handle(w, generate, r.Generate)
+	return w.Every("exports.delete_expired", DeleteExpiredInterval, r.DeleteExpired)
+}
+
+// Generate writes the file of a job's export and records the outcome. A
+// failure is retried by the worker, after the last attempt the export fails
+// and the creator can queue it again. Exports that are gone or finished are
+// skipped.
+func (r *Runner) Generate(ctx context.Context, p store.GenerateExportJobPayload) error {
+	job, err := r.exportStore.StartExportJob(p.ExportID)
+	if err != nil || job == nil {
+		return err
+	}
+
+	key, rows, err := r.write(ctx, job)
+	if err == nil {
+		err = r.exportStore.CompleteExportJob(job.ID, key, rows)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if _, last := jobs.Attempt(ctx); last {
+		if err := r.exportStore.FailExportJob(job.ID, "export could not be generated"); err != nil {
+			r.logger.Printf("ERROR: failExportJob: %v", err)
+		}
+	}
+	return fmt.Errorf("generating export %d: %w", job.ID, err)
+}
+
+// write streams the export to a temporary file first, so the blob store
+// gets the file's size and no rows are held in memory.
+func (r *Runner) write(ctx context.Context, job *store.ExportJob) (string, int64, error) {
+	tmp, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rows, err := Participants(tmp, job.Format, r.exportStore, job.Scope())
+	if err != nil {
+		return "", 0, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	key := fmt.Sprintf("exports/%d/%d-%s", job.UserID, job.ID, Filename(job.Scope(), job.Format))
+	err = r.blobs.Put(ctx, key, tmp, size, ContentType(job.Format))
+	if err != nil {
+		return "", 0, err
+	}
+	return key, rows, nil
+}
+
+// DeleteExpired removes expired exports and their files.
+func (r *Runner) DeleteExpired(ctx context.Context) error {
+	keys, err := r.exportStore.DeleteExpiredExportJobs()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err := r.blobs.Delete(ctx, key)
+		if err != nil && err != blob.ErrNotFound {
+			r.logger.Printf("ERROR: deleteBlob: %v", err)
+		}
+	}
+	return nil
+}
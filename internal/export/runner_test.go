@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/harundarat/be-socialtask/internal/blob"
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExportStore struct {
+	store.ExportStore
+	exports   map[int64]*store.ExportJob
+	rowErr    error
+	completed map[int64]string
+	failed    []int64
+	expired   []string
+}
+
+func (f *fakeExportStore) EachParticipant(scope store.ExportScope, fn func(*store.ExportParticipant) error) error {
+	if f.rowErr != nil {
+		return f.rowErr
+	}
+	for i := int64(1); i <= 3; i++ {
+		err := fn(&store.ExportParticipant{ParticipationID: i, TaskID: scope.TaskID, Username: "user", Status: store.ParticipationVerified, RewardUSDT: 2})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeExportStore) StartExportJob(id int64) (*store.ExportJob, error) {
+	job, ok := f.exports[id]
+	if !ok || job.Status == store.ExportCompleted || job.Status == store.ExportFailed {
+		return nil, nil
+	}
+	job.Status = store.ExportRunning
+	job.Attempts++
+	return job, nil
+}
+
+func (f *fakeExportStore) CompleteExportJob(id int64, blobKey string, rowCount int64) error {
+	f.completed[id] = blobKey
+	f.exports[id].Status = store.ExportCompleted
+	return nil
+}
+
+func (f *fakeExportStore) FailExportJob(id int64, reason string) error {
+	f.failed = append(f.failed, id)
+	f.exports[id].Status = store.ExportFailed
+	return nil
+}
+
+func (f *fakeExportStore) DeleteExpiredExportJobs() ([]string, error) {
+	keys := f.expired
+	f.expired = nil
+	return keys, nil
+}
+
+func TestRunner(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	taskID, campaignID := int64(7), int64(3)
+	exportStore := &fakeExportStore{
+		completed: map[int64]string{},
+		exports: map[int64]*store.ExportJob{
+			1: {ID: 1, UserID: 9, TaskID: &taskID, Format: store.ExportCSV},
+			2: {ID: 2, UserID: 9, CampaignID: &campaignID, Format: store.ExportXLSX},
+			3: {ID: 3, UserID: 9, TaskID: &taskID, Format: store.ExportCSV},
+		},
+	}
+	var logs bytes.Buffer
+	runner := NewRunner(exportStore, blobs, log.New(&logs, "", 0))
+
+	// run runs the job of export id as its attempt-th attempt
+	run := func(id int64, attempt int) error {
+		ctx := jobs.WithAttempt(context.Background(), attempt, store.ExportMaxAttempts)
+		return runner.Generate(ctx, store.GenerateExportJobPayload{ExportID: id})
+	}
+
+	t.Run("generates queued exports", func(t *testing.T) {
+		require.NoError(t, run(1, 1))
+		require.NoError(t, run(2, 1))
+		assert.Equal(t, "exports/9/1-task-7-participants.csv", exportStore.completed[1])
+		assert.Equal(t, "exports/9/2-campaign-3-participants.xlsx", exportStore.completed[2])
+
+		body, contentType, err := blobs.Get(context.Background(), exportStore.completed[1])
+		require.NoError(t, err)
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+
+		assert.Equal(t, "text/csv; charset=utf-8", contentType)
+		assert.Equal(t, 4, strings.Count(string(data), "\n"), "a header and 3 rows")
+
+		// a job run again after the export completed changes nothing
+		require.NoError(t, run(1, 2))
+		assert.Equal(t, 1, exportStore.exports[1].Attempts)
+	})
+
+	t.Run("failed exports are retried and then marked failed", func(t *testing.T) {
+		exportStore.rowErr = errors.New("connection reset")
+
+		assert.ErrorContains(t, run(3, 1), "connection reset")
+		assert.Empty(t, exportStore.failed)
+		assert.Equal(t, store.ExportRunning, exportStore.exports[3].Status)
+
+		assert.Error(t, run(3, store.ExportMaxAttempts))
+		assert.Equal(t, []int64{3}, exportStore.failed)
+	})
+
+	t.Run("missing exports are skipped", func(t *testing.T) {
+		assert.NoError(t, run(100, 1))
+	})
+
+	t.Run("expired files are deleted", func(t *testing.T) {
+		exportStore.expired = []string{exportStore.completed[1], "exports/9/gone.csv"}
+		require.NoError(t, runner.DeleteExpired(context.Background()))
+
+		_, _, err := blobs.Get(context.Background(), exportStore.completed[1])
+		assert.Equal(t, blob.ErrNotFound, err)
+	})
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRows = [][]any{
+	{"username", "reward_usdt", "joined_at"},
+	{"alice", 2.5, time.Date(2025, time.November, 1, 12, 0, 0, 0, time.UTC)},
+	{"=HYPERLINK(\"x\")", int64(3), time.Date(2025, time.November, 2, 0, 0, 0, 0, time.UTC)},
+	{"<b>&co</b>", 0.0, time.Date(2025, time.November, 3, 0, 0, 0, 0, time.UTC)},
+}
+
+func writeTestRows(t *testing.T, format store.ExportFormat) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format)
+	require.NoError(t, err)
+	for _, row := range testRows {
+		require.NoError(t, w.WriteRow(row))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeTestRows(t, store.ExportCSV))).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"alice", "2.5", "2025-11-01T12:00:00Z"}, records[1])
+	assert.Equal(t, `'=HYPERLINK("x")`, records[2][0], "formulas stay text")
+	assert.Equal(t, "3", records[2][1])
+}
+
+func TestXLSXWriter(t *testing.T) {
+	data := writeTestRows(t, store.ExportXLSX)
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	parts := map[string]*zip.File{}
+	for _, f := range z.File {
+		parts[f.Name] = f
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		assert.Contains(t, parts, name)
+	}
+	require.Contains(t, parts, "xl/worksheets/sheet1.xml")
+
+	f, err := parts["xl/worksheets/sheet1.xml"].Open()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string `xml:"r,attr"`
+				T      string `xml:"t,attr"`
+				S      string `xml:"s,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	require.NoError(t, xml.Unmarshal(raw, &sheet))
+
+	require.Len(t, sheet.Rows, 4)
+	row := sheet.Rows[1]
+	assert.Equal(t, 2, row.R)
+	assert.Equal(t, "A2", row.Cells[0].R)
+	assert.Equal(t, "alice", row.Cells[0].Inline)
+	assert.Equal(t, "2.5", row.Cells[1].Value)
+	assert.Equal(t, "1", row.Cells[2].S)
+	assert.Equal(t, "45962.5", row.Cells[2].Value)
+	assert.Equal(t, "<b>&co</b>", sheet.Rows[3].Cells[0].Inline)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	_, err := NewWriter(io.Discard, "pdf")
+	assert.Error(t, err)
+}
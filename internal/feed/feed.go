@@ -0,0 +1,154 @@
+package feed
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// UrgencyWindow is how close to its due date a task starts to count as
+// expiring soon. Urgency grows linearly to 1 at the due date.
+const UrgencyWindow = 7 * 24 * time.Hour
+
+// Weights scale each ranking signal. Every signal is between 0 and 1, so a
+// weight is the most that signal can add to a task's score.
+type Weights struct {
+	Unseen          float64
+	Reward          float64
+	Urgency         float64
+	ActionAffinity  float64
+	CreatorAffinity float64
+}
+
+func DefaultWeights() Weights {
+	return Weights{
+		Unseen:          1,
+		Reward:          1,
+		Urgency:         0.5,
+		ActionAffinity:  0.75,
+		CreatorAffinity: 0.5,
+	}
+}
+
+// WeightsFromEnv reads FEED_WEIGHT_UNSEEN, FEED_WEIGHT_REWARD,
+// FEED_WEIGHT_URGENCY, FEED_WEIGHT_ACTION_AFFINITY and
+// FEED_WEIGHT_CREATOR_AFFINITY, keeping the default for unset ones.
+func WeightsFromEnv() (Weights, error) {
+	w := DefaultWeights()
+
+	vars := []struct {
+		key    string
+		weight *float64
+	}{
+		{"FEED_WEIGHT_UNSEEN", &w.Unseen},
+		{"FEED_WEIGHT_REWARD", &w.Reward},
+		{"FEED_WEIGHT_URGENCY", &w.Urgency},
+		{"FEED_WEIGHT_ACTION_AFFINITY", &w.ActionAffinity},
+		{"FEED_WEIGHT_CREATOR_AFFINITY", &w.CreatorAffinity},
+	}
+	for _, v := range vars {
+		value, ok := os.LookupEnv(v.key)
+		if !ok || value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return w, fmt.Errorf("%s must be a non-negative number", v.key)
+		}
+		*v.weight = f
+	}
+
+	return w, nil
+}
+
+// Signals are a task's ranking signals before weighting.
+type Signals struct {
+	Unseen          float64 `json:"unseen"`
+	Reward          float64 `json:"reward"`
+	Urgency         float64 `json:"urgency"`
+	ActionAffinity  float64 `json:"action_affinity"`
+	CreatorAffinity float64 `json:"creator_affinity"`
+}
+
+func (s Signals) score(w Weights) float64 {
+	return s.Unseen*w.Unseen +
+		s.Reward*w.Reward +
+		s.Urgency*w.Urgency +
+		s.ActionAffinity*w.ActionAffinity +
+		s.CreatorAffinity*w.CreatorAffinity
+}
+
+// Item is a ranked feed entry.
+type Item struct {
+	store.Task
+	Score   float64 `json:"score"`
+	Signals Signals `json:"signals"`
+}
+
+// saturate maps a count onto [0, 1), the first engagements matter most.
+func saturate(n int64) float64 {
+	return float64(n) / float64(n+1)
+}
+
+// signals computes the candidate's signals. maxReward is the highest reward
+// among all candidates, rewards are scored relative to it.
+func signals(c store.FeedCandidate, profile *store.FeedProfile, maxReward float64, now time.Time) Signals {
+	var s Signals
+
+	if !c.Seen {
+		s.Unseen = 1
+	}
+
+	if maxReward > 0 {
+		s.Reward = c.Task.RewardUSDT / maxReward
+	}
+
+	if remaining := c.Task.DueDate.Sub(now); remaining > 0 && remaining < UrgencyWindow {
+		s.Urgency = 1 - float64(remaining)/float64(UrgencyWindow)
+	}
+
+	// a task is as familiar as its most practised action
+	for _, a := range c.Task.Actions {
+		s.ActionAffinity = max(s.ActionAffinity, saturate(profile.ActionsCompleted[a.Type]))
+	}
+
+	s.CreatorAffinity = saturate(profile.CreatorEngagements[c.Task.UserID])
+
+	return s
+}
+
+// Rank scores every candidate for the user behind profile and returns the
+// best limit of them, highest score first. Ties go to the task due soonest,
+// then to the newest task, so equal inputs always rank the same way.
+func Rank(candidates []store.FeedCandidate, profile *store.FeedProfile, w Weights, now time.Time, limit int) []Item {
+	var maxReward float64
+	for _, c := range candidates {
+		maxReward = max(maxReward, c.Task.RewardUSDT)
+	}
+
+	items := make([]Item, len(candidates))
+	for i, c := range candidates {
+		s := signals(c, profile, maxReward, now)
+		items[i] = Item{Task: c.Task, Score: s.score(w), Signals: s}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Signals.Urgency != b.Signals.Urgency {
+			return a.Signals.Urgency > b.Signals.Urgency
+		}
+		return a.ID > b.ID
+	})
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
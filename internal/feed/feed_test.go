@@ -0,0 +1,160 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+
+func candidate(id int, reward float64, due time.Time, seen bool) store.FeedCandidate {
+	return store.FeedCandidate{
+		Task: store.Task{ID: id, UserID: 1, RewardUSDT: reward, DueDate: due},
+		Seen: seen,
+	}
+}
+
+func emptyProfile() *store.FeedProfile {
+	return &store.FeedProfile{
+		ActionsCompleted:   map[store.TypeAction]int64{},
+		CreatorEngagements: map[int64]int64{},
+	}
+}
+
+func ids(items []Item) []int {
+	var out []int
+	for _, item := range items {
+		out = append(out, item.ID)
+	}
+	return out
+}
+
+// only lets the named signal count, so each test isolates one of them
+func only(set func(*Weights)) Weights {
+	var w Weights
+	set(&w)
+	return w
+}
+
+func TestRankSignals(t *testing.T) {
+	far := now.AddDate(1, 0, 0)
+
+	t.Run("unseen tasks come first", func(t *testing.T) {
+		candidates := []store.FeedCandidate{candidate(1, 1, far, true), candidate(2, 1, far, false)}
+		items := Rank(candidates, emptyProfile(), only(func(w *Weights) { w.Unseen = 1 }), now, 10)
+		assert.Equal(t, []int{2, 1}, ids(items))
+	})
+
+	t.Run("higher rewards come first", func(t *testing.T) {
+		candidates := []store.FeedCandidate{candidate(1, 2, far, false), candidate(2, 8, far, false)}
+		items := Rank(candidates, emptyProfile(), only(func(w *Weights) { w.Reward = 1 }), now, 10)
+		assert.Equal(t, []int{2, 1}, ids(items))
+		assert.Equal(t, 1.0, items[0].Signals.Reward)
+		assert.Equal(t, 0.25, items[1].Signals.Reward)
+	})
+
+	t.Run("tasks expiring soon come first", func(t *testing.T) {
+		candidates := []store.FeedCandidate{
+			candidate(1, 1, far, false),
+			candidate(2, 1, now.Add(UrgencyWindow/2), false),
+			candidate(3, 1, now.Add(UrgencyWindow/4), false),
+		}
+		items := Rank(candidates, emptyProfile(), only(func(w *Weights) { w.Urgency = 1 }), now, 10)
+		assert.Equal(t, []int{3, 2, 1}, ids(items))
+		assert.Equal(t, 0.75, items[0].Signals.Urgency)
+		assert.Equal(t, 0.0, items[2].Signals.Urgency)
+	})
+
+	t.Run("familiar actions come first", func(t *testing.T) {
+		follow := candidate(1, 1, far, false)
+		follow.Task.Actions = []store.ActionTask{{Type: store.Type1}}
+		repost := candidate(2, 1, far, false)
+		repost.Task.Actions = []store.ActionTask{{Type: store.Type2}}
+
+		profile := emptyProfile()
+		profile.ActionsCompleted[store.Type2] = 3
+
+		items := Rank([]store.FeedCandidate{follow, repost}, profile, only(func(w *Weights) { w.ActionAffinity = 1 }), now, 10)
+		assert.Equal(t, []int{2, 1}, ids(items))
+		assert.Equal(t, 0.75, items[0].Signals.ActionAffinity)
+	})
+
+	t.Run("creators engaged with come first", func(t *testing.T) {
+		known := candidate(1, 1, far, false)
+		known.Task.UserID = 5
+		unknown := candidate(2, 1, far, false)
+		unknown.Task.UserID = 6
+
+		profile := emptyProfile()
+		profile.CreatorEngagements[5] = 1
+
+		items := Rank([]store.FeedCandidate{unknown, known}, profile, only(func(w *Weights) { w.CreatorAffinity = 1 }), now, 10)
+		assert.Equal(t, []int{1, 2}, ids(items))
+		assert.Equal(t, 0.5, items[0].Signals.CreatorAffinity)
+	})
+}
+
+func TestRankWeights(t *testing.T) {
+	far := now.AddDate(1, 0, 0)
+	// a seen task with the top reward against an unseen one with a small reward
+	candidates := []store.FeedCandidate{candidate(1, 10, far, true), candidate(2, 1, far, false)}
+
+	items := Rank(candidates, emptyProfile(), Weights{Unseen: 1, Reward: 2}, now, 10)
+	assert.Equal(t, []int{1, 2}, ids(items))
+	assert.InDelta(t, 2.0, items[0].Score, 1e-9)
+	assert.InDelta(t, 1.2, items[1].Score, 1e-9)
+
+	items = Rank(candidates, emptyProfile(), Weights{Unseen: 2, Reward: 1}, now, 10)
+	assert.Equal(t, []int{2, 1}, ids(items))
+}
+
+func TestRankIsDeterministic(t *testing.T) {
+	far := now.AddDate(1, 0, 0)
+	candidates := []store.FeedCandidate{
+		candidate(1, 1, far, false),
+		candidate(2, 1, now.Add(time.Hour), false),
+		candidate(3, 1, far, false),
+		candidate(4, 1, far, false),
+	}
+
+	// with urgency weighted 0 every score ties, the soonest due task still
+	// leads and the rest fall back to newest first
+	w := Weights{Unseen: 1}
+	for i := 0; i < 5; i++ {
+		items := Rank(candidates, emptyProfile(), w, now, 10)
+		assert.Equal(t, []int{2, 4, 3, 1}, ids(items))
+	}
+
+	items := Rank(candidates, emptyProfile(), w, now, 2)
+	assert.Equal(t, []int{2, 4}, ids(items))
+
+	assert.Empty(t, Rank(nil, emptyProfile(), w, now, 10))
+}
+
+func TestWeightsFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		w, err := WeightsFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, DefaultWeights(), w)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("FEED_WEIGHT_REWARD", "3")
+		t.Setenv("FEED_WEIGHT_UNSEEN", "0")
+		w, err := WeightsFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, w.Reward)
+		assert.Equal(t, 0.0, w.Unseen)
+		assert.Equal(t, DefaultWeights().Urgency, w.Urgency)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("FEED_WEIGHT_URGENCY", "-1")
+		_, err := WeightsFromEnv()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// MaxRows is the most tasks one import may hold.
+const MaxRows = 1000
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// DetectFormat picks the format from the file name's extension, or else from
+// its content type. It returns "" when neither is known.
+func DetectFormat(contentType, filename string) Format {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "application/json":
+		return FormatJSON
+	}
+	return ""
+}
+
+// Row is one task of the file. Errors holds what is wrong with it, the task
+// is only worth creating when there are none.
+type Row struct {
+	// Number counts the tasks from 1, not counting the CSV header.
+	Number int
+	Task   store.Task
+	Errors []string
+}
+
+// TaskColumns are the CSV columns a task can be imported from, title is the
+// only one that has to be there. Lists of ids are separated by semicolons
+// and eligibility holds the same JSON as the task's eligibility field.
+var TaskColumns = []string{
+	"title",
+	"description",
+	"reward_usdt",
+	"due_date",
+	"max_participant",
+	"task_image",
+	"recurrence",
+	"recurrence_timezone",
+	"publish_at",
+	"action_ids",
+	"reward_ids",
+	"eligibility",
+	"organization_id",
+}
+
+// ReadTasks parses every task of the file. An error means the file as a
+// whole can not be read, mistakes in a single task end up in its Row.
+func ReadTasks(r io.Reader, format Format) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatJSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q", format)
+}
+
+func readJSON(r io.Reader) ([]Row, error) {
+	var items []json.RawMessage
+	err := json.NewDecoder(r).Decode(&items)
+	if err != nil {
+		return nil, errors.New("file must hold a JSON array of tasks")
+	}
+	if len(items) > MaxRows {
+		return nil, fmt.Errorf("file must hold at most %d tasks", MaxRows)
+	}
+
+	rows := make([]Row, len(items))
+	for i, item := range items {
+		rows[i].Number = i + 1
+
+		// the fields are decoded one task at a time, so that a wrong type
+		// only fails its own row
+		err := json.Unmarshal(item, &rows[i].Task)
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr) && typeErr.Field != "":
+			rows[i].Errors = append(rows[i].Errors, fmt.Sprintf("%s has the wrong type", typeErr.Field))
+		case err != nil:
+			rows[i].Errors = append(rows[i].Errors, "task must be a JSON object")
+		}
+	}
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	// spreadsheet apps like to start the file with a byte order mark
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file is not valid CSV: %v", err)
+	}
+
+	known := make(map[string]bool, len(TaskColumns))
+	for _, name := range TaskColumns {
+		known[name] = true
+	}
+	columns := make([]string, len(header))
+	hasTitle := false
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q, columns are %s", name, strings.Join(TaskColumns, ", "))
+		}
+		hasTitle = hasTitle || name == "title"
+		columns[i] = name
+	}
+	if !hasTitle {
+		return nil, errors.New("file must have a title column")
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file is not valid CSV: %v", err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("file must hold at most %d tasks", MaxRows)
+		}
+
+		row := Row{Number: len(rows) + 1}
+		if len(record) > len(columns) {
+			row.Errors = append(row.Errors, fmt.Sprintf("row has %d cells but there are %d columns", len(record), len(columns)))
+		}
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			err := setTaskField(&row.Task, columns[i], strings.TrimSpace(value))
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// setTaskField sets the task field behind a CSV column, empty cells keep
+// the field's zero value.
+func setTaskField(task *store.Task, column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch column {
+	case "title":
+		task.Title = value
+	case "description":
+		task.Description = value
+	case "reward_usdt":
+		task.RewardUSDT, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("reward_usdt must be a number")
+		}
+	case "due_date":
+		task.DueDate, err = parseTime(value)
+		if err != nil {
+			return errors.New("due_date must be a date or an RFC 3339 timestamp")
+		}
+	case "max_participant":
+		task.MaxParticipant = value
+	case "task_image":
+		task.TaskImage = value
+	case "recurrence":
+		task.Recurrence = store.Recurrence(strings.ToLower(value))
+	case "recurrence_timezone":
+		task.RecurrenceTimezone = value
+	case "publish_at":
+		publishAt, err := parseTime(value)
+		if err != nil {
+			return errors.New("publish_at must be a date or an RFC 3339 timestamp")
+		}
+		task.PublishAt = &publishAt
+	case "action_ids":
+		task.ActionIDs, err = parseIDs(value)
+		if err != nil {
+			return errors.New("action_ids must be ids separated by semicolons")
+		}
+	case "reward_ids":
+		task.RewardIDs, err = parseIDs(value)
+		if err != nil {
+			return errors.New("reward_ids must be ids separated by semicolons")
+		}
+	case "eligibility":
+		err = json.Unmarshal([]byte(value), &task.Eligibility)
+		if err != nil {
+			return errors.New("eligibility must be a JSON list of rules")
+		}
+	case "organization_id":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("organization_id must be an id")
+		}
+		task.OrganizationID = &id
+	}
+	return nil
+}
+
+// parseTime reads an RFC 3339 timestamp, or a day like 2025-11-01 which
+// stands for its midnight in UTC.
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func parseIDs(value string) ([]int, error) {
+	parts := strings.Split(value, ";")
+	ids := make([]int, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, FormatCSV, DetectFormat("application/octet-stream", "tasks.CSV"))
+	assert.Equal(t, FormatJSON, DetectFormat("", "tasks.json"))
+	assert.Equal(t, FormatCSV, DetectFormat("text/csv; charset=utf-8", ""))
+	assert.Equal(t, FormatJSON, DetectFormat("application/json", ""))
+	assert.Equal(t, Format(""), DetectFormat("text/plain", "tasks.txt"))
+}
+
+func TestReadTasksCSV(t *testing.T) {
+	file := "\ufeffTitle,reward_usdt,due_date,action_ids,organization_id,publish_at\n" +
+		"Follow us,2.5,2025-12-01,1;2,4,2025-11-20T09:00:00Z\n" +
+		",,,,,\n" +
+		"Repost,lots,next week,1;x,,\n"
+
+	rows, err := ReadTasks(strings.NewReader(file), FormatCSV)
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "blank rows are skipped")
+
+	first := rows[0]
+	assert.Equal(t, 1, first.Number)
+	assert.Empty(t, first.Errors)
+	assert.Equal(t, "Follow us", first.Task.Title)
+	assert.Equal(t, 2.5, first.Task.RewardUSDT)
+	assert.Equal(t, time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC), first.Task.DueDate)
+	assert.Equal(t, []int{1, 2}, first.Task.ActionIDs)
+	require.NotNil(t, first.Task.OrganizationID)
+	assert.Equal(t, int64(4), *first.Task.OrganizationID)
+	require.NotNil(t, first.Task.PublishAt)
+
+	second := rows[1]
+	assert.Equal(t, 2, second.Number)
+	assert.Equal(t, []string{
+		"reward_usdt must be a number",
+		"due_date must be a date or an RFC 3339 timestamp",
+		"action_ids must be ids separated by semicolons",
+	}, second.Errors)
+}
+
+func TestReadTasksCSVHeader(t *testing.T) {
+	_, err := ReadTasks(strings.NewReader("title,colour\nx,red\n"), FormatCSV)
+	assert.ErrorContains(t, err, `unknown column "colour"`)
+
+	_, err = ReadTasks(strings.NewReader("description\nx\n"), FormatCSV)
+	assert.ErrorContains(t, err, "title column")
+
+	_, err = ReadTasks(strings.NewReader(""), FormatCSV)
+	assert.ErrorContains(t, err, "empty")
+}
+
+func TestReadTasksJSON(t *testing.T) {
+	file := `[
+		{"title": "Follow us", "reward_usdt": 2.5, "status": "DRAFT", "eligibility": [{"kind": "min_x_followers", "threshold": 100}]},
+		{"title": "Repost", "reward_usdt": "lots"},
+		"nope"
+	]`
+
+	rows, err := ReadTasks(strings.NewReader(file), FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Empty(t, rows[0].Errors)
+	assert.Equal(t, store.TaskStatusDraft, rows[0].Task.Status)
+	assert.Len(t, rows[0].Task.Eligibility, 1)
+	assert.Equal(t, []string{"reward_usdt has the wrong type"}, rows[1].Errors)
+	assert.Equal(t, []string{"task must be a JSON object"}, rows[2].Errors)
+
+	_, err = ReadTasks(strings.NewReader(`{"title": "x"}`), FormatJSON)
+	assert.Error(t, err)
+}
+
+func TestReadTasksLimit(t *testing.T) {
+	file := "title\n" + strings.Repeat("task\n", MaxRows+1)
+	_, err := ReadTasks(strings.NewReader(file), FormatCSV)
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+// Package jobs runs background work queued in Postgres.
+//
+// A Kind ties a job's name to the Go type of its payload. Jobs are enqueued
+// through their kind and run by a Worker that has a handler for the kind.
+// Several workers, in the API process or in cmd/worker, can share the
+// queues: jobs are claimed with FOR UPDATE SKIP LOCKED and leased to one
+// worker at a time. A failed job is retried with DefaultBackoff until it
+// used up its attempts and then becomes dead, where it stays for someone to
+// look at. Handlers may run more than once for the same job and have to be
+// idempotent. Work that recurs runs as a job too, see Worker.Every.
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultQueue is the queue of kinds that do not name one.
+	DefaultQueue = "default"
+	// DefaultMaxAttempts is how often a job of a kind that does not say
+	// otherwise is run, with DefaultBackoff that is about 4 hours of
+	// retries.
+	DefaultMaxAttempts = 10
+)
+
+// Kind is a type of job whose payload is a T, encoded as JSON.
+type Kind[T any] struct {
+	Name string
+	// Queue defaults to DefaultQueue and MaxAttempts to
+	// DefaultMaxAttempts.
+	Queue       string
+	MaxAttempts int
+}
+
+func (k Kind[T]) queue() string {
+	if k.Queue == "" {
+		return DefaultQueue
+	}
+	return k.Queue
+}
+
+func (k Kind[T]) maxAttempts() int {
+	if k.MaxAttempts < 1 {
+		return DefaultMaxAttempts
+	}
+	return k.MaxAttempts
+}
+
+// Enqueue queues a job of the kind to run right away.
+func (k Kind[T]) Enqueue(jobStore store.JobStore, payload T) (*store.Job, error) {
+	return k.EnqueueAt(jobStore, payload, time.Time{})
+}
+
+// EnqueueAt queues a job of the kind to run once runAt has passed.
+func (k Kind[T]) EnqueueAt(jobStore store.JobStore, payload T, runAt time.Time) (*store.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return jobStore.EnqueueJob(&store.Job{
+		Queue:       k.queue(),
+		Kind:        k.Name,
+		Payload:     data,
+		MaxAttempts: k.maxAttempts(),
+		RunAt:       runAt,
+	})
+}
+
+// EnqueueOnce queues a job of the kind to run once runAt has passed, unless
+// a job of the kind with the same key is kept. It returns nil, nil then.
+func (k Kind[T]) EnqueueOnce(jobStore store.JobStore, key string, payload T, runAt time.Time) (*store.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return jobStore.EnqueueJob(&store.Job{
+		Queue:       k.queue(),
+		Kind:        k.Name,
+		Payload:     data,
+		UniqueKey:   k.Name + ":" + key,
+		MaxAttempts: k.maxAttempts(),
+		RunAt:       runAt,
+	})
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying will not fix, the job becomes dead
+// right away.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
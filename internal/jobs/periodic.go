@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// periodicMaxAttempts is how often a periodic run is tried, the next run
+// comes anyway.
+const periodicMaxAttempts = 3
+
+// Slot is the payload of a periodic job, the time the run is due.
+type Slot struct {
+	At time.Time `json:"at"`
+}
+
+// Every makes w run fn every interval as a job of the kind name. Runs are
+// due at multiples of interval and every run enqueues the next one first,
+// keyed by its time, so that each run is enqueued once however many
+// processes schedule it. Every enqueues the next run right away and returns
+// the error of doing so.
+func (w *Worker) Every(name string, interval time.Duration, fn func(ctx context.Context) error) error {
+	kind := Kind[Slot]{Name: name, MaxAttempts: periodicMaxAttempts}
+	schedule := func() error {
+		next := w.now().Truncate(interval).Add(interval)
+		_, err := kind.EnqueueOnce(w.jobStore, strconv.FormatInt(next.Unix(), 10), Slot{At: next}, next)
+		return err
+	}
+
+	Handle(w, kind, func(ctx context.Context, slot Slot) error {
+		err := schedule()
+		if err != nil {
+			return fmt.Errorf("scheduling the next run: %w", err)
+		}
+		return fn(ctx)
+	})
+	return schedule()
+}
@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultInterval is how often a worker looks for due jobs.
+	DefaultInterval = time.Second
+	// DefaultConcurrency is how many jobs of a queue a worker runs at the
+	// same time, unless Queue says otherwise.
+	DefaultConcurrency = 4
+	// DefaultDrainTimeout is how long a stopping worker waits for its
+	// running jobs before it cancels them.
+	DefaultDrainTimeout = 30 * time.Second
+	// cancelTimeout is how long a stopping worker waits for cancelled jobs
+	// to put themselves back in their queue.
+	cancelTimeout = 5 * time.Second
+	// Lease is how long a claimed job belongs to its worker, another worker
+	// may claim it again after that.
+	Lease = 5 * time.Minute
+	// Timeout is how long a job may run before its context is cancelled. It
+	// ends before the lease, so the outcome is recorded while the job still
+	// belongs to the worker.
+	Timeout = Lease - time.Minute
+)
+
+// Backoff is a wait between attempts that starts at First and doubles with
+// every failed attempt up to Max.
+type Backoff struct {
+	First time.Duration
+	Max   time.Duration
+}
+
+// DefaultBackoff is the wait between the attempts of a job.
+var DefaultBackoff = Backoff{First: 30 * time.Second, Max: 2 * time.Hour}
+
+// After is how long to wait before retrying what failed its attempt-th
+// attempt.
+func (b Backoff) After(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	wait := b.First
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= b.Max {
+			return b.Max
+		}
+	}
+	return wait
+}
+
+type attemptKey struct{}
+
+type attempt struct {
+	n, max int
+}
+
+// Attempt reports which attempt at its job the context of a handler runs,
+// counting from 1, and whether the job fails for good when it fails.
+func Attempt(ctx context.Context) (n int, last bool) {
+	a, _ := ctx.Value(attemptKey{}).(attempt)
+	return a.n, a.n >= a.max
+}
+
+// WithAttempt returns a context for running the n-th of maxAttempts at a
+// job, as the worker passes it to handlers.
+func WithAttempt(ctx context.Context, n, maxAttempts int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt{n: n, max: maxAttempts})
+}
+
+type handler func(ctx context.Context, job *store.Job) error
+
+// Worker runs the jobs of the kinds it has handlers for.
+type Worker struct {
+	jobStore     store.JobStore
+	interval     time.Duration
+	drainTimeout time.Duration
+	now          store.Clock
+	logger       *log.Logger
+
+	mu       sync.Mutex
+	queues   map[string]int
+	handlers map[string]handler
+}
+
+func NewWorker(jobStore store.JobStore, interval, drainTimeout time.Duration, clock store.Clock, logger *log.Logger) *Worker {
+	return &Worker{
+		jobStore:     jobStore,
+		interval:     interval,
+		drainTimeout: drainTimeout,
+		now:          clock,
+		logger:       logger,
+		queues:       map[string]int{},
+		handlers:     map[string]handler{},
+	}
+}
+
+// Queue sets how many jobs of the queue run at the same time. The queues of
+// the handled kinds run DefaultConcurrency jobs unless they are set.
+func (w *Worker) Queue(name string, concurrency int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queues[name] = max(concurrency, 1)
+}
+
+// Handle registers fn to run the jobs of kind. Handlers have to be
+// registered before the worker runs. A payload that does not decode makes
+// the job dead, as does an error marked with Permanent.
+func Handle[T any](w *Worker, kind Kind[T], fn func(ctx context.Context, payload T) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.queues[kind.queue()]; !ok {
+		w.queues[kind.queue()] = DefaultConcurrency
+	}
+	w.handlers[kind.Name] = func(ctx context.Context, job *store.Job) error {
+		var payload T
+		err := json.Unmarshal(job.Payload, &payload)
+		if err != nil {
+			return Permanent(fmt.Errorf("decoding payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Run works the queues until ctx is done. It then stops claiming jobs and
+// waits for the running ones to finish, for the drain timeout at most, after
+// which it cancels them and waits a little longer for them to be put back
+// in their queue. Jobs that do not stop in time run again once their lease
+// ran out.
+func (w *Worker) Run(ctx context.Context) {
+	// the jobs outlive ctx while the worker drains
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	w.mu.Lock()
+	queues := make(map[string]int, len(w.queues))
+	for name, concurrency := range w.queues {
+		queues[name] = concurrency
+	}
+	w.mu.Unlock()
+
+	var pollers, running sync.WaitGroup
+	for name, concurrency := range queues {
+		pollers.Add(1)
+		go func() {
+			defer pollers.Done()
+			w.poll(ctx, jobCtx, name, concurrency, &running)
+		}()
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		w.DeleteOld()
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	pollers.Wait()
+	drained := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return
+	case <-time.After(w.drainTimeout):
+		w.logger.Printf("ERROR: drainJobs: jobs still running after %s, cancelling them", w.drainTimeout)
+	}
+
+	// the caller may close the database once Run returns
+	cancelJobs()
+	select {
+	case <-drained:
+	case <-time.After(cancelTimeout):
+		w.logger.Printf("ERROR: drainJobs: cancelled jobs still running after %s", cancelTimeout)
+	}
+}
+
+// poll claims the queue's due jobs whenever one of its slots is free and
+// runs them until ctx is done.
+func (w *Worker) poll(ctx, jobCtx context.Context, queue string, concurrency int, running *sync.WaitGroup) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	slots := make(chan struct{}, concurrency)
+	// a finished job frees a slot, which is filled right away
+	freed := make(chan struct{}, 1)
+
+	for ctx.Err() == nil {
+		if free := concurrency - len(slots); free > 0 {
+			jobs, err := w.jobStore.ClaimJobs(queue, free, Lease)
+			if err != nil {
+				w.logger.Printf("ERROR: claimJobs: %v", err)
+			}
+
+			for _, job := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					w.run(jobCtx, job)
+					<-slots
+					select {
+					case freed <- struct{}{}:
+					default:
+					}
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-freed:
+		}
+	}
+}
+
+// run runs one claimed job and records the outcome.
+func (w *Worker) run(ctx context.Context, job *store.Job) {
+	w.mu.Lock()
+	h, ok := w.handlers[job.Kind]
+	w.mu.Unlock()
+
+	var err error
+	switch {
+	case job.Attempts > job.MaxAttempts:
+		// its last attempt never finished
+		err = Permanent(errors.New("lease ran out on the last attempt"))
+	case !ok:
+		// another worker might know the kind
+		err = fmt.Errorf("no handler for job kind %q", job.Kind)
+	default:
+		runCtx, cancel := context.WithTimeout(ctx, Timeout)
+		runCtx = WithAttempt(runCtx, job.Attempts, job.MaxAttempts)
+		err = call(runCtx, h, job)
+		cancel()
+	}
+
+	switch {
+	case err == nil:
+		err = w.jobStore.CompleteJob(job.ID, job.Attempts)
+	case ctx.Err() != nil:
+		// cancelled by a shutdown, not the job's fault
+		err = w.jobStore.RetryJob(job.ID, job.Attempts, "interrupted by shutdown", w.now())
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		w.logger.Printf("ERROR: runJob: %s %d is dead after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		err = w.jobStore.KillJob(job.ID, job.Attempts, err.Error())
+	default:
+		w.logger.Printf("ERROR: runJob: %s %d: %v", job.Kind, job.ID, err)
+		err = w.jobStore.RetryJob(job.ID, job.Attempts, err.Error(), w.now().Add(DefaultBackoff.After(job.Attempts)))
+	}
+	if err != nil {
+		// a lost lease leaves the job to the worker that claimed it again,
+		// otherwise the lease runs out and the job runs again
+		w.logger.Printf("ERROR: recordJob: %s %d: %v", job.Kind, job.ID, err)
+	}
+}
+
+func call(ctx context.Context, h handler, job *store.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// DeleteOld trims the finished jobs to store.JobRetention and
+// store.DeadJobRetention.
+func (w *Worker) DeleteOld() {
+	_, err := w.jobStore.DeleteOldJobs()
+	if err != nil {
+		w.logger.Printf("ERROR: deleteOldJobs: %v", err)
+	}
+}
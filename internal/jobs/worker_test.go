@@ -0,0 +1,451 @@
+package jobs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJobStore keeps jobs in memory and claims them by its clock like the
+// Postgres store.
+type fakeJobStore struct {
+	mu   sync.Mutex
+	now  time.Time
+	jobs []*store.Job
+}
+
+func (f *fakeJobStore) clock() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeJobStore) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *fakeJobStore) job(id int64) store.Job {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return *f.jobs[id-1]
+}
+
+func (f *fakeJobStore) EnqueueJob(job *store.Job) (*store.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if job.RunAt.IsZero() {
+		job.RunAt = f.now
+	}
+	for _, existing := range f.jobs {
+		if job.UniqueKey != "" && existing.UniqueKey == job.UniqueKey {
+			return nil, nil
+		}
+	}
+	job.ID = int64(len(f.jobs) + 1)
+	job.Status = store.JobPending
+	f.jobs = append(f.jobs, job)
+	copied := *job
+	return &copied, nil
+}
+
+func (f *fakeJobStore) ClaimJobs(queue string, limit int, lease time.Duration) ([]*store.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var claimed []*store.Job
+	for _, j := range f.jobs {
+		due := (j.Status == store.JobPending || j.Status == store.JobRunning) && !j.RunAt.After(f.now)
+		if j.Queue != queue || !due || len(claimed) == limit {
+			continue
+		}
+		j.Status = store.JobRunning
+		j.Attempts++
+		j.RunAt = f.now.Add(lease)
+		copied := *j
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+// claimed returns the job while attempt still holds its lease.
+func (f *fakeJobStore) claimed(id int64, attempt int) (*store.Job, error) {
+	j := f.jobs[id-1]
+	if j.Status != store.JobRunning || j.Attempts != attempt {
+		return nil, store.ErrJobLeaseLost
+	}
+	return j, nil
+}
+
+func (f *fakeJobStore) CompleteJob(id int64, attempt int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, err := f.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	j.Status = store.JobSucceeded
+	return nil
+}
+
+func (f *fakeJobStore) RetryJob(id int64, attempt int, reason string, runAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, err := f.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	j.Status = store.JobPending
+	j.RunAt = runAt
+	j.LastError = reason
+	return nil
+}
+
+func (f *fakeJobStore) KillJob(id int64, attempt int, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, err := f.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	j.Status = store.JobDead
+	j.LastError = reason
+	return nil
+}
+
+func (f *fakeJobStore) GetJob(id int64) (*store.Job, error) {
+	job := f.job(id)
+	return &job, nil
+}
+
+func (f *fakeJobStore) DeleteOldJobs() (int64, error) {
+	return 0, nil
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+var greet = jobs.Kind[greeting]{Name: "greet", MaxAttempts: 2}
+
+// start runs the worker until the returned stop is called, which waits for
+// it to drain.
+func start(w *jobs.Worker) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestWorker(t *testing.T) {
+	const wait, tick = time.Second, 5 * time.Millisecond
+
+	newWorker := func(jobStore *fakeJobStore, drainTimeout time.Duration) (*jobs.Worker, *bytes.Buffer) {
+		var logs bytes.Buffer
+		return jobs.NewWorker(jobStore, tick, drainTimeout, jobStore.clock, log.New(&logs, "", 0)), &logs
+	}
+
+	t.Run("runs typed jobs once they are due", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, _ := newWorker(jobStore, time.Second)
+
+		var mu sync.Mutex
+		var names []string
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, g.Name)
+			return nil
+		})
+
+		_, err := greet.Enqueue(jobStore, greeting{Name: "now"})
+		require.NoError(t, err)
+		later, err := greet.EnqueueAt(jobStore, greeting{Name: "later"}, jobStore.clock().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, jobs.DefaultQueue, later.Queue)
+		assert.Equal(t, 2, later.MaxAttempts)
+
+		stop := start(worker)
+		defer stop()
+
+		assert.Eventually(t, func() bool { return jobStore.job(1).Status == store.JobSucceeded }, wait, tick)
+		assert.Equal(t, store.JobPending, jobStore.job(2).Status)
+
+		jobStore.advance(time.Hour)
+		assert.Eventually(t, func() bool { return jobStore.job(2).Status == store.JobSucceeded }, wait, tick)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"now", "later"}, names)
+	})
+
+	t.Run("unique jobs are enqueued once per key", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+
+		first, err := greet.EnqueueOnce(jobStore, "daily", greeting{Name: "first"}, time.Time{})
+		require.NoError(t, err)
+		require.NotNil(t, first)
+		assert.Equal(t, greet.Name+":daily", first.UniqueKey)
+
+		again, err := greet.EnqueueOnce(jobStore, "daily", greeting{Name: "again"}, time.Time{})
+		require.NoError(t, err)
+		assert.Nil(t, again)
+
+		other, err := greet.EnqueueOnce(jobStore, "weekly", greeting{Name: "other"}, time.Time{})
+		require.NoError(t, err)
+		assert.NotNil(t, other)
+	})
+
+	t.Run("failed jobs are retried and then dead", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, logs := newWorker(jobStore, time.Second)
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			if g.Name == "panic" {
+				panic("oops")
+			}
+			return errors.New("mailbox full")
+		})
+
+		_, err := greet.Enqueue(jobStore, greeting{Name: "retry"})
+		require.NoError(t, err)
+		_, err = greet.Enqueue(jobStore, greeting{Name: "panic"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		defer stop()
+
+		assert.Eventually(t, func() bool { return jobStore.job(1).LastError == "mailbox full" }, wait, tick)
+		assert.Eventually(t, func() bool { return jobStore.job(2).LastError == "panic: oops" }, wait, tick)
+		first := jobStore.job(1)
+		assert.Equal(t, store.JobPending, first.Status)
+		assert.Equal(t, jobStore.clock().Add(jobs.DefaultBackoff.After(1)), first.RunAt)
+
+		jobStore.advance(jobs.DefaultBackoff.After(1))
+		assert.Eventually(t, func() bool { return jobStore.job(1).Status == store.JobDead }, wait, tick)
+		assert.Equal(t, 2, jobStore.job(1).Attempts)
+		assert.Eventually(t, func() bool { return jobStore.job(2).Status == store.JobDead }, wait, tick)
+		assert.Contains(t, logs.String(), "ERROR: runJob: greet 1 is dead after 2 attempts")
+	})
+
+	t.Run("handlers are told their attempt", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, _ := newWorker(jobStore, time.Second)
+
+		type attempt struct {
+			n    int
+			last bool
+		}
+		attempts := make(chan attempt, 2)
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			n, last := jobs.Attempt(ctx)
+			attempts <- attempt{n: n, last: last}
+			return errors.New("mailbox full")
+		})
+
+		_, err := greet.Enqueue(jobStore, greeting{Name: "retry"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		defer stop()
+
+		assert.Equal(t, attempt{n: 1, last: false}, <-attempts)
+		jobStore.advance(jobs.DefaultBackoff.After(1))
+		assert.Equal(t, attempt{n: 2, last: true}, <-attempts)
+	})
+
+	t.Run("periodic jobs run once per slot", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Date(2025, time.November, 10, 9, 10, 0, 0, time.UTC)}
+		worker, _ := newWorker(jobStore, time.Second)
+		other, _ := newWorker(jobStore, time.Second)
+
+		var runs atomic.Int32
+		count := func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}
+		require.NoError(t, worker.Every("tick", time.Hour, count))
+		require.NoError(t, other.Every("tick", time.Hour, count))
+
+		slot := time.Date(2025, time.November, 10, 10, 0, 0, 0, time.UTC)
+		first := jobStore.job(1)
+		assert.Equal(t, slot, first.RunAt)
+		assert.Equal(t, "tick:"+strconv.FormatInt(slot.Unix(), 10), first.UniqueKey)
+		jobStore.mu.Lock()
+		assert.Len(t, jobStore.jobs, 1)
+		jobStore.mu.Unlock()
+
+		stop := start(worker)
+		defer stop()
+
+		jobStore.advance(50 * time.Minute)
+		assert.Eventually(t, func() bool { return jobStore.job(1).Status == store.JobSucceeded }, wait, tick)
+		assert.Equal(t, int32(1), runs.Load())
+		next := jobStore.job(2)
+		assert.Equal(t, store.JobPending, next.Status)
+		assert.Equal(t, slot.Add(time.Hour), next.RunAt)
+	})
+
+	t.Run("bad payloads and permanent errors are dead right away", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, _ := newWorker(jobStore, time.Second)
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			return jobs.Permanent(errors.New("no such user"))
+		})
+
+		_, err := jobStore.EnqueueJob(&store.Job{Queue: jobs.DefaultQueue, Kind: "greet", Payload: []byte(`"not an object"`), MaxAttempts: 5})
+		require.NoError(t, err)
+		_, err = greet.Enqueue(jobStore, greeting{Name: "gone"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		defer stop()
+
+		assert.Eventually(t, func() bool { return jobStore.job(1).Status == store.JobDead }, wait, tick)
+		assert.Contains(t, jobStore.job(1).LastError, "decoding payload")
+		assert.Eventually(t, func() bool { return jobStore.job(2).Status == store.JobDead }, wait, tick)
+		assert.Equal(t, 1, jobStore.job(2).Attempts)
+	})
+
+	t.Run("queues run at most their concurrency", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, _ := newWorker(jobStore, time.Second)
+		worker.Queue(jobs.DefaultQueue, 2)
+
+		var mu sync.Mutex
+		running, most := 0, 0
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			mu.Lock()
+			running++
+			most = max(most, running)
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+
+		for range 6 {
+			_, err := greet.Enqueue(jobStore, greeting{Name: "busy"})
+			require.NoError(t, err)
+		}
+
+		stop := start(worker)
+		defer stop()
+
+		assert.Eventually(t, func() bool { return jobStore.job(6).Status == store.JobSucceeded }, wait, tick)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 2, most)
+	})
+
+	t.Run("a job reclaimed after its lease is left to the new worker", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, logs := newWorker(jobStore, time.Second)
+		// no free slot, so only the other worker claims it
+		worker.Queue(jobs.DefaultQueue, 1)
+
+		started, release := make(chan struct{}), make(chan struct{})
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			close(started)
+			<-release
+			return errors.New("too slow")
+		})
+		_, err := greet.Enqueue(jobStore, greeting{Name: "slow"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		<-started
+
+		// another worker claims the job once the lease ran out
+		jobStore.advance(jobs.Lease)
+		reclaimed, err := jobStore.ClaimJobs(jobs.DefaultQueue, 1, jobs.Lease)
+		require.NoError(t, err)
+		require.Len(t, reclaimed, 1)
+		close(release)
+		stop()
+
+		assert.Contains(t, logs.String(), "ERROR: recordJob: greet 1: lost lease")
+		job := jobStore.job(1)
+		assert.Equal(t, store.JobRunning, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+		assert.Empty(t, job.LastError)
+	})
+
+	t.Run("stopping drains the running jobs", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, _ := newWorker(jobStore, time.Second)
+
+		started, release := make(chan struct{}), make(chan struct{})
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		})
+		_, err := greet.Enqueue(jobStore, greeting{Name: "slow"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		<-started
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+		}()
+		stop()
+
+		assert.Equal(t, store.JobSucceeded, jobStore.job(1).Status)
+	})
+
+	t.Run("jobs still running after the drain timeout are cancelled", func(t *testing.T) {
+		jobStore := &fakeJobStore{now: time.Now()}
+		worker, logs := newWorker(jobStore, 20*time.Millisecond)
+
+		started := make(chan struct{})
+		jobs.Handle(worker, greet, func(ctx context.Context, g greeting) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		_, err := greet.Enqueue(jobStore, greeting{Name: "stuck"})
+		require.NoError(t, err)
+
+		stop := start(worker)
+		<-started
+		stop()
+
+		assert.Contains(t, logs.String(), "ERROR: drainJobs")
+		// recorded before Run returned
+		assert.Equal(t, "interrupted by shutdown", jobStore.job(1).LastError)
+		assert.Equal(t, store.JobPending, jobStore.job(1).Status)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, jobs.DefaultBackoff.After(0))
+	assert.Equal(t, 30*time.Second, jobs.DefaultBackoff.After(1))
+	assert.Equal(t, 2*time.Minute, jobs.DefaultBackoff.After(3))
+	assert.Equal(t, 2*time.Hour, jobs.DefaultBackoff.After(20))
+
+	fast := jobs.Backoff{First: time.Second, Max: 10 * time.Minute}
+	assert.Equal(t, 4*time.Second, fast.After(3))
+	assert.Equal(t, 10*time.Minute, fast.After(30))
+}
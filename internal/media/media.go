@@ -0,0 +1,147 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxUploadBytes is the largest file accepted for upload.
+	MaxUploadBytes = 10 << 20
+	// MaxPixels guards against small files that decode into huge images.
+	MaxPixels = 40_000_000
+	// ThumbnailSize is the longest side of a thumbnail in pixels.
+	ThumbnailSize = 320
+
+	jpegQuality = 90
+)
+
+var (
+	ErrUnsupportedType = errors.New("file must be a JPEG or PNG image")
+	ErrInvalidImage    = errors.New("image could not be decoded")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// Image is an uploaded image after processing. Data and Thumbnail are
+// re-encoded from the decoded pixels, so they carry no EXIF or other
+// metadata from the original file.
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	Thumbnail   []byte
+}
+
+// Process sniffs the upload's real content type, ignoring whatever the client
+// claimed, then strips its metadata and renders a thumbnail in the same
+// format. JPEGs are rotated upright first, their EXIF orientation is lost
+// with the rest of the metadata.
+func Process(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	img := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	out := &Image{
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	out.Data, err = encode(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Thumbnail, err = encode(fit(img, ThumbnailSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return buf.Bytes(), err
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// fit scales img down so its longest side is at most size, averaging every
+// source pixel that falls into a destination pixel. Smaller images are kept.
+func fit(img *image.RGBA, size int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	dw, dh := size, h*size/w
+	if h > w {
+		dw, dh = w*size/h, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*h/dh, max((dy+1)*h/dh, dy*h/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*w/dw, max((dx+1)*w/dw, dx*w/dw+1)
+
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := img.Pix[y*img.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			o := dst.Pix[dy*dst.Stride+dx*4:]
+			for i := range 4 {
+				o[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,141 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// halves is w x h, red on the left half and blue on the right.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment carrying orientation right after
+// the JPEG's start of image marker.
+func withOrientation(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	// tag, SHORT, one value, value padded to four bytes
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func TestProcessJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, halves(40, 20), &jpeg.Options{Quality: 100}))
+	upload := withOrientation(t, buf.Bytes(), 6)
+	require.Equal(t, 6, exifOrientation(upload))
+
+	img, err := Process(upload)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", img.ContentType)
+	assert.False(t, bytes.Contains(img.Data, []byte("Exif")))
+	assert.Equal(t, 1, exifOrientation(img.Data))
+
+	// turned clockwise the left (red) half ends up on top
+	assert.Equal(t, 20, img.Width)
+	assert.Equal(t, 40, img.Height)
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	r, _, b, _ := decoded.At(10, 5).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = decoded.At(10, 35).RGBA()
+	assert.Greater(t, b, r)
+}
+
+func TestProcessPNGThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, halves(1000, 500)))
+
+	img, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, 1000, img.Width)
+
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, ThumbnailSize, ThumbnailSize/2), thumb.Bounds())
+
+	// small images are not scaled up
+	buf.Reset()
+	require.NoError(t, png.Encode(&buf, halves(10, 10)))
+	img, err = Process(buf.Bytes())
+	require.NoError(t, err)
+	thumb, err = png.Decode(bytes.NewReader(img.Thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, 10, thumb.Bounds().Dx())
+}
+
+func TestProcessRejects(t *testing.T) {
+	_, err := Process([]byte("<html>not an image</html>"))
+	assert.Equal(t, ErrUnsupportedType, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, gif.Encode(&buf, halves(4, 4), nil))
+	_, err = Process(buf.Bytes())
+	assert.Equal(t, ErrUnsupportedType, err)
+
+	// a valid PNG signature followed by garbage
+	_, err = Process(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	assert.Equal(t, ErrInvalidImage, err)
+
+	// a tiny file claiming to be 20000 x 20000
+	buf.Reset()
+	require.NoError(t, png.Encode(&buf, halves(1, 1)))
+	bomb := buf.Bytes()
+	binary.BigEndian.PutUint32(bomb[16:], 20000)
+	binary.BigEndian.PutUint32(bomb[20:], 20000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	_, err = Process(bomb)
+	assert.Equal(t, ErrTooManyPixels, err)
+}
+
+func TestOrient(t *testing.T) {
+	src := halves(4, 2)
+	for o := 1; o <= 8; o++ {
+		out := orient(src, o)
+		if o >= 5 {
+			assert.Equal(t, image.Rect(0, 0, 2, 4), out.Bounds(), "orientation %d", o)
+		} else {
+			assert.Equal(t, image.Rect(0, 0, 4, 2), out.Bounds(), "orientation %d", o)
+		}
+	}
+	// rotating 180 moves the red half to the right
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, orient(src, 3).RGBAAt(0, 0))
+}
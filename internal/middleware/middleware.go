@@ -46,7 +46,7 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 		authHeader := r.Header.Get("Authorization")
 
 		if authHeader == "" {
-			SetUser(r, store.AnonymousUser)
+			r = SetUser(r, store.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -96,3 +96,27 @@ func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (um *UserMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return um.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := GetUser(r)
+		if !user.IsAdmin() {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (um *UserMiddleware) RequireModerator(next http.HandlerFunc) http.HandlerFunc {
+	return um.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := GetUser(r)
+		if !user.IsModerator() {
+			utils.WriteJSON(w, utils.StatusError, utils.MessageForbidden, http.StatusForbidden, nil, nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
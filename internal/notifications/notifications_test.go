@@ -0,0 +1,183 @@
+package notifications_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/notifications"
+	"github.com/harundarat/be-socialtask/internal/outbox"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotificationStore keeps notifications in memory and dedupes them like
+// the Postgres store.
+type fakeNotificationStore struct {
+	store.NotificationStore
+	notifications  []store.Notification
+	disabled       map[store.NotificationCategory]bool
+	participations []store.ExpiringParticipation
+}
+
+func (f *fakeNotificationStore) CreateNotification(n *store.Notification) (bool, error) {
+	if f.disabled[n.Category] {
+		return false, nil
+	}
+	for _, existing := range f.notifications {
+		if existing.UserID == n.UserID && existing.DedupeKey == n.DedupeKey {
+			return false, nil
+		}
+	}
+	n.ID = int64(len(f.notifications) + 1)
+	f.notifications = append(f.notifications, *n)
+	return true, nil
+}
+
+func (f *fakeNotificationStore) GetExpiringParticipations(from, to time.Time) ([]store.ExpiringParticipation, error) {
+	var due []store.ExpiringParticipation
+	for _, p := range f.participations {
+		if p.DueDate.After(from) && !p.DueDate.After(to) {
+			due = append(due, p)
+		}
+	}
+	return due, nil
+}
+
+// fakeJobStore keeps the enqueued jobs and skips those with a unique key
+// that is taken, like the Postgres store.
+type fakeJobStore struct {
+	store.JobStore
+	jobs []*store.Job
+}
+
+func (f *fakeJobStore) EnqueueJob(job *store.Job) (*store.Job, error) {
+	for _, existing := range f.jobs {
+		if job.UniqueKey != "" && existing.UniqueKey == job.UniqueKey {
+			return nil, nil
+		}
+	}
+	job.ID = int64(len(f.jobs) + 1)
+	f.jobs = append(f.jobs, job)
+	return job, nil
+}
+
+type fakeTaskStore struct {
+	store.TaskStore
+	tasks map[int64]*store.Task
+}
+
+func (f *fakeTaskStore) GetTaskByID(id int64) (*store.Task, error) {
+	return f.tasks[id], nil
+}
+
+func event(t *testing.T, id int64, eventType store.OutboxEventType, data store.OutboxTaskData) *store.OutboxEvent {
+	payload, err := json.Marshal(data)
+	require.NoError(t, err)
+	return &store.OutboxEvent{ID: id, AggregateType: store.AggregateTask, AggregateID: data.TaskID, Type: eventType, Payload: payload}
+}
+
+func TestNotifier(t *testing.T) {
+	notificationStore := &fakeNotificationStore{}
+	taskStore := &fakeTaskStore{tasks: map[int64]*store.Task{1: {ID: 1, Title: "Follow us"}}}
+	notifier := notifications.NewNotifier(notificationStore, taskStore, log.New(&bytes.Buffer{}, "", 0))
+	bus := outbox.NewBus()
+	notifier.Subscribe(bus)
+	ctx := context.Background()
+
+	t.Run("reviews and rewards notify the participant", func(t *testing.T) {
+		require.NoError(t, bus.Publish(ctx, event(t, 1, store.OutboxTaskCompleted, store.OutboxTaskData{TaskID: 1, UserID: 7})))
+		require.NoError(t, bus.Publish(ctx, event(t, 2, store.OutboxRewardCreated, store.OutboxTaskData{TaskID: 1, UserID: 7, RewardUSDT: 2.5})))
+		require.NoError(t, bus.Publish(ctx, event(t, 3, store.OutboxTaskRejected, store.OutboxTaskData{TaskID: 1, UserID: 8})))
+
+		require.Len(t, notificationStore.notifications, 3)
+		approved := notificationStore.notifications[0]
+		assert.Equal(t, int64(7), approved.UserID)
+		assert.Equal(t, store.NotificationSubmissions, approved.Category)
+		assert.Equal(t, store.NotificationSubmissionApproved, approved.Type)
+		assert.Equal(t, `Your work on "Follow us" was approved.`, approved.Body)
+		assert.Equal(t, int64(1), approved.Data.TaskID)
+
+		reward := notificationStore.notifications[1]
+		assert.Equal(t, store.NotificationRewards, reward.Category)
+		assert.Equal(t, `You earned 2.5 USDT for "Follow us".`, reward.Body)
+		assert.Equal(t, 2.5, reward.Data.RewardUSDT)
+
+		assert.Equal(t, store.NotificationSubmissionRejected, notificationStore.notifications[2].Type)
+	})
+
+	t.Run("a republished event notifies once", func(t *testing.T) {
+		require.NoError(t, bus.Publish(ctx, event(t, 1, store.OutboxTaskCompleted, store.OutboxTaskData{TaskID: 1, UserID: 7})))
+		assert.Len(t, notificationStore.notifications, 3)
+	})
+
+	t.Run("other events and deleted tasks are skipped", func(t *testing.T) {
+		require.NoError(t, bus.Publish(ctx, event(t, 4, store.OutboxTaskJoined, store.OutboxTaskData{TaskID: 1, UserID: 7})))
+		require.NoError(t, bus.Publish(ctx, event(t, 5, store.OutboxTaskCompleted, store.OutboxTaskData{TaskID: 2, UserID: 7})))
+		require.NoError(t, notifier.HandleEvent(ctx, &store.OutboxEvent{ID: 6, Type: store.OutboxTaskCompleted, Payload: json.RawMessage(`[]`)}))
+		assert.Len(t, notificationStore.notifications, 3)
+	})
+}
+
+func TestReminder(t *testing.T) {
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	notificationStore := &fakeNotificationStore{
+		participations: []store.ExpiringParticipation{
+			{TaskID: 1, TaskTitle: "Follow us", UserID: 7, DueDate: now.Add(5 * time.Hour)},
+			{TaskID: 2, TaskTitle: "Retweet", UserID: 7, DueDate: now.Add(30 * time.Minute)},
+			{TaskID: 3, TaskTitle: "Join the server", UserID: 7, DueDate: now.Add(48 * time.Hour)},
+			{TaskID: 1, TaskTitle: "Follow us", UserID: 8, DueDate: now.Add(5 * time.Hour)},
+		},
+		disabled: map[store.NotificationCategory]bool{},
+	}
+	jobStore := &fakeJobStore{}
+	reminder := notifications.NewReminder(notificationStore, time.Hour, func() time.Time { return now })
+
+	remind := func(t *testing.T) int {
+		created, err := reminder.RemindExpiring()
+		require.NoError(t, err)
+		return created
+	}
+
+	t.Run("participants of tasks ending soon are reminded", func(t *testing.T) {
+		assert.Equal(t, 3, remind(t))
+		require.Len(t, notificationStore.notifications, 3)
+
+		first := notificationStore.notifications[0]
+		assert.Equal(t, store.NotificationReminders, first.Category)
+		assert.Equal(t, store.NotificationTaskExpiring, first.Type)
+		assert.Equal(t, `"Follow us" ends in 5 hours, submit your work before it closes.`, first.Body)
+		assert.Equal(t, store.ExpiringTaskKey(1, now.Add(5*time.Hour)), first.DedupeKey)
+		assert.Contains(t, notificationStore.notifications[1].Body, "less than an hour")
+	})
+
+	t.Run("participants are reminded once", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		assert.Equal(t, 0, remind(t))
+	})
+
+	t.Run("a moved due date reminds again", func(t *testing.T) {
+		notificationStore.participations[0].DueDate = now.Add(10 * time.Hour)
+		assert.Equal(t, 1, remind(t))
+	})
+
+	t.Run("disabled categories are not reminded", func(t *testing.T) {
+		notificationStore.disabled[store.NotificationReminders] = true
+		now = now.Add(24 * time.Hour)
+		assert.Equal(t, 0, remind(t))
+	})
+
+	t.Run("reminders run as a periodic job", func(t *testing.T) {
+		worker := jobs.NewWorker(jobStore, time.Second, time.Second, func() time.Time { return now }, log.New(io.Discard, "", 0))
+		require.NoError(t, reminder.Register(worker))
+		require.Len(t, jobStore.jobs, 1)
+		assert.Equal(t, "notifications.remind_expiring", jobStore.jobs[0].Kind)
+		assert.Equal(t, now.Truncate(time.Hour).Add(time.Hour), jobStore.jobs[0].RunAt)
+	})
+}
@@ -0,0 +1,83 @@
+// Package notifications fills the users' in-app inbox: from the events on
+// the outbox bus and with reminders about tasks that are about to end.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/harundarat/be-socialtask/internal/outbox"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// Notifier turns the events of a user's participations into notifications.
+type Notifier struct {
+	notificationStore store.NotificationStore
+	taskStore         store.TaskStore
+	logger            *log.Logger
+}
+
+func NewNotifier(notificationStore store.NotificationStore, taskStore store.TaskStore, logger *log.Logger) *Notifier {
+	return &Notifier{
+		notificationStore: notificationStore,
+		taskStore:         taskStore,
+		logger:            logger,
+	}
+}
+
+// Subscribe registers the notifier for the events it notifies about.
+func (n *Notifier) Subscribe(bus *outbox.Bus) {
+	bus.Subscribe("notifications", n.HandleEvent, store.OutboxTaskCompleted, store.OutboxTaskRejected, store.OutboxRewardCreated)
+}
+
+// HandleEvent notifies the participant of the event. Events are published
+// at least once, the event id makes sure the user is notified once.
+func (n *Notifier) HandleEvent(ctx context.Context, e *store.OutboxEvent) error {
+	var data store.OutboxTaskData
+	err := e.Decode(&data)
+	if err != nil {
+		// publishing it again will not help
+		n.logger.Printf("ERROR: decodeNotificationEvent: %v", err)
+		return nil
+	}
+
+	task, err := n.taskStore.GetTaskByID(data.TaskID)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		// deleted since, there is nothing to link to
+		return nil
+	}
+
+	notification := &store.Notification{
+		UserID:    data.UserID,
+		Data:      store.NotificationData{TaskID: data.TaskID},
+		DedupeKey: fmt.Sprintf("event:%d", e.ID),
+	}
+	switch e.Type {
+	case store.OutboxTaskCompleted:
+		notification.Category = store.NotificationSubmissions
+		notification.Type = store.NotificationSubmissionApproved
+		notification.Title = "Submission approved"
+		notification.Body = fmt.Sprintf("Your work on %q was approved.", task.Title)
+	case store.OutboxTaskRejected:
+		notification.Category = store.NotificationSubmissions
+		notification.Type = store.NotificationSubmissionRejected
+		notification.Title = "Submission rejected"
+		notification.Body = fmt.Sprintf("Your work on %q was not approved.", task.Title)
+	case store.OutboxRewardCreated:
+		notification.Category = store.NotificationRewards
+		notification.Type = store.NotificationRewardReceived
+		notification.Title = "Reward received"
+		notification.Body = fmt.Sprintf("You earned %s USDT for %q.", strconv.FormatFloat(data.RewardUSDT, 'f', -1, 64), task.Title)
+		notification.Data.RewardUSDT = data.RewardUSDT
+	default:
+		return nil
+	}
+
+	_, err = n.notificationStore.CreateNotification(notification)
+	return err
+}
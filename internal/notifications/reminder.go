@@ -0,0 +1,87 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultReminderInterval is how often tasks about to end are looked for.
+	DefaultReminderInterval = 15 * time.Minute
+	// ReminderWindow is how long before a task ends its participants who
+	// have not submitted yet are reminded.
+	ReminderWindow = 24 * time.Hour
+)
+
+// Reminder reminds participants of the tasks they joined and did not submit
+// that end within ReminderWindow, once per task and end date.
+type Reminder struct {
+	notificationStore store.NotificationStore
+	interval          time.Duration
+	now               store.Clock
+}
+
+func NewReminder(notificationStore store.NotificationStore, interval time.Duration, clock store.Clock) *Reminder {
+	return &Reminder{
+		notificationStore: notificationStore,
+		interval:          interval,
+		now:               clock,
+	}
+}
+
+// Register makes w send the due reminders every interval.
+func (r *Reminder) Register(w *jobs.Worker) error {
+	return w.Every("notifications.remind_expiring", r.interval, func(ctx context.Context) error {
+		_, err := r.RemindExpiring()
+		return err
+	})
+}
+
+// RemindExpiring sends the due reminders and returns how many were created.
+// It sends all it can and returns the errors of those that failed.
+func (r *Reminder) RemindExpiring() (int, error) {
+	now := r.now()
+	participations, err := r.notificationStore.GetExpiringParticipations(now, now.Add(ReminderWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, p := range participations {
+		ok, err := r.notificationStore.CreateNotification(&store.Notification{
+			UserID:    p.UserID,
+			Category:  store.NotificationReminders,
+			Type:      store.NotificationTaskExpiring,
+			Title:     "Task ending soon",
+			Body:      fmt.Sprintf("%q ends in %s, submit your work before it closes.", p.TaskTitle, remaining(p.DueDate.Sub(now))),
+			Data:      store.NotificationData{TaskID: p.TaskID},
+			DedupeKey: store.ExpiringTaskKey(p.TaskID, p.DueDate),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reminding user %d of task %d: %w", p.UserID, p.TaskID, err))
+			continue
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// remaining spells out the time left in whole hours.
+func remaining(d time.Duration) string {
+	switch hours := int(d / time.Hour); {
+	case hours < 1:
+		return "less than an hour"
+	case hours == 1:
+		return "1 hour"
+	default:
+		return fmt.Sprintf("%d hours", hours)
+	}
+}
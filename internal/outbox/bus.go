@@ -0,0 +1,88 @@
+// Package outbox publishes the events the stores write to the outbox table
+// to in-process subscribers.
+//
+// Events are written in the transaction of the change they report, so a
+// subscriber never hears of a change that was rolled back and never misses
+// one that was committed. Delivery is at least once: an event is published
+// again, to every subscriber, when any of them fails or the process dies
+// before the outcome is recorded, so subscribers have to be idempotent. The
+// events of an aggregate, like a task, reach the subscribers in the order
+// they were written.
+//
+// Webhooks do not subscribe here, their deliveries are queued in the same
+// transaction as the outbox event, see recordTaskEvent in the store.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// Handler reacts to an event. An error has the event published again later.
+type Handler func(ctx context.Context, e *store.OutboxEvent) error
+
+type subscription struct {
+	name    string
+	types   map[store.OutboxEventType]bool
+	handler Handler
+}
+
+// Bus hands events to the subscribers registered for their type.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers handler for the given event types, or for every event
+// when none is given. The name shows up in errors.
+func (b *Bus) Subscribe(name string, handler Handler, types ...store.OutboxEventType) {
+	sub := subscription{name: name, handler: handler}
+	if len(types) > 0 {
+		sub.types = make(map[store.OutboxEventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions = append(b.subscriptions, sub)
+}
+
+// Publish calls every subscriber of the event, in the order they subscribed,
+// and returns what went wrong with each. Every subscriber is called even
+// when an earlier one fails, and a panicking subscriber is a failure.
+func (b *Bus) Publish(ctx context.Context, e *store.OutboxEvent) error {
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subscriptions {
+		if sub.types != nil && !sub.types[e.Type] {
+			continue
+		}
+		err := call(ctx, sub.handler, e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func call(ctx context.Context, handler Handler, e *store.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, e)
+}
@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DefaultInterval is how often the dispatcher looks for new events.
+	DefaultInterval = time.Second
+	// BatchSize is how many events are claimed at a time.
+	BatchSize = 100
+)
+
+// backoff is the wait between the publications of an event that failed,
+// which is retried for as long as it takes.
+var backoff = jobs.Backoff{First: time.Second, Max: 10 * time.Minute}
+
+// Dispatcher publishes the events in the outbox to the bus in the
+// background. A batch holds at most one event per aggregate, the next one is
+// only claimed once the previous one was published.
+type Dispatcher struct {
+	outboxStore store.OutboxStore
+	bus         *Bus
+	interval    time.Duration
+	now         store.Clock
+	logger      *log.Logger
+}
+
+func NewDispatcher(outboxStore store.OutboxStore, bus *Bus, interval time.Duration, clock store.Clock, logger *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		outboxStore: outboxStore,
+		bus:         bus,
+		interval:    interval,
+		now:         clock,
+		logger:      logger,
+	}
+}
+
+// Run publishes due events and trims the outbox right away and then on every
+// tick until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.PublishPending(ctx)
+		d.DeleteOld()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending publishes due events until none is left and returns how
+// many were published.
+func (d *Dispatcher) PublishPending(ctx context.Context) int {
+	published := 0
+	for ctx.Err() == nil {
+		events, err := d.outboxStore.ClaimOutboxEvents(BatchSize, jobs.Lease)
+		if err != nil {
+			d.logger.Printf("ERROR: claimOutboxEvents: %v", err)
+			break
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		for _, e := range events {
+			if d.publish(ctx, e) {
+				published++
+			}
+		}
+	}
+	return published
+}
+
+// publish hands one event to the bus and records the outcome.
+func (d *Dispatcher) publish(ctx context.Context, e *store.OutboxEvent) bool {
+	err := d.bus.Publish(ctx, e)
+	if err != nil {
+		d.logger.Printf("ERROR: publishOutboxEvent: %s %d: %v", e.Type, e.ID, err)
+		err = d.outboxStore.RecordOutboxFailure(e.ID, err.Error(), d.now().Add(backoff.After(e.Attempts)))
+		if err != nil {
+			// the lease runs out and the event is published again
+			d.logger.Printf("ERROR: recordOutboxFailure: %v", err)
+		}
+		return false
+	}
+
+	err = d.outboxStore.MarkOutboxEventPublished(e.ID)
+	if err != nil {
+		d.logger.Printf("ERROR: markOutboxEventPublished: %v", err)
+		return false
+	}
+	return true
+}
+
+// DeleteOld trims the outbox to store.OutboxRetention.
+func (d *Dispatcher) DeleteOld() {
+	_, err := d.outboxStore.DeleteOldOutboxEvents()
+	if err != nil {
+		d.logger.Printf("ERROR: deleteOldOutboxEvents: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/outbox"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOutboxStore keeps events in memory and claims them like the Postgres
+// store: due, and only the oldest unpublished event of an aggregate.
+type fakeOutboxStore struct {
+	now    *time.Time
+	events []*fakeEvent
+}
+
+type fakeEvent struct {
+	event     store.OutboxEvent
+	dueAt     time.Time
+	published bool
+	lastError string
+}
+
+func (f *fakeOutboxStore) add(aggregateID int64, eventType store.OutboxEventType) {
+	f.events = append(f.events, &fakeEvent{
+		event: store.OutboxEvent{ID: int64(len(f.events) + 1), AggregateType: store.AggregateTask, AggregateID: aggregateID, Type: eventType},
+		dueAt: *f.now,
+	})
+}
+
+func (f *fakeOutboxStore) ClaimOutboxEvents(limit int, lease time.Duration) ([]*store.OutboxEvent, error) {
+	var claimed []*store.OutboxEvent
+	heads := map[int64]bool{}
+	for _, e := range f.events {
+		if e.published {
+			continue
+		}
+		isHead := !heads[e.event.AggregateID]
+		heads[e.event.AggregateID] = true
+		if !isHead || e.dueAt.After(*f.now) || len(claimed) == limit {
+			continue
+		}
+		e.event.Attempts++
+		e.dueAt = f.now.Add(time.Minute)
+		event := e.event
+		claimed = append(claimed, &event)
+	}
+	return claimed, nil
+}
+
+func (f *fakeOutboxStore) MarkOutboxEventPublished(id int64) error {
+	f.events[id-1].published = true
+	return nil
+}
+
+func (f *fakeOutboxStore) RecordOutboxFailure(id int64, reason string, retryAt time.Time) error {
+	f.events[id-1].dueAt = retryAt
+	f.events[id-1].lastError = reason
+	return nil
+}
+
+func (f *fakeOutboxStore) DeleteOldOutboxEvents() (int64, error) {
+	return 0, nil
+}
+
+func TestBus(t *testing.T) {
+	bus := outbox.NewBus()
+	var calls []string
+	bus.Subscribe("all", func(ctx context.Context, e *store.OutboxEvent) error {
+		calls = append(calls, "all:"+string(e.Type))
+		return nil
+	})
+	bus.Subscribe("created", func(ctx context.Context, e *store.OutboxEvent) error {
+		calls = append(calls, "created")
+		return errors.New("boom")
+	}, store.OutboxTaskCreated)
+	bus.Subscribe("panics", func(ctx context.Context, e *store.OutboxEvent) error {
+		panic("oops")
+	}, store.OutboxTaskDeleted)
+
+	err := bus.Publish(context.Background(), &store.OutboxEvent{Type: store.OutboxTaskCreated})
+	assert.EqualError(t, err, "created: boom")
+
+	err = bus.Publish(context.Background(), &store.OutboxEvent{Type: store.OutboxTaskDeleted})
+	assert.EqualError(t, err, "panics: panic: oops")
+
+	assert.NoError(t, bus.Publish(context.Background(), &store.OutboxEvent{Type: store.OutboxTaskUpdated}))
+	assert.Equal(t, []string{"all:task.created", "created", "all:task.deleted", "all:task.updated"}, calls)
+}
+
+func TestDispatcher(t *testing.T) {
+	now := time.Now()
+	outboxStore := &fakeOutboxStore{now: &now}
+	bus := outbox.NewBus()
+	var logs bytes.Buffer
+	dispatcher := outbox.NewDispatcher(outboxStore, bus, time.Hour, func() time.Time { return now }, log.New(&logs, "", 0))
+
+	var seen []int64
+	failing := map[int64]bool{}
+	bus.Subscribe("recorder", func(ctx context.Context, e *store.OutboxEvent) error {
+		if failing[e.ID] {
+			return errors.New("not now")
+		}
+		seen = append(seen, e.ID)
+		return nil
+	})
+
+	outboxStore.add(1, store.OutboxTaskCreated)   // 1
+	outboxStore.add(2, store.OutboxTaskCreated)   // 2
+	outboxStore.add(1, store.OutboxTaskUpdated)   // 3
+	outboxStore.add(1, store.OutboxTaskPublished) // 4
+
+	t.Run("events of an aggregate are published in order", func(t *testing.T) {
+		assert.Equal(t, 4, dispatcher.PublishPending(context.Background()))
+		assert.Equal(t, []int64{1, 2, 3, 4}, seen)
+	})
+
+	t.Run("a failing event holds back its aggregate only", func(t *testing.T) {
+		seen = nil
+		outboxStore.add(1, store.OutboxTaskUpdated) // 5
+		outboxStore.add(1, store.OutboxTaskDeleted) // 6
+		outboxStore.add(2, store.OutboxTaskUpdated) // 7
+		failing[5] = true
+
+		assert.Equal(t, 1, dispatcher.PublishPending(context.Background()))
+		assert.Equal(t, []int64{7}, seen)
+		assert.Equal(t, "recorder: not now", outboxStore.events[4].lastError)
+		assert.Contains(t, logs.String(), "ERROR: publishOutboxEvent")
+
+		// not due before the backoff
+		assert.Zero(t, dispatcher.PublishPending(context.Background()))
+
+		delete(failing, 5)
+		now = now.Add(time.Second)
+		assert.Equal(t, 2, dispatcher.PublishPending(context.Background()))
+		assert.Equal(t, []int64{7, 5, 6}, seen)
+		assert.Equal(t, 2, outboxStore.events[4].event.Attempts)
+	})
+}
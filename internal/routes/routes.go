@@ -15,7 +15,7 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Session-ID"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
 		MaxAge:           300,
@@ -41,8 +41,6 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	// })
 
 	r.Get("/health", app.HealthCheck)
-	r.Get("/tasks", app.TaskHandler.HandleGetAllTask)
-	r.Get("/tasks/{id}", app.TaskHandler.HandleGetTaskByID)
 	r.Get("/users/{id}/tasks", app.UserHandler.HandleGetUserTasks)
 	r.Get("/login/twitter", app.AuthHandler.HandleTwitterLogin)
 	r.Get("/login/twitter/callback", app.AuthHandler.HandleTwitterCallback)
@@ -60,6 +58,34 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r.Get("/reward", app.RewardHandler.HandleGetAllReward)
 	r.Get("/reward/{id}", app.RewardHandler.HandleGetRewardByID)
 
+	// badges
+	r.Get("/badges", app.BadgeHandler.HandleGetAllBadge)
+	r.Get("/badges/{id}", app.BadgeHandler.HandleGetBadgeByID)
+	r.Get("/users/{id}/badges", app.BadgeHandler.HandleGetUserBadges)
+
+	// quests
+	r.Get("/quests", app.QuestHandler.HandleGetAllQuest)
+	r.Get("/quests/{id}", app.QuestHandler.HandleGetQuestByID)
+
+	// uploads
+	r.Get("/uploads/{id}", app.UploadHandler.HandleGetUpload)
+	r.Get("/uploads/{id}/thumbnail", app.UploadHandler.HandleGetUploadThumbnail)
+
+	// optional auth, responses include the caller's own state when signed in
+	r.Group(func(r chi.Router) {
+		r.Use(app.UserMiddleware.Authenticate)
+
+		r.Get("/tasks", app.TaskHandler.HandleGetAllTask)
+		r.Get("/tasks/{id}", app.TaskHandler.HandleGetTaskByID)
+
+		// leaderboards
+		r.Get("/leaderboards/global", app.LeaderboardHandler.HandleGetGlobalLeaderboard)
+		r.Get("/tasks/{id}/leaderboard", app.LeaderboardHandler.HandleGetTaskLeaderboard)
+
+		// engagement events, from anonymous sessions too
+		r.Post("/events", app.EventHandler.HandleRecordEvents)
+	})
+
 	r.Group(func(r chi.Router) {
 		r.Use(app.UserMiddleware.Authenticate)
 		r.Use(func(next http.Handler) http.Handler {
@@ -69,10 +95,108 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 		// user
 		r.Get("/users/current", app.UserMiddleware.RequireUser(app.UserHandler.HandleGetCurrentUser))
 
+		// feed
+		r.Get("/feed", app.FeedHandler.HandleGetFeed)
+
+		// uploads
+		r.Post("/uploads", app.UploadHandler.HandleUpload)
+
 		// task
 		r.Post("/tasks", app.TaskHandler.HandleCreateTask)
+		r.Post("/tasks/import", app.TaskHandler.HandleImportTasks)
 		r.Put("/tasks/{id}", app.TaskHandler.HandleEditTask)
 		r.Delete("/tasks/{id}", app.TaskHandler.HandleDeleteTask)
+		r.Post("/tasks/{id}/publish", app.TaskHandler.HandlePublishTask)
+		r.Post("/tasks/{id}/clone", app.TaskHandler.HandleCloneTask)
+		r.Get("/tasks/{id}/analytics", app.TaskHandler.HandleGetTaskAnalytics)
+
+		// task templates
+		r.Get("/templates", app.TemplateHandler.HandleGetTemplates)
+		r.Post("/templates", app.TemplateHandler.HandleCreateTemplate)
+		r.Get("/templates/{id}", app.TemplateHandler.HandleGetTemplate)
+		r.Put("/templates/{id}", app.TemplateHandler.HandleUpdateTemplate)
+		r.Delete("/templates/{id}", app.TemplateHandler.HandleDeleteTemplate)
+		r.Post("/templates/{id}/tasks", app.TemplateHandler.HandleCreateTaskFromTemplate)
+
+		// campaigns
+		r.Get("/campaigns", app.CampaignHandler.HandleGetCampaigns)
+		r.Post("/campaigns", app.CampaignHandler.HandleCreateCampaign)
+		r.Get("/campaigns/{id}", app.CampaignHandler.HandleGetCampaign)
+		r.Put("/campaigns/{id}", app.CampaignHandler.HandleUpdateCampaign)
+		r.Delete("/campaigns/{id}", app.CampaignHandler.HandleDeleteCampaign)
+		r.Get("/campaigns/{id}/tasks", app.CampaignHandler.HandleGetCampaignTasks)
+		r.Post("/campaigns/{id}/tasks", app.CampaignHandler.HandleAddCampaignTasks)
+		r.Delete("/campaigns/{id}/tasks/{taskId}", app.CampaignHandler.HandleRemoveCampaignTask)
+		r.Get("/campaigns/{id}/participants", app.CampaignHandler.HandleGetCampaignParticipants)
+		r.Get("/campaigns/{id}/analytics", app.CampaignHandler.HandleGetCampaignAnalytics)
+
+		// organizations
+		r.Get("/organizations", app.OrganizationHandler.HandleGetOrganizations)
+		r.Post("/organizations", app.OrganizationHandler.HandleCreateOrganization)
+		r.Get("/organizations/{id}", app.OrganizationHandler.HandleGetOrganization)
+		r.Put("/organizations/{id}", app.OrganizationHandler.HandleUpdateOrganization)
+		r.Delete("/organizations/{id}", app.OrganizationHandler.HandleDeleteOrganization)
+		r.Get("/organizations/{id}/members", app.OrganizationHandler.HandleGetMembers)
+		r.Put("/organizations/{id}/members/{userId}", app.OrganizationHandler.HandleSetMemberRole)
+		r.Delete("/organizations/{id}/members/{userId}", app.OrganizationHandler.HandleRemoveMember)
+		r.Get("/organizations/{id}/invitations", app.OrganizationHandler.HandleGetOrganizationInvitations)
+		r.Post("/organizations/{id}/invitations", app.OrganizationHandler.HandleCreateInvitation)
+		r.Delete("/organizations/{id}/invitations/{invitationId}", app.OrganizationHandler.HandleRevokeInvitation)
+		r.Get("/invitations", app.OrganizationHandler.HandleGetMyInvitations)
+		r.Post("/invitations/{id}/accept", app.OrganizationHandler.HandleAcceptInvitation)
+		r.Post("/invitations/{id}/decline", app.OrganizationHandler.HandleDeclineInvitation)
+
+		// participation
+		r.Post("/tasks/{id}/join", app.ParticipationHandler.HandleJoinTask)
+		r.Get("/tasks/{id}/participation", app.ParticipationHandler.HandleGetMyParticipation)
+		r.Get("/tasks/{id}/participations", app.ParticipationHandler.HandleGetTaskParticipations)
+		r.Post("/participations/{id}/verify", app.ParticipationHandler.HandleVerifyParticipation)
+		r.Post("/participations/{id}/reject", app.ParticipationHandler.HandleRejectParticipation)
+
+		// proof submissions
+		r.Post("/tasks/{id}/submissions", app.SubmissionHandler.HandleCreateSubmission)
+		r.Get("/tasks/{id}/submissions", app.SubmissionHandler.HandleGetTaskSubmissions)
+		r.Get("/submissions", app.UserMiddleware.RequireModerator(app.SubmissionHandler.HandleGetSubmissions))
+		r.Get("/submissions/{id}", app.SubmissionHandler.HandleGetSubmission)
+		r.Post("/submissions/{id}/approve", app.SubmissionHandler.HandleApproveSubmission)
+		r.Post("/submissions/{id}/reject", app.SubmissionHandler.HandleRejectSubmission)
+
+		// disputes
+		r.Post("/participations/{id}/disputes", app.DisputeHandler.HandleOpenDispute)
+		r.Get("/disputes", app.UserMiddleware.RequireModerator(app.DisputeHandler.HandleGetDisputes))
+		r.Get("/disputes/{id}", app.DisputeHandler.HandleGetDispute)
+		r.Post("/disputes/{id}/respond", app.DisputeHandler.HandleRespondToDispute)
+		r.Post("/disputes/{id}/resolve", app.DisputeHandler.HandleResolveDispute)
+
+		// participant exports
+		r.Get("/tasks/{id}/export", app.ExportHandler.HandleExportTaskParticipants)
+		r.Post("/tasks/{id}/export", app.ExportHandler.HandleQueueTaskExport)
+		r.Get("/campaigns/{id}/export", app.ExportHandler.HandleExportCampaignParticipants)
+		r.Post("/campaigns/{id}/export", app.ExportHandler.HandleQueueCampaignExport)
+		r.Get("/exports/{id}", app.ExportHandler.HandleGetExport)
+		r.Get("/exports/{id}/download", app.ExportHandler.HandleDownloadExport)
+
+		// webhooks
+		r.Post("/webhooks", app.WebhookHandler.HandleCreateWebhook)
+		r.Get("/webhooks", app.WebhookHandler.HandleGetWebhooks)
+		r.Get("/webhooks/{id}", app.WebhookHandler.HandleGetWebhook)
+		r.Put("/webhooks/{id}", app.WebhookHandler.HandleUpdateWebhook)
+		r.Delete("/webhooks/{id}", app.WebhookHandler.HandleDeleteWebhook)
+		r.Get("/webhooks/{id}/deliveries", app.WebhookHandler.HandleGetWebhookDeliveries)
+		r.Get("/webhooks/{id}/deliveries/{deliveryId}", app.WebhookHandler.HandleGetWebhookDelivery)
+		r.Post("/webhooks/{id}/deliveries/{deliveryId}/redeliver", app.WebhookHandler.HandleRedeliverWebhookDelivery)
+
+		// notifications
+		r.Get("/notifications", app.NotificationHandler.HandleGetNotifications)
+		r.Post("/notifications/read-all", app.NotificationHandler.HandleMarkAllNotificationsRead)
+		r.Get("/notifications/preferences", app.NotificationHandler.HandleGetNotificationPreferences)
+		r.Put("/notifications/preferences", app.NotificationHandler.HandleUpdateNotificationPreferences)
+		r.Post("/notifications/{id}/read", app.NotificationHandler.HandleMarkNotificationRead)
+
+		// quests
+		r.Post("/quests", app.QuestHandler.HandleCreateQuest)
+		r.Delete("/quests/{id}", app.QuestHandler.HandleDeleteQuest)
+		r.Get("/quests/{id}/progress", app.QuestHandler.HandleGetQuestProgress)
 
 		// // action
 		// r.Post("/actions", app.ActionHandler.HandleCreateAction)
@@ -86,6 +210,14 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 
 		// rewards
 		r.Post("/rewards", app.RewardsHandler.HandleCreateReward)
+
+		// badges (admin only)
+		r.Post("/badges", app.UserMiddleware.RequireAdmin(app.BadgeHandler.HandleCreateBadge))
+		r.Put("/badges/{id}", app.UserMiddleware.RequireAdmin(app.BadgeHandler.HandleEditBadge))
+		r.Delete("/badges/{id}", app.UserMiddleware.RequireAdmin(app.BadgeHandler.HandleDeleteBadge))
+
+		// user roles (admin only)
+		r.Put("/users/{id}/role", app.UserMiddleware.RequireAdmin(app.UserHandler.HandleSetUserRole))
 	})
 
 	return r
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// DefaultRollupInterval is how often analytics events are rolled up. Reads
+// add the pending events themselves, so this only bounds how many they scan.
+const DefaultRollupInterval = 5 * time.Minute
+
+// RollupBatchSize is how many events one rollup statement folds.
+const RollupBatchSize = 5000
+
+// Rollup folds analytics events into the daily per-task tables.
+type Rollup struct {
+	analyticsStore store.AnalyticsStore
+	interval       time.Duration
+	logger         *log.Logger
+}
+
+func NewRollup(analyticsStore store.AnalyticsStore, interval time.Duration, logger *log.Logger) *Rollup {
+	return &Rollup{
+		analyticsStore: analyticsStore,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+// Run rolls up pending events right away and then on every tick until ctx is
+// done.
+func (r *Rollup) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.RollupPending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RollupPending folds batches until no events are pending and returns how
+// many events it folded. A failed batch is logged and retried on the next
+// tick.
+func (r *Rollup) RollupPending(ctx context.Context) int64 {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := r.analyticsStore.RollupTaskEvents(RollupBatchSize)
+		if err != nil {
+			r.logger.Printf("ERROR: rollupTaskEvents: %v", err)
+			break
+		}
+		total += n
+		if n < RollupBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		r.logger.Printf("rolled up %d analytics events", total)
+	}
+	return total
+}
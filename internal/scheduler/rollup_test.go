@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAnalyticsStore struct {
+	store.AnalyticsStore
+	batches []int64
+	err     error
+	calls   int
+}
+
+func (f *fakeAnalyticsStore) RollupTaskEvents(limit int) (int64, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.batches) == 0 {
+		return 0, nil
+	}
+	n := f.batches[0]
+	f.batches = f.batches[1:]
+	return n, nil
+}
+
+func TestRollupPending(t *testing.T) {
+	t.Run("folds batches until one is not full", func(t *testing.T) {
+		analytics := &fakeAnalyticsStore{batches: []int64{RollupBatchSize, RollupBatchSize, 12, 40}}
+		r := NewRollup(analytics, DefaultRollupInterval, log.New(&bytes.Buffer{}, "", 0))
+
+		assert.Equal(t, int64(2*RollupBatchSize+12), r.RollupPending(context.Background()))
+		assert.Equal(t, 3, analytics.calls)
+	})
+
+	t.Run("errors are logged and not fatal", func(t *testing.T) {
+		var logs bytes.Buffer
+		analytics := &fakeAnalyticsStore{err: errors.New("connection refused")}
+		r := NewRollup(analytics, DefaultRollupInterval, log.New(&logs, "", 0))
+
+		assert.Zero(t, r.RollupPending(context.Background()))
+		assert.Equal(t, 1, analytics.calls)
+		assert.Contains(t, logs.String(), "connection refused")
+	})
+
+	t.Run("stops once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		analytics := &fakeAnalyticsStore{batches: []int64{RollupBatchSize}}
+		r := NewRollup(analytics, DefaultRollupInterval, log.New(&bytes.Buffer{}, "", 0))
+
+		assert.Zero(t, r.RollupPending(ctx))
+		assert.Zero(t, analytics.calls)
+	})
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+// DefaultInterval is how often due drafts are published, so a task goes
+// live at most this long after its publish_at.
+const DefaultInterval = time.Minute
+
+// Scheduler publishes drafts once their publish_at has passed.
+type Scheduler struct {
+	taskStore store.TaskStore
+	interval  time.Duration
+	now       store.Clock
+	logger    *log.Logger
+}
+
+func NewScheduler(taskStore store.TaskStore, interval time.Duration, clock store.Clock, logger *log.Logger) *Scheduler {
+	return &Scheduler{
+		taskStore: taskStore,
+		interval:  interval,
+		now:       clock,
+		logger:    logger,
+	}
+}
+
+// Run publishes due drafts right away and then on every tick until ctx is
+// done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.PublishDue()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue runs a single pass. A failed pass is logged and retried on the
+// next tick.
+func (s *Scheduler) PublishDue() []int64 {
+	ids, err := s.taskStore.PublishDueTasks(s.now())
+	if err != nil {
+		s.logger.Printf("ERROR: publishDueTasks: %v", err)
+		return nil
+	}
+	if len(ids) > 0 {
+		s.logger.Printf("published %d scheduled tasks: %v", len(ids), ids)
+	}
+	return ids
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTaskStore struct {
+	store.TaskStore
+	mu    sync.Mutex
+	calls []time.Time
+	ids   []int64
+	err   error
+}
+
+func (f *fakeTaskStore) PublishDueTasks(now time.Time) ([]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, now)
+	return f.ids, f.err
+}
+
+func (f *fakeTaskStore) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func TestPublishDue(t *testing.T) {
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("publishes with the current time", func(t *testing.T) {
+		tasks := &fakeTaskStore{ids: []int64{3, 7}}
+		s := NewScheduler(tasks, time.Minute, clock, log.New(&bytes.Buffer{}, "", 0))
+
+		assert.Equal(t, []int64{3, 7}, s.PublishDue())
+		require.Len(t, tasks.calls, 1)
+		assert.Equal(t, now, tasks.calls[0])
+	})
+
+	t.Run("errors are logged and not fatal", func(t *testing.T) {
+		var logs bytes.Buffer
+		tasks := &fakeTaskStore{err: errors.New("connection refused")}
+		s := NewScheduler(tasks, time.Minute, clock, log.New(&logs, "", 0))
+
+		assert.Nil(t, s.PublishDue())
+		assert.Contains(t, logs.String(), "connection refused")
+	})
+}
+
+func TestRun(t *testing.T) {
+	tasks := &fakeTaskStore{}
+	s := NewScheduler(tasks, time.Millisecond, time.Now, log.New(&bytes.Buffer{}, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return tasks.callCount() >= 2 }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop after the context was cancelled")
+	}
+}
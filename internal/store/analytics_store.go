@@ -0,0 +1,491 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskEventType is what a task analytics event counts.
+type TaskEventType string
+
+const (
+	TaskEventView       TaskEventType = "view"
+	TaskEventImpression TaskEventType = "impression"
+	TaskEventClick      TaskEventType = "click"
+	TaskEventJoin       TaskEventType = "join"
+	TaskEventSubmission TaskEventType = "submission"
+	TaskEventVerified   TaskEventType = "verified"
+	TaskEventRejected   TaskEventType = "rejected"
+	TaskEventRewarded   TaskEventType = "rewarded"
+)
+
+// IsClientEvent reports whether clients may send events of the type, every
+// other type is only recorded by the server.
+func (t TaskEventType) IsClientEvent() bool {
+	return t == TaskEventImpression || t == TaskEventClick
+}
+
+// TaskEvent is one raw analytics event. The rollup folds events into daily
+// per-task totals, which is what analytics are read from.
+type TaskEvent struct {
+	TaskID int64
+	// UserID is 0 for anonymous events, which carry a SessionID instead.
+	UserID    int64
+	SessionID string
+	Type      TaskEventType
+	// RewardUSDT is set on verified and rewarded events and Duration on
+	// verified ones: the reward paid and the time from joining to
+	// verification.
+	RewardUSDT float64
+	Duration   time.Duration
+	OccurredAt time.Time
+	// OutboxEventID is the outbox event a server event was recorded as. An
+	// event is written once per outbox event, however often it is published.
+	OutboxEventID int64
+}
+
+// values are the event's task_events columns after the id, in table order.
+func (e *TaskEvent) values() []any {
+	var userID, sessionID, duration, outboxEventID any
+	if e.UserID != 0 {
+		userID = e.UserID
+	}
+	if e.SessionID != "" {
+		sessionID = e.SessionID
+	}
+	if e.Type == TaskEventVerified {
+		duration = int64(max(e.Duration, 0) / time.Second)
+	}
+	if e.OutboxEventID != 0 {
+		outboxEventID = e.OutboxEventID
+	}
+	return []any{e.TaskID, userID, e.Type, e.RewardUSDT, duration, e.OccurredAt, sessionID, outboxEventID}
+}
+
+// taskEventColumns are the task_events columns values fills.
+const taskEventColumns = "task_id, user_id, type, reward_usdt, duration_seconds, occurred_at, session_id, outbox_event_id"
+
+// recordTaskEvent writes a server event to the outbox and queues its webhook
+// deliveries. Like the audit log it runs in the transaction of the change it
+// counts. The analytics subscriber of the bus hands the event to the
+// buffered writer, so every kind of event reaches task_events the same way.
+//
+// Webhook deliveries are queued here rather than by a bus subscriber:
+// webhook_deliveries already is an outbox of its own, written in this
+// transaction, with a retry schedule and a log per receiver. Going through
+// the bus first would only add a hop, and a way to tell a republished event
+// from a new one.
+func recordTaskEvent(q dbtx, e *TaskEvent) error {
+	eventType, ok := webhookEvents[e.Type]
+	if !ok {
+		return fmt.Errorf("%s events are not recorded by the server", e.Type)
+	}
+
+	data := OutboxTaskData{TaskID: e.TaskID, UserID: e.UserID, RewardUSDT: e.RewardUSDT}
+	if e.Type == TaskEventVerified {
+		data.DurationSeconds = int64(max(e.Duration, 0) / time.Second)
+	}
+	id, err := enqueueTaskEvent(q, OutboxEventType(eventType), data, e.OccurredAt)
+	if err != nil {
+		return err
+	}
+	return enqueueWebhookDeliveries(q, id, e)
+}
+
+// TaskEventFromOutbox returns the analytics event an outbox event was
+// recorded from, or nil when the outbox event is not one.
+func TaskEventFromOutbox(e *OutboxEvent) (*TaskEvent, error) {
+	for taskEventType, eventType := range webhookEvents {
+		if OutboxEventType(eventType) != e.Type {
+			continue
+		}
+
+		var data OutboxTaskData
+		err := e.Decode(&data)
+		if err != nil {
+			return nil, err
+		}
+		return &TaskEvent{
+			TaskID:        data.TaskID,
+			UserID:        data.UserID,
+			Type:          taskEventType,
+			RewardUSDT:    data.RewardUSDT,
+			Duration:      time.Duration(data.DurationSeconds) * time.Second,
+			OccurredAt:    e.CreatedAt,
+			OutboxEventID: e.ID,
+		}, nil
+	}
+	return nil, nil
+}
+
+// CompletionBuckets are the exclusive upper bounds of the time-to-complete
+// distribution, a last bucket holds everything slower.
+var CompletionBuckets = []time.Duration{
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// completionBucket is the SQL expression of the bucket index of a duration
+// in seconds, from 0 to len(CompletionBuckets).
+func completionBucket(seconds string) string {
+	bounds := make([]string, len(CompletionBuckets))
+	for i, b := range CompletionBuckets {
+		bounds[i] = fmt.Sprint(int64(b / time.Second))
+	}
+	return fmt.Sprintf("width_bucket(%s, ARRAY[%s]::bigint[])", seconds, strings.Join(bounds, ", "))
+}
+
+// eventTotals aggregates task_events rows into the columns of
+// task_daily_stats, in table order. Spend is counted on the rewarded events,
+// which cover quest rewards too, the verified events only repeat the task's.
+const eventTotals = `
+	COUNT(*) FILTER (WHERE type = 'view'),
+	COUNT(*) FILTER (WHERE type = 'join'),
+	COUNT(*) FILTER (WHERE type = 'submission'),
+	COUNT(*) FILTER (WHERE type = 'verified'),
+	COUNT(*) FILTER (WHERE type = 'rejected'),
+	COALESCE(SUM(reward_usdt) FILTER (WHERE type = 'rewarded'), 0),
+	COALESCE(SUM(duration_seconds), 0),
+	COUNT(*) FILTER (WHERE type = 'impression'),
+	COUNT(*) FILTER (WHERE type = 'click')
+`
+
+// AnalyticsRangeDays is the default and MaxAnalyticsRangeDays the longest
+// range of days analytics cover.
+const (
+	AnalyticsRangeDays    = 30
+	MaxAnalyticsRangeDays = 366
+)
+
+var ErrAnalyticsRange = fmt.Errorf("from must not be after to and the range can cover at most %d days", MaxAnalyticsRangeDays)
+
+// AnalyticsFilter selects a task, or every task of a campaign, and the UTC
+// days to cover.
+type AnalyticsFilter struct {
+	TaskID     int64
+	CampaignID int64
+	// From and To are inclusive days. To defaults to today and From to
+	// AnalyticsRangeDays before To.
+	From time.Time
+	To   time.Time
+}
+
+// resolve applies the defaults and truncates the range to whole days.
+func (f *AnalyticsFilter) resolve(now time.Time) error {
+	day := func(t time.Time) time.Time {
+		t = t.UTC()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	if f.To.IsZero() {
+		f.To = now
+	}
+	f.To = day(f.To)
+	if f.From.IsZero() {
+		f.From = f.To.AddDate(0, 0, 1-AnalyticsRangeDays)
+	}
+	f.From = day(f.From)
+
+	if f.From.After(f.To) || f.To.Sub(f.From) >= MaxAnalyticsRangeDays*24*time.Hour {
+		return ErrAnalyticsRange
+	}
+	return nil
+}
+
+// scope is the condition selecting the filter's tasks by task_id.
+func (f AnalyticsFilter) scope(arg int) (string, int64) {
+	if f.CampaignID != 0 {
+		return fmt.Sprintf("task_id IN (SELECT id FROM tasks WHERE campaign_id = $%d)", arg), f.CampaignID
+	}
+	return fmt.Sprintf("task_id = $%d", arg), f.TaskID
+}
+
+type AnalyticsCounts struct {
+	Impressions int64   `json:"impressions"`
+	Views       int64   `json:"views"`
+	Clicks      int64   `json:"clicks"`
+	Joins       int64   `json:"joins"`
+	Submissions int64   `json:"submissions"`
+	Verified    int64   `json:"verified"`
+	Rejected    int64   `json:"rejected"`
+	RewardUSDT  float64 `json:"reward_spend_usdt"`
+	// completionSeconds sums the time-to-complete of Verified.
+	completionSeconds int64
+}
+
+func (c *AnalyticsCounts) add(o AnalyticsCounts) {
+	c.Impressions += o.Impressions
+	c.Views += o.Views
+	c.Clicks += o.Clicks
+	c.Joins += o.Joins
+	c.Submissions += o.Submissions
+	c.Verified += o.Verified
+	c.Rejected += o.Rejected
+	c.RewardUSDT += o.RewardUSDT
+	c.completionSeconds += o.completionSeconds
+}
+
+type AnalyticsTotals struct {
+	AnalyticsCounts
+	// PassRate is the share of reviews that verified the participation, 0
+	// without reviews.
+	PassRate float64 `json:"pass_rate"`
+	// AvgCompletionSeconds is the mean time from joining to verification.
+	AvgCompletionSeconds float64 `json:"avg_completion_seconds"`
+}
+
+type AnalyticsDay struct {
+	Day string `json:"day"`
+	AnalyticsCounts
+}
+
+type CompletionBucket struct {
+	// MaxSeconds is the bucket's exclusive upper bound, nil for the last one.
+	MaxSeconds *int64 `json:"max_seconds"`
+	Count      int64  `json:"count"`
+}
+
+type TaskAnalytics struct {
+	From            string             `json:"from"`
+	To              string             `json:"to"`
+	Totals          AnalyticsTotals    `json:"totals"`
+	Daily           []AnalyticsDay     `json:"daily"`
+	CompletionTimes []CompletionBucket `json:"completion_times"`
+}
+
+// buildAnalytics lays out the days from from to to, zero for days without
+// events, and sums them up.
+func buildAnalytics(from, to time.Time, days map[string]AnalyticsCounts, buckets map[int]int64) *TaskAnalytics {
+	a := &TaskAnalytics{
+		From:  from.Format(time.DateOnly),
+		To:    to.Format(time.DateOnly),
+		Daily: []AnalyticsDay{},
+	}
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := AnalyticsDay{Day: d.Format(time.DateOnly), AnalyticsCounts: days[d.Format(time.DateOnly)]}
+		a.Daily = append(a.Daily, day)
+		a.Totals.add(day.AnalyticsCounts)
+	}
+
+	if reviewed := a.Totals.Verified + a.Totals.Rejected; reviewed > 0 {
+		a.Totals.PassRate = float64(a.Totals.Verified) / float64(reviewed)
+	}
+	if a.Totals.Verified > 0 {
+		a.Totals.AvgCompletionSeconds = float64(a.Totals.completionSeconds) / float64(a.Totals.Verified)
+	}
+
+	for i := 0; i <= len(CompletionBuckets); i++ {
+		bucket := CompletionBucket{Count: buckets[i]}
+		if i < len(CompletionBuckets) {
+			seconds := int64(CompletionBuckets[i] / time.Second)
+			bucket.MaxSeconds = &seconds
+		}
+		a.CompletionTimes = append(a.CompletionTimes, bucket)
+	}
+
+	return a
+}
+
+type PostgresAnalyticsStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresAnalyticsStore(db *sql.DB, clock Clock) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{db: db, now: clock}
+}
+
+type AnalyticsStore interface {
+	GetTaskAnalytics(filter AnalyticsFilter) (*TaskAnalytics, error)
+	InsertTaskEvents(events []TaskEvent) (int64, error)
+	RollupTaskEvents(limit int) (int64, error)
+}
+
+// GetTaskAnalytics reads the rolled up days and adds the events the rollup
+// has not reached yet, so results are current without scanning every event.
+// It returns ErrAnalyticsRange for an invalid range.
+func (pg *PostgresAnalyticsStore) GetTaskAnalytics(filter AnalyticsFilter) (*TaskAnalytics, error) {
+	err := filter.resolve(pg.now())
+	if err != nil {
+		return nil, err
+	}
+
+	scope, scopeID := filter.scope(1)
+	// days are compared as dates in the rollups and as timestamps on events
+	args := []any{scopeID, filter.From, filter.To, filter.From, filter.To.AddDate(0, 0, 1)}
+
+	query := `
+		SELECT
+			day::text,
+			SUM(views)::bigint,
+			SUM(joins)::bigint,
+			SUM(submissions)::bigint,
+			SUM(verified)::bigint,
+			SUM(rejected)::bigint,
+			SUM(reward_usdt),
+			SUM(completion_seconds)::bigint,
+			SUM(impressions)::bigint,
+			SUM(clicks)::bigint
+		FROM (
+			SELECT day, views, joins, submissions, verified, rejected, reward_usdt, completion_seconds, impressions, clicks
+			FROM task_daily_stats
+			WHERE ` + scope + ` AND day BETWEEN $2::date AND $3::date
+			UNION ALL
+			SELECT (occurred_at AT TIME ZONE 'UTC')::date, ` + eventTotals + `
+			FROM task_events
+			WHERE NOT rolled_up AND ` + scope + ` AND occurred_at >= $4 AND occurred_at < $5
+			GROUP BY 1
+		) d
+		GROUP BY day
+	`
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := map[string]AnalyticsCounts{}
+	for rows.Next() {
+		var day string
+		var c AnalyticsCounts
+		err = rows.Scan(&day, &c.Views, &c.Joins, &c.Submissions, &c.Verified, &c.Rejected, &c.RewardUSDT, &c.completionSeconds, &c.Impressions, &c.Clicks)
+		if err != nil {
+			return nil, err
+		}
+		days[day] = c
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT bucket, SUM(completions)::bigint
+		FROM (
+			SELECT bucket, completions
+			FROM task_completion_stats
+			WHERE ` + scope + ` AND day BETWEEN $2::date AND $3::date
+			UNION ALL
+			SELECT ` + completionBucket("duration_seconds") + `, COUNT(*)
+			FROM task_events
+			WHERE NOT rolled_up AND type = 'verified' AND ` + scope + ` AND occurred_at >= $4 AND occurred_at < $5
+			GROUP BY 1
+		) c
+		GROUP BY bucket
+	`
+	rows, err = pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := map[int]int64{}
+	for rows.Next() {
+		var bucket int
+		var count int64
+		err = rows.Scan(&bucket, &count)
+		if err != nil {
+			return nil, err
+		}
+		buckets[bucket] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildAnalytics(filter.From, filter.To, days, buckets), nil
+}
+
+// InsertTaskEvents appends a batch of events in one statement and returns
+// how many were written. Events of tasks or users that no longer exist are
+// skipped, they come from clients or were buffered before a deletion, and so
+// are the outbox events that were written before.
+func (pg *PostgresAnalyticsStore) InsertTaskEvents(events []TaskEvent) (int64, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	rows := make([]string, len(events))
+	args := make([]any, 0, len(events)*8)
+	for i := range events {
+		n := len(args)
+		rows[i] = fmt.Sprintf("($%d::bigint, $%d::bigint, $%d, $%d::float8, $%d::bigint, $%d::timestamptz, $%d, $%d::bigint)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		args = append(args, events[i].values()...)
+	}
+
+	query := `
+		INSERT INTO task_events (` + taskEventColumns + `)
+		SELECT e.*
+		FROM (VALUES ` + strings.Join(rows, ", ") + `) AS e(` + taskEventColumns + `)
+		WHERE EXISTS (SELECT 1 FROM tasks t WHERE t.id = e.task_id)
+			AND (e.user_id IS NULL OR EXISTS (SELECT 1 FROM users u WHERE u.id = e.user_id))
+		ON CONFLICT (outbox_event_id) WHERE outbox_event_id IS NOT NULL DO NOTHING
+	`
+	result, err := pg.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RollupTaskEvents folds up to limit pending events, oldest first, into the
+// daily tables and returns how many it folded. Events are marked and added
+// in one statement, so none is counted twice, and concurrent rollups skip
+// each other's events.
+func (pg *PostgresAnalyticsStore) RollupTaskEvents(limit int) (int64, error) {
+	if limit <= 0 {
+		return 0, errors.New("limit must be positive")
+	}
+
+	query := `
+		WITH batch AS (
+			UPDATE task_events
+			SET rolled_up = TRUE
+			WHERE id IN (
+				SELECT id
+				FROM task_events
+				WHERE NOT rolled_up
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING task_id, type, reward_usdt, duration_seconds, (occurred_at AT TIME ZONE 'UTC')::date AS day
+		),
+		daily AS (
+			INSERT INTO task_daily_stats (task_id, day, views, joins, submissions, verified, rejected, reward_usdt, completion_seconds, impressions, clicks)
+			SELECT task_id, day, ` + eventTotals + `
+			FROM batch
+			GROUP BY task_id, day
+			ON CONFLICT (task_id, day) DO UPDATE SET
+				views = task_daily_stats.views + EXCLUDED.views,
+				joins = task_daily_stats.joins + EXCLUDED.joins,
+				submissions = task_daily_stats.submissions + EXCLUDED.submissions,
+				verified = task_daily_stats.verified + EXCLUDED.verified,
+				rejected = task_daily_stats.rejected + EXCLUDED.rejected,
+				reward_usdt = task_daily_stats.reward_usdt + EXCLUDED.reward_usdt,
+				completion_seconds = task_daily_stats.completion_seconds + EXCLUDED.completion_seconds,
+				impressions = task_daily_stats.impressions + EXCLUDED.impressions,
+				clicks = task_daily_stats.clicks + EXCLUDED.clicks
+		),
+		completions AS (
+			INSERT INTO task_completion_stats (task_id, day, bucket, completions)
+			SELECT task_id, day, ` + completionBucket("duration_seconds") + `, COUNT(*)
+			FROM batch
+			WHERE type = 'verified'
+			GROUP BY 1, 2, 3
+			ON CONFLICT (task_id, day, bucket) DO UPDATE SET
+				completions = task_completion_stats.completions + EXCLUDED.completions
+		)
+		SELECT COUNT(*) FROM batch
+	`
+
+	var rolled int64
+	err := pg.db.QueryRow(query, limit).Scan(&rolled)
+	return rolled, err
+}
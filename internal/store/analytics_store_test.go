@@ -0,0 +1,203 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBAnalytics(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE task_events, outbox_events, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+// writeOutboxTaskEvents does what the analytics subscriber of the event bus
+// does: it writes the server events waiting in the outbox to task_events.
+// Every other outbox event is marked published on the way.
+func writeOutboxTaskEvents(t *testing.T, db *sql.DB) {
+	t.Helper()
+	outboxStore := NewPostgresOutboxStore(db, time.Now)
+	analyticsStore := NewPostgresAnalyticsStore(db, time.Now)
+
+	for {
+		events, err := outboxStore.ClaimOutboxEvents(100, time.Minute)
+		require.NoError(t, err)
+		if len(events) == 0 {
+			return
+		}
+
+		for _, e := range events {
+			taskEvent, err := TaskEventFromOutbox(e)
+			require.NoError(t, err)
+			if taskEvent != nil {
+				_, err = analyticsStore.InsertTaskEvents([]TaskEvent{*taskEvent})
+				require.NoError(t, err)
+			}
+			require.NoError(t, outboxStore.MarkOutboxEventPublished(e.ID))
+		}
+	}
+}
+
+func TestAnalyticsFilterResolve(t *testing.T) {
+	now := time.Date(2025, time.November, 30, 15, 4, 0, 0, time.UTC)
+
+	f := AnalyticsFilter{TaskID: 1}
+	require.NoError(t, f.resolve(now))
+	assert.Equal(t, time.Date(2025, time.November, 30, 0, 0, 0, 0, time.UTC), f.To)
+	assert.Equal(t, time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC), f.From)
+
+	f = AnalyticsFilter{From: now, To: now.AddDate(0, 0, -1)}
+	assert.Equal(t, ErrAnalyticsRange, f.resolve(now))
+
+	f = AnalyticsFilter{From: now.AddDate(0, 0, -MaxAnalyticsRangeDays), To: now}
+	assert.Equal(t, ErrAnalyticsRange, f.resolve(now))
+
+	f = AnalyticsFilter{From: now.AddDate(0, 0, 1-MaxAnalyticsRangeDays), To: now}
+	assert.NoError(t, f.resolve(now))
+}
+
+func TestBuildAnalytics(t *testing.T) {
+	from := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	days := map[string]AnalyticsCounts{
+		"2025-11-01": {Views: 10, Joins: 4, Verified: 1, RewardUSDT: 2.5, completionSeconds: 3600},
+		"2025-11-03": {Views: 2, Submissions: 3, Verified: 2, Rejected: 1, RewardUSDT: 5, completionSeconds: 7200},
+	}
+
+	a := buildAnalytics(from, to, days, map[int]int64{0: 1, 2: 2})
+	assert.Equal(t, "2025-11-01", a.From)
+	assert.Equal(t, "2025-11-03", a.To)
+
+	require.Len(t, a.Daily, 3)
+	assert.Equal(t, "2025-11-02", a.Daily[1].Day)
+	assert.Zero(t, a.Daily[1].Views)
+
+	assert.Equal(t, int64(12), a.Totals.Views)
+	assert.Equal(t, int64(3), a.Totals.Verified)
+	assert.InDelta(t, 7.5, a.Totals.RewardUSDT, 0.0001)
+	assert.InDelta(t, 0.75, a.Totals.PassRate, 0.0001)
+	assert.InDelta(t, 3600, a.Totals.AvgCompletionSeconds, 0.0001)
+
+	require.Len(t, a.CompletionTimes, len(CompletionBuckets)+1)
+	assert.Equal(t, int64(3600), *a.CompletionTimes[0].MaxSeconds)
+	assert.Equal(t, int64(1), a.CompletionTimes[0].Count)
+	assert.Equal(t, int64(2), a.CompletionTimes[2].Count)
+	assert.Nil(t, a.CompletionTimes[len(CompletionBuckets)].MaxSeconds)
+
+	empty := buildAnalytics(from, from, nil, nil)
+	assert.Zero(t, empty.Totals.PassRate)
+	assert.Zero(t, empty.Totals.AvgCompletionSeconds)
+}
+
+func TestAnalyticsStore(t *testing.T) {
+	db := setupTestDBAnalytics(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	analyticsStore := NewPostgresAnalyticsStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	var users []*User
+	for _, name := range []string{"analytics-creator", "analytics-a", "analytics-b"} {
+		user := &User{Username: name, Email: name + "@gmail.com"}
+		user.PasswordHash.Set("password123")
+		user, err := userStore.CreateUser(user)
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	creator, a, b := users[0], users[1], users[2]
+
+	task, err := taskStore.CreateTask(&Task{Title: "Tracked", UserID: creator.ID, RewardUSDT: 2.5, DueDate: now.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	taskID := int64(task.ID)
+
+	inserted, err := analyticsStore.InsertTaskEvents([]TaskEvent{
+		{TaskID: taskID, UserID: a.ID, Type: TaskEventView, OccurredAt: now},
+		{TaskID: taskID, UserID: b.ID, Type: TaskEventView, OccurredAt: now},
+		{TaskID: taskID, SessionID: "anonymous", Type: TaskEventView, OccurredAt: now},
+		{TaskID: taskID, SessionID: "anonymous", Type: TaskEventImpression, OccurredAt: now},
+		{TaskID: taskID, UserID: a.ID, Type: TaskEventClick, OccurredAt: now},
+		{TaskID: taskID + 1000, Type: TaskEventClick, OccurredAt: now},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), inserted, "events of missing tasks are skipped")
+
+	pa, _, err := participationStore.Join(taskID, a.ID)
+	require.NoError(t, err)
+	pb, _, err := participationStore.Join(taskID, b.ID)
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Hour)
+	_, _, err = participationStore.VerifyParticipation(pa.ID, creator.ID)
+	require.NoError(t, err)
+	require.NoError(t, participationStore.RejectParticipation(pb.ID, creator.ID))
+
+	t.Run("server events are written through the outbox", func(t *testing.T) {
+		got, err := analyticsStore.GetTaskAnalytics(AnalyticsFilter{TaskID: taskID})
+		require.NoError(t, err)
+		assert.Zero(t, got.Totals.Joins)
+
+		writeOutboxTaskEvents(t, db)
+
+		var joinEvent TaskEvent
+		err = db.QueryRow(`SELECT outbox_event_id, occurred_at FROM task_events WHERE type = 'join' AND user_id = $1`, a.ID).Scan(&joinEvent.OutboxEventID, &joinEvent.OccurredAt)
+		require.NoError(t, err)
+
+		// a republished event is not written twice
+		joinEvent.TaskID, joinEvent.UserID, joinEvent.Type = taskID, a.ID, TaskEventJoin
+		inserted, err := analyticsStore.InsertTaskEvents([]TaskEvent{joinEvent})
+		require.NoError(t, err)
+		assert.Zero(t, inserted)
+	})
+
+	check := func(t *testing.T) {
+		got, err := analyticsStore.GetTaskAnalytics(AnalyticsFilter{TaskID: taskID})
+		require.NoError(t, err)
+		assert.Equal(t, "2025-11-10", got.To)
+		assert.Equal(t, int64(3), got.Totals.Views)
+		assert.Equal(t, int64(1), got.Totals.Impressions)
+		assert.Equal(t, int64(1), got.Totals.Clicks)
+		assert.Equal(t, int64(2), got.Totals.Joins)
+		assert.Equal(t, int64(1), got.Totals.Verified)
+		assert.Equal(t, int64(1), got.Totals.Rejected)
+		assert.InDelta(t, 2.5, got.Totals.RewardUSDT, 0.0001, "rewarded events do not count twice")
+		assert.InDelta(t, 0.5, got.Totals.PassRate, 0.0001)
+		assert.InDelta(t, 7200, got.Totals.AvgCompletionSeconds, 0.0001)
+		assert.Equal(t, int64(1), got.CompletionTimes[1].Count)
+		assert.Equal(t, int64(3), got.Daily[len(got.Daily)-1].Views)
+	}
+
+	t.Run("pending events count before the rollup", check)
+
+	n, err := analyticsStore.RollupTaskEvents(100)
+	require.NoError(t, err)
+	// 5 engagement events, 2 joins, a verification, its reward and a rejection
+	assert.Equal(t, int64(10), n)
+
+	t.Run("rolled up events give the same totals", check)
+
+	n, err = analyticsStore.RollupTaskEvents(100)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	t.Run("campaign scope without tasks is empty", func(t *testing.T) {
+		got, err := analyticsStore.GetTaskAnalytics(AnalyticsFilter{CampaignID: 999})
+		require.NoError(t, err)
+		assert.Zero(t, got.Totals.Views)
+		assert.Len(t, got.Daily, AnalyticsRangeDays)
+	})
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Asset is an uploaded file kept in the blob store, tasks reference images
+// by asset id.
+type Asset struct {
+	ID           string    `json:"id"`
+	UserID       int64     `json:"user_id"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	BlobKey      string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type PostgresAssetStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAssetStore(db *sql.DB) *PostgresAssetStore {
+	return &PostgresAssetStore{db: db}
+}
+
+type AssetStore interface {
+	CreateAsset(asset *Asset) (*Asset, error)
+	GetAssetByID(id string) (*Asset, error)
+}
+
+func (pg *PostgresAssetStore) CreateAsset(asset *Asset) (*Asset, error) {
+	query := `
+		INSERT INTO assets (id, user_id, content_type, size_bytes, width, height, blob_key, thumbnail_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`
+
+	err := pg.db.QueryRow(query,
+		asset.ID,
+		asset.UserID,
+		asset.ContentType,
+		asset.SizeBytes,
+		asset.Width,
+		asset.Height,
+		asset.BlobKey,
+		asset.ThumbnailKey,
+	).Scan(&asset.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// GetAssetByID returns nil when there is no asset with the id.
+func (pg *PostgresAssetStore) GetAssetByID(id string) (*Asset, error) {
+	query := `
+		SELECT id, user_id, content_type, size_bytes, width, height, blob_key, thumbnail_key, created_at
+		FROM assets
+		WHERE id = $1
+	`
+
+	var a Asset
+	err := pg.db.QueryRow(query, id).Scan(
+		&a.ID,
+		&a.UserID,
+		&a.ContentType,
+		&a.SizeBytes,
+		&a.Width,
+		&a.Height,
+		&a.BlobKey,
+		&a.ThumbnailKey,
+		&a.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// checkTaskImage makes sure image is an asset one of uploaderIDs uploaded,
+// the task's owner or whoever edits it. Empty images are fine, a task does
+// not need one.
+func checkTaskImage(q dbtx, image string, uploaderIDs ...int64) error {
+	if image == "" {
+		return nil
+	}
+
+	var owned bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM assets WHERE id = $1 AND user_id = ANY($2))`, image, uploaderIDs).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+func isAssetOwnedBy(q dbtx, id string, userID int64) (bool, error) {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM assets WHERE id = $1 AND user_id = $2)`, id, userID).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBAsset(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE assets, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestAssetStore(t *testing.T) {
+	db := setupTestDBAsset(t)
+	defer db.Close()
+
+	assetStore := NewPostgresAssetStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	owner := &User{Username: "asset-owner", Email: "asset-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	other := &User{Username: "asset-other", Email: "asset-other@gmail.com"}
+	other.PasswordHash.Set("password123")
+	other, err = userStore.CreateUser(other)
+	require.NoError(t, err)
+
+	asset, err := assetStore.CreateAsset(&Asset{
+		ID:           "a1b2c3",
+		UserID:       owner.ID,
+		ContentType:  "image/png",
+		SizeBytes:    1024,
+		Width:        800,
+		Height:       400,
+		BlobKey:      "assets/a1b2c3/original",
+		ThumbnailKey: "assets/a1b2c3/thumbnail",
+	})
+	require.NoError(t, err)
+
+	stored, err := assetStore.GetAssetByID(asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "assets/a1b2c3/thumbnail", stored.ThumbnailKey)
+
+	missing, err := assetStore.GetAssetByID("missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	t.Run("tasks only take their owner's images", func(t *testing.T) {
+		due := time.Now().AddDate(0, 1, 0)
+
+		task, err := taskStore.CreateTask(&Task{Title: "With image", UserID: owner.ID, DueDate: due, TaskImage: asset.ID})
+		require.NoError(t, err)
+		assert.Equal(t, asset.ID, task.TaskImage)
+
+		_, err = taskStore.CreateTask(&Task{Title: "Borrowed image", UserID: other.ID, DueDate: due, TaskImage: asset.ID})
+		assert.ErrorIs(t, err, ErrImageNotFound)
+
+		err = taskStore.EditTask(&Task{ID: task.ID, TaskImage: "missing"}, owner.ID)
+		assert.ErrorIs(t, err, ErrImageNotFound)
+	})
+
+	t.Run("editors may use their own images", func(t *testing.T) {
+		editorAsset, err := assetStore.CreateAsset(&Asset{ID: "d4e5f6", UserID: other.ID, ContentType: "image/png", SizeBytes: 512, BlobKey: "assets/d4e5f6/original"})
+		require.NoError(t, err)
+		task, err := taskStore.CreateTask(&Task{Title: "Edited by another", UserID: owner.ID, DueDate: time.Now().AddDate(0, 1, 0)})
+		require.NoError(t, err)
+
+		err = taskStore.EditTask(&Task{ID: task.ID, TaskImage: editorAsset.ID}, other.ID)
+		require.NoError(t, err)
+		err = taskStore.EditTask(&Task{ID: task.ID, TaskImage: asset.ID}, other.ID)
+		require.NoError(t, err, "the owner's images stay usable")
+
+		third := &User{Username: "asset-third", Email: "asset-third@gmail.com"}
+		third.PasswordHash.Set("password123")
+		third, err = userStore.CreateUser(third)
+		require.NoError(t, err)
+		err = taskStore.EditTask(&Task{ID: task.ID, TaskImage: editorAsset.ID}, third.ID)
+		assert.ErrorIs(t, err, ErrImageNotFound)
+	})
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// Audit actions, named after the entity and what happened to it.
+const (
+	AuditParticipationVerified = "participation.verified"
+	AuditParticipationRejected = "participation.rejected"
+	AuditSubmissionCreated     = "submission.created"
+	AuditSubmissionApproved    = "submission.approved"
+	AuditSubmissionRejected    = "submission.rejected"
+	AuditDisputeOpened         = "dispute.opened"
+	AuditDisputeResponded      = "dispute.responded"
+	AuditDisputeUpheld         = "dispute.upheld"
+	AuditDisputeOverturned     = "dispute.overturned"
+	AuditUserRoleChanged       = "user.role_changed"
+)
+
+// Audited entity types.
+const (
+	AuditEntityParticipation = "participation"
+	AuditEntitySubmission    = "submission"
+	AuditEntityDispute       = "dispute"
+	AuditEntityUser          = "user"
+)
+
+type AuditEntry struct {
+	ID         int64          `json:"id"`
+	ActorID    int64          `json:"actor_id"`
+	Action     string         `json:"action"`
+	EntityType string         `json:"entity_type"`
+	EntityID   int64          `json:"entity_id"`
+	Details    map[string]any `json:"details"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type PostgresAuditStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{db: db}
+}
+
+type AuditStore interface {
+	GetAuditEntries(entityType string, entityID int64, page utils.PageParams) ([]AuditEntry, utils.PageBounds, error)
+}
+
+// recordAudit appends an entry to the audit log. It runs in the transaction
+// of the change it describes so the log never misses or invents a decision.
+func recordAudit(q dbtx, entry *AuditEntry) error {
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	return q.QueryRow(query, entry.ActorID, entry.Action, entry.EntityType, entry.EntityID, raw, entry.CreatedAt).Scan(&entry.ID)
+}
+
+// GetAuditEntries returns a page of the entity's audit trail, oldest first.
+func (pg *PostgresAuditStore) GetAuditEntries(entityType string, entityID int64, page utils.PageParams) ([]AuditEntry, utils.PageBounds, error) {
+	ks := keyset{key: "created_at", cast: "timestamptz", id: "id"}
+	cond, orderBy, args := ks.clause(page, 3)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(actor_id, 0), action, entity_type, entity_id, details, created_at, %s
+		FROM audit_log
+		WHERE entity_type = $1 AND entity_id = $2 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, ks.keyColumn(), cond, orderBy, len(args)+3)
+
+	args = append([]any{entityType, entityID}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[AuditEntry]
+	for rows.Next() {
+		var item keyed[AuditEntry]
+		e := &item.row
+		var raw []byte
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID, &raw, &e.CreatedAt, &item.key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		if err := json.Unmarshal(raw, &e.Details); err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = e.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	entries, bounds := keysetPage(items, page)
+	return entries, bounds, nil
+}
@@ -0,0 +1,383 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type BadgeRuleKind string
+
+const (
+	// BadgeRuleTasksCompleted counts every reward the user received.
+	BadgeRuleTasksCompleted BadgeRuleKind = "tasks_completed"
+	// BadgeRuleActionCompleted counts rewards for tasks of a single action type.
+	BadgeRuleActionCompleted BadgeRuleKind = "action_completed"
+	// BadgeRuleStreakDays is the longest run of consecutive UTC days with a reward.
+	BadgeRuleStreakDays BadgeRuleKind = "streak_days"
+	// BadgeRuleTotalEarnings sums reward_usdt over every rewarded task.
+	BadgeRuleTotalEarnings BadgeRuleKind = "total_earnings"
+	// BadgeRuleTasksJoined counts every participation, each check-in of a
+	// recurring task included.
+	BadgeRuleTasksJoined BadgeRuleKind = "tasks_joined"
+	// BadgeRuleCheckInStreak is the longest check-in streak on any recurring
+	// task.
+	BadgeRuleCheckInStreak BadgeRuleKind = "check_in_streak"
+)
+
+// ErrBadgeCodeTaken is returned when another badge has the code.
+var ErrBadgeCodeTaken = errors.New("code is already used by another badge")
+
+type BadgeRule struct {
+	Kind       BadgeRuleKind `json:"kind"`
+	Threshold  float64       `json:"threshold"`
+	ActionType TypeAction    `json:"action_type,omitempty"`
+}
+
+func (r BadgeRule) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *BadgeRule) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into BadgeRule", src)
+	}
+}
+
+type Badge struct {
+	ID          int64     `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"image_url"`
+	Rule        BadgeRule `json:"rule"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type UserBadge struct {
+	Badge
+	AwardedAt time.Time `json:"awarded_at"`
+}
+
+// UserActivityStats is everything badge rules are evaluated against.
+type UserActivityStats struct {
+	TasksCompleted       int64
+	ActionsCompleted     map[TypeAction]int64
+	LongestStreak        int64
+	TotalEarnings        float64
+	TasksJoined          int64
+	LongestCheckInStreak int64
+}
+
+type PostgresBadgeStore struct {
+	db *sql.DB
+}
+
+func NewPostgresBadgeStore(db *sql.DB) *PostgresBadgeStore {
+	return &PostgresBadgeStore{db: db}
+}
+
+type BadgeStore interface {
+	CreateBadge(badge *Badge) (*Badge, error)
+	GetBadges(page utils.PageParams) ([]Badge, utils.PageBounds, error)
+	GetBadgeByID(id int64) (*Badge, error)
+	EditBadge(badge *Badge) error
+	DeleteBadge(id int64) error
+	GetUserBadges(userID int64) ([]UserBadge, error)
+	AwardBadge(userID, badgeID int64) (bool, error)
+	GetUserActivityStats(userID int64) (*UserActivityStats, error)
+}
+
+// CreateBadge stores a new badge. It returns ErrBadgeCodeTaken when another
+// badge has its code.
+func (pg *PostgresBadgeStore) CreateBadge(badge *Badge) (*Badge, error) {
+	query := `
+		INSERT INTO badges (code, name, description, image_url, rule)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+
+	err := pg.db.QueryRow(query, badge.Code, badge.Name, badge.Description, badge.ImageURL, badge.Rule).
+		Scan(&badge.ID, &badge.CreatedAt, &badge.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrBadgeCodeTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return badge, nil
+}
+
+// GetBadges returns a page of badges in the order they were created.
+func (pg *PostgresBadgeStore) GetBadges(page utils.PageParams) ([]Badge, utils.PageBounds, error) {
+	ks := keyset{id: "id"}
+	cond, orderBy, args := ks.clause(page, 1)
+	if cond != "" {
+		cond = "WHERE " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, code, name, COALESCE(description, ''), COALESCE(image_url, ''), rule, created_at, updated_at
+		FROM badges
+		%s
+		ORDER BY %s
+		LIMIT $%d
+	`, cond, orderBy, len(args)+1)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Badge]
+	for rows.Next() {
+		var item keyed[Badge]
+		b := &item.row
+		if err := rows.Scan(&b.ID, &b.Code, &b.Name, &b.Description, &b.ImageURL, &b.Rule, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = b.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	badges, bounds := keysetPage(items, page)
+	return badges, bounds, nil
+}
+
+func (pg *PostgresBadgeStore) GetBadgeByID(id int64) (*Badge, error) {
+	query := `
+		SELECT id, code, name, COALESCE(description, ''), COALESCE(image_url, ''), rule, created_at, updated_at
+		FROM badges
+		WHERE id = $1
+	`
+
+	var b Badge
+	err := pg.db.QueryRow(query, id).Scan(&b.ID, &b.Code, &b.Name, &b.Description, &b.ImageURL, &b.Rule, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// EditBadge replaces the badge's fields. It returns sql.ErrNoRows when the
+// badge does not exist and ErrBadgeCodeTaken when another badge has the code.
+func (pg *PostgresBadgeStore) EditBadge(badge *Badge) error {
+	query := `
+		UPDATE badges
+		SET code = $1, name = $2, description = $3, image_url = $4, rule = $5, updated_at = NOW()
+		WHERE id = $6 AND NOT EXISTS (SELECT 1 FROM badges other WHERE other.code = $1 AND other.id <> $6)
+	`
+
+	result, err := pg.db.Exec(query, badge.Code, badge.Name, badge.Description, badge.ImageURL, badge.Rule, badge.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM badges WHERE id = $1)`, badge.ID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrBadgeCodeTaken
+}
+
+func (pg *PostgresBadgeStore) DeleteBadge(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM badges WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresBadgeStore) GetUserBadges(userID int64) ([]UserBadge, error) {
+	query := `
+		SELECT b.id, b.code, b.name, COALESCE(b.description, ''), COALESCE(b.image_url, ''), b.rule, b.created_at, b.updated_at, ub.awarded_at
+		FROM user_badges ub
+		JOIN badges b ON b.id = ub.badge_id
+		WHERE ub.user_id = $1
+		ORDER BY ub.awarded_at, b.id
+	`
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := []UserBadge{}
+	for rows.Next() {
+		var b UserBadge
+		if err := rows.Scan(&b.ID, &b.Code, &b.Name, &b.Description, &b.ImageURL, &b.Rule, &b.CreatedAt, &b.UpdatedAt, &b.AwardedAt); err != nil {
+			return nil, err
+		}
+		badges = append(badges, b)
+	}
+
+	return badges, rows.Err()
+}
+
+// AwardBadge gives a badge to a user. It reports false when the user already
+// had the badge, so a badge is never awarded twice.
+func (pg *PostgresBadgeStore) AwardBadge(userID, badgeID int64) (bool, error) {
+	if userID == 0 || badgeID == 0 {
+		return false, errors.New("user id and badge id are required")
+	}
+
+	query := `
+		INSERT INTO user_badges (user_id, badge_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, badge_id) DO NOTHING
+	`
+
+	result, err := pg.db.Exec(query, userID, badgeID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (pg *PostgresBadgeStore) GetUserActivityStats(userID int64) (*UserActivityStats, error) {
+	stats := &UserActivityStats{
+		ActionsCompleted: map[TypeAction]int64{},
+	}
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE quest_id IS NULL), COALESCE(SUM(reward_usdt), 0)
+		FROM rewards
+		WHERE user_id = $1
+	`
+	err := pg.db.QueryRow(query, userID).Scan(&stats.TasksCompleted, &stats.TotalEarnings)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT a.type, COUNT(*)
+		FROM rewards r
+		JOIN tasks t ON t.id = r.task_id
+		JOIN task_action_links l ON l.task_id = t.id
+		JOIN task_actions a ON a.id = l.action_id
+		WHERE r.user_id = $1 AND r.quest_id IS NULL
+		GROUP BY a.type
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var actionType TypeAction
+		var count int64
+		if err := rows.Scan(&actionType, &count); err != nil {
+			return nil, err
+		}
+		stats.ActionsCompleted[actionType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT DISTINCT (created_at AT TIME ZONE 'UTC')::date AS day
+		FROM rewards
+		WHERE user_id = $1
+		ORDER BY day
+	`
+	dayRows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer dayRows.Close()
+
+	var days []time.Time
+	for dayRows.Next() {
+		var day time.Time
+		if err := dayRows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	if err := dayRows.Err(); err != nil {
+		return nil, err
+	}
+	stats.LongestStreak = longestDailyStreak(days)
+
+	query = `
+		SELECT
+			(SELECT COUNT(*) FROM task_participations WHERE user_id = $1),
+			(SELECT COALESCE(MAX(longest_streak), 0) FROM task_streaks WHERE user_id = $1)
+	`
+	err = pg.db.QueryRow(query, userID).Scan(&stats.TasksJoined, &stats.LongestCheckInStreak)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// longestDailyStreak returns the longest run of consecutive days in a sorted,
+// de-duplicated list of UTC dates.
+func longestDailyStreak(days []time.Time) int64 {
+	var longest, current int64
+	for i, day := range days {
+		if i > 0 && day.Sub(days[i-1]) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBBadge(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE user_badges, badges, rewards, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestLongestDailyStreak(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2025, time.October, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	assert.Equal(t, int64(0), longestDailyStreak(nil))
+	assert.Equal(t, int64(1), longestDailyStreak([]time.Time{day(1)}))
+	assert.Equal(t, int64(3), longestDailyStreak([]time.Time{day(1), day(2), day(4), day(5), day(6)}))
+}
+
+func TestBadgeStore(t *testing.T) {
+	db := setupTestDBBadge(t)
+	defer db.Close()
+
+	badgeStore := NewPostgresBadgeStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	rewardsStore := NewPostgresRewardsStore(db)
+	participationStore := NewPostgresParticipationStore(db, time.Now)
+
+	user := &User{Username: "test-badge", Email: "test-badge@gmail.com"}
+	user.PasswordHash.Set("password123")
+	user, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	var badge *Badge
+
+	t.Run("CreateBadge", func(t *testing.T) {
+		badge, err = badgeStore.CreateBadge(&Badge{
+			Code: "two_tasks",
+			Name: "Two Tasks",
+			Rule: BadgeRule{Kind: BadgeRuleTasksCompleted, Threshold: 2},
+		})
+		require.NoError(t, err)
+		assert.Greater(t, badge.ID, int64(0))
+
+		retrieved, err := badgeStore.GetBadgeByID(badge.ID)
+		require.NoError(t, err)
+		assert.Equal(t, BadgeRuleTasksCompleted, retrieved.Rule.Kind)
+		assert.Equal(t, float64(2), retrieved.Rule.Threshold)
+	})
+
+	t.Run("codes are unique", func(t *testing.T) {
+		_, err := badgeStore.CreateBadge(&Badge{Code: "two_tasks", Name: "Again", Rule: BadgeRule{Kind: BadgeRuleTasksCompleted, Threshold: 1}})
+		assert.Equal(t, ErrBadgeCodeTaken, err)
+
+		other, err := badgeStore.CreateBadge(&Badge{Code: "joiner", Name: "Joiner", Rule: BadgeRule{Kind: BadgeRuleTasksJoined, Threshold: 1}})
+		require.NoError(t, err)
+		other.Code = "two_tasks"
+		assert.Equal(t, ErrBadgeCodeTaken, badgeStore.EditBadge(other))
+
+		// keeping its own code is fine
+		other.Code = "joiner"
+		require.NoError(t, badgeStore.EditBadge(other))
+		assert.Equal(t, sql.ErrNoRows, badgeStore.EditBadge(&Badge{ID: other.ID + 100, Code: "missing"}))
+		require.NoError(t, badgeStore.DeleteBadge(other.ID))
+	})
+
+	t.Run("GetUserActivityStats", func(t *testing.T) {
+		task, err := taskStore.CreateTask(&Task{Title: "Task", UserID: user.ID, RewardUSDT: 2.5})
+		require.NoError(t, err)
+		for range 2 {
+			_, err := rewardsStore.Create(&Reward{UserID: user.ID, TaskID: int64(task.ID)})
+			require.NoError(t, err)
+		}
+
+		stats, err := badgeStore.GetUserActivityStats(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.TasksCompleted)
+		assert.Equal(t, float64(5), stats.TotalEarnings)
+		assert.Equal(t, int64(1), stats.LongestStreak)
+		assert.Zero(t, stats.TasksJoined)
+
+		_, _, err = participationStore.Join(int64(task.ID), user.ID)
+		require.NoError(t, err)
+		stats, err = badgeStore.GetUserActivityStats(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.TasksJoined)
+		assert.Zero(t, stats.LongestCheckInStreak)
+	})
+
+	t.Run("AwardBadge only once", func(t *testing.T) {
+		ok, err := badgeStore.AwardBadge(user.ID, badge.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = badgeStore.AwardBadge(user.ID, badge.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		badges, err := badgeStore.GetUserBadges(user.ID)
+		require.NoError(t, err)
+		require.Len(t, badges, 1)
+		assert.Equal(t, "two_tasks", badges[0].Code)
+	})
+
+	t.Run("DeleteBadge", func(t *testing.T) {
+		err := badgeStore.DeleteBadge(badge.ID)
+		require.NoError(t, err)
+
+		retrieved, err := badgeStore.GetBadgeByID(badge.ID)
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+
+		err = badgeStore.DeleteBadge(badge.ID)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+}
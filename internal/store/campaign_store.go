@@ -0,0 +1,529 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// MaxCampaignTasks bounds how many tasks one request can add to a campaign.
+const MaxCampaignTasks = 100
+
+var brandColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+var (
+	ErrCampaignAssetNotFound = errors.New("brand images must be ids of images you uploaded")
+	ErrCampaignTaskNotFound  = errors.New("task_ids must only contain tasks with the same owner as the campaign")
+)
+
+// CampaignStatus follows from the campaign's date range.
+type CampaignStatus string
+
+const (
+	CampaignUpcoming CampaignStatus = "upcoming"
+	CampaignActive   CampaignStatus = "active"
+	CampaignEnded    CampaignStatus = "ended"
+)
+
+// CampaignBrand is how the brand behind a campaign presents itself.
+type CampaignBrand struct {
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	WebsiteURL string `json:"website_url"`
+	// LogoAssetID and BannerAssetID are images the campaign's owner uploaded.
+	LogoAssetID   *string `json:"logo_asset_id"`
+	BannerAssetID *string `json:"banner_asset_id"`
+}
+
+// Campaign groups the tasks a creator runs for one launch or brand.
+type Campaign struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// OrganizationID is set on campaigns an organization owns. It is fixed
+	// when the campaign is created.
+	OrganizationID *int64        `json:"organization_id,omitempty"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description"`
+	Brand          CampaignBrand `json:"brand"`
+	BudgetUSDT     float64       `json:"budget_usdt"`
+	StartsAt       time.Time     `json:"starts_at"`
+	EndsAt         time.Time     `json:"ends_at"`
+	// Status and TaskCount are filled in when reading.
+	Status    CampaignStatus `json:"status"`
+	TaskCount int64          `json:"task_count"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Validate reports the first problem with the campaign's fields. Whether the
+// brand images exist is checked when the campaign is saved.
+func (c *Campaign) Validate() error {
+	if strings.TrimSpace(c.Name) == "" || utf8.RuneCountInString(c.Name) > 255 {
+		return errors.New("name is required and must be at most 255 characters")
+	}
+	if utf8.RuneCountInString(c.Description) > 5000 {
+		return errors.New("description must be at most 5000 characters")
+	}
+	if utf8.RuneCountInString(c.Brand.Name) > 100 {
+		return errors.New("brand.name must be at most 100 characters")
+	}
+	if c.Brand.Color != "" && !brandColorPattern.MatchString(c.Brand.Color) {
+		return errors.New("brand.color must be a hex color like #1DA1F2")
+	}
+	if c.Brand.WebsiteURL != "" && !isHTTPURL(c.Brand.WebsiteURL) {
+		return errors.New("brand.website_url must be an http or https URL")
+	}
+	if c.BudgetUSDT < 0 {
+		return errors.New("budget_usdt must not be negative")
+	}
+	if c.StartsAt.IsZero() || c.EndsAt.IsZero() {
+		return errors.New("starts_at and ends_at are required")
+	}
+	if !c.EndsAt.After(c.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+func (c *Campaign) setStatus(now time.Time) {
+	switch {
+	case now.Before(c.StartsAt):
+		c.Status = CampaignUpcoming
+	case now.Before(c.EndsAt):
+		c.Status = CampaignActive
+	default:
+		c.Status = CampaignEnded
+	}
+}
+
+// CampaignStats aggregates every task in a campaign.
+type CampaignStats struct {
+	TaskCount int64 `json:"task_count"`
+	// Participants counts distinct users, Participations every join including
+	// each period of recurring tasks.
+	Participants   int64 `json:"participants"`
+	Participations int64 `json:"participations"`
+	Verified       int64 `json:"verified"`
+	// CompletionRate is Verified over Participations, 0 without participations.
+	CompletionRate float64 `json:"completion_rate"`
+	// SpendUSDT is the USDT paid out by granted rewards.
+	SpendUSDT           float64 `json:"spend_usdt"`
+	RemainingBudgetUSDT float64 `json:"remaining_budget_usdt"`
+}
+
+// CampaignParticipant is one user who joined any of a campaign's tasks.
+type CampaignParticipant struct {
+	UserID         int64     `json:"user_id"`
+	Username       string    `json:"username"`
+	Participations int64     `json:"participations"`
+	Verified       int64     `json:"verified"`
+	FirstJoinedAt  time.Time `json:"first_joined_at"`
+}
+
+type PostgresCampaignStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresCampaignStore(db *sql.DB, clock Clock) *PostgresCampaignStore {
+	return &PostgresCampaignStore{db: db, now: clock}
+}
+
+type CampaignStore interface {
+	CreateCampaign(c *Campaign) (*Campaign, error)
+	GetCampaignByID(id int64) (*Campaign, error)
+	GetCampaigns(userID, orgID int64, page utils.PageParams) ([]Campaign, utils.PageBounds, error)
+	UpdateCampaign(c *Campaign) error
+	DeleteCampaign(id int64) error
+	AddCampaignTasks(campaignID int64, taskIDs []int64) error
+	RemoveCampaignTask(campaignID, taskID int64) error
+	GetCampaignStats(campaignID int64) (*CampaignStats, error)
+	GetCampaignParticipants(campaignID int64, page utils.PageParams) ([]CampaignParticipant, utils.PageBounds, error)
+}
+
+// checkCampaignAssets makes sure the brand images belong to the owner.
+func checkCampaignAssets(q dbtx, c *Campaign) error {
+	for _, id := range []*string{c.Brand.LogoAssetID, c.Brand.BannerAssetID} {
+		if id == nil {
+			continue
+		}
+		owned, err := isAssetOwnedBy(q, *id, c.UserID)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return ErrCampaignAssetNotFound
+		}
+	}
+	return nil
+}
+
+func (pg *PostgresCampaignStore) CreateCampaign(c *Campaign) (*Campaign, error) {
+	if c.UserID == 0 {
+		return nil, errors.New("user id is required and can not be zero")
+	}
+
+	err := checkCampaignAssets(pg.db, c)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO campaigns (
+			user_id,
+			organization_id,
+			name,
+			description,
+			brand_name,
+			brand_color,
+			website_url,
+			logo_asset_id,
+			banner_asset_id,
+			budget_usdt,
+			starts_at,
+			ends_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at
+	`
+	err = pg.db.QueryRow(query, c.UserID, c.OrganizationID, c.Name, c.Description, c.Brand.Name, c.Brand.Color, c.Brand.WebsiteURL,
+		c.Brand.LogoAssetID, c.Brand.BannerAssetID, c.BudgetUSDT, c.StartsAt, c.EndsAt).
+		Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	c.TaskCount = 0
+	c.setStatus(pg.now())
+	return c, nil
+}
+
+const campaignColumns = `
+	c.id,
+	c.user_id,
+	c.organization_id,
+	c.name,
+	c.description,
+	c.brand_name,
+	c.brand_color,
+	c.website_url,
+	c.logo_asset_id,
+	c.banner_asset_id,
+	c.budget_usdt,
+	c.starts_at,
+	c.ends_at,
+	(SELECT COUNT(*) FROM tasks t WHERE t.campaign_id = c.id),
+	c.created_at,
+	c.updated_at
+`
+
+func scanCampaign(row interface{ Scan(dest ...any) error }, extra ...any) (*Campaign, error) {
+	var c Campaign
+	dest := append([]any{
+		&c.ID,
+		&c.UserID,
+		&c.OrganizationID,
+		&c.Name,
+		&c.Description,
+		&c.Brand.Name,
+		&c.Brand.Color,
+		&c.Brand.WebsiteURL,
+		&c.Brand.LogoAssetID,
+		&c.Brand.BannerAssetID,
+		&c.BudgetUSDT,
+		&c.StartsAt,
+		&c.EndsAt,
+		&c.TaskCount,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (pg *PostgresCampaignStore) GetCampaignByID(id int64) (*Campaign, error) {
+	c, err := scanCampaign(pg.db.QueryRow(`SELECT `+campaignColumns+` FROM campaigns c WHERE c.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.setStatus(pg.now())
+	return c, nil
+}
+
+// GetCampaigns returns a page of the organization's campaigns, or of the
+// user's own campaigns when orgID is 0, newest first.
+func (pg *PostgresCampaignStore) GetCampaigns(userID, orgID int64, page utils.PageParams) ([]Campaign, utils.PageBounds, error) {
+	ks := keyset{key: "c.created_at", cast: "timestamptz", id: "c.id", desc: true}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	owner := "c.organization_id = $1"
+	ownerArg := orgID
+	if orgID == 0 {
+		owner = "c.organization_id IS NULL AND c.user_id = $1"
+		ownerArg = userID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM campaigns c
+		WHERE %s %s
+		ORDER BY %s
+		LIMIT $%d
+	`, campaignColumns, ks.keyColumn(), owner, cond, orderBy, len(args)+2)
+
+	args = append([]any{ownerArg}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	now := pg.now()
+	var items []keyed[Campaign]
+	for rows.Next() {
+		var key string
+		c, err := scanCampaign(rows, &key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		c.setStatus(now)
+		items = append(items, keyed[Campaign]{row: *c, key: key, id: c.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	campaigns, bounds := keysetPage(items, page)
+	return campaigns, bounds, nil
+}
+
+// UpdateCampaign replaces every editable field of the campaign.
+func (pg *PostgresCampaignStore) UpdateCampaign(c *Campaign) error {
+	err := checkCampaignAssets(pg.db, c)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE campaigns
+		SET name = $1,
+			description = $2,
+			brand_name = $3,
+			brand_color = $4,
+			website_url = $5,
+			logo_asset_id = $6,
+			banner_asset_id = $7,
+			budget_usdt = $8,
+			starts_at = $9,
+			ends_at = $10,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $11
+		RETURNING updated_at
+	`
+	err = pg.db.QueryRow(query, c.Name, c.Description, c.Brand.Name, c.Brand.Color, c.Brand.WebsiteURL,
+		c.Brand.LogoAssetID, c.Brand.BannerAssetID, c.BudgetUSDT, c.StartsAt, c.EndsAt, c.ID).Scan(&c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("campaign with id %d not found", c.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.setStatus(pg.now())
+	return nil
+}
+
+// DeleteCampaign removes the campaign, its tasks stay without one.
+func (pg *PostgresCampaignStore) DeleteCampaign(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM campaigns WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("campaign with id %d not found", id)
+	}
+
+	return nil
+}
+
+// AddCampaignTasks moves tasks into the campaign, taking them out of any
+// campaign they were in. The tasks must belong to the campaign's
+// organization, or to its creator alone for campaigns without one. Either
+// every task moves or none does.
+func (pg *PostgresCampaignStore) AddCampaignTasks(campaignID int64, taskIDs []int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int64
+	var orgID *int64
+	err = tx.QueryRow(`SELECT user_id, organization_id FROM campaigns WHERE id = $1`, campaignID).Scan(&ownerID, &orgID)
+	if err != nil {
+		return err
+	}
+
+	for _, taskID := range taskIDs {
+		result, err := tx.Exec(`
+			UPDATE tasks
+			SET campaign_id = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND CASE
+				WHEN $4::bigint IS NULL THEN organization_id IS NULL AND user_id = $3
+				ELSE organization_id = $4
+			END`, campaignID, taskID, ownerID, orgID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("%w: %d", ErrCampaignTaskNotFound, taskID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveCampaignTask takes a task out of the campaign. It returns
+// sql.ErrNoRows when the task is not in it.
+func (pg *PostgresCampaignStore) RemoveCampaignTask(campaignID, taskID int64) error {
+	result, err := pg.db.Exec(`
+		UPDATE tasks
+		SET campaign_id = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND campaign_id = $2`, taskID, campaignID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetCampaignStats aggregates the campaign's tasks. Spend adds up the
+// rewarded task events, which hold each reward's amount at the time it was
+// granted, quest rewards included, like the campaign's analytics do.
+func (pg *PostgresCampaignStore) GetCampaignStats(campaignID int64) (*CampaignStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM tasks WHERE campaign_id = $1),
+			COUNT(DISTINCT p.user_id),
+			COUNT(p.id),
+			COUNT(p.id) FILTER (WHERE p.status = $2),
+			(
+				SELECT COALESCE(SUM(e.reward_usdt), 0)
+				FROM task_events e
+				JOIN tasks et ON et.id = e.task_id
+				WHERE e.type = $3 AND et.campaign_id = $1
+			),
+			(SELECT budget_usdt FROM campaigns WHERE id = $1)
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE t.campaign_id = $1
+	`
+
+	var stats CampaignStats
+	var budget float64
+	err := pg.db.QueryRow(query, campaignID, ParticipationVerified, TaskEventRewarded).Scan(
+		&stats.TaskCount,
+		&stats.Participants,
+		&stats.Participations,
+		&stats.Verified,
+		&stats.SpendUSDT,
+		&budget,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Participations > 0 {
+		stats.CompletionRate = float64(stats.Verified) / float64(stats.Participations)
+	}
+	stats.RemainingBudgetUSDT = budget - stats.SpendUSDT
+	return &stats, nil
+}
+
+// GetCampaignParticipants returns a page of the distinct users who joined any
+// of the campaign's tasks, by user id.
+func (pg *PostgresCampaignStore) GetCampaignParticipants(campaignID int64, page utils.PageParams) ([]CampaignParticipant, utils.PageBounds, error) {
+	ks := keyset{id: "u.id"}
+	cond, orderBy, args := ks.clause(page, 3)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			u.id,
+			u.username,
+			COUNT(p.id),
+			COUNT(p.id) FILTER (WHERE p.status = $2),
+			MIN(p.created_at)
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		JOIN users u ON u.id = p.user_id
+		WHERE t.campaign_id = $1 %s
+		GROUP BY u.id, u.username
+		ORDER BY %s
+		LIMIT $%d
+	`, cond, orderBy, len(args)+3)
+
+	args = append([]any{campaignID, ParticipationVerified}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[CampaignParticipant]
+	for rows.Next() {
+		var item keyed[CampaignParticipant]
+		r := &item.row
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Participations, &r.Verified, &r.FirstJoinedAt); err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = r.UserID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	participants, bounds := keysetPage(items, page)
+	return participants, bounds, nil
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBCampaign(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE campaigns, leaderboard_scores, rewards, task_participations, task_events, outbox_events, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestCampaignValidate(t *testing.T) {
+	start := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	valid := Campaign{
+		Name:       "Launch",
+		Brand:      CampaignBrand{Name: "Acme", Color: "#1DA1F2", WebsiteURL: "https://acme.example"},
+		BudgetUSDT: 500,
+		StartsAt:   start,
+		EndsAt:     start.AddDate(0, 1, 0),
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		mutate func(*Campaign)
+	}{
+		{"missing name", func(c *Campaign) { c.Name = "" }},
+		{"bad color", func(c *Campaign) { c.Brand.Color = "blue" }},
+		{"bad website", func(c *Campaign) { c.Brand.WebsiteURL = "acme.example" }},
+		{"negative budget", func(c *Campaign) { c.BudgetUSDT = -1 }},
+		{"missing dates", func(c *Campaign) { c.StartsAt = time.Time{} }},
+		{"ends before it starts", func(c *Campaign) { c.EndsAt = c.StartsAt }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.mutate(&c)
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestCampaignStatus(t *testing.T) {
+	start := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	c := Campaign{StartsAt: start, EndsAt: start.AddDate(0, 1, 0)}
+
+	c.setStatus(start.Add(-time.Second))
+	assert.Equal(t, CampaignUpcoming, c.Status)
+	c.setStatus(start)
+	assert.Equal(t, CampaignActive, c.Status)
+	c.setStatus(c.EndsAt)
+	assert.Equal(t, CampaignEnded, c.Status)
+}
+
+func TestCampaignStore(t *testing.T) {
+	db := setupTestDBCampaign(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	campaignStore := NewPostgresCampaignStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+
+	owner := &User{Username: "campaign-owner", Email: "campaign-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	var players []*User
+	for _, name := range []string{"campaign-player-1", "campaign-player-2"} {
+		player := &User{Username: name, Email: name + "@gmail.com"}
+		player.PasswordHash.Set("password123")
+		player, err = userStore.CreateUser(player)
+		require.NoError(t, err)
+		players = append(players, player)
+	}
+
+	campaign, err := campaignStore.CreateCampaign(&Campaign{
+		UserID:     owner.ID,
+		Name:       "Launch",
+		BudgetUSDT: 10,
+		StartsAt:   now.AddDate(0, 0, -1),
+		EndsAt:     now.AddDate(0, 1, 0),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, CampaignActive, campaign.Status)
+
+	var taskIDs []int64
+	for _, reward := range []float64{2, 3} {
+		task, err := taskStore.CreateTask(&Task{Title: "Campaign task", UserID: owner.ID, RewardUSDT: reward, DueDate: now.AddDate(0, 1, 0)})
+		require.NoError(t, err)
+		taskIDs = append(taskIDs, int64(task.ID))
+	}
+	require.NoError(t, campaignStore.AddCampaignTasks(campaign.ID, taskIDs))
+
+	t.Run("other users' tasks can not be added", func(t *testing.T) {
+		task, err := taskStore.CreateTask(&Task{Title: "Not mine", UserID: players[0].ID, DueDate: now.AddDate(0, 1, 0)})
+		require.NoError(t, err)
+
+		err = campaignStore.AddCampaignTasks(campaign.ID, []int64{int64(task.ID)})
+		assert.ErrorIs(t, err, ErrCampaignTaskNotFound)
+	})
+
+	t.Run("stats add up every task", func(t *testing.T) {
+		for _, player := range players {
+			for _, taskID := range taskIDs {
+				_, _, err := participationStore.Join(taskID, player.ID)
+				require.NoError(t, err)
+			}
+		}
+		p, err := participationStore.GetCurrentParticipation(taskIDs[0], players[0].ID)
+		require.NoError(t, err)
+		_, _, err = participationStore.VerifyParticipation(p.ID, owner.ID)
+		require.NoError(t, err)
+		// raising the reward later does not change what was spent
+		_, err = db.Exec(`UPDATE tasks SET reward_usdt = 5 WHERE id = $1`, taskIDs[0])
+		require.NoError(t, err)
+		writeOutboxTaskEvents(t, db)
+
+		stats, err := campaignStore.GetCampaignStats(campaign.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.TaskCount)
+		assert.Equal(t, int64(2), stats.Participants)
+		assert.Equal(t, int64(4), stats.Participations)
+		assert.Equal(t, int64(1), stats.Verified)
+		assert.Equal(t, 0.25, stats.CompletionRate)
+		assert.Equal(t, 2.0, stats.SpendUSDT)
+		assert.Equal(t, 8.0, stats.RemainingBudgetUSDT)
+	})
+
+	t.Run("participants are listed once", func(t *testing.T) {
+		participants, _, err := campaignStore.GetCampaignParticipants(campaign.ID, utils.PageParams{Limit: 10, Sort: "id"})
+		require.NoError(t, err)
+		require.Len(t, participants, 2)
+		assert.Equal(t, players[0].ID, participants[0].UserID)
+		assert.Equal(t, int64(2), participants[0].Participations)
+		assert.Equal(t, int64(1), participants[0].Verified)
+	})
+
+	t.Run("tasks are listed through the task filter", func(t *testing.T) {
+		tasks, _, total, err := taskStore.GetAllTask(TaskFilter{CampaignID: campaign.ID, Sort: TaskSortNewest, Page: utils.PageParams{Limit: 10, Sort: string(TaskSortNewest)}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		require.Len(t, tasks, 2)
+		assert.Equal(t, campaign.ID, *tasks[0].CampaignID)
+	})
+
+	t.Run("removing and deleting keep the tasks", func(t *testing.T) {
+		require.NoError(t, campaignStore.RemoveCampaignTask(campaign.ID, taskIDs[0]))
+		assert.Equal(t, sql.ErrNoRows, campaignStore.RemoveCampaignTask(campaign.ID, taskIDs[0]))
+
+		require.NoError(t, campaignStore.DeleteCampaign(campaign.ID))
+		task, err := taskStore.GetTaskByID(taskIDs[1])
+		require.NoError(t, err)
+		assert.Nil(t, task.CampaignID)
+	})
+}
@@ -10,6 +10,14 @@ import (
 	"github.com/pressly/goose/v3"
 )
 
+// dbtx is implemented by both *sql.DB and *sql.Tx, so helpers can run
+// inside or outside a transaction.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 type DatabaseConfig struct {
 	Host     string
 	User     string
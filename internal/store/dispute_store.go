@@ -0,0 +1,504 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type DisputeStatus string
+
+const (
+	// DisputeAwaitingCreator waits for the task's creator to respond.
+	DisputeAwaitingCreator DisputeStatus = "awaiting_creator"
+	// DisputeAwaitingModerator waits for a moderator's final decision.
+	DisputeAwaitingModerator DisputeStatus = "awaiting_moderator"
+	// DisputeUpheld keeps the rejection.
+	DisputeUpheld DisputeStatus = "upheld"
+	// DisputeOverturned verified the participation and granted its reward.
+	DisputeOverturned DisputeStatus = "overturned"
+)
+
+func (s DisputeStatus) IsOpen() bool {
+	return s == DisputeAwaitingCreator || s == DisputeAwaitingModerator
+}
+
+// Dispute SLAs. A creator who does not respond in time loses the chance to,
+// the dispute is escalated and a moderator can decide without them.
+const (
+	// DisputeAppealWindow is how long after a rejection it can be appealed.
+	DisputeAppealWindow = 14 * 24 * time.Hour
+	// DisputeResponseSLA is the creator's time to respond to an appeal.
+	DisputeResponseSLA = 72 * time.Hour
+	// DisputeDecisionSLA is the moderators' time to decide once the dispute
+	// reached them, by response or by escalation.
+	DisputeDecisionSLA = 5 * 24 * time.Hour
+)
+
+const MaxDisputeMessageLength = 5000
+
+var (
+	ErrNotRejected          = errors.New("only rejected participations can be appealed")
+	ErrAppealWindowClosed   = fmt.Errorf("rejections can only be appealed within %d days", int(DisputeAppealWindow.Hours()/24))
+	ErrAlreadyDisputed      = errors.New("this rejection has already been appealed")
+	ErrDisputeOpen          = errors.New("an appeal is open for this participation")
+	ErrDisputeClosed        = errors.New("dispute has already been resolved")
+	ErrDisputeResponded     = errors.New("creator has already responded to this dispute")
+	ErrAwaitingCreator      = errors.New("dispute is waiting for the creator's response")
+	ErrEvidenceAssetMissing = errors.New("evidence_asset_id must be the id of an image you uploaded")
+	errEvidenceURLInvalid   = errors.New("evidence_url must be an http or https URL")
+	errEvidenceURLTooLong   = fmt.Errorf("evidence_url must be at most %d characters", MaxProofURLLength)
+)
+
+// DisputeDecision is a moderator's final call on a dispute.
+type DisputeDecision string
+
+const (
+	DisputeUphold   DisputeDecision = "uphold"
+	DisputeOverturn DisputeDecision = "overturn"
+)
+
+func (d DisputeDecision) IsValid() bool {
+	return d == DisputeUphold || d == DisputeOverturn
+}
+
+func (d DisputeDecision) status() DisputeStatus {
+	if d == DisputeOverturn {
+		return DisputeOverturned
+	}
+	return DisputeUpheld
+}
+
+// Appeal is what a participant sends to open a dispute.
+type Appeal struct {
+	Message         string `json:"message"`
+	EvidenceURL     string `json:"evidence_url"`
+	EvidenceAssetID string `json:"evidence_asset_id"`
+}
+
+func (a Appeal) Validate() error {
+	if err := ValidateDisputeMessage(a.Message); err != nil {
+		return err
+	}
+
+	if a.EvidenceURL != "" {
+		if len(a.EvidenceURL) > MaxProofURLLength {
+			return errEvidenceURLTooLong
+		}
+		if !isHTTPURL(a.EvidenceURL) {
+			return errEvidenceURLInvalid
+		}
+	}
+
+	return nil
+}
+
+// ValidateDisputeMessage checks the free text every step of a dispute
+// carries: the appeal, the creator's response and the resolution.
+func ValidateDisputeMessage(message string) error {
+	if strings.TrimSpace(message) == "" {
+		return errors.New("message is required")
+	}
+	if utf8.RuneCountInString(message) > MaxDisputeMessageLength {
+		return fmt.Errorf("message must be at most %d characters", MaxDisputeMessageLength)
+	}
+	return nil
+}
+
+type Dispute struct {
+	ID              int64         `json:"id"`
+	ParticipationID int64         `json:"participation_id"`
+	TaskID          int64         `json:"task_id"`
+	UserID          int64         `json:"user_id"`
+	TaskOwnerID     int64         `json:"task_owner_id"`
+	Status          DisputeStatus `json:"status"`
+	Message         string        `json:"message"`
+	EvidenceURL     string        `json:"evidence_url"`
+	EvidenceAssetID *string       `json:"evidence_asset_id"`
+	CreatorResponse string        `json:"creator_response"`
+	RespondedAt     *time.Time    `json:"responded_at"`
+	Resolution      string        `json:"resolution"`
+	ResolvedBy      *int64        `json:"resolved_by"`
+	ResolvedAt      *time.Time    `json:"resolved_at"`
+	RewardID        *int64        `json:"reward_id"`
+	ResponseDueAt   time.Time     `json:"response_due_at"`
+	DecisionDueAt   time.Time     `json:"decision_due_at"`
+	// Escalated is set once the creator missed their deadline.
+	Escalated bool `json:"escalated"`
+	// Overdue is set on open disputes past their decision deadline.
+	Overdue   bool      `json:"overdue"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// applySLA fills in the flags derived from the deadlines as of now.
+func (d *Dispute) applySLA(now time.Time) {
+	d.Escalated = d.Status == DisputeAwaitingCreator && now.After(d.ResponseDueAt)
+	d.Overdue = d.Status.IsOpen() && now.After(d.DecisionDueAt)
+}
+
+// AwaitsModerator reports whether a moderator can decide the dispute now.
+func (d *Dispute) AwaitsModerator() bool {
+	return d.Status == DisputeAwaitingModerator || d.Escalated
+}
+
+type PostgresDisputeStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresDisputeStore(db *sql.DB, clock Clock) *PostgresDisputeStore {
+	return &PostgresDisputeStore{db: db, now: clock}
+}
+
+type DisputeStore interface {
+	OpenDispute(participationID, userID int64, appeal Appeal) (*Dispute, error)
+	GetDisputeByID(id int64) (*Dispute, error)
+	GetDisputes(status DisputeStatus, overdue bool, page utils.PageParams) ([]Dispute, utils.PageBounds, error)
+	RespondToDispute(id, creatorID int64, response string) (*Dispute, error)
+	ResolveDispute(id, moderatorID int64, decision DisputeDecision, resolution string) (*Dispute, *Reward, []QuestProgress, error)
+}
+
+func hasOpenDispute(q dbtx, participationID int64) (bool, error) {
+	var open bool
+	query := `SELECT EXISTS (SELECT 1 FROM disputes WHERE participation_id = $1 AND status IN ($2, $3))`
+	err := q.QueryRow(query, participationID, DisputeAwaitingCreator, DisputeAwaitingModerator).Scan(&open)
+	return open, err
+}
+
+// OpenDispute appeals the rejection of the user's participation. It starts
+// the creator's response deadline and the overall decision deadline.
+func (pg *PostgresDisputeStore) OpenDispute(participationID, userID int64, appeal Appeal) (*Dispute, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	d := &Dispute{
+		ParticipationID: participationID,
+		UserID:          userID,
+		Status:          DisputeAwaitingCreator,
+		Message:         appeal.Message,
+		EvidenceURL:     appeal.EvidenceURL,
+		ResponseDueAt:   now.Add(DisputeResponseSLA),
+		DecisionDueAt:   now.Add(DisputeResponseSLA + DisputeDecisionSLA),
+	}
+
+	var participant int64
+	var status ParticipationStatus
+	var rejectedAt time.Time
+	query := `
+		SELECT p.task_id, p.user_id, p.status, p.updated_at, t.user_id
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`
+	err = tx.QueryRow(query, participationID).Scan(&d.TaskID, &participant, &status, &rejectedAt, &d.TaskOwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if participant != userID {
+		// the participation is not the caller's, treat it as missing
+		return nil, sql.ErrNoRows
+	}
+	if status != ParticipationRejected {
+		return nil, ErrNotRejected
+	}
+	if now.Sub(rejectedAt) > DisputeAppealWindow {
+		return nil, ErrAppealWindowClosed
+	}
+
+	if appeal.EvidenceAssetID != "" {
+		owned, err := isAssetOwnedBy(tx, appeal.EvidenceAssetID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return nil, ErrEvidenceAssetMissing
+		}
+		d.EvidenceAssetID = &appeal.EvidenceAssetID
+	}
+
+	query = `
+		INSERT INTO disputes (participation_id, task_id, user_id, status, message, evidence_url, evidence_asset_id,
+			response_due_at, decision_due_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		ON CONFLICT (participation_id) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, d.ParticipationID, d.TaskID, d.UserID, d.Status, d.Message, d.EvidenceURL, d.EvidenceAssetID,
+		d.ResponseDueAt, d.DecisionDueAt, now).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyDisputed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    userID,
+		Action:     AuditDisputeOpened,
+		EntityType: AuditEntityDispute,
+		EntityID:   d.ID,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	d.applySLA(now)
+	return d, nil
+}
+
+const disputeColumns = `
+	d.id, d.participation_id, d.task_id, d.user_id, t.user_id, d.status, d.message, d.evidence_url, d.evidence_asset_id,
+	d.creator_response, d.responded_at, d.resolution, d.resolved_by, d.resolved_at, d.reward_id,
+	d.response_due_at, d.decision_due_at, d.created_at, d.updated_at
+`
+
+func disputeFields(d *Dispute) []any {
+	return []any{
+		&d.ID, &d.ParticipationID, &d.TaskID, &d.UserID, &d.TaskOwnerID, &d.Status, &d.Message, &d.EvidenceURL, &d.EvidenceAssetID,
+		&d.CreatorResponse, &d.RespondedAt, &d.Resolution, &d.ResolvedBy, &d.ResolvedAt, &d.RewardID,
+		&d.ResponseDueAt, &d.DecisionDueAt, &d.CreatedAt, &d.UpdatedAt,
+	}
+}
+
+// GetDisputeByID returns nil when there is no dispute with the id.
+func (pg *PostgresDisputeStore) GetDisputeByID(id int64) (*Dispute, error) {
+	query := `SELECT ` + disputeColumns + `
+		FROM disputes d
+		JOIN tasks t ON t.id = d.task_id
+		WHERE d.id = $1
+	`
+
+	var d Dispute
+	err := pg.db.QueryRow(query, id).Scan(disputeFields(&d)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.applySLA(pg.now())
+	return &d, nil
+}
+
+// GetDisputes returns a page of disputes, oldest first, optionally only those
+// with the status or only open ones past their decision deadline.
+func (pg *PostgresDisputeStore) GetDisputes(status DisputeStatus, overdue bool, page utils.PageParams) ([]Dispute, utils.PageBounds, error) {
+	now := pg.now()
+
+	ks := keyset{key: "d.created_at", cast: "timestamptz", id: "d.id"}
+	cond, orderBy, args := ks.clause(page, 6)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM disputes d
+		JOIN tasks t ON t.id = d.task_id
+		WHERE ($1 = '' OR d.status = $1)
+			AND (NOT $2 OR (d.status IN ($3, $4) AND d.decision_due_at < $5)) %s
+		ORDER BY %s
+		LIMIT $%d
+	`, disputeColumns, ks.keyColumn(), cond, orderBy, len(args)+6)
+
+	args = append([]any{status, overdue, DisputeAwaitingCreator, DisputeAwaitingModerator, now}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Dispute]
+	for rows.Next() {
+		var item keyed[Dispute]
+		err := rows.Scan(append(disputeFields(&item.row), &item.key)...)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.row.applySLA(now)
+		item.id = item.row.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	disputes, bounds := keysetPage(items, page)
+	return disputes, bounds, nil
+}
+
+// lockDispute reads an open dispute for update.
+func lockDispute(tx *sql.Tx, id int64, now time.Time) (*Dispute, error) {
+	query := `SELECT ` + disputeColumns + `
+		FROM disputes d
+		JOIN tasks t ON t.id = d.task_id
+		WHERE d.id = $1
+		FOR UPDATE OF d
+	`
+
+	var d Dispute
+	err := tx.QueryRow(query, id).Scan(disputeFields(&d)...)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Status.IsOpen() {
+		return nil, ErrDisputeClosed
+	}
+
+	d.applySLA(now)
+	return &d, nil
+}
+
+// RespondToDispute stores the creator's side and hands the dispute to the
+// moderators. A late response is still recorded but does not extend the
+// decision deadline.
+func (pg *PostgresDisputeStore) RespondToDispute(id, creatorID int64, response string) (*Dispute, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	d, err := lockDispute(tx, id, now)
+	if err != nil {
+		return nil, err
+	}
+	if d.RespondedAt != nil {
+		return nil, ErrDisputeResponded
+	}
+
+	decisionDue := now.Add(DisputeDecisionSLA)
+	if d.DecisionDueAt.Before(decisionDue) {
+		decisionDue = d.DecisionDueAt
+	}
+
+	query := `
+		UPDATE disputes
+		SET status = $1, creator_response = $2, responded_at = $3, decision_due_at = $4, updated_at = $3
+		WHERE id = $5
+	`
+	_, err = tx.Exec(query, DisputeAwaitingModerator, response, now, decisionDue, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    creatorID,
+		Action:     AuditDisputeResponded,
+		EntityType: AuditEntityDispute,
+		EntityID:   id,
+		Details:    map[string]any{"late": d.Escalated},
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	d.Status = DisputeAwaitingModerator
+	d.CreatorResponse = response
+	d.RespondedAt = &now
+	d.DecisionDueAt = decisionDue
+	d.UpdatedAt = now
+	d.applySLA(now)
+	return d, nil
+}
+
+// ResolveDispute records a moderator's final decision. Overturning verifies
+// the participation in the same transaction, which grants the task reward
+// and advances quests as if it had never been rejected.
+func (pg *PostgresDisputeStore) ResolveDispute(id, moderatorID int64, decision DisputeDecision, resolution string) (*Dispute, *Reward, []QuestProgress, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	d, err := lockDispute(tx, id, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !d.AwaitsModerator() {
+		return nil, nil, nil, ErrAwaitingCreator
+	}
+
+	var reward *Reward
+	var progress []QuestProgress
+	if decision == DisputeOverturn {
+		reward, progress, err = verifyParticipation(tx, d.ParticipationID, now)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		d.RewardID = &reward.ID
+	}
+
+	d.Status = decision.status()
+	query := `
+		UPDATE disputes
+		SET status = $1, resolution = $2, resolved_by = $3, resolved_at = $4, reward_id = $5, updated_at = $4
+		WHERE id = $6
+	`
+	_, err = tx.Exec(query, d.Status, resolution, moderatorID, now, d.RewardID, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	action := AuditDisputeUpheld
+	details := map[string]any{"resolution": resolution}
+	if reward != nil {
+		action = AuditDisputeOverturned
+		details["reward_id"] = reward.ID
+	}
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    moderatorID,
+		Action:     action,
+		EntityType: AuditEntityDispute,
+		EntityID:   id,
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	d.Resolution = resolution
+	d.ResolvedBy = &moderatorID
+	d.ResolvedAt = &now
+	d.UpdatedAt = now
+	d.applySLA(now)
+	return d, reward, progress, nil
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisputeSLA(t *testing.T) {
+	opened := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	dispute := func(status DisputeStatus) Dispute {
+		return Dispute{
+			Status:        status,
+			ResponseDueAt: opened.Add(DisputeResponseSLA),
+			DecisionDueAt: opened.Add(DisputeResponseSLA + DisputeDecisionSLA),
+		}
+	}
+
+	tests := []struct {
+		name          string
+		status        DisputeStatus
+		at            time.Time
+		wantEscalated bool
+		wantOverdue   bool
+		wantModerator bool
+	}{
+		{name: "creator still has time", status: DisputeAwaitingCreator, at: opened.Add(time.Hour)},
+		{name: "creator missed the deadline", status: DisputeAwaitingCreator, at: opened.Add(DisputeResponseSLA + time.Minute), wantEscalated: true, wantModerator: true},
+		{name: "nobody acted in time", status: DisputeAwaitingCreator, at: opened.AddDate(0, 1, 0), wantEscalated: true, wantOverdue: true, wantModerator: true},
+		{name: "waiting for a moderator", status: DisputeAwaitingModerator, at: opened.Add(time.Hour), wantModerator: true},
+		{name: "moderators are late", status: DisputeAwaitingModerator, at: opened.AddDate(0, 1, 0), wantOverdue: true, wantModerator: true},
+		{name: "resolved disputes are never late", status: DisputeUpheld, at: opened.AddDate(0, 1, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := dispute(tt.status)
+			d.applySLA(tt.at)
+			assert.Equal(t, tt.wantEscalated, d.Escalated)
+			assert.Equal(t, tt.wantOverdue, d.Overdue)
+			assert.Equal(t, tt.wantModerator, d.AwaitsModerator())
+		})
+	}
+}
+
+func TestAppealValidate(t *testing.T) {
+	assert.NoError(t, Appeal{Message: "the post is live", EvidenceURL: "https://x.com/me/status/1"}.Validate())
+	assert.Error(t, Appeal{Message: " "}.Validate())
+	assert.Equal(t, errEvidenceURLInvalid, Appeal{Message: "see", EvidenceURL: "ftp://example.com"}.Validate())
+}
+
+func setupTestDBDispute(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE audit_log, disputes, task_submissions, leaderboard_scores, rewards, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestDisputeStore(t *testing.T) {
+	db := setupTestDBDispute(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	disputeStore := NewPostgresDisputeStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	submissionStore := NewPostgresSubmissionStore(db, fixedClock(&now))
+	auditStore := NewPostgresAuditStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	owner := &User{Username: "dispute-owner", Email: "dispute-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	player := &User{Username: "dispute-player", Email: "dispute-player@gmail.com"}
+	player.PasswordHash.Set("password123")
+	player, err = userStore.CreateUser(player)
+	require.NoError(t, err)
+
+	moderator := &User{Username: "dispute-moderator", Email: "dispute-moderator@gmail.com"}
+	moderator.PasswordHash.Set("password123")
+	moderator, err = userStore.CreateUser(moderator)
+	require.NoError(t, err)
+
+	task, err := taskStore.CreateTask(&Task{Title: "Screenshot your wallet", UserID: owner.ID, RewardUSDT: 2, DueDate: now.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	taskID := int64(task.ID)
+
+	p, _, err := participationStore.Join(taskID, player.ID)
+	require.NoError(t, err)
+
+	t.Run("only rejections can be appealed", func(t *testing.T) {
+		_, err := disputeStore.OpenDispute(p.ID, player.ID, Appeal{Message: "please check again"})
+		assert.Equal(t, ErrNotRejected, err)
+	})
+
+	require.NoError(t, participationStore.RejectParticipation(p.ID, owner.ID))
+
+	t.Run("appeals belong to the participant", func(t *testing.T) {
+		_, err := disputeStore.OpenDispute(p.ID, moderator.ID, Appeal{Message: "not mine"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	d, err := disputeStore.OpenDispute(p.ID, player.ID, Appeal{Message: "the screenshot was cropped, here is the full one", EvidenceURL: "https://imgur.com/a/1"})
+	require.NoError(t, err)
+	assert.Equal(t, DisputeAwaitingCreator, d.Status)
+	assert.Equal(t, now.Add(DisputeResponseSLA), d.ResponseDueAt)
+
+	t.Run("one appeal per rejection and no new proof meanwhile", func(t *testing.T) {
+		_, err := disputeStore.OpenDispute(p.ID, player.ID, Appeal{Message: "again"})
+		assert.Equal(t, ErrAlreadyDisputed, err)
+
+		_, err = submissionStore.CreateSubmission(taskID, player.ID, Proof{Text: "new proof"})
+		assert.Equal(t, ErrDisputeOpen, err)
+	})
+
+	t.Run("moderators wait for the creator until the deadline", func(t *testing.T) {
+		_, _, _, err := disputeStore.ResolveDispute(d.ID, moderator.ID, DisputeOverturn, "looks fine")
+		assert.Equal(t, ErrAwaitingCreator, err)
+
+		now = now.Add(DisputeResponseSLA + time.Hour)
+		escalated, _, err := disputeStore.GetDisputes("", false, utils.PageParams{Limit: 10, Sort: string(TaskSortOldest)})
+		require.NoError(t, err)
+		require.Len(t, escalated, 1)
+		assert.True(t, escalated[0].Escalated)
+		assert.False(t, escalated[0].Overdue)
+	})
+
+	t.Run("a late response keeps the decision deadline", func(t *testing.T) {
+		responded, err := disputeStore.RespondToDispute(d.ID, owner.ID, "the wallet in the screenshot is empty")
+		require.NoError(t, err)
+		assert.Equal(t, DisputeAwaitingModerator, responded.Status)
+		assert.Equal(t, d.DecisionDueAt, responded.DecisionDueAt)
+
+		_, err = disputeStore.RespondToDispute(d.ID, owner.ID, "again")
+		assert.Equal(t, ErrDisputeResponded, err)
+	})
+
+	t.Run("overturning grants the reward", func(t *testing.T) {
+		resolved, reward, _, err := disputeStore.ResolveDispute(d.ID, moderator.ID, DisputeOverturn, "the full screenshot shows the balance")
+		require.NoError(t, err)
+		assert.Equal(t, DisputeOverturned, resolved.Status)
+		require.NotNil(t, reward)
+		assert.Equal(t, player.ID, reward.UserID)
+		assert.Equal(t, reward.ID, *resolved.RewardID)
+
+		verified, err := participationStore.GetParticipationByID(p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ParticipationVerified, verified.Status)
+
+		_, _, _, err = disputeStore.ResolveDispute(d.ID, moderator.ID, DisputeUphold, "changed my mind")
+		assert.Equal(t, ErrDisputeClosed, err)
+
+		history, _, err := auditStore.GetAuditEntries(AuditEntityDispute, d.ID, utils.PageParams{Limit: 10, Sort: string(TaskSortOldest)})
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, AuditDisputeOpened, history[0].Action)
+		assert.Equal(t, true, history[1].Details["late"])
+		assert.Equal(t, AuditDisputeOverturned, history[2].Action)
+	})
+}
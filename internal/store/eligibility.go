@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type EligibilityRuleKind string
+
+const (
+	// EligibilityMinXAccountAge is the minimum age of the linked X account in days.
+	EligibilityMinXAccountAge EligibilityRuleKind = "min_x_account_age_days"
+	// EligibilityMinXFollowers is the minimum follower count of the linked X account.
+	EligibilityMinXFollowers EligibilityRuleKind = "min_x_followers"
+	// EligibilityEmailVerified requires an email confirmed by the login provider.
+	EligibilityEmailVerified EligibilityRuleKind = "email_verified"
+	// EligibilityWalletLinked requires a wallet address on the account.
+	EligibilityWalletLinked EligibilityRuleKind = "wallet_linked"
+	// EligibilityMinLevel is the minimum platform level, see PlatformLevel.
+	EligibilityMinLevel EligibilityRuleKind = "min_level"
+	// EligibilityAllowlist only lets the listed users join.
+	EligibilityAllowlist EligibilityRuleKind = "allowlist"
+	// EligibilityDenylist keeps the listed users out.
+	EligibilityDenylist EligibilityRuleKind = "denylist"
+)
+
+// PointsPerLevel is how many all-time global leaderboard points make a level.
+const PointsPerLevel int64 = 100
+
+// PlatformLevel is the level a user with the given all-time points is at,
+// everyone starts at level 1.
+func PlatformLevel(points int64) int64 {
+	return points/PointsPerLevel + 1
+}
+
+type EligibilityRule struct {
+	Kind EligibilityRuleKind `json:"kind"`
+	// Threshold is the minimum for the min_* kinds.
+	Threshold int64 `json:"threshold,omitempty"`
+	// UserIDs lists the users of allowlist and denylist rules.
+	UserIDs []int64 `json:"user_ids,omitempty"`
+}
+
+// EligibilityRules must all pass for a user to join a task, an empty list
+// lets everyone join.
+type EligibilityRules []EligibilityRule
+
+func (rules EligibilityRules) Value() (driver.Value, error) {
+	if rules == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(rules)
+}
+
+func (rules *EligibilityRules) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, rules)
+	case string:
+		return json.Unmarshal([]byte(v), rules)
+	default:
+		return fmt.Errorf("cannot scan %T into EligibilityRules", src)
+	}
+}
+
+// Validate reports the first malformed rule.
+func (rules EligibilityRules) Validate() error {
+	seen := make(map[EligibilityRuleKind]bool, len(rules))
+	for _, rule := range rules {
+		if seen[rule.Kind] {
+			return fmt.Errorf("eligibility must not contain %s twice", rule.Kind)
+		}
+		seen[rule.Kind] = true
+
+		switch rule.Kind {
+		case EligibilityMinXAccountAge, EligibilityMinXFollowers, EligibilityMinLevel:
+			if rule.Threshold <= 0 {
+				return fmt.Errorf("eligibility rule %s needs a positive threshold", rule.Kind)
+			}
+		case EligibilityAllowlist, EligibilityDenylist:
+			if len(rule.UserIDs) == 0 {
+				return fmt.Errorf("eligibility rule %s needs user_ids", rule.Kind)
+			}
+		case EligibilityEmailVerified, EligibilityWalletLinked:
+		default:
+			return fmt.Errorf("eligibility rule kind %q is not supported", rule.Kind)
+		}
+	}
+
+	return nil
+}
+
+// EligibilityProfile is everything eligibility rules are evaluated against.
+type EligibilityProfile struct {
+	UserID            int64
+	XAccountCreatedAt *time.Time
+	XFollowers        *int64
+	EmailVerified     bool
+	WalletLinked      bool
+	Level             int64
+}
+
+// RuleFailure explains why a user does not meet a rule. Actual is the user's
+// value for the min_* kinds, nil when it is unknown.
+type RuleFailure struct {
+	Kind      EligibilityRuleKind `json:"kind"`
+	Threshold int64               `json:"threshold,omitempty"`
+	Actual    *int64              `json:"actual,omitempty"`
+	Message   string              `json:"message"`
+}
+
+// IneligibleError is returned when a user fails one or more of a task's
+// eligibility rules.
+type IneligibleError struct {
+	Failures []RuleFailure
+}
+
+func (e *IneligibleError) Error() string {
+	return fmt.Sprintf("user does not meet %d eligibility rule(s)", len(e.Failures))
+}
+
+// IsIneligible returns the failed rules when err is an IneligibleError.
+func IsIneligible(err error) ([]RuleFailure, bool) {
+	var ie *IneligibleError
+	if errors.As(err, &ie) {
+		return ie.Failures, true
+	}
+	return nil, false
+}
+
+func minimumFailure(rule EligibilityRule, actual *int64, message string) *RuleFailure {
+	if actual != nil && *actual >= rule.Threshold {
+		return nil
+	}
+	return &RuleFailure{Kind: rule.Kind, Threshold: rule.Threshold, Actual: actual, Message: message}
+}
+
+// check returns why p fails the rule at now, or nil when it passes.
+func (rule EligibilityRule) check(p EligibilityProfile, now time.Time) *RuleFailure {
+	switch rule.Kind {
+	case EligibilityMinXAccountAge:
+		var days *int64
+		if p.XAccountCreatedAt != nil {
+			d := int64(now.Sub(*p.XAccountCreatedAt) / (24 * time.Hour))
+			days = &d
+		}
+		return minimumFailure(rule, days, fmt.Sprintf("X account must be at least %d days old", rule.Threshold))
+	case EligibilityMinXFollowers:
+		return minimumFailure(rule, p.XFollowers, fmt.Sprintf("X account must have at least %d followers", rule.Threshold))
+	case EligibilityMinLevel:
+		level := p.Level
+		return minimumFailure(rule, &level, fmt.Sprintf("platform level must be at least %d", rule.Threshold))
+	case EligibilityEmailVerified:
+		if !p.EmailVerified {
+			return &RuleFailure{Kind: rule.Kind, Message: "email must be verified"}
+		}
+	case EligibilityWalletLinked:
+		if !p.WalletLinked {
+			return &RuleFailure{Kind: rule.Kind, Message: "a wallet must be linked"}
+		}
+	case EligibilityAllowlist:
+		if !slices.Contains(rule.UserIDs, p.UserID) {
+			return &RuleFailure{Kind: rule.Kind, Message: "task is limited to invited users"}
+		}
+	case EligibilityDenylist:
+		if slices.Contains(rule.UserIDs, p.UserID) {
+			return &RuleFailure{Kind: rule.Kind, Message: "user is excluded from this task"}
+		}
+	default:
+		// a rule this version does not know keeps everyone out rather than
+		// letting everyone in
+		return &RuleFailure{Kind: rule.Kind, Message: "unsupported eligibility rule"}
+	}
+
+	return nil
+}
+
+// Evaluate returns every rule p fails at now, empty when p may join.
+func (rules EligibilityRules) Evaluate(p EligibilityProfile, now time.Time) []RuleFailure {
+	failures := []RuleFailure{}
+	for _, rule := range rules {
+		if f := rule.check(p, now); f != nil {
+			failures = append(failures, *f)
+		}
+	}
+	return failures
+}
+
+func loadEligibilityProfile(q dbtx, userID int64) (*EligibilityProfile, error) {
+	query := `
+		SELECT
+			u.x_account_created_at,
+			u.x_followers_count,
+			u.email_verified_at IS NOT NULL,
+			u.wallet_address IS NOT NULL AND u.wallet_address <> '',
+			COALESCE((
+				SELECT ls.points FROM leaderboard_scores ls
+				WHERE ls.scope = 'global' AND ls.scope_id = 0 AND ls.period = 'all_time' AND ls.user_id = u.id
+			), 0)
+		FROM users u
+		WHERE u.id = $1
+	`
+
+	p := &EligibilityProfile{UserID: userID}
+	var createdAt sql.NullTime
+	var followers sql.NullInt64
+	var points int64
+	err := q.QueryRow(query, userID).Scan(&createdAt, &followers, &p.EmailVerified, &p.WalletLinked, &points)
+	if err != nil {
+		return nil, err
+	}
+
+	if createdAt.Valid {
+		p.XAccountCreatedAt = &createdAt.Time
+	}
+	if followers.Valid {
+		p.XFollowers = &followers.Int64
+	}
+	p.Level = PlatformLevel(points)
+
+	return p, nil
+}
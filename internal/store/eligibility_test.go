@@ -0,0 +1,114 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEligibilityRulesEvaluate(t *testing.T) {
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	created := now.AddDate(0, 0, -40)
+	followers := int64(250)
+
+	profile := EligibilityProfile{
+		UserID:            7,
+		XAccountCreatedAt: &created,
+		XFollowers:        &followers,
+		EmailVerified:     true,
+		Level:             2,
+	}
+
+	tests := []struct {
+		name string
+		rule EligibilityRule
+		pass bool
+	}{
+		{"account old enough", EligibilityRule{Kind: EligibilityMinXAccountAge, Threshold: 30}, true},
+		{"account too young", EligibilityRule{Kind: EligibilityMinXAccountAge, Threshold: 60}, false},
+		{"enough followers", EligibilityRule{Kind: EligibilityMinXFollowers, Threshold: 250}, true},
+		{"too few followers", EligibilityRule{Kind: EligibilityMinXFollowers, Threshold: 1000}, false},
+		{"email verified", EligibilityRule{Kind: EligibilityEmailVerified}, true},
+		{"no wallet", EligibilityRule{Kind: EligibilityWalletLinked}, false},
+		{"level met", EligibilityRule{Kind: EligibilityMinLevel, Threshold: 2}, true},
+		{"level not met", EligibilityRule{Kind: EligibilityMinLevel, Threshold: 3}, false},
+		{"allowlisted", EligibilityRule{Kind: EligibilityAllowlist, UserIDs: []int64{3, 7}}, true},
+		{"not allowlisted", EligibilityRule{Kind: EligibilityAllowlist, UserIDs: []int64{3}}, false},
+		{"denylisted", EligibilityRule{Kind: EligibilityDenylist, UserIDs: []int64{7}}, false},
+		{"not denylisted", EligibilityRule{Kind: EligibilityDenylist, UserIDs: []int64{3}}, true},
+		{"unknown kind", EligibilityRule{Kind: "karma"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := EligibilityRules{tt.rule}.Evaluate(profile, now)
+			if tt.pass {
+				assert.Empty(t, failures)
+			} else {
+				require.Len(t, failures, 1)
+				assert.Equal(t, tt.rule.Kind, failures[0].Kind)
+				assert.NotEmpty(t, failures[0].Message)
+			}
+		})
+	}
+
+	t.Run("every failed rule is reported", func(t *testing.T) {
+		rules := EligibilityRules{
+			{Kind: EligibilityMinXFollowers, Threshold: 1000},
+			{Kind: EligibilityEmailVerified},
+			{Kind: EligibilityWalletLinked},
+		}
+		failures := rules.Evaluate(profile, now)
+		require.Len(t, failures, 2)
+		assert.Equal(t, int64(1000), failures[0].Threshold)
+		assert.Equal(t, int64(250), *failures[0].Actual)
+		assert.Equal(t, EligibilityWalletLinked, failures[1].Kind)
+	})
+
+	t.Run("unlinked X account fails X rules", func(t *testing.T) {
+		rules := EligibilityRules{{Kind: EligibilityMinXFollowers, Threshold: 1}}
+		failures := rules.Evaluate(EligibilityProfile{UserID: 7, Level: 1}, now)
+		require.Len(t, failures, 1)
+		assert.Nil(t, failures[0].Actual)
+	})
+}
+
+func TestEligibilityRulesValidate(t *testing.T) {
+	valid := EligibilityRules{
+		{Kind: EligibilityMinXAccountAge, Threshold: 30},
+		{Kind: EligibilityEmailVerified},
+		{Kind: EligibilityDenylist, UserIDs: []int64{4}},
+	}
+	assert.NoError(t, valid.Validate())
+	assert.NoError(t, EligibilityRules(nil).Validate())
+
+	invalid := map[string]EligibilityRules{
+		"missing threshold": {{Kind: EligibilityMinLevel}},
+		"empty allowlist":   {{Kind: EligibilityAllowlist}},
+		"unknown kind":      {{Kind: "karma"}},
+		"duplicate kind":    {{Kind: EligibilityWalletLinked}, {Kind: EligibilityWalletLinked}},
+	}
+	for name, rules := range invalid {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, rules.Validate())
+		})
+	}
+}
+
+func TestEligibilityRulesScan(t *testing.T) {
+	value, err := EligibilityRules(nil).Value()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("[]"), value)
+
+	var rules EligibilityRules
+	require.NoError(t, rules.Scan([]byte(`[{"kind": "min_level", "threshold": 3}]`)))
+	assert.Equal(t, EligibilityRules{{Kind: EligibilityMinLevel, Threshold: 3}}, rules)
+}
+
+func TestPlatformLevel(t *testing.T) {
+	assert.Equal(t, int64(1), PlatformLevel(0))
+	assert.Equal(t, int64(1), PlatformLevel(PointsPerLevel-1))
+	assert.Equal(t, int64(2), PlatformLevel(PointsPerLevel))
+}
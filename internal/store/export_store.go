@@ -0,0 +1,298 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ExportFormat is the file type an export is written as.
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportXLSX ExportFormat = "xlsx"
+)
+
+func (f ExportFormat) IsValid() bool {
+	return f == ExportCSV || f == ExportXLSX
+}
+
+type ExportJobStatus string
+
+const (
+	ExportPending   ExportJobStatus = "pending"
+	ExportRunning   ExportJobStatus = "running"
+	ExportCompleted ExportJobStatus = "completed"
+	ExportFailed    ExportJobStatus = "failed"
+)
+
+const (
+	// ExportTTL is how long a generated export can be downloaded.
+	ExportTTL = 7 * 24 * time.Hour
+	// GenerateExportJob is the kind of the jobs that generate exports, one
+	// is queued with every export. Its payload is a
+	// GenerateExportJobPayload.
+	GenerateExportJob = "exports.generate"
+	// ExportQueue is the job queue of the exports.
+	ExportQueue = "exports"
+	// ExportMaxAttempts is how often an export is tried before it fails.
+	ExportMaxAttempts = 3
+)
+
+// GenerateExportJobPayload names the export a job generates.
+type GenerateExportJobPayload struct {
+	ExportID int64 `json:"export_id"`
+}
+
+// ExportScope selects a task, or every task of a campaign.
+type ExportScope struct {
+	TaskID     int64
+	CampaignID int64
+}
+
+// where is the condition selecting the scope's participations p.
+func (s ExportScope) where(arg int) (string, int64) {
+	if s.CampaignID != 0 {
+		return fmt.Sprintf("t.campaign_id = $%d", arg), s.CampaignID
+	}
+	return fmt.Sprintf("p.task_id = $%d", arg), s.TaskID
+}
+
+// ExportParticipant is one participation as a spreadsheet row.
+type ExportParticipant struct {
+	ParticipationID int64
+	TaskID          int64
+	TaskTitle       string
+	UserID          int64
+	Username        string
+	XHandle         string
+	WalletAddress   string
+	Status          ParticipationStatus
+	// RewardUSDT is what the participation was paid, 0 until it is verified.
+	RewardUSDT float64
+	JoinedAt   time.Time
+	UpdatedAt  time.Time
+}
+
+// ExportJob is an export generated in the background. Once completed the
+// file can be downloaded until ExpiresAt.
+type ExportJob struct {
+	ID          int64           `json:"id"`
+	UserID      int64           `json:"user_id"`
+	TaskID      *int64          `json:"task_id"`
+	CampaignID  *int64          `json:"campaign_id"`
+	Format      ExportFormat    `json:"format"`
+	Status      ExportJobStatus `json:"status"`
+	RowCount    int64           `json:"row_count"`
+	BlobKey     string          `json:"-"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"-"`
+	StartedAt   *time.Time      `json:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	ExpiresAt   *time.Time      `json:"expires_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Scope is what the job exports.
+func (j *ExportJob) Scope() ExportScope {
+	if j.CampaignID != nil {
+		return ExportScope{CampaignID: *j.CampaignID}
+	}
+	return ExportScope{TaskID: *j.TaskID}
+}
+
+type PostgresExportStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresExportStore(db *sql.DB, clock Clock) *PostgresExportStore {
+	return &PostgresExportStore{db: db, now: clock}
+}
+
+type ExportStore interface {
+	CountParticipants(scope ExportScope) (int64, error)
+	EachParticipant(scope ExportScope, fn func(*ExportParticipant) error) error
+	CreateExportJob(job *ExportJob) (*ExportJob, error)
+	GetExportJob(id int64) (*ExportJob, error)
+	StartExportJob(id int64) (*ExportJob, error)
+	CompleteExportJob(id int64, blobKey string, rowCount int64) error
+	FailExportJob(id int64, reason string) error
+	DeleteExpiredExportJobs() ([]string, error)
+}
+
+// CountParticipants returns how many rows an export of the scope has.
+func (pg *PostgresExportStore) CountParticipants(scope ExportScope) (int64, error) {
+	where, id := scope.where(1)
+	query := `
+		SELECT COUNT(*)
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE ` + where
+
+	var count int64
+	err := pg.db.QueryRow(query, id).Scan(&count)
+	return count, err
+}
+
+// EachParticipant calls fn with every participation of the scope, by task
+// and then in the order they were made. Rows are read one at a time, so
+// exports of any size run in constant memory. An error from fn stops the
+// iteration and is returned.
+func (pg *PostgresExportStore) EachParticipant(scope ExportScope, fn func(*ExportParticipant) error) error {
+	where, id := scope.where(1)
+	query := `
+		SELECT
+			p.id, p.task_id, t.title, u.id, u.username,
+			COALESCE(u.x_username, ''), COALESCE(u.wallet_address, ''),
+			p.status,
+			CASE WHEN p.status = 'verified' THEN t.reward_usdt ELSE 0 END,
+			p.created_at, p.updated_at
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + where + `
+		ORDER BY p.task_id, p.created_at, p.id
+	`
+	rows, err := pg.db.Query(query, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var row ExportParticipant
+	for rows.Next() {
+		err = rows.Scan(&row.ParticipationID, &row.TaskID, &row.TaskTitle, &row.UserID, &row.Username,
+			&row.XHandle, &row.WalletAddress, &row.Status, &row.RewardUSDT, &row.JoinedAt, &row.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if err = fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+const exportJobColumns = `id, user_id, task_id, campaign_id, format, status, row_count, COALESCE(blob_key, ''), error, attempts, started_at, completed_at, expires_at, created_at`
+
+func scanExportJob(row interface{ Scan(dest ...any) error }) (*ExportJob, error) {
+	var job ExportJob
+	err := row.Scan(&job.ID, &job.UserID, &job.TaskID, &job.CampaignID, &job.Format, &job.Status, &job.RowCount,
+		&job.BlobKey, &job.Error, &job.Attempts, &job.StartedAt, &job.CompletedAt, &job.ExpiresAt, &job.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CreateExportJob stores an export and queues the job that generates it.
+func (pg *PostgresExportStore) CreateExportJob(job *ExportJob) (*ExportJob, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO export_jobs (user_id, task_id, campaign_id, format, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + exportJobColumns
+	created, err := scanExportJob(tx.QueryRow(query, job.UserID, job.TaskID, job.CampaignID, job.Format, now))
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(GenerateExportJobPayload{ExportID: created.ID})
+	if err != nil {
+		return nil, err
+	}
+	_, err = enqueueJob(tx, &Job{
+		Queue:       ExportQueue,
+		Kind:        GenerateExportJob,
+		Payload:     payload,
+		MaxAttempts: ExportMaxAttempts,
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, tx.Commit()
+}
+
+func (pg *PostgresExportStore) GetExportJob(id int64) (*ExportJob, error) {
+	query := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE id = $1`
+
+	job, err := scanExportJob(pg.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// StartExportJob marks an export as running for another attempt and returns
+// it, nil when it is gone or already finished.
+func (pg *PostgresExportStore) StartExportJob(id int64) (*ExportJob, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = 'running', attempts = attempts + 1, started_at = $1
+		WHERE id = $2 AND status IN ('pending', 'running')
+		RETURNING ` + exportJobColumns
+
+	job, err := scanExportJob(pg.db.QueryRow(query, pg.now(), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// CompleteExportJob records the generated file, it expires after ExportTTL.
+func (pg *PostgresExportStore) CompleteExportJob(id int64, blobKey string, rowCount int64) error {
+	now := pg.now()
+	query := `
+		UPDATE export_jobs
+		SET status = 'completed', blob_key = $1, row_count = $2, completed_at = $3, expires_at = $4
+		WHERE id = $5
+	`
+	_, err := pg.db.Exec(query, blobKey, rowCount, now, now.Add(ExportTTL), id)
+	return err
+}
+
+func (pg *PostgresExportStore) FailExportJob(id int64, reason string) error {
+	query := `UPDATE export_jobs SET status = 'failed', error = $1, completed_at = $2 WHERE id = $3`
+	_, err := pg.db.Exec(query, reason, pg.now(), id)
+	return err
+}
+
+// DeleteExpiredExportJobs deletes the jobs whose file expired, and those
+// that failed more than ExportTTL ago, and returns the blob keys of the
+// files to delete.
+func (pg *PostgresExportStore) DeleteExpiredExportJobs() ([]string, error) {
+	now := pg.now()
+	query := `
+		DELETE FROM export_jobs
+		WHERE expires_at < $1 OR (status = 'failed' AND completed_at < $2)
+		RETURNING COALESCE(blob_key, '')
+	`
+	rows, err := pg.db.Query(query, now, now.Add(-ExportTTL))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBExport(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE export_jobs, jobs, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestExportStore(t *testing.T) {
+	db := setupTestDBExport(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	exportStore := NewPostgresExportStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	var users []*User
+	for _, name := range []string{"export-creator", "export-a", "export-b"} {
+		user := &User{Username: name, Email: name + "@gmail.com"}
+		user.PasswordHash.Set("password123")
+		user, err := userStore.CreateUser(user)
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	creator, a, b := users[0], users[1], users[2]
+	require.NoError(t, userStore.UpdateXProfile(a.ID, XProfile{ID: "x-a", Username: "alice_x", CreatedAt: now}))
+
+	task, err := taskStore.CreateTask(&Task{Title: "Exported", UserID: creator.ID, RewardUSDT: 4, DueDate: now.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	taskID := int64(task.ID)
+
+	pa, _, err := participationStore.Join(taskID, a.ID)
+	require.NoError(t, err)
+	_, _, err = participationStore.Join(taskID, b.ID)
+	require.NoError(t, err)
+	_, _, err = participationStore.VerifyParticipation(pa.ID, creator.ID)
+	require.NoError(t, err)
+
+	t.Run("participants are streamed in join order", func(t *testing.T) {
+		scope := ExportScope{TaskID: taskID}
+		count, err := exportStore.CountParticipants(scope)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		var rows []ExportParticipant
+		err = exportStore.EachParticipant(scope, func(p *ExportParticipant) error {
+			rows = append(rows, *p)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "alice_x", rows[0].XHandle)
+		assert.Equal(t, ParticipationVerified, rows[0].Status)
+		assert.Equal(t, 4.0, rows[0].RewardUSDT)
+		assert.Equal(t, "export-b", rows[1].Username)
+		assert.Zero(t, rows[1].RewardUSDT)
+	})
+
+	t.Run("exports are generated by a job and expire", func(t *testing.T) {
+		job, err := exportStore.CreateExportJob(&ExportJob{UserID: creator.ID, TaskID: &taskID, Format: ExportXLSX})
+		require.NoError(t, err)
+		assert.Equal(t, ExportPending, job.Status)
+
+		var payload GenerateExportJobPayload
+		var raw []byte
+		err = db.QueryRow(`SELECT payload FROM jobs WHERE kind = $1 AND queue = $2`, GenerateExportJob, ExportQueue).Scan(&raw)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(raw, &payload))
+		assert.Equal(t, job.ID, payload.ExportID)
+
+		started, err := exportStore.StartExportJob(job.ID)
+		require.NoError(t, err)
+		require.NotNil(t, started)
+		assert.Equal(t, ExportRunning, started.Status)
+		assert.Equal(t, 1, started.Attempts)
+
+		require.NoError(t, exportStore.CompleteExportJob(job.ID, "exports/1/file.xlsx", 2))
+		done, err := exportStore.GetExportJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ExportCompleted, done.Status)
+		assert.Equal(t, "exports/1/file.xlsx", done.BlobKey)
+
+		// a job run again after the export finished does not start it
+		again, err := exportStore.StartExportJob(job.ID)
+		require.NoError(t, err)
+		assert.Nil(t, again)
+
+		now = now.Add(ExportTTL + time.Hour)
+		keys, err := exportStore.DeleteExpiredExportJobs()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"exports/1/file.xlsx"}, keys)
+
+		missing, err := exportStore.StartExportJob(job.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// feedCandidateLimit caps how many open tasks are ranked for a feed, the
+// newest ones are kept.
+const feedCandidateLimit = 500
+
+// FeedCandidate is an open task the user can still join, with whether they
+// have already opened it.
+type FeedCandidate struct {
+	Task Task
+	Seen bool
+}
+
+// FeedProfile is what the user has done before, which the feed ranks tasks
+// against.
+type FeedProfile struct {
+	// ActionsCompleted counts the user's rewarded tasks per action type.
+	ActionsCompleted map[TypeAction]int64
+	// CreatorEngagements counts the user's participations per task creator.
+	CreatorEngagements map[int64]int64
+}
+
+type PostgresFeedStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresFeedStore(db *sql.DB, clock Clock) *PostgresFeedStore {
+	return &PostgresFeedStore{db: db, now: clock}
+}
+
+type FeedStore interface {
+	GetFeedCandidates(userID int64) ([]FeedCandidate, error)
+	GetFeedProfile(userID int64) (*FeedProfile, error)
+	RecordTaskView(userID, taskID int64) error
+}
+
+// GetFeedCandidates returns the open tasks userID could join right now: not
+// completed, not past their due date, not their own, not a locked quest step,
+// not full, not already joined for the current period and with every
+// eligibility rule met.
+func (pg *PostgresFeedStore) GetFeedCandidates(userID int64) ([]FeedCandidate, error) {
+	now := pg.now()
+
+	profile, err := loadEligibilityProfile(pg.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// latest_period is the newest period anyone joined and latest_count the
+	// participants in it, which is the current period's count when they match
+	query := `
+		SELECT
+			t.id,
+			t.title,
+			COALESCE(t.description, ''),
+			t.user_id,
+			t.reward_usdt,
+			t.due_date,
+			COALESCE(t.max_participant, ''),
+			COALESCE(t.task_image, ''),
+			t.status,
+			t.recurrence,
+			t.recurrence_timezone,
+			t.eligibility,
+			t.created_at,
+			EXISTS (SELECT 1 FROM task_views v WHERE v.task_id = t.id AND v.user_id = $1),
+			(SELECT MAX(p.period_start) FROM task_participations p WHERE p.task_id = t.id AND p.user_id = $1),
+			latest.period_start,
+			COALESCE(latest.participants, 0)
+		FROM tasks t
+		LEFT JOIN LATERAL (
+			SELECT p.period_start, COUNT(*) AS participants
+			FROM task_participations p
+			WHERE p.task_id = t.id
+			GROUP BY p.period_start
+			ORDER BY p.period_start DESC
+			LIMIT 1
+		) latest ON TRUE
+		WHERE t.status::text NOT IN ('COMPLETED', 'DRAFT')
+		AND (t.due_date IS NULL OR t.due_date > $2)
+		AND t.user_id <> $1
+		AND NOT EXISTS (
+			SELECT 1
+			FROM quest_steps qs
+			JOIN quest_steps prev ON prev.quest_id = qs.quest_id AND prev.position < qs.position
+			WHERE qs.task_id = t.id
+			AND NOT EXISTS (
+				SELECT 1 FROM task_participations p
+				WHERE p.task_id = prev.task_id AND p.user_id = $1 AND p.status = 'verified'
+			)
+		)
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT $3
+	`
+	rows, err := pg.db.Query(query, userID, now, feedCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []FeedCandidate{}
+	for rows.Next() {
+		var c FeedCandidate
+		var dueDate, joinedPeriod, latestPeriod sql.NullTime
+		var latestCount int64
+		t := &c.Task
+		err := rows.Scan(
+			&t.ID,
+			&t.Title,
+			&t.Description,
+			&t.UserID,
+			&t.RewardUSDT,
+			&dueDate,
+			&t.MaxParticipant,
+			&t.TaskImage,
+			&t.Status,
+			&t.Recurrence,
+			&t.RecurrenceTimezone,
+			&t.Eligibility,
+			&t.CreatedAt,
+			&c.Seen,
+			&joinedPeriod,
+			&latestPeriod,
+			&latestCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.DueDate = dueDate.Time
+
+		loc, err := time.LoadLocation(t.RecurrenceTimezone)
+		if err != nil {
+			return nil, err
+		}
+		period := t.Recurrence.PeriodStart(now, loc)
+
+		if joinedPeriod.Valid && joinedPeriod.Time.Equal(period) {
+			continue
+		}
+		if latestPeriod.Valid && latestPeriod.Time.Equal(period) && isTaskFull(t.MaxParticipant, latestCount) {
+			continue
+		}
+		if len(t.Eligibility.Evaluate(*profile, now)) > 0 {
+			continue
+		}
+
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	refs := make([]*Task, len(candidates))
+	for i := range candidates {
+		refs[i] = &candidates[i].Task
+	}
+	err = loadTaskLinks(pg.db, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// isTaskFull reports whether count participants reach maxParticipant. Tasks
+// without a numeric maximum are never full.
+func isTaskFull(maxParticipant string, count int64) bool {
+	max, err := strconv.ParseInt(maxParticipant, 10, 64)
+	return err == nil && max > 0 && count >= max
+}
+
+func (pg *PostgresFeedStore) GetFeedProfile(userID int64) (*FeedProfile, error) {
+	profile := &FeedProfile{
+		ActionsCompleted:   map[TypeAction]int64{},
+		CreatorEngagements: map[int64]int64{},
+	}
+
+	query := `
+		SELECT a.type, COUNT(*)
+		FROM rewards r
+		JOIN task_action_links l ON l.task_id = r.task_id
+		JOIN task_actions a ON a.id = l.action_id
+		WHERE r.user_id = $1 AND r.quest_id IS NULL
+		GROUP BY a.type
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var actionType TypeAction
+		var count int64
+		if err := rows.Scan(&actionType, &count); err != nil {
+			return nil, err
+		}
+		profile.ActionsCompleted[actionType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT t.user_id, COUNT(*)
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE p.user_id = $1
+		GROUP BY t.user_id
+	`
+	creatorRows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer creatorRows.Close()
+
+	for creatorRows.Next() {
+		var creatorID, count int64
+		if err := creatorRows.Scan(&creatorID, &count); err != nil {
+			return nil, err
+		}
+		profile.CreatorEngagements[creatorID] = count
+	}
+
+	return profile, creatorRows.Err()
+}
+
+// RecordTaskView notes that the user opened the task, the feed ranks tasks
+// they have not opened yet higher. The analytics view event is recorded
+// separately, through the event writer.
+func (pg *PostgresFeedStore) RecordTaskView(userID, taskID int64) error {
+	query := `
+		INSERT INTO task_views (user_id, task_id, first_viewed_at, last_viewed_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id, task_id)
+		DO UPDATE SET
+			view_count = task_views.view_count + 1,
+			last_viewed_at = EXCLUDED.last_viewed_at
+	`
+	_, err := pg.db.Exec(query, userID, taskID, pg.now())
+	return err
+}
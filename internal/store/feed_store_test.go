@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBFeed(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE task_views, quest_progress, quest_steps, quests, rewards, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestFeedStore(t *testing.T) {
+	db := setupTestDBFeed(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	feedStore := NewPostgresFeedStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	questStore := NewPostgresQuestStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	creator := &User{Username: "feed-creator", Email: "feed-creator@gmail.com"}
+	creator.PasswordHash.Set("password123")
+	creator, err := userStore.CreateUser(creator)
+	require.NoError(t, err)
+
+	viewer := &User{Username: "feed-viewer", Email: "feed-viewer@gmail.com"}
+	viewer.PasswordHash.Set("password123")
+	viewer, err = userStore.CreateUser(viewer)
+	require.NoError(t, err)
+
+	createTask := func(title string, owner int64, due time.Time, maxParticipant string) int64 {
+		task, err := taskStore.CreateTask(&Task{
+			Title:          title,
+			UserID:         owner,
+			RewardUSDT:     1,
+			DueDate:        due,
+			MaxParticipant: maxParticipant,
+		})
+		require.NoError(t, err)
+		return int64(task.ID)
+	}
+
+	open := createTask("Open", creator.ID, now.AddDate(0, 1, 0), "")
+	createTask("Expired", creator.ID, now.AddDate(0, 0, -1), "")
+	createTask("Own", viewer.ID, now.AddDate(0, 1, 0), "")
+	joined := createTask("Joined", creator.ID, now.AddDate(0, 1, 0), "")
+	full := createTask("Full", creator.ID, now.AddDate(0, 1, 0), "1")
+	first := createTask("First step", creator.ID, now.AddDate(0, 1, 0), "")
+	locked := createTask("Second step", creator.ID, now.AddDate(0, 1, 0), "")
+
+	_, err = questStore.CreateQuest(&Quest{
+		UserID: creator.ID,
+		Title:  "Steps",
+		Steps:  []QuestStep{{TaskID: first}, {TaskID: locked}},
+	})
+	require.NoError(t, err)
+
+	_, _, err = participationStore.Join(joined, viewer.ID)
+	require.NoError(t, err)
+	_, _, err = participationStore.Join(full, creator.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, feedStore.RecordTaskView(viewer.ID, open))
+	require.NoError(t, feedStore.RecordTaskView(viewer.ID, open))
+
+	t.Run("candidates leave out tasks the viewer can not join", func(t *testing.T) {
+		candidates, err := feedStore.GetFeedCandidates(viewer.ID)
+		require.NoError(t, err)
+
+		seen := map[int64]bool{}
+		for _, c := range candidates {
+			seen[int64(c.Task.ID)] = c.Seen
+		}
+		assert.Len(t, candidates, 2)
+		assert.Contains(t, seen, first)
+		assert.False(t, seen[first])
+		assert.True(t, seen[open])
+	})
+
+	t.Run("profile counts engagements per creator", func(t *testing.T) {
+		profile, err := feedStore.GetFeedProfile(viewer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), profile.CreatorEngagements[creator.ID])
+		assert.Empty(t, profile.ActionsCompleted)
+	})
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	// JobDead jobs failed their last attempt, they are kept for
+	// DeadJobRetention so that someone can look into them.
+	JobDead JobStatus = "dead"
+)
+
+const (
+	// JobRetention is how long succeeded jobs are kept.
+	JobRetention = 7 * 24 * time.Hour
+	// DeadJobRetention is how long dead jobs are kept.
+	DeadJobRetention = 30 * 24 * time.Hour
+)
+
+// Job is a unit of background work of a kind, run by the workers of its
+// queue once RunAt has passed.
+type Job struct {
+	ID      int64           `json:"id"`
+	Queue   string          `json:"queue"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// UniqueKey, when set, keeps a second job with the same key from being
+	// enqueued for as long as the first one is kept.
+	UniqueKey string    `json:"unique_key,omitempty"`
+	Status    JobStatus `json:"status"`
+	// Attempts counts the runs, including the current one.
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+	// RunAt is when a pending job is due, and until when a running job is
+	// leased to its worker.
+	RunAt       time.Time  `json:"run_at"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// ErrJobLeaseLost is returned when the outcome of a job is recorded by a
+// worker whose lease ran out, the job may be running elsewhere by now.
+var ErrJobLeaseLost = errors.New("lost lease")
+
+type PostgresJobStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresJobStore(db *sql.DB, clock Clock) *PostgresJobStore {
+	return &PostgresJobStore{db: db, now: clock}
+}
+
+type JobStore interface {
+	EnqueueJob(job *Job) (*Job, error)
+	GetJob(id int64) (*Job, error)
+	ClaimJobs(queue string, limit int, lease time.Duration) ([]*Job, error)
+	CompleteJob(id int64, attempt int) error
+	RetryJob(id int64, attempt int, reason string, runAt time.Time) error
+	KillJob(id int64, attempt int, reason string) error
+	DeleteOldJobs() (int64, error)
+}
+
+const jobColumns = "j.id, j.queue, j.kind, j.payload, COALESCE(j.unique_key, ''), j.status, j.attempts, j.max_attempts, j.run_at, j.last_error, j.created_at, j.updated_at, j.completed_at"
+
+func scanJob(row interface{ Scan(dest ...any) error }) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Queue, &j.Kind, &j.Payload, &j.UniqueKey, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &j.CreatedAt, &j.UpdatedAt, &j.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// enqueueJob writes a pending job. Stores call it in the transaction of a
+// change to run the job only once the change is committed. It returns nil,
+// nil when a job with the same unique key exists.
+func enqueueJob(q dbtx, job *Job, now time.Time) (*Job, error) {
+	if job.Queue == "" || job.Kind == "" {
+		return nil, errors.New("job queue and kind are required")
+	}
+	if job.MaxAttempts < 1 {
+		return nil, errors.New("job max attempts must be at least 1")
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if job.Payload == nil {
+		job.Payload = json.RawMessage("{}")
+	}
+
+	query := `
+		INSERT INTO jobs AS j (queue, kind, payload, unique_key, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $7)
+		ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL DO NOTHING
+		RETURNING ` + jobColumns
+	created, err := scanJob(q.QueryRow(query, job.Queue, job.Kind, []byte(job.Payload), job.UniqueKey, job.MaxAttempts, job.RunAt, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return created, err
+}
+
+// EnqueueJob queues a job to run at job.RunAt, or right away when it is not
+// set, and returns it as stored. It returns nil, nil when a job with the
+// same unique key was enqueued before.
+func (pg *PostgresJobStore) EnqueueJob(job *Job) (*Job, error) {
+	return enqueueJob(pg.db, job, pg.now())
+}
+
+// GetJob returns nil, nil when the job does not exist.
+func (pg *PostgresJobStore) GetJob(id int64) (*Job, error) {
+	job, err := scanJob(pg.db.QueryRow(`SELECT `+jobColumns+` FROM jobs j WHERE j.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// ClaimJobs returns up to limit due jobs of the queue, in the order they are
+// due, and leases them to the caller for lease. A running job whose lease
+// ran out, because its worker died, is claimed again. Concurrent workers
+// never claim the same job.
+func (pg *PostgresJobStore) ClaimJobs(queue string, limit int, lease time.Duration) ([]*Job, error) {
+	now := pg.now()
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM jobs
+			WHERE queue = $1 AND status IN ('pending', 'running') AND run_at <= $2
+			ORDER BY run_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs j
+		SET status = 'running', attempts = j.attempts + 1, run_at = $4, updated_at = $2
+		FROM due
+		WHERE j.id = due.id
+		RETURNING ` + jobColumns
+	rows, err := pg.db.Query(query, queue, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// The outcome of a job is only recorded while it is still running the
+// attempt the worker claimed, otherwise the worker lost its lease and the
+// methods return ErrJobLeaseLost.
+
+// CompleteJob marks a claimed job as succeeded.
+func (pg *PostgresJobStore) CompleteJob(id int64, attempt int) error {
+	now := pg.now()
+	query := `
+		UPDATE jobs SET status = 'succeeded', last_error = '', updated_at = $1, completed_at = $1
+		WHERE id = $2 AND status = 'running' AND attempts = $3
+	`
+	return pg.recordOutcome(query, now, id, attempt)
+}
+
+// RetryJob puts a claimed job that failed back in its queue, due at runAt.
+func (pg *PostgresJobStore) RetryJob(id int64, attempt int, reason string, runAt time.Time) error {
+	query := `
+		UPDATE jobs SET status = 'pending', run_at = $4, last_error = $5, updated_at = $1
+		WHERE id = $2 AND status = 'running' AND attempts = $3
+	`
+	return pg.recordOutcome(query, pg.now(), id, attempt, runAt, cleanJobError(reason))
+}
+
+// KillJob moves a claimed job that can not succeed to the dead letters.
+func (pg *PostgresJobStore) KillJob(id int64, attempt int, reason string) error {
+	now := pg.now()
+	query := `
+		UPDATE jobs SET status = 'dead', last_error = $4, updated_at = $1, completed_at = $1
+		WHERE id = $2 AND status = 'running' AND attempts = $3
+	`
+	return pg.recordOutcome(query, now, id, attempt, cleanJobError(reason))
+}
+
+func (pg *PostgresJobStore) recordOutcome(query string, args ...any) error {
+	res, err := pg.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// DeleteOldJobs deletes succeeded jobs after JobRetention and dead ones after
+// DeadJobRetention, and returns how many it deleted.
+func (pg *PostgresJobStore) DeleteOldJobs() (int64, error) {
+	now := pg.now()
+	query := `
+		DELETE FROM jobs
+		WHERE (status = 'succeeded' AND completed_at < $1)
+			OR (status = 'dead' AND completed_at < $2)
+	`
+	res, err := pg.db.Exec(query, now.Add(-JobRetention), now.Add(-DeadJobRetention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// cleanJobError keeps the error of a job storable, Postgres only takes valid
+// UTF-8 without NUL bytes.
+func cleanJobError(reason string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(reason, "\x00", ""), "\uFFFD")
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBJob(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE jobs")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestJobStore(t *testing.T) {
+	db := setupTestDBJob(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	jobStore := NewPostgresJobStore(db, fixedClock(&now))
+
+	enqueue := func(t *testing.T, queue string, runAt time.Time) *Job {
+		job, err := jobStore.EnqueueJob(&Job{Queue: queue, Kind: "greet", Payload: json.RawMessage(`{"name":"alice"}`), MaxAttempts: 3, RunAt: runAt})
+		require.NoError(t, err)
+		return job
+	}
+
+	first := enqueue(t, "default", time.Time{})
+	second := enqueue(t, "default", now.Add(-time.Minute))
+	scheduled := enqueue(t, "default", now.Add(time.Hour))
+	other := enqueue(t, "mail", time.Time{})
+
+	t.Run("enqueued jobs are pending", func(t *testing.T) {
+		assert.Equal(t, JobPending, first.Status)
+		assert.Equal(t, now, first.RunAt.UTC())
+		assert.JSONEq(t, `{"name":"alice"}`, string(first.Payload))
+
+		_, err := jobStore.EnqueueJob(&Job{Queue: "default", Kind: "greet"})
+		assert.Error(t, err)
+	})
+
+	t.Run("unique keys are enqueued once", func(t *testing.T) {
+		unique, err := jobStore.EnqueueJob(&Job{Queue: "unique", Kind: "greet", Payload: json.RawMessage(`{}`), UniqueKey: "greet:1", MaxAttempts: 3})
+		require.NoError(t, err)
+		require.NotNil(t, unique)
+		assert.Equal(t, "greet:1", unique.UniqueKey)
+
+		again, err := jobStore.EnqueueJob(&Job{Queue: "unique", Kind: "greet", Payload: json.RawMessage(`{}`), UniqueKey: "greet:1", MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.Nil(t, again)
+
+		got, err := jobStore.GetJob(unique.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "greet:1", got.UniqueKey)
+	})
+
+	t.Run("due jobs of the queue are claimed once", func(t *testing.T) {
+		jobs, err := jobStore.ClaimJobs("default", 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		ids := []int64{jobs[0].ID, jobs[1].ID}
+		assert.ElementsMatch(t, []int64{first.ID, second.ID}, ids)
+		assert.Equal(t, JobRunning, jobs[0].Status)
+		assert.Equal(t, 1, jobs[0].Attempts)
+
+		jobs, err = jobStore.ClaimJobs("default", 10, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, jobs)
+	})
+
+	t.Run("the limit is respected", func(t *testing.T) {
+		jobs, err := jobStore.ClaimJobs("mail", 0, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, jobs)
+	})
+
+	t.Run("outcomes are recorded", func(t *testing.T) {
+		require.NoError(t, jobStore.CompleteJob(first.ID, 1))
+		require.NoError(t, jobStore.RetryJob(second.ID, 1, "mailbox full\x00", now.Add(time.Minute)))
+
+		job, err := jobStore.GetJob(second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobPending, job.Status)
+		assert.Equal(t, "mailbox full", job.LastError)
+
+		now = now.Add(time.Minute)
+		jobs, err := jobStore.ClaimJobs("default", 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, 2, jobs[0].Attempts)
+
+		require.NoError(t, jobStore.KillJob(second.ID, 2, "no such user"))
+		job, err = jobStore.GetJob(second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobDead, job.Status)
+		assert.NotNil(t, job.CompletedAt)
+	})
+
+	t.Run("expired leases are claimed again", func(t *testing.T) {
+		jobs, err := jobStore.ClaimJobs("mail", 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		now = now.Add(2 * time.Minute)
+		jobs, err = jobStore.ClaimJobs("mail", 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, other.ID, jobs[0].ID)
+		assert.Equal(t, 2, jobs[0].Attempts)
+
+		// the first worker finishing late does not touch the new attempt
+		assert.ErrorIs(t, jobStore.RetryJob(other.ID, 1, "timeout", now), ErrJobLeaseLost)
+		assert.ErrorIs(t, jobStore.KillJob(other.ID, 1, "timeout"), ErrJobLeaseLost)
+		job, err := jobStore.GetJob(other.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobRunning, job.Status)
+
+		require.NoError(t, jobStore.CompleteJob(other.ID, 2))
+		assert.ErrorIs(t, jobStore.CompleteJob(other.ID, 2), ErrJobLeaseLost)
+	})
+
+	t.Run("scheduled jobs wait for their time", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		jobs, err := jobStore.ClaimJobs("default", 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, scheduled.ID, jobs[0].ID)
+	})
+
+	t.Run("finished jobs are trimmed", func(t *testing.T) {
+		now = now.Add(JobRetention + time.Hour)
+		deleted, err := jobStore.DeleteOldJobs()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		now = now.Add(DeadJobRetention)
+		deleted, err = jobStore.DeleteOldJobs()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		missing, err := jobStore.GetJob(first.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type LeaderboardScope string
+
+const (
+	LeaderboardScopeGlobal LeaderboardScope = "global"
+	LeaderboardScopeTask   LeaderboardScope = "task"
+)
+
+type LeaderboardWindow string
+
+const (
+	LeaderboardDaily   LeaderboardWindow = "daily"
+	LeaderboardWeekly  LeaderboardWindow = "weekly"
+	LeaderboardAllTime LeaderboardWindow = "all_time"
+)
+
+type LeaderboardSort string
+
+const (
+	LeaderboardByPoints   LeaderboardSort = "points"
+	LeaderboardByEarnings LeaderboardSort = "earnings"
+)
+
+// PointsPerReward is the number of points a user scores for each granted reward.
+// Keep in sync with the backfill in migrations/00012_leaderboard_scores.sql.
+const PointsPerReward int64 = 10
+
+// allTimePeriodStart is the fixed period_start used by the all_time window.
+var allTimePeriodStart = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type LeaderboardEntry struct {
+	Rank     int64   `json:"rank"`
+	UserID   int64   `json:"user_id"`
+	Username string  `json:"username"`
+	Points   int64   `json:"points"`
+	Earnings float64 `json:"earnings"`
+}
+
+type LeaderboardQuery struct {
+	Scope   LeaderboardScope
+	ScopeID int64
+	Window  LeaderboardWindow
+	SortBy  LeaderboardSort
+	Limit   int64
+	// At selects which daily/weekly period to read, defaults to now.
+	At time.Time
+}
+
+type PostgresLeaderboardStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLeaderboardStore(db *sql.DB) *PostgresLeaderboardStore {
+	return &PostgresLeaderboardStore{db: db}
+}
+
+type LeaderboardStore interface {
+	GetLeaderboard(q LeaderboardQuery) ([]LeaderboardEntry, error)
+	GetUserRank(q LeaderboardQuery, userID int64) (*LeaderboardEntry, error)
+}
+
+// periodStart returns the start of the window containing t. Daily and weekly
+// windows are aligned to UTC days and ISO weeks (starting on Monday).
+func periodStart(window LeaderboardWindow, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch window {
+	case LeaderboardDaily:
+		return day
+	case LeaderboardWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return allTimePeriodStart
+	}
+}
+
+type leaderboardScopeRef struct {
+	scope LeaderboardScope
+	id    int64
+}
+
+// taskLeaderboardScopes are the leaderboards a reward for taskID counts on.
+func taskLeaderboardScopes(taskID int64) []leaderboardScopeRef {
+	return []leaderboardScopeRef{
+		{LeaderboardScopeGlobal, 0},
+		{LeaderboardScopeTask, taskID},
+	}
+}
+
+// incrementLeaderboardScores adds a granted reward to every leaderboard in
+// scopes. It runs inside the transaction that records the reward so the
+// materialized scores never drift from the rewards table.
+func incrementLeaderboardScores(tx *sql.Tx, userID int64, scopes []leaderboardScopeRef, earnings float64, at time.Time) error {
+	query := `
+		INSERT INTO leaderboard_scores (scope, scope_id, user_id, period, period_start, points, earnings, last_scored_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (scope, scope_id, user_id, period, period_start)
+		DO UPDATE SET
+			points = leaderboard_scores.points + EXCLUDED.points,
+			earnings = leaderboard_scores.earnings + EXCLUDED.earnings,
+			last_scored_at = EXCLUDED.last_scored_at
+	`
+
+	windows := []LeaderboardWindow{LeaderboardDaily, LeaderboardWeekly, LeaderboardAllTime}
+
+	for _, s := range scopes {
+		for _, w := range windows {
+			_, err := tx.Exec(query, s.scope, s.id, userID, w, periodStart(w, at), PointsPerReward, earnings, at)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func leaderboardOrderBy(sortBy LeaderboardSort) string {
+	// ties are broken by whoever reached the score first, then by user id,
+	// so the ranking is stable between requests
+	if sortBy == LeaderboardByEarnings {
+		return "ls.earnings DESC, ls.last_scored_at ASC, ls.user_id ASC"
+	}
+	return "ls.points DESC, ls.last_scored_at ASC, ls.user_id ASC"
+}
+
+func (q LeaderboardQuery) periodStart() time.Time {
+	at := q.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return periodStart(q.Window, at)
+}
+
+func (pg *PostgresLeaderboardStore) GetLeaderboard(q LeaderboardQuery) ([]LeaderboardEntry, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			ROW_NUMBER() OVER (ORDER BY %[1]s) AS rank,
+			ls.user_id,
+			u.username,
+			ls.points,
+			ls.earnings
+		FROM leaderboard_scores ls
+		JOIN users u ON u.id = ls.user_id
+		WHERE ls.scope = $1 AND ls.scope_id = $2 AND ls.period = $3 AND ls.period_start = $4
+		ORDER BY %[1]s
+		LIMIT $5
+	`, leaderboardOrderBy(q.SortBy))
+
+	rows, err := pg.db.Query(query, q.Scope, q.ScopeID, q.Window, q.periodStart(), q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Username, &e.Points, &e.Earnings); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (pg *PostgresLeaderboardStore) GetUserRank(q LeaderboardQuery, userID int64) (*LeaderboardEntry, error) {
+	query := fmt.Sprintf(`
+		WITH ranked AS (
+			SELECT
+				ROW_NUMBER() OVER (ORDER BY %s) AS rank,
+				ls.user_id,
+				u.username,
+				ls.points,
+				ls.earnings
+			FROM leaderboard_scores ls
+			JOIN users u ON u.id = ls.user_id
+			WHERE ls.scope = $1 AND ls.scope_id = $2 AND ls.period = $3 AND ls.period_start = $4
+		)
+		SELECT rank, user_id, username, points, earnings
+		FROM ranked
+		WHERE user_id = $5
+	`, leaderboardOrderBy(q.SortBy))
+
+	var e LeaderboardEntry
+	err := pg.db.QueryRow(query, q.Scope, q.ScopeID, q.Window, q.periodStart(), userID).Scan(
+		&e.Rank,
+		&e.UserID,
+		&e.Username,
+		&e.Points,
+		&e.Earnings,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
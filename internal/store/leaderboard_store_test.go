@@ -0,0 +1,129 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBLeaderboard(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE leaderboard_scores, rewards, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestPeriodStart(t *testing.T) {
+	// Wednesday evening in Jakarta is still Wednesday in UTC
+	at := time.Date(2025, time.October, 22, 20, 30, 0, 0, time.FixedZone("WIB", 7*60*60))
+
+	assert.Equal(t, time.Date(2025, time.October, 22, 0, 0, 0, 0, time.UTC), periodStart(LeaderboardDaily, at))
+	assert.Equal(t, time.Date(2025, time.October, 20, 0, 0, 0, 0, time.UTC), periodStart(LeaderboardWeekly, at))
+	assert.Equal(t, allTimePeriodStart, periodStart(LeaderboardAllTime, at))
+
+	// Sunday belongs to the week that started on the previous Monday
+	sunday := time.Date(2025, time.October, 26, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.October, 20, 0, 0, 0, 0, time.UTC), periodStart(LeaderboardWeekly, sunday))
+}
+
+func TestLeaderboard(t *testing.T) {
+	db := setupTestDBLeaderboard(t)
+	defer db.Close()
+
+	leaderboardStore := NewPostgresLeaderboardStore(db)
+	rewardsStore := NewPostgresRewardsStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	var users []*User
+	for _, name := range []string{"alice", "bob", "carol"} {
+		u := &User{Username: name, Email: name + "@gmail.com"}
+		u.PasswordHash.Set("password123")
+		created, err := userStore.CreateUser(u)
+		require.NoError(t, err)
+		users = append(users, created)
+	}
+
+	small, err := taskStore.CreateTask(&Task{Title: "Small", UserID: users[0].ID, RewardUSDT: 1})
+	require.NoError(t, err)
+	big, err := taskStore.CreateTask(&Task{Title: "Big", UserID: users[0].ID, RewardUSDT: 5})
+	require.NoError(t, err)
+
+	// alice: 2 small rewards, bob: 1 big reward, carol: 1 small reward after bob
+	grants := []struct {
+		user *User
+		task *Task
+	}{
+		{users[0], small},
+		{users[0], small},
+		{users[1], big},
+		{users[2], small},
+	}
+	for _, g := range grants {
+		_, err := rewardsStore.Create(&Reward{UserID: g.user.ID, TaskID: int64(g.task.ID)})
+		require.NoError(t, err)
+	}
+
+	t.Run("by points", func(t *testing.T) {
+		entries, err := leaderboardStore.GetLeaderboard(LeaderboardQuery{
+			Scope:  LeaderboardScopeGlobal,
+			Window: LeaderboardAllTime,
+			SortBy: LeaderboardByPoints,
+			Limit:  10,
+		})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		assert.Equal(t, users[0].ID, entries[0].UserID)
+		assert.Equal(t, 2*PointsPerReward, entries[0].Points)
+		// bob and carol tie on points, bob scored first
+		assert.Equal(t, users[1].ID, entries[1].UserID)
+		assert.Equal(t, users[2].ID, entries[2].UserID)
+		assert.Equal(t, int64(3), entries[2].Rank)
+	})
+
+	t.Run("by earnings", func(t *testing.T) {
+		entries, err := leaderboardStore.GetLeaderboard(LeaderboardQuery{
+			Scope:  LeaderboardScopeGlobal,
+			Window: LeaderboardDaily,
+			SortBy: LeaderboardByEarnings,
+			Limit:  10,
+		})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, users[1].ID, entries[0].UserID)
+		assert.Equal(t, float64(5), entries[0].Earnings)
+	})
+
+	t.Run("task scope and own rank", func(t *testing.T) {
+		q := LeaderboardQuery{
+			Scope:   LeaderboardScopeTask,
+			ScopeID: int64(small.ID),
+			Window:  LeaderboardWeekly,
+			SortBy:  LeaderboardByPoints,
+			Limit:   10,
+		}
+		entries, err := leaderboardStore.GetLeaderboard(q)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		me, err := leaderboardStore.GetUserRank(q, users[2].ID)
+		require.NoError(t, err)
+		require.NotNil(t, me)
+		assert.Equal(t, int64(2), me.Rank)
+
+		me, err = leaderboardStore.GetUserRank(q, users[1].ID)
+		require.NoError(t, err)
+		assert.Nil(t, me)
+	})
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// NotificationCategory groups notifications a user can turn off together.
+type NotificationCategory string
+
+const (
+	// NotificationSubmissions are the reviews of the user's work.
+	NotificationSubmissions NotificationCategory = "submissions"
+	// NotificationRewards are the rewards paid to the user.
+	NotificationRewards NotificationCategory = "rewards"
+	// NotificationReminders are about tasks the user joined and has not
+	// finished.
+	NotificationReminders NotificationCategory = "reminders"
+)
+
+// NotificationCategories lists every category, in the order they are shown.
+var NotificationCategories = []NotificationCategory{
+	NotificationSubmissions,
+	NotificationRewards,
+	NotificationReminders,
+}
+
+func (c NotificationCategory) IsValid() bool {
+	for _, category := range NotificationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationType is what a notification tells about.
+type NotificationType string
+
+const (
+	NotificationSubmissionApproved NotificationType = "submission.approved"
+	NotificationSubmissionRejected NotificationType = "submission.rejected"
+	NotificationRewardReceived     NotificationType = "reward.received"
+	NotificationTaskExpiring       NotificationType = "task.expiring"
+)
+
+type Notification struct {
+	ID       int64                `json:"id"`
+	UserID   int64                `json:"user_id"`
+	Category NotificationCategory `json:"category"`
+	Type     NotificationType     `json:"type"`
+	Title    string               `json:"title"`
+	Body     string               `json:"body"`
+	Data     NotificationData     `json:"data"`
+	// DedupeKey names what the notification is about, a user is notified
+	// once per key.
+	DedupeKey string     `json:"-"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationData links a notification to what it is about.
+type NotificationData struct {
+	TaskID     int64   `json:"task_id,omitempty"`
+	RewardUSDT float64 `json:"reward_usdt,omitempty"`
+}
+
+func (d NotificationData) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *NotificationData) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("cannot scan %T into NotificationData", src)
+	}
+}
+
+// NotificationFilter narrows a user's notifications, the zero value keeps
+// them all.
+type NotificationFilter struct {
+	Category   NotificationCategory
+	UnreadOnly bool
+}
+
+// UnreadCounts counts a user's unread notifications.
+type UnreadCounts struct {
+	Total      int64                          `json:"total"`
+	ByCategory map[NotificationCategory]int64 `json:"by_category"`
+}
+
+// NotificationPreferences tells for every category whether the user is
+// notified.
+type NotificationPreferences map[NotificationCategory]bool
+
+// ExpiringParticipation is a participation that has not been submitted
+// while its task is about to end.
+type ExpiringParticipation struct {
+	TaskID    int64
+	TaskTitle string
+	UserID    int64
+	DueDate   time.Time
+}
+
+// ExpiringTaskKey is the dedupe key of the reminder about a task ending at
+// dueDate. expiringTaskKeySQL builds the same key in SQL.
+func ExpiringTaskKey(taskID int64, dueDate time.Time) string {
+	return fmt.Sprintf("expiring:%d:%d", taskID, dueDate.Unix())
+}
+
+const expiringTaskKeySQL = `'expiring:' || t.id || ':' || FLOOR(EXTRACT(EPOCH FROM t.due_date))::bigint`
+
+type PostgresNotificationStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresNotificationStore(db *sql.DB, clock Clock) *PostgresNotificationStore {
+	return &PostgresNotificationStore{db: db, now: clock}
+}
+
+type NotificationStore interface {
+	CreateNotification(n *Notification) (bool, error)
+	GetNotifications(userID int64, filter NotificationFilter, page utils.PageParams) ([]Notification, utils.PageBounds, error)
+	GetUnreadCounts(userID int64) (*UnreadCounts, error)
+	MarkNotificationRead(id, userID int64) (*Notification, error)
+	MarkAllNotificationsRead(userID int64, category NotificationCategory) (int64, error)
+	GetNotificationPreferences(userID int64) (NotificationPreferences, error)
+	SetNotificationPreferences(userID int64, prefs NotificationPreferences) error
+	GetExpiringParticipations(from, to time.Time) ([]ExpiringParticipation, error)
+}
+
+const notificationColumns = `n.id, n.user_id, n.category, n.type, n.title, n.body, n.data, n.read_at, n.created_at`
+
+func scanNotification(row interface{ Scan(dest ...any) error }, extra ...any) (*Notification, error) {
+	var n Notification
+	dest := append([]any{&n.ID, &n.UserID, &n.Category, &n.Type, &n.Title, &n.Body, &n.Data, &n.ReadAt, &n.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// CreateNotification notifies the user unless they turned the category off
+// or were already notified with the same dedupe key. It reports whether the
+// notification was created.
+func (pg *PostgresNotificationStore) CreateNotification(n *Notification) (bool, error) {
+	query := `
+		INSERT INTO notifications (user_id, category, type, title, body, data, dedupe_key, created_at)
+		SELECT $1, $2::varchar, $3, $4, $5, $6, $7, $8
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM notification_preferences
+			WHERE user_id = $1 AND category = $2::varchar AND NOT enabled
+		)
+		ON CONFLICT (user_id, dedupe_key) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := pg.db.QueryRow(query, n.UserID, n.Category, n.Type, n.Title, n.Body, n.Data, n.DedupeKey, pg.now()).Scan(&n.ID, &n.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetNotifications returns a page of the user's notifications, newest first.
+func (pg *PostgresNotificationStore) GetNotifications(userID int64, filter NotificationFilter, page utils.PageParams) ([]Notification, utils.PageBounds, error) {
+	conditions := []string{"n.user_id = $1"}
+	args := []any{userID}
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		conditions = append(conditions, fmt.Sprintf("n.category = $%d", len(args)))
+	}
+	if filter.UnreadOnly {
+		conditions = append(conditions, "n.read_at IS NULL")
+	}
+
+	ks := keyset{key: "n.created_at", cast: "timestamptz", id: "n.id", desc: true}
+	cond, orderBy, keyArgs := ks.clause(page, len(args)+1)
+	if cond != "" {
+		conditions = append(conditions, cond)
+	}
+	args = append(args, keyArgs...)
+	args = append(args, page.Limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM notifications n
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+	`, notificationColumns, ks.keyColumn(), strings.Join(conditions, " AND "), orderBy, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Notification]
+	for rows.Next() {
+		var key string
+		n, err := scanNotification(rows, &key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[Notification]{row: *n, key: key, id: n.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	notifications, bounds := keysetPage(items, page)
+	return notifications, bounds, nil
+}
+
+// GetUnreadCounts counts the user's unread notifications, every category is
+// in ByCategory.
+func (pg *PostgresNotificationStore) GetUnreadCounts(userID int64) (*UnreadCounts, error) {
+	query := `
+		SELECT category, COUNT(*)
+		FROM notifications
+		WHERE user_id = $1 AND read_at IS NULL
+		GROUP BY category
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := &UnreadCounts{ByCategory: make(map[NotificationCategory]int64, len(NotificationCategories))}
+	for _, category := range NotificationCategories {
+		counts.ByCategory[category] = 0
+	}
+	for rows.Next() {
+		var category NotificationCategory
+		var count int64
+		if err := rows.Scan(&category, &count); err != nil {
+			return nil, err
+		}
+		counts.ByCategory[category] = count
+		counts.Total += count
+	}
+	return counts, rows.Err()
+}
+
+// MarkNotificationRead marks one of the user's notifications as read, it
+// keeps the time it was first read. It returns nil, nil when the user has no
+// such notification.
+func (pg *PostgresNotificationStore) MarkNotificationRead(id, userID int64) (*Notification, error) {
+	query := `
+		UPDATE notifications n
+		SET read_at = COALESCE(n.read_at, $3)
+		WHERE n.id = $1 AND n.user_id = $2
+		RETURNING ` + notificationColumns
+	n, err := scanNotification(pg.db.QueryRow(query, id, userID, pg.now()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return n, err
+}
+
+// MarkAllNotificationsRead marks the user's unread notifications of the
+// category, or of every category when it is empty, as read and returns how
+// many there were.
+func (pg *PostgresNotificationStore) MarkAllNotificationsRead(userID int64, category NotificationCategory) (int64, error) {
+	query := `
+		UPDATE notifications
+		SET read_at = $2
+		WHERE user_id = $1 AND read_at IS NULL AND ($3 = '' OR category = $3)
+	`
+	res, err := pg.db.Exec(query, userID, pg.now(), string(category))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetNotificationPreferences returns the user's setting for every category,
+// categories are enabled until the user turns them off.
+func (pg *PostgresNotificationStore) GetNotificationPreferences(userID int64) (NotificationPreferences, error) {
+	rows, err := pg.db.Query(`SELECT category, enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := make(NotificationPreferences, len(NotificationCategories))
+	for _, category := range NotificationCategories {
+		prefs[category] = true
+	}
+	for rows.Next() {
+		var category NotificationCategory
+		var enabled bool
+		if err := rows.Scan(&category, &enabled); err != nil {
+			return nil, err
+		}
+		if category.IsValid() {
+			prefs[category] = enabled
+		}
+	}
+	return prefs, rows.Err()
+}
+
+// SetNotificationPreferences changes the categories in prefs and keeps the
+// others.
+func (pg *PostgresNotificationStore) SetNotificationPreferences(userID int64, prefs NotificationPreferences) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notification_preferences (user_id, category, enabled, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, category) DO UPDATE
+		SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at
+	`
+	now := pg.now()
+	for category, enabled := range prefs {
+		_, err = tx.Exec(query, userID, category, enabled, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetExpiringParticipations returns the participations still waiting for a
+// submission on active tasks ending after from and up to to, leaving out
+// those whose user was already reminded.
+func (pg *PostgresNotificationStore) GetExpiringParticipations(from, to time.Time) ([]ExpiringParticipation, error) {
+	query := `
+		SELECT DISTINCT t.id, t.title, p.user_id, t.due_date
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE p.status = 'joined'
+			AND t.status::text = 'ACTIVE'
+			AND t.due_date > $1 AND t.due_date <= $2
+			AND NOT EXISTS (
+				SELECT 1
+				FROM notifications n
+				WHERE n.user_id = p.user_id AND n.dedupe_key = ` + expiringTaskKeySQL + `
+			)
+		ORDER BY t.due_date, t.id, p.user_id
+	`
+	rows, err := pg.db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participations []ExpiringParticipation
+	for rows.Next() {
+		var p ExpiringParticipation
+		if err := rows.Scan(&p.TaskID, &p.TaskTitle, &p.UserID, &p.DueDate); err != nil {
+			return nil, err
+		}
+		participations = append(participations, p)
+	}
+	return participations, rows.Err()
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBNotification(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE notifications, notification_preferences, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestNotificationStore(t *testing.T) {
+	db := setupTestDBNotification(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	notificationStore := NewPostgresNotificationStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	var users []*User
+	for _, name := range []string{"notify-creator", "notify-a", "notify-b"} {
+		user := &User{Username: name, Email: name + "@gmail.com"}
+		user.PasswordHash.Set("password123")
+		user, err := userStore.CreateUser(user)
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	creator, a, b := users[0], users[1], users[2]
+
+	notify := func(t *testing.T, userID int64, category NotificationCategory, key string) bool {
+		created, err := notificationStore.CreateNotification(&Notification{UserID: userID, Category: category, Type: NotificationRewardReceived, Title: "Reward received", Data: NotificationData{TaskID: 1, RewardUSDT: 2}, DedupeKey: key})
+		require.NoError(t, err)
+		return created
+	}
+
+	t.Run("notifications are deduped per user", func(t *testing.T) {
+		assert.True(t, notify(t, a.ID, NotificationRewards, "event:1"))
+		now = now.Add(time.Minute)
+		assert.True(t, notify(t, a.ID, NotificationSubmissions, "event:2"))
+		assert.False(t, notify(t, a.ID, NotificationRewards, "event:1"))
+		assert.True(t, notify(t, b.ID, NotificationRewards, "event:1"))
+	})
+
+	t.Run("notifications are listed newest first", func(t *testing.T) {
+		notifications, _, err := notificationStore.GetNotifications(a.ID, NotificationFilter{}, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.Equal(t, NotificationSubmissions, notifications[0].Category)
+		assert.Equal(t, 2.0, notifications[1].Data.RewardUSDT)
+
+		notifications, _, err = notificationStore.GetNotifications(a.ID, NotificationFilter{Category: NotificationRewards}, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, notifications, 1)
+
+		page, bounds, err := notificationStore.GetNotifications(a.ID, NotificationFilter{}, utils.PageParams{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.True(t, bounds.HasMore)
+	})
+
+	t.Run("notifications are marked read", func(t *testing.T) {
+		counts, err := notificationStore.GetUnreadCounts(a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), counts.Total)
+		assert.Equal(t, int64(0), counts.ByCategory[NotificationReminders])
+
+		notifications, _, err := notificationStore.GetNotifications(a.ID, NotificationFilter{}, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+
+		missing, err := notificationStore.MarkNotificationRead(notifications[0].ID, b.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+
+		read, err := notificationStore.MarkNotificationRead(notifications[0].ID, a.ID)
+		require.NoError(t, err)
+		require.NotNil(t, read.ReadAt)
+		assert.Equal(t, now, read.ReadAt.UTC())
+
+		unread, _, err := notificationStore.GetNotifications(a.ID, NotificationFilter{UnreadOnly: true}, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, unread, 1)
+
+		marked, err := notificationStore.MarkAllNotificationsRead(a.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), marked)
+
+		counts, err = notificationStore.GetUnreadCounts(b.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), counts.Total)
+	})
+
+	t.Run("disabled categories are not notified", func(t *testing.T) {
+		prefs, err := notificationStore.GetNotificationPreferences(a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, NotificationPreferences{NotificationSubmissions: true, NotificationRewards: true, NotificationReminders: true}, prefs)
+
+		require.NoError(t, notificationStore.SetNotificationPreferences(a.ID, NotificationPreferences{NotificationRewards: false}))
+		prefs, err = notificationStore.GetNotificationPreferences(a.ID)
+		require.NoError(t, err)
+		assert.False(t, prefs[NotificationRewards])
+		assert.True(t, prefs[NotificationSubmissions])
+
+		assert.False(t, notify(t, a.ID, NotificationRewards, "event:3"))
+		assert.True(t, notify(t, a.ID, NotificationSubmissions, "event:4"))
+	})
+
+	t.Run("participants of tasks ending soon are found once", func(t *testing.T) {
+		soon, err := taskStore.CreateTask(&Task{Title: "Ending soon", UserID: creator.ID, RewardUSDT: 1, DueDate: now.Add(5 * time.Hour)})
+		require.NoError(t, err)
+		later, err := taskStore.CreateTask(&Task{Title: "Ending later", UserID: creator.ID, RewardUSDT: 1, DueDate: now.Add(72 * time.Hour)})
+		require.NoError(t, err)
+		completed, err := taskStore.CreateTask(&Task{Title: "Completed", UserID: creator.ID, RewardUSDT: 1, DueDate: now.Add(2 * time.Hour)})
+		require.NoError(t, err)
+		for _, taskID := range []int64{int64(soon.ID), int64(later.ID), int64(completed.ID)} {
+			_, _, err = participationStore.Join(taskID, a.ID)
+			require.NoError(t, err)
+		}
+		_, err = db.Exec("UPDATE tasks SET status = 'ACTIVE' WHERE id IN ($1, $2)", soon.ID, later.ID)
+		require.NoError(t, err)
+		_, err = db.Exec("UPDATE tasks SET status = 'COMPLETED' WHERE id = $1", completed.ID)
+		require.NoError(t, err)
+		_, _, err = participationStore.Join(int64(soon.ID), b.ID)
+		require.NoError(t, err)
+
+		expiring, err := notificationStore.GetExpiringParticipations(now, now.Add(24*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, expiring, 2)
+		assert.Equal(t, "Ending soon", expiring[0].TaskTitle)
+
+		created, err := notificationStore.CreateNotification(&Notification{UserID: a.ID, Category: NotificationReminders, Type: NotificationTaskExpiring, Title: "Task ending soon", DedupeKey: ExpiringTaskKey(expiring[0].TaskID, expiring[0].DueDate)})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		expiring, err = notificationStore.GetExpiringParticipations(now, now.Add(24*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, expiring, 1)
+		assert.Equal(t, b.ID, expiring[0].UserID)
+	})
+}
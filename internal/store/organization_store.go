@@ -0,0 +1,773 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// OrgRole is what a member may do in an organization. Every role allows what
+// the roles below it do.
+type OrgRole string
+
+const (
+	// OrgRoleOwner also manages the organization, its members and invitations.
+	OrgRoleOwner OrgRole = "owner"
+	// OrgRoleManager creates, edits and publishes tasks and campaigns.
+	OrgRoleManager OrgRole = "manager"
+	// OrgRoleReviewer verifies participations and reviews proofs and disputes.
+	OrgRoleReviewer OrgRole = "reviewer"
+	// OrgRoleViewer reads drafts, campaigns and participations.
+	OrgRoleViewer OrgRole = "viewer"
+)
+
+var orgRoleRanks = map[OrgRole]int{
+	OrgRoleViewer:   1,
+	OrgRoleReviewer: 2,
+	OrgRoleManager:  3,
+	OrgRoleOwner:    4,
+}
+
+func (r OrgRole) IsValid() bool {
+	return orgRoleRanks[r] > 0
+}
+
+// Can reports whether r allows everything want does. The empty role, which
+// stands for no access, allows nothing.
+func (r OrgRole) Can(want OrgRole) bool {
+	return r.IsValid() && orgRoleRanks[r] >= orgRoleRanks[want]
+}
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+	InvitationRevoked  InvitationStatus = "revoked"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// InvitationTTL is how long an invitation can be accepted.
+const InvitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrLastOrgOwner      = errors.New("an organization needs at least one owner")
+	ErrAlreadyMember     = errors.New("the user is already a member of the organization")
+	ErrInvitationExists  = errors.New("the user already has an open invitation to the organization")
+	ErrInviteeNotFound   = errors.New("no user with that username")
+	ErrInvitationClosed  = errors.New("invitation has already been answered or revoked")
+	ErrInvitationExpired = errors.New("invitation has expired")
+	ErrEmailNotVerified  = errors.New("invitations sent to an email address need a verified email, sign in with Google to verify it")
+)
+
+// Organization lets several users manage the same tasks and campaigns, for
+// example the staff of an agency working for one brand.
+type Organization struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	CreatedBy   *int64 `json:"created_by"`
+	MemberCount int64  `json:"member_count"`
+	// Role is the reading user's role, filled in by the handlers.
+	Role      OrgRole   `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (o *Organization) Validate() error {
+	if strings.TrimSpace(o.Name) == "" || utf8.RuneCountInString(o.Name) > 255 {
+		return errors.New("name is required and must be at most 255 characters")
+	}
+	return nil
+}
+
+type OrgMember struct {
+	OrganizationID int64     `json:"organization_id"`
+	UserID         int64     `json:"user_id"`
+	Username       string    `json:"username"`
+	Role           OrgRole   `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// OrgInvitation asks a user to join an organization. It names either an
+// existing user or an email address, which is matched against the verified
+// email of whoever signs in with it.
+type OrgInvitation struct {
+	ID               int64            `json:"id"`
+	OrganizationID   int64            `json:"organization_id"`
+	OrganizationName string           `json:"organization_name"`
+	UserID           *int64           `json:"user_id"`
+	Username         string           `json:"username,omitempty"`
+	Email            string           `json:"email,omitempty"`
+	Role             OrgRole          `json:"role"`
+	Status           InvitationStatus `json:"status"`
+	InvitedBy        *int64           `json:"invited_by"`
+	ExpiresAt        time.Time        `json:"expires_at"`
+	RespondedAt      *time.Time       `json:"responded_at"`
+	CreatedAt        time.Time        `json:"created_at"`
+}
+
+type PostgresOrganizationStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresOrganizationStore(db *sql.DB, clock Clock) *PostgresOrganizationStore {
+	return &PostgresOrganizationStore{db: db, now: clock}
+}
+
+type OrganizationStore interface {
+	CreateOrganization(org *Organization, ownerID int64) (*Organization, error)
+	GetOrganizationByID(id int64) (*Organization, error)
+	GetUserOrganizations(userID int64, page utils.PageParams) ([]Organization, utils.PageBounds, error)
+	UpdateOrganization(org *Organization) error
+	DeleteOrganization(id int64) error
+	GetMemberRole(orgID, userID int64) (OrgRole, error)
+	GetTaskRole(taskID, userID int64) (OrgRole, error)
+	GetMembers(orgID int64, page utils.PageParams) ([]OrgMember, utils.PageBounds, error)
+	SetMemberRole(orgID, userID int64, role OrgRole) error
+	RemoveMember(orgID, userID int64) error
+	CreateInvitation(inv *OrgInvitation) (*OrgInvitation, error)
+	GetInvitationByID(id int64) (*OrgInvitation, error)
+	GetOrganizationInvitations(orgID int64, page utils.PageParams) ([]OrgInvitation, utils.PageBounds, error)
+	GetUserInvitations(userID int64, page utils.PageParams) ([]OrgInvitation, utils.PageBounds, error)
+	RevokeInvitation(id int64) error
+	RespondToInvitation(id, userID int64, accept bool) (*OrgInvitation, error)
+}
+
+// CreateOrganization saves the organization with ownerID as its first owner.
+func (pg *PostgresOrganizationStore) CreateOrganization(org *Organization, ownerID int64) (*Organization, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO organizations (name, created_by)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at`, org.Name, ownerID).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)`, org.ID, ownerID, OrgRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	org.CreatedBy = &ownerID
+	org.MemberCount = 1
+	org.Role = OrgRoleOwner
+	return org, nil
+}
+
+const organizationColumns = `
+	o.id,
+	o.name,
+	o.created_by,
+	(SELECT COUNT(*) FROM organization_members om WHERE om.organization_id = o.id),
+	o.created_at,
+	o.updated_at
+`
+
+func scanOrganization(row interface{ Scan(dest ...any) error }, extra ...any) (*Organization, error) {
+	var o Organization
+	dest := append([]any{&o.ID, &o.Name, &o.CreatedBy, &o.MemberCount, &o.CreatedAt, &o.UpdatedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (pg *PostgresOrganizationStore) GetOrganizationByID(id int64) (*Organization, error) {
+	o, err := scanOrganization(pg.db.QueryRow(`SELECT `+organizationColumns+` FROM organizations o WHERE o.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return o, err
+}
+
+// GetUserOrganizations returns a page of the organizations the user is a
+// member of, with the user's role, oldest first.
+func (pg *PostgresOrganizationStore) GetUserOrganizations(userID int64, page utils.PageParams) ([]Organization, utils.PageBounds, error) {
+	ks := keyset{id: "o.id"}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, organizationColumns, cond, orderBy, len(args)+2)
+
+	args = append([]any{userID}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Organization]
+	for rows.Next() {
+		var role OrgRole
+		o, err := scanOrganization(rows, &role)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		o.Role = role
+		items = append(items, keyed[Organization]{row: *o, id: o.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	orgs, bounds := keysetPage(items, page)
+	return orgs, bounds, nil
+}
+
+func (pg *PostgresOrganizationStore) UpdateOrganization(org *Organization) error {
+	err := pg.db.QueryRow(`
+		UPDATE organizations
+		SET name = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING updated_at`, org.Name, org.ID).Scan(&org.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("organization with id %d not found", org.ID)
+	}
+	return err
+}
+
+// DeleteOrganization removes the organization with its members and
+// invitations. Its tasks and campaigns go back to the users who created them.
+func (pg *PostgresOrganizationStore) DeleteOrganization(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM organizations WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("organization with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetMemberRole returns the user's role in the organization, empty when they
+// are not a member.
+func (pg *PostgresOrganizationStore) GetMemberRole(orgID, userID int64) (OrgRole, error) {
+	var role OrgRole
+	err := pg.db.QueryRow(`
+		SELECT role FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// GetTaskRole returns the user's role on a task: owner of the tasks they
+// created on their own, their member role on an organization's tasks and
+// empty otherwise or when the task does not exist.
+func (pg *PostgresOrganizationStore) GetTaskRole(taskID, userID int64) (OrgRole, error) {
+	var role OrgRole
+	err := pg.db.QueryRow(`
+		SELECT CASE
+			WHEN t.organization_id IS NOT NULL THEN COALESCE(m.role, '')
+			WHEN t.user_id = $2 THEN $3
+			ELSE ''
+		END
+		FROM tasks t
+		LEFT JOIN organization_members m ON m.organization_id = t.organization_id AND m.user_id = $2
+		WHERE t.id = $1`, taskID, userID, OrgRoleOwner).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// GetMembers returns a page of the organization's members by user id.
+func (pg *PostgresOrganizationStore) GetMembers(orgID int64, page utils.PageParams) ([]OrgMember, utils.PageBounds, error) {
+	ks := keyset{id: "m.user_id"}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT m.organization_id, m.user_id, u.username, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, cond, orderBy, len(args)+2)
+
+	args = append([]any{orgID}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[OrgMember]
+	for rows.Next() {
+		var item keyed[OrgMember]
+		m := &item.row
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = m.UserID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	members, bounds := keysetPage(items, page)
+	return members, bounds, nil
+}
+
+// lockMember locks the organization, so concurrent changes can not take
+// away its last owner, and returns the member's current role. It returns
+// sql.ErrNoRows when the user is not a member.
+func lockMember(tx *sql.Tx, orgID, userID int64) (OrgRole, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	var role OrgRole
+	err = tx.QueryRow(`
+		SELECT role FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+	return role, err
+}
+
+// isLastOwner reports whether the organization has no owner but userID.
+func isLastOwner(tx *sql.Tx, orgID, userID int64) (bool, error) {
+	var others bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM organization_members
+			WHERE organization_id = $1 AND role = $2 AND user_id <> $3
+		)`, orgID, OrgRoleOwner, userID).Scan(&others)
+	return !others, err
+}
+
+// SetMemberRole changes a member's role. It returns sql.ErrNoRows when the
+// user is not a member and ErrLastOrgOwner when it would leave the
+// organization without an owner.
+func (pg *PostgresOrganizationStore) SetMemberRole(orgID, userID int64, role OrgRole) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockMember(tx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == OrgRoleOwner && role != OrgRoleOwner {
+		last, err := isLastOwner(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if last {
+			return ErrLastOrgOwner
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE organization_members
+		SET role = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $2 AND user_id = $3`, role, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember takes a user out of the organization. It returns
+// sql.ErrNoRows when the user is not a member and ErrLastOrgOwner for the
+// last owner.
+func (pg *PostgresOrganizationStore) RemoveMember(orgID, userID int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockMember(tx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == OrgRoleOwner {
+		last, err := isLastOwner(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if last {
+			return ErrLastOrgOwner
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateInvitation invites inv.Username, or inv.Email when no username is
+// given, to the organization. It returns ErrInviteeNotFound for unknown
+// usernames, ErrAlreadyMember and ErrInvitationExists.
+func (pg *PostgresOrganizationStore) CreateInvitation(inv *OrgInvitation) (*OrgInvitation, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var email sql.NullString
+	if inv.Username != "" {
+		var userID int64
+		err = tx.QueryRow(`SELECT id FROM users WHERE username = $1`, inv.Username).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return nil, ErrInviteeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		inv.UserID = &userID
+		inv.Email = ""
+	} else {
+		// an email invitation is never tied to an account up front, only
+		// whoever verified the address can take it
+		inv.UserID = nil
+		email = sql.NullString{String: inv.Email, Valid: true}
+	}
+
+	var member bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM organization_members m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.organization_id = $1 AND (u.id = $2 OR LOWER(u.email) = LOWER($3))
+		)`, inv.OrganizationID, inv.UserID, email).Scan(&member)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, ErrAlreadyMember
+	}
+
+	// expired invitations do not block inviting the same person again
+	_, err = tx.Exec(`
+		UPDATE organization_invitations
+		SET status = $1
+		WHERE organization_id = $2 AND status = $3 AND expires_at <= $4`,
+		InvitationExpired, inv.OrganizationID, InvitationPending, now)
+	if err != nil {
+		return nil, err
+	}
+
+	inv.Status = InvitationPending
+	inv.ExpiresAt = now.Add(InvitationTTL)
+	err = tx.QueryRow(`
+		INSERT INTO organization_invitations (organization_id, user_id, email, role, status, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at`,
+		inv.OrganizationID, inv.UserID, email, inv.Role, inv.Status, inv.InvitedBy, inv.ExpiresAt, now).
+		Scan(&inv.ID, &inv.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`SELECT name FROM organizations WHERE id = $1`, inv.OrganizationID).Scan(&inv.OrganizationName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+const invitationSelect = `
+	SELECT
+		i.id,
+		i.organization_id,
+		o.name,
+		i.user_id,
+		COALESCE(u.username, ''),
+		COALESCE(i.email, ''),
+		i.role,
+		i.status,
+		i.invited_by,
+		i.expires_at,
+		i.responded_at,
+		i.created_at
+	FROM organization_invitations i
+	JOIN organizations o ON o.id = i.organization_id
+	LEFT JOIN users u ON u.id = i.user_id
+`
+
+func scanInvitation(row interface{ Scan(dest ...any) error }) (*OrgInvitation, error) {
+	var inv OrgInvitation
+	err := row.Scan(
+		&inv.ID,
+		&inv.OrganizationID,
+		&inv.OrganizationName,
+		&inv.UserID,
+		&inv.Username,
+		&inv.Email,
+		&inv.Role,
+		&inv.Status,
+		&inv.InvitedBy,
+		&inv.ExpiresAt,
+		&inv.RespondedAt,
+		&inv.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (pg *PostgresOrganizationStore) GetInvitationByID(id int64) (*OrgInvitation, error) {
+	inv, err := scanInvitation(pg.db.QueryRow(invitationSelect+` WHERE i.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+// GetOrganizationInvitations returns a page of the organization's open
+// invitations, newest first.
+func (pg *PostgresOrganizationStore) GetOrganizationInvitations(orgID int64, page utils.PageParams) ([]OrgInvitation, utils.PageBounds, error) {
+	ks := keyset{id: "i.id", desc: true}
+	cond, orderBy, args := ks.clause(page, 4)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`%s
+		WHERE i.organization_id = $1 AND i.status = $2 AND i.expires_at > $3 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, invitationSelect, cond, orderBy, len(args)+4)
+
+	args = append([]any{orgID, InvitationPending, pg.now()}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[OrgInvitation]
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[OrgInvitation]{row: *inv, id: inv.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	invitations, bounds := keysetPage(items, page)
+	return invitations, bounds, nil
+}
+
+// invitedUser matches the invitations addressed to a user: those naming the
+// user and those sent to the user's email once it is verified.
+const invitedUser = `(
+	i.user_id = $1
+	OR (i.user_id IS NULL AND LOWER(i.email) = (
+		SELECT LOWER(email) FROM users WHERE id = $1 AND email_verified_at IS NOT NULL
+	))
+)`
+
+// GetUserInvitations returns a page of the user's open invitations, newest
+// first.
+func (pg *PostgresOrganizationStore) GetUserInvitations(userID int64, page utils.PageParams) ([]OrgInvitation, utils.PageBounds, error) {
+	ks := keyset{id: "i.id", desc: true}
+	cond, orderBy, args := ks.clause(page, 4)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`%s
+		WHERE %s AND i.status = $2 AND i.expires_at > $3 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, invitationSelect, invitedUser, cond, orderBy, len(args)+4)
+
+	args = append([]any{userID, InvitationPending, pg.now()}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[OrgInvitation]
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[OrgInvitation]{row: *inv, id: inv.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	invitations, bounds := keysetPage(items, page)
+	return invitations, bounds, nil
+}
+
+// RevokeInvitation withdraws an open invitation. It returns
+// ErrInvitationClosed when it was already answered or revoked.
+func (pg *PostgresOrganizationStore) RevokeInvitation(id int64) error {
+	result, err := pg.db.Exec(`
+		UPDATE organization_invitations
+		SET status = $1, responded_at = $2
+		WHERE id = $3 AND status = $4`, InvitationRevoked, pg.now(), id, InvitationPending)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationClosed
+	}
+
+	return nil
+}
+
+// RespondToInvitation accepts or declines an invitation addressed to the
+// user. Accepting makes the user a member with the invitation's role, a user
+// who already is one keeps their role. It returns sql.ErrNoRows when the
+// invitation is not the user's, ErrEmailNotVerified when it was sent to
+// the user's unverified email, ErrInvitationClosed and ErrInvitationExpired.
+func (pg *PostgresOrganizationStore) RespondToInvitation(id, userID int64, accept bool) (*OrgInvitation, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		status    InvitationStatus
+		expiresAt time.Time
+		mine      bool
+		verified  bool
+	)
+	err = tx.QueryRow(`
+		SELECT
+			i.status,
+			i.expires_at,
+			COALESCE(i.user_id = $2 OR (i.user_id IS NULL AND LOWER(i.email) = LOWER(u.email)), FALSE),
+			u.email_verified_at IS NOT NULL
+		FROM organization_invitations i, users u
+		WHERE i.id = $1 AND u.id = $2
+		FOR UPDATE OF i`, id, userID).Scan(&status, &expiresAt, &mine, &verified)
+	if err != nil {
+		return nil, err
+	}
+	if !mine {
+		return nil, sql.ErrNoRows
+	}
+
+	inv, err := scanInvitation(tx.QueryRow(invitationSelect+` WHERE i.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	if inv.UserID == nil && !verified {
+		return nil, ErrEmailNotVerified
+	}
+	if status != InvitationPending {
+		return nil, ErrInvitationClosed
+	}
+	if !now.Before(expiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	inv.Status = InvitationDeclined
+	if accept {
+		inv.Status = InvitationAccepted
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (organization_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, user_id) DO NOTHING`, inv.OrganizationID, userID, inv.Role)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	inv.RespondedAt = &now
+	_, err = tx.Exec(`
+		UPDATE organization_invitations
+		SET status = $1, responded_at = $2
+		WHERE id = $3`, inv.Status, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
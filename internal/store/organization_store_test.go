@@ -0,0 +1,146 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBOrganization(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE organizations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestOrgRoleCan(t *testing.T) {
+	assert.True(t, OrgRoleOwner.Can(OrgRoleManager))
+	assert.True(t, OrgRoleReviewer.Can(OrgRoleReviewer))
+	assert.False(t, OrgRoleReviewer.Can(OrgRoleManager))
+	assert.False(t, OrgRoleViewer.Can(OrgRoleReviewer))
+	assert.False(t, OrgRole("").Can(OrgRoleViewer))
+	assert.False(t, OrgRole("admin").IsValid())
+}
+
+func TestOrganizationStore(t *testing.T) {
+	db := setupTestDBOrganization(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	orgStore := NewPostgresOrganizationStore(db, fixedClock(&now))
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+
+	var users []*User
+	for _, name := range []string{"org-owner", "org-member", "org-invitee"} {
+		user := &User{Username: name, Email: name + "@gmail.com"}
+		user.PasswordHash.Set("password123")
+		user, err := userStore.CreateUser(user)
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	owner, member, invitee := users[0], users[1], users[2]
+
+	org, err := orgStore.CreateOrganization(&Organization{Name: "Acme"}, owner.ID)
+	require.NoError(t, err)
+
+	role, err := orgStore.GetMemberRole(org.ID, owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, OrgRoleOwner, role)
+
+	t.Run("invite by username and accept", func(t *testing.T) {
+		inv, err := orgStore.CreateInvitation(&OrgInvitation{OrganizationID: org.ID, Username: member.Username, Role: OrgRoleReviewer, InvitedBy: &owner.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "Acme", inv.OrganizationName)
+
+		_, err = orgStore.CreateInvitation(&OrgInvitation{OrganizationID: org.ID, Username: member.Username, Role: OrgRoleViewer, InvitedBy: &owner.ID})
+		assert.Equal(t, ErrInvitationExists, err)
+
+		_, err = orgStore.RespondToInvitation(inv.ID, invitee.ID, true)
+		assert.Equal(t, sql.ErrNoRows, err)
+
+		accepted, err := orgStore.RespondToInvitation(inv.ID, member.ID, true)
+		require.NoError(t, err)
+		assert.Equal(t, InvitationAccepted, accepted.Status)
+
+		role, err := orgStore.GetMemberRole(org.ID, member.ID)
+		require.NoError(t, err)
+		assert.Equal(t, OrgRoleReviewer, role)
+
+		_, err = orgStore.CreateInvitation(&OrgInvitation{OrganizationID: org.ID, Username: member.Username, Role: OrgRoleViewer, InvitedBy: &owner.ID})
+		assert.Equal(t, ErrAlreadyMember, err)
+	})
+
+	t.Run("email invitations need a verified email", func(t *testing.T) {
+		inv, err := orgStore.CreateInvitation(&OrgInvitation{OrganizationID: org.ID, Email: "ORG-INVITEE@gmail.com", Role: OrgRoleViewer, InvitedBy: &owner.ID})
+		require.NoError(t, err)
+
+		_, err = orgStore.RespondToInvitation(inv.ID, invitee.ID, true)
+		assert.Equal(t, ErrEmailNotVerified, err)
+
+		require.NoError(t, userStore.MarkEmailVerified(invitee.ID))
+		mine, _, err := orgStore.GetUserInvitations(invitee.ID, utils.PageParams{Limit: 10, Sort: string(TaskSortNewest)})
+		require.NoError(t, err)
+		require.Len(t, mine, 1)
+		assert.Equal(t, inv.ID, mine[0].ID)
+
+		declined, err := orgStore.RespondToInvitation(inv.ID, invitee.ID, false)
+		require.NoError(t, err)
+		assert.Equal(t, InvitationDeclined, declined.Status)
+
+		_, err = orgStore.RespondToInvitation(inv.ID, invitee.ID, true)
+		assert.Equal(t, ErrInvitationClosed, err)
+	})
+
+	t.Run("invitations expire", func(t *testing.T) {
+		inv, err := orgStore.CreateInvitation(&OrgInvitation{OrganizationID: org.ID, Username: invitee.Username, Role: OrgRoleViewer, InvitedBy: &owner.ID})
+		require.NoError(t, err)
+
+		now = now.Add(InvitationTTL + time.Hour)
+		_, err = orgStore.RespondToInvitation(inv.ID, invitee.ID, true)
+		assert.Equal(t, ErrInvitationExpired, err)
+	})
+
+	t.Run("task roles follow membership", func(t *testing.T) {
+		task, err := taskStore.CreateTask(&Task{Title: "Team task", UserID: owner.ID, OrganizationID: &org.ID, DueDate: now.AddDate(0, 1, 0)})
+		require.NoError(t, err)
+		personal, err := taskStore.CreateTask(&Task{Title: "Personal task", UserID: member.ID, DueDate: now.AddDate(0, 1, 0)})
+		require.NoError(t, err)
+
+		role, err := orgStore.GetTaskRole(int64(task.ID), member.ID)
+		require.NoError(t, err)
+		assert.Equal(t, OrgRoleReviewer, role)
+
+		role, err = orgStore.GetTaskRole(int64(personal.ID), member.ID)
+		require.NoError(t, err)
+		assert.Equal(t, OrgRoleOwner, role)
+
+		role, err = orgStore.GetTaskRole(int64(personal.ID), owner.ID)
+		require.NoError(t, err)
+		assert.Empty(t, role)
+	})
+
+	t.Run("the last owner stays", func(t *testing.T) {
+		assert.Equal(t, ErrLastOrgOwner, orgStore.RemoveMember(org.ID, owner.ID))
+		assert.Equal(t, ErrLastOrgOwner, orgStore.SetMemberRole(org.ID, owner.ID, OrgRoleManager))
+		assert.Equal(t, sql.ErrNoRows, orgStore.RemoveMember(org.ID, invitee.ID))
+
+		require.NoError(t, orgStore.SetMemberRole(org.ID, member.ID, OrgRoleOwner))
+		require.NoError(t, orgStore.RemoveMember(org.ID, owner.ID))
+
+		role, err := orgStore.GetMemberRole(org.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Empty(t, role)
+	})
+}
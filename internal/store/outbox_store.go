@@ -0,0 +1,193 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OutboxEventType is what an outbox event reports.
+type OutboxEventType string
+
+// The events of a task's lifecycle.
+const (
+	OutboxTaskCreated   OutboxEventType = "task.created"
+	OutboxTaskUpdated   OutboxEventType = "task.updated"
+	OutboxTaskPublished OutboxEventType = "task.published"
+	OutboxTaskDeleted   OutboxEventType = "task.deleted"
+)
+
+// The events of a task's participations, named like their webhooks.
+const (
+	OutboxTaskJoined    = OutboxEventType(WebhookTaskJoined)
+	OutboxTaskSubmitted = OutboxEventType(WebhookTaskSubmitted)
+	OutboxTaskCompleted = OutboxEventType(WebhookTaskCompleted)
+	OutboxTaskRejected  = OutboxEventType(WebhookTaskRejected)
+	OutboxRewardCreated = OutboxEventType(WebhookRewardCreated)
+)
+
+// AggregateTask is the aggregate type of every task event, its events are
+// published in the order they were written.
+const AggregateTask = "task"
+
+// OutboxRetention is how long published events are kept.
+const OutboxRetention = 7 * 24 * time.Hour
+
+// OutboxEvent is a change that was committed and has to be told to the
+// subscribers of the event bus. It is published at least once.
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Type          OutboxEventType `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	// Attempts counts the publications, including the current one.
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxTaskData is the payload of the task events.
+type OutboxTaskData struct {
+	TaskID int64 `json:"task_id"`
+	// UserID is the participant in the events of a participation, the
+	// task's owner otherwise.
+	UserID     int64   `json:"user_id"`
+	RewardUSDT float64 `json:"reward_usdt,omitempty"`
+	// DurationSeconds is the time from joining to verification, set on
+	// task.completed events.
+	DurationSeconds int64 `json:"duration_seconds,omitempty"`
+}
+
+// Decode unmarshals the event's payload into v.
+func (e *OutboxEvent) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+type PostgresOutboxStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresOutboxStore(db *sql.DB, clock Clock) *PostgresOutboxStore {
+	return &PostgresOutboxStore{db: db, now: clock}
+}
+
+type OutboxStore interface {
+	ClaimOutboxEvents(limit int, lease time.Duration) ([]*OutboxEvent, error)
+	MarkOutboxEventPublished(id int64) error
+	RecordOutboxFailure(id int64, reason string, retryAt time.Time) error
+	DeleteOldOutboxEvents() (int64, error)
+}
+
+// enqueueOutboxEvent writes an event to the outbox, due at now. It runs in
+// the transaction of the change it reports, so only committed changes are
+// published.
+func enqueueOutboxEvent(q dbtx, aggregateType string, aggregateID int64, eventType OutboxEventType, payload any, now time.Time) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id
+	`
+	var id int64
+	err = q.QueryRow(query, aggregateType, aggregateID, eventType, data, now).Scan(&id)
+	return id, err
+}
+
+// enqueueTaskEvent writes an event of the task to the outbox and returns its
+// id.
+func enqueueTaskEvent(q dbtx, eventType OutboxEventType, data OutboxTaskData, now time.Time) (int64, error) {
+	return enqueueOutboxEvent(q, AggregateTask, data.TaskID, eventType, data, now)
+}
+
+// ClaimOutboxEvents returns up to limit events that are due and leases them
+// to the caller, oldest first. Only the oldest unpublished event of an
+// aggregate is ever claimed, so the events of an aggregate are published
+// one after the other and in order. An event whose outcome is not recorded
+// within the lease is claimed again.
+func (pg *PostgresOutboxStore) ClaimOutboxEvents(limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	now := pg.now()
+	query := `
+		WITH due AS (
+			SELECT e.id
+			FROM outbox_events e
+			WHERE e.published_at IS NULL
+				AND e.next_attempt_at <= $1
+				AND NOT EXISTS (
+					SELECT 1
+					FROM outbox_events earlier
+					WHERE earlier.aggregate_type = e.aggregate_type
+						AND earlier.aggregate_id = e.aggregate_id
+						AND earlier.published_at IS NULL
+						AND earlier.id < e.id
+				)
+			ORDER BY e.id
+			LIMIT $2
+			FOR UPDATE OF e SKIP LOCKED
+		)
+		UPDATE outbox_events e
+		SET attempts = e.attempts + 1, next_attempt_at = $3
+		FROM due
+		WHERE e.id = due.id
+		RETURNING e.id, e.aggregate_type, e.aggregate_id, e.event_type, e.payload, e.attempts, e.created_at
+	`
+	rows, err := pg.db.Query(query, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		err = rows.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.Type, &e.Payload, &e.Attempts, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING keeps no order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// MarkOutboxEventPublished completes a claimed event, which lets the next
+// event of its aggregate be claimed.
+func (pg *PostgresOutboxStore) MarkOutboxEventPublished(id int64) error {
+	query := `UPDATE outbox_events SET published_at = $1, last_error = '' WHERE id = $2`
+	_, err := pg.db.Exec(query, pg.now(), id)
+	return err
+}
+
+// RecordOutboxFailure schedules a claimed event to be published again at
+// retryAt, the later events of its aggregate wait for it.
+func (pg *PostgresOutboxStore) RecordOutboxFailure(id int64, reason string, retryAt time.Time) error {
+	// the reason often quotes what failed, Postgres only stores valid UTF-8
+	// without NUL bytes
+	reason = strings.ToValidUTF8(strings.ReplaceAll(reason, "\x00", ""), "\uFFFD")
+
+	query := `UPDATE outbox_events SET next_attempt_at = $1, last_error = $2 WHERE id = $3`
+	_, err := pg.db.Exec(query, retryAt, reason, id)
+	return err
+}
+
+// DeleteOldOutboxEvents deletes the events published more than
+// OutboxRetention ago and returns how many it deleted.
+func (pg *PostgresOutboxStore) DeleteOldOutboxEvents() (int64, error) {
+	query := `DELETE FROM outbox_events WHERE published_at < $1`
+	res, err := pg.db.Exec(query, pg.now().Add(-OutboxRetention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBOutbox(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE outbox_events, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestOutboxStore(t *testing.T) {
+	db := setupTestDBOutbox(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	outboxStore := NewPostgresOutboxStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, fixedClock(&now))
+
+	user := &User{Username: "outbox-creator", Email: "outbox-creator@gmail.com"}
+	user.PasswordHash.Set("password123")
+	user, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	first, err := taskStore.CreateTask(&Task{Title: "First", UserID: user.ID, DueDate: now.AddDate(0, 1, 0), Status: TaskStatusDraft})
+	require.NoError(t, err)
+	second, err := taskStore.CreateTask(&Task{Title: "Second", UserID: user.ID, DueDate: now.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	require.NoError(t, taskStore.EditTask(&Task{ID: first.ID, Title: "First, edited"}, user.ID))
+	require.NoError(t, taskStore.PublishTask(int64(first.ID)))
+
+	// a dry run is rolled back with its events
+	_, err = taskStore.CreateTasks([]*Task{{Title: "Dry", UserID: user.ID, DueDate: now.AddDate(0, 1, 0)}}, true)
+	require.NoError(t, err)
+
+	claim := func(t *testing.T) []*OutboxEvent {
+		events, err := outboxStore.ClaimOutboxEvents(10, time.Minute)
+		require.NoError(t, err)
+		return events
+	}
+
+	t.Run("only the oldest event of a task is claimed", func(t *testing.T) {
+		events := claim(t)
+		require.Len(t, events, 2)
+		assert.Equal(t, OutboxTaskCreated, events[0].Type)
+		assert.Equal(t, int64(first.ID), events[0].AggregateID)
+		assert.Equal(t, int64(second.ID), events[1].AggregateID)
+		assert.Equal(t, 1, events[0].Attempts)
+		assert.Equal(t, now, events[0].CreatedAt.UTC())
+
+		var data OutboxTaskData
+		require.NoError(t, events[0].Decode(&data))
+		assert.Equal(t, OutboxTaskData{TaskID: int64(first.ID), UserID: user.ID}, data)
+
+		// leased, and the later events wait for them
+		assert.Empty(t, claim(t))
+
+		require.NoError(t, outboxStore.MarkOutboxEventPublished(events[0].ID))
+		require.NoError(t, outboxStore.RecordOutboxFailure(events[1].ID, "not now\x00", now.Add(time.Minute)))
+	})
+
+	t.Run("published events let the next one through", func(t *testing.T) {
+		events := claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, OutboxTaskUpdated, events[0].Type)
+		require.NoError(t, outboxStore.MarkOutboxEventPublished(events[0].ID))
+
+		events = claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, OutboxTaskPublished, events[0].Type)
+		require.NoError(t, outboxStore.MarkOutboxEventPublished(events[0].ID))
+	})
+
+	t.Run("failed events are retried", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		events := claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(second.ID), events[0].AggregateID)
+		assert.Equal(t, 2, events[0].Attempts)
+		require.NoError(t, outboxStore.MarkOutboxEventPublished(events[0].ID))
+	})
+
+	t.Run("deletes are published too", func(t *testing.T) {
+		require.NoError(t, taskStore.DeleteTask(int64(second.ID)))
+
+		events := claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, OutboxTaskDeleted, events[0].Type)
+		require.NoError(t, outboxStore.MarkOutboxEventPublished(events[0].ID))
+	})
+
+	t.Run("old events are trimmed", func(t *testing.T) {
+		now = now.Add(OutboxRetention + time.Hour)
+		deleted, err := outboxStore.DeleteOldOutboxEvents()
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), deleted)
+	})
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// keyset describes the ordering of a keyset-paginated list. Rows are ordered
+// by key, then by id to break ties, both in the same direction.
+type keyset struct {
+	// key is the SQL expression of the sort key, empty to order by id alone.
+	// It must not be NULL, wrap nullable columns in COALESCE.
+	key string
+	// cast is the type the key's text form is read back as, e.g. timestamptz.
+	cast string
+	id   string
+	desc bool
+}
+
+// keyColumn is the select expression for the key's text form, which is what
+// cursors carry.
+func (k keyset) keyColumn() string {
+	if k.key == "" {
+		return "''"
+	}
+	return fmt.Sprintf("(%s)::text", k.key)
+}
+
+// clause returns the condition selecting the rows past p's cursor and the
+// ORDER BY reading them in cursor direction. Placeholders are numbered from
+// argCount. cond is empty on the first page.
+func (k keyset) clause(p utils.PageParams, argCount int) (cond, orderBy string, args []any) {
+	desc := k.desc
+	if p.Cursor != nil && p.Cursor.Backward {
+		desc = !desc
+	}
+
+	dir, op := "ASC", ">"
+	if desc {
+		dir, op = "DESC", "<"
+	}
+
+	if k.key == "" {
+		orderBy = fmt.Sprintf("%s %s", k.id, dir)
+	} else {
+		orderBy = fmt.Sprintf("%s %s, %s %s", k.key, dir, k.id, dir)
+	}
+
+	if p.Cursor == nil {
+		return "", orderBy, nil
+	}
+
+	if k.key == "" {
+		cond = fmt.Sprintf("%s %s $%d", k.id, op, argCount)
+		return cond, orderBy, []any{p.Cursor.ID}
+	}
+
+	cond = fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", k.key, k.id, op, argCount, k.cast, argCount+1)
+	return cond, orderBy, []any{p.Cursor.Key, p.Cursor.ID}
+}
+
+// keyed is a row together with the key and id its cursor is made of.
+type keyed[T any] struct {
+	row T
+	key string
+	id  int64
+}
+
+// keysetPage trims rows read with LIMIT p.Limit+1 down to the page, puts
+// them back in list order when reading backwards and returns the bounds the
+// handler builds cursors from.
+func keysetPage[T any](items []keyed[T], p utils.PageParams) ([]T, utils.PageBounds) {
+	var bounds utils.PageBounds
+
+	if int64(len(items)) > p.Limit {
+		bounds.HasMore = true
+		items = items[:p.Limit]
+	}
+
+	if p.Cursor != nil && p.Cursor.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	rows := make([]T, len(items))
+	for i, item := range items {
+		rows[i] = item.row
+	}
+
+	if len(items) > 0 {
+		first := utils.Cursor{Sort: p.Sort, Key: items[0].key, ID: items[0].id}
+		last := utils.Cursor{Sort: p.Sort, Key: items[len(items)-1].key, ID: items[len(items)-1].id}
+		bounds.First, bounds.Last = &first, &last
+	}
+
+	return rows, bounds
+}
@@ -0,0 +1,520 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type ParticipationStatus string
+
+const (
+	ParticipationJoined    ParticipationStatus = "joined"
+	ParticipationSubmitted ParticipationStatus = "submitted"
+	ParticipationVerified  ParticipationStatus = "verified"
+	ParticipationRejected  ParticipationStatus = "rejected"
+)
+
+var (
+	ErrAlreadyJoined = errors.New("user already joined this task for the current period")
+	ErrTaskClosed    = errors.New("task is past its due date")
+	ErrTaskFull      = errors.New("task has reached its maximum number of participants")
+	ErrStepLocked    = errors.New("previous quest steps must be verified first")
+	ErrNotReviewable = errors.New("participation has already been reviewed")
+)
+
+type Participation struct {
+	ID          int64               `json:"id"`
+	TaskID      int64               `json:"task_id"`
+	UserID      int64               `json:"user_id"`
+	PeriodStart time.Time           `json:"period_start"`
+	Status      ParticipationStatus `json:"status"`
+	TaskOwnerID int64               `json:"task_owner_id"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type TaskStreak struct {
+	TaskID          int64     `json:"task_id"`
+	UserID          int64     `json:"user_id"`
+	CurrentStreak   int64     `json:"current_streak"`
+	LongestStreak   int64     `json:"longest_streak"`
+	LastPeriodStart time.Time `json:"last_period_start"`
+}
+
+type PostgresParticipationStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresParticipationStore(db *sql.DB, clock Clock) *PostgresParticipationStore {
+	return &PostgresParticipationStore{db: db, now: clock}
+}
+
+type ParticipationStore interface {
+	Join(taskID, userID int64) (*Participation, *TaskStreak, error)
+	GetParticipationByID(id int64) (*Participation, error)
+	GetCurrentParticipation(taskID, userID int64) (*Participation, error)
+	GetTaskParticipations(taskID int64, status ParticipationStatus, page utils.PageParams) ([]Participation, utils.PageBounds, error)
+	UpdateParticipationStatus(id int64, status ParticipationStatus) error
+	VerifyParticipation(id, reviewerID int64) (*Reward, []QuestProgress, error)
+	RejectParticipation(id, reviewerID int64) error
+	GetStreak(taskID, userID int64) (*TaskStreak, error)
+}
+
+type taskSchedule struct {
+	ownerID        int64
+	status         TaskStatus
+	recurrence     Recurrence
+	loc            *time.Location
+	dueDate        sql.NullTime
+	maxParticipant sql.NullString
+	eligibility    EligibilityRules
+}
+
+func loadTaskSchedule(q dbtx, taskID int64, forUpdate bool) (*taskSchedule, error) {
+	query := `SELECT user_id, status, recurrence, recurrence_timezone, due_date, max_participant, eligibility FROM tasks WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	var s taskSchedule
+	var timezone string
+	err := q.QueryRow(query, taskID).Scan(&s.ownerID, &s.status, &s.recurrence, &timezone, &s.dueDate, &s.maxParticipant, &s.eligibility)
+	if err != nil {
+		return nil, err
+	}
+
+	s.loc, err = time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Join records that the user takes part in the task's current period. For
+// recurring tasks this is the user's check-in and advances their streak.
+func (pg *PostgresParticipationStore) Join(taskID, userID int64) (*Participation, *TaskStreak, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the task so concurrent joins see each other when checking capacity
+	schedule, err := loadTaskSchedule(tx, taskID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	// drafts do not exist for anyone but their owner yet
+	if schedule.status == TaskStatusDraft {
+		return nil, nil, sql.ErrNoRows
+	}
+	if schedule.dueDate.Valid && now.After(schedule.dueDate.Time) {
+		return nil, nil, ErrTaskClosed
+	}
+
+	unlocked, err := isTaskUnlocked(tx, taskID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !unlocked {
+		return nil, nil, ErrStepLocked
+	}
+
+	if len(schedule.eligibility) > 0 {
+		profile, err := loadEligibilityProfile(tx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if failures := schedule.eligibility.Evaluate(*profile, now); len(failures) > 0 {
+			return nil, nil, &IneligibleError{Failures: failures}
+		}
+	}
+
+	period := schedule.recurrence.PeriodStart(now, schedule.loc)
+
+	if schedule.maxParticipant.Valid {
+		max, err := strconv.ParseInt(schedule.maxParticipant.String, 10, 64)
+		if err == nil && max > 0 {
+			var count int64
+			err = tx.QueryRow(`SELECT COUNT(*) FROM task_participations WHERE task_id = $1 AND period_start = $2`, taskID, period).Scan(&count)
+			if err != nil {
+				return nil, nil, err
+			}
+			if count >= max {
+				return nil, nil, ErrTaskFull
+			}
+		}
+	}
+
+	p := &Participation{
+		TaskID:      taskID,
+		UserID:      userID,
+		PeriodStart: period,
+		Status:      ParticipationJoined,
+		TaskOwnerID: schedule.ownerID,
+	}
+	query := `
+		INSERT INTO task_participations (task_id, user_id, period_start, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (task_id, user_id, period_start) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, taskID, userID, period, p.Status, now).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrAlreadyJoined
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = recordTaskEvent(tx, &TaskEvent{TaskID: taskID, UserID: userID, Type: TaskEventJoin, OccurredAt: now})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var streak *TaskStreak
+	if schedule.recurrence.IsRecurring() {
+		streak, err = checkInStreak(tx, taskID, userID, period, schedule)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p, streak, nil
+}
+
+func checkInStreak(tx *sql.Tx, taskID, userID int64, period time.Time, schedule *taskSchedule) (*TaskStreak, error) {
+	s := TaskStreak{TaskID: taskID, UserID: userID}
+
+	query := `
+		SELECT current_streak, longest_streak, last_period_start
+		FROM task_streaks
+		WHERE task_id = $1 AND user_id = $2
+		FOR UPDATE
+	`
+	err := tx.QueryRow(query, taskID, userID).Scan(&s.CurrentStreak, &s.LongestStreak, &s.LastPeriodStart)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	s = advanceStreak(s, period, schedule.recurrence, schedule.loc)
+
+	query = `
+		INSERT INTO task_streaks (task_id, user_id, current_streak, longest_streak, last_period_start, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (task_id, user_id)
+		DO UPDATE SET
+			current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_period_start = EXCLUDED.last_period_start,
+			updated_at = NOW()
+	`
+	_, err = tx.Exec(query, taskID, userID, s.CurrentStreak, s.LongestStreak, s.LastPeriodStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+const participationSelect = `
+	SELECT p.id, p.task_id, p.user_id, p.period_start, p.status, t.user_id, p.created_at, p.updated_at
+	FROM task_participations p
+	JOIN tasks t ON t.id = p.task_id
+`
+
+func scanParticipation(row interface{ Scan(dest ...any) error }) (*Participation, error) {
+	var p Participation
+	err := row.Scan(&p.ID, &p.TaskID, &p.UserID, &p.PeriodStart, &p.Status, &p.TaskOwnerID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (pg *PostgresParticipationStore) GetParticipationByID(id int64) (*Participation, error) {
+	query := participationSelect + `WHERE p.id = $1`
+
+	p, err := scanParticipation(pg.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return p, err
+}
+
+// GetCurrentParticipation returns the user's participation in the task's
+// current period, or nil when they have not joined it yet.
+func (pg *PostgresParticipationStore) GetCurrentParticipation(taskID, userID int64) (*Participation, error) {
+	schedule, err := loadTaskSchedule(pg.db, taskID, false)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	period := schedule.recurrence.PeriodStart(pg.now(), schedule.loc)
+
+	query := participationSelect + `WHERE p.task_id = $1 AND p.user_id = $2 AND p.period_start = $3`
+
+	p, err := scanParticipation(pg.db.QueryRow(query, taskID, userID, period))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return p, err
+}
+
+// GetTaskParticipations returns a page of the task's participations in the
+// order they were made, optionally only those with the given status.
+func (pg *PostgresParticipationStore) GetTaskParticipations(taskID int64, status ParticipationStatus, page utils.PageParams) ([]Participation, utils.PageBounds, error) {
+	ks := keyset{key: "p.created_at", cast: "timestamptz", id: "p.id"}
+	cond, orderBy, args := ks.clause(page, 3)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT p.id, p.task_id, p.user_id, p.period_start, p.status, t.user_id, p.created_at, p.updated_at, %s
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE p.task_id = $1 AND ($2 = '' OR p.status = $2) %s
+		ORDER BY %s
+		LIMIT $%d
+	`, ks.keyColumn(), cond, orderBy, len(args)+3)
+
+	args = append([]any{taskID, status}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Participation]
+	for rows.Next() {
+		var item keyed[Participation]
+		p := &item.row
+		err := rows.Scan(&p.ID, &p.TaskID, &p.UserID, &p.PeriodStart, &p.Status, &p.TaskOwnerID, &p.CreatedAt, &p.UpdatedAt, &item.key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = p.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	participations, bounds := keysetPage(items, page)
+	return participations, bounds, nil
+}
+
+func (pg *PostgresParticipationStore) UpdateParticipationStatus(id int64, status ParticipationStatus) error {
+	query := `UPDATE task_participations SET status = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := pg.db.Exec(query, status, pg.now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// VerifyParticipation marks a participation as verified and, in the same
+// transaction, grants the task reward, advances the user's quests and
+// records the reviewer's decision in the audit log.
+func (pg *PostgresParticipationStore) VerifyParticipation(id, reviewerID int64) (*Reward, []QuestProgress, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	reward, progress, err := verifyParticipation(tx, id, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = closePendingSubmission(tx, id, SubmissionApproved, reviewerID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    reviewerID,
+		Action:     AuditParticipationVerified,
+		EntityType: AuditEntityParticipation,
+		EntityID:   id,
+		Details:    map[string]any{"reward_id": reward.ID},
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reward, progress, nil
+}
+
+// verifyParticipation does the work of VerifyParticipation inside tx, it is
+// shared with approving a proof submission.
+func verifyParticipation(tx *sql.Tx, id int64, now time.Time) (*Reward, []QuestProgress, error) {
+	var taskID, userID int64
+	var status ParticipationStatus
+	var joinedAt time.Time
+	var rewardUSDT float64
+	query := `
+		SELECT p.task_id, p.user_id, p.status, p.created_at, t.reward_usdt
+		FROM task_participations p
+		JOIN tasks t ON t.id = p.task_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`
+	err := tx.QueryRow(query, id).Scan(&taskID, &userID, &status, &joinedAt, &rewardUSDT)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status == ParticipationVerified {
+		return nil, nil, ErrNotReviewable
+	}
+
+	_, err = tx.Exec(`UPDATE task_participations SET status = $1, updated_at = $2 WHERE id = $3`, ParticipationVerified, now, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reward := &Reward{UserID: userID, TaskID: taskID}
+	err = insertReward(tx, reward)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	progress, err := advanceQuestProgress(tx, userID, taskID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = recordTaskEvent(tx, &TaskEvent{
+		TaskID:     taskID,
+		UserID:     userID,
+		Type:       TaskEventVerified,
+		RewardUSDT: rewardUSDT,
+		Duration:   now.Sub(joinedAt),
+		OccurredAt: now,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reward, progress, nil
+}
+
+// RejectParticipation marks a participation as rejected and records the
+// reviewer's decision in the audit log.
+func (pg *PostgresParticipationStore) RejectParticipation(id, reviewerID int64) error {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taskID, userID int64
+	var status ParticipationStatus
+	err = tx.QueryRow(`SELECT task_id, user_id, status FROM task_participations WHERE id = $1 FOR UPDATE`, id).Scan(&taskID, &userID, &status)
+	if err != nil {
+		return err
+	}
+	if status == ParticipationVerified {
+		return ErrNotReviewable
+	}
+
+	_, err = tx.Exec(`UPDATE task_participations SET status = $1, updated_at = $2 WHERE id = $3`, ParticipationRejected, now, id)
+	if err != nil {
+		return err
+	}
+
+	err = closePendingSubmission(tx, id, SubmissionRejected, reviewerID, now)
+	if err != nil {
+		return err
+	}
+
+	err = recordTaskEvent(tx, &TaskEvent{TaskID: taskID, UserID: userID, Type: TaskEventRejected, OccurredAt: now})
+	if err != nil {
+		return err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    reviewerID,
+		Action:     AuditParticipationRejected,
+		EntityType: AuditEntityParticipation,
+		EntityID:   id,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetStreak returns the user's streak on a recurring task as of now, or nil
+// when they never checked in.
+func (pg *PostgresParticipationStore) GetStreak(taskID, userID int64) (*TaskStreak, error) {
+	schedule, err := loadTaskSchedule(pg.db, taskID, false)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	s := TaskStreak{TaskID: taskID, UserID: userID}
+	query := `
+		SELECT current_streak, longest_streak, last_period_start
+		FROM task_streaks
+		WHERE task_id = $1 AND user_id = $2
+	`
+	err = pg.db.QueryRow(query, taskID, userID).Scan(&s.CurrentStreak, &s.LongestStreak, &s.LastPeriodStart)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s = currentStreak(s, pg.now(), schedule.recurrence, schedule.loc)
+	return &s, nil
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBParticipation(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE task_streaks, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+// fixedClock returns a Clock that can be moved forward by the test.
+func fixedClock(now *time.Time) Clock {
+	return func() time.Time { return *now }
+}
+
+func TestParticipationStore(t *testing.T) {
+	db := setupTestDBParticipation(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	user := &User{Username: "test-participation", Email: "test-participation@gmail.com"}
+	user.PasswordHash.Set("password123")
+	user, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	oneShot, err := taskStore.CreateTask(&Task{
+		Title:          "One shot",
+		UserID:         user.ID,
+		DueDate:        now.Add(48 * time.Hour),
+		MaxParticipant: "10",
+	})
+	require.NoError(t, err)
+
+	daily, err := taskStore.CreateTask(&Task{
+		Title:              "Daily check-in",
+		UserID:             user.ID,
+		DueDate:            now.AddDate(0, 1, 0),
+		Recurrence:         RecurrenceDaily,
+		RecurrenceTimezone: "Asia/Jakarta",
+	})
+	require.NoError(t, err)
+
+	t.Run("join one-shot task once", func(t *testing.T) {
+		p, streak, err := participationStore.Join(int64(oneShot.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ParticipationJoined, p.Status)
+		assert.Nil(t, streak)
+
+		_, _, err = participationStore.Join(int64(oneShot.ID), user.ID)
+		assert.Equal(t, ErrAlreadyJoined, err)
+	})
+
+	t.Run("tasks without a due date stay open", func(t *testing.T) {
+		open, err := taskStore.CreateTask(&Task{Title: "No deadline", UserID: user.ID})
+		require.NoError(t, err)
+
+		stored, err := taskStore.GetTaskByID(int64(open.ID))
+		require.NoError(t, err)
+		assert.True(t, stored.DueDate.IsZero())
+
+		p, _, err := participationStore.Join(int64(open.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ParticipationJoined, p.Status)
+	})
+
+	t.Run("daily check-ins build a streak", func(t *testing.T) {
+		_, streak, err := participationStore.Join(int64(daily.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), streak.CurrentStreak)
+
+		now = now.Add(24 * time.Hour)
+		_, streak, err = participationStore.Join(int64(daily.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), streak.CurrentStreak)
+
+		current, err := participationStore.GetCurrentParticipation(int64(daily.ID), user.ID)
+		require.NoError(t, err)
+		require.NotNil(t, current)
+	})
+
+	t.Run("missed period resets the streak", func(t *testing.T) {
+		now = now.Add(48 * time.Hour)
+
+		streak, err := participationStore.GetStreak(int64(daily.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), streak.CurrentStreak)
+		assert.Equal(t, int64(2), streak.LongestStreak)
+
+		_, streak, err = participationStore.Join(int64(daily.ID), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), streak.CurrentStreak)
+	})
+
+	t.Run("ineligible users are told which rules failed", func(t *testing.T) {
+		restricted, err := taskStore.CreateTask(&Task{
+			Title:   "Verified only",
+			UserID:  user.ID,
+			DueDate: now.AddDate(0, 1, 0),
+			Eligibility: EligibilityRules{
+				{Kind: EligibilityEmailVerified},
+				{Kind: EligibilityDenylist, UserIDs: []int64{user.ID}},
+			},
+		})
+		require.NoError(t, err)
+
+		_, _, err = participationStore.Join(int64(restricted.ID), user.ID)
+		failures, ok := IsIneligible(err)
+		require.True(t, ok)
+		require.Len(t, failures, 2)
+		assert.Equal(t, EligibilityEmailVerified, failures[0].Kind)
+
+		require.NoError(t, userStore.MarkEmailVerified(user.ID))
+		_, _, err = participationStore.Join(int64(restricted.ID), user.ID)
+		failures, _ = IsIneligible(err)
+		require.Len(t, failures, 1)
+		assert.Equal(t, EligibilityDenylist, failures[0].Kind)
+	})
+
+	t.Run("closed task", func(t *testing.T) {
+		_, _, err := participationStore.Join(int64(oneShot.ID), user.ID+1)
+		assert.Equal(t, ErrTaskClosed, err)
+	})
+
+	t.Run("update status", func(t *testing.T) {
+		participations, _, err := participationStore.GetTaskParticipations(int64(oneShot.ID), "", utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, participations, 1)
+
+		err = participationStore.UpdateParticipationStatus(participations[0].ID, ParticipationVerified)
+		require.NoError(t, err)
+
+		verified, _, err := participationStore.GetTaskParticipations(int64(oneShot.ID), ParticipationVerified, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, verified, 1)
+	})
+}
@@ -0,0 +1,383 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type Quest struct {
+	ID          int64       `json:"id"`
+	UserID      int64       `json:"user_id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	RewardUSDT  float64     `json:"reward_usdt"`
+	Steps       []QuestStep `json:"steps"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+type QuestStep struct {
+	Position int    `json:"position"`
+	TaskID   int64  `json:"task_id"`
+	Title    string `json:"title"`
+}
+
+type QuestProgress struct {
+	QuestID        int64      `json:"quest_id"`
+	UserID         int64      `json:"user_id"`
+	CompletedSteps int        `json:"completed_steps"`
+	TotalSteps     int        `json:"total_steps"`
+	NextTaskID     *int64     `json:"next_task_id"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	RewardUSDT     float64    `json:"reward_usdt"`
+}
+
+// ErrQuestTaskNotFound is returned when a step is not a task of the quest's
+// creator.
+var ErrQuestTaskNotFound = errors.New("task not found")
+
+type PostgresQuestStore struct {
+	db *sql.DB
+}
+
+func NewPostgresQuestStore(db *sql.DB) *PostgresQuestStore {
+	return &PostgresQuestStore{db: db}
+}
+
+type QuestStore interface {
+	CreateQuest(quest *Quest) (*Quest, error)
+	GetQuests(page utils.PageParams) ([]Quest, utils.PageBounds, error)
+	GetQuestByID(id int64) (*Quest, error)
+	DeleteQuest(id int64) error
+	GetQuestProgress(questID, userID int64) (*QuestProgress, error)
+}
+
+// CreateQuest stores a quest with its steps in the given order. Every step
+// must be a task owned by the quest's creator.
+func (pg *PostgresQuestStore) CreateQuest(quest *Quest) (*Quest, error) {
+	if quest.UserID == 0 {
+		return nil, errors.New("user id is required and can not be zero")
+	}
+	if len(quest.Steps) == 0 {
+		return nil, errors.New("quest needs at least one step")
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO quests (user_id, title, description, reward_usdt)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, quest.UserID, quest.Title, quest.Description, quest.RewardUSDT).
+		Scan(&quest.ID, &quest.CreatedAt, &quest.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range quest.Steps {
+		step := &quest.Steps[i]
+		step.Position = i + 1
+
+		err = tx.QueryRow(`SELECT title FROM tasks WHERE id = $1 AND user_id = $2`, step.TaskID, quest.UserID).Scan(&step.Title)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: id %d", ErrQuestTaskNotFound, step.TaskID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`INSERT INTO quest_steps (quest_id, task_id, position) VALUES ($1, $2, $3)`, quest.ID, step.TaskID, step.Position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return quest, nil
+}
+
+func getQuestSteps(q dbtx, questID int64) ([]QuestStep, error) {
+	query := `
+		SELECT qs.position, qs.task_id, t.title
+		FROM quest_steps qs
+		JOIN tasks t ON t.id = qs.task_id
+		WHERE qs.quest_id = $1
+		ORDER BY qs.position
+	`
+
+	rows, err := q.Query(query, questID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []QuestStep{}
+	for rows.Next() {
+		var step QuestStep
+		if err := rows.Scan(&step.Position, &step.TaskID, &step.Title); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
+
+// GetQuests returns a page of quests in the order they were created, each
+// with its steps.
+func (pg *PostgresQuestStore) GetQuests(page utils.PageParams) ([]Quest, utils.PageBounds, error) {
+	ks := keyset{id: "id"}
+	cond, orderBy, args := ks.clause(page, 1)
+	if cond != "" {
+		cond = "WHERE " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, title, COALESCE(description, ''), reward_usdt, created_at, updated_at
+		FROM quests
+		%s
+		ORDER BY %s
+		LIMIT $%d
+	`, cond, orderBy, len(args)+1)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Quest]
+	for rows.Next() {
+		var item keyed[Quest]
+		q := &item.row
+		if err := rows.Scan(&q.ID, &q.UserID, &q.Title, &q.Description, &q.RewardUSDT, &q.CreatedAt, &q.UpdatedAt); err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = q.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	quests, bounds := keysetPage(items, page)
+	for i := range quests {
+		quests[i].Steps, err = getQuestSteps(pg.db, quests[i].ID)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+	}
+
+	return quests, bounds, nil
+}
+
+func (pg *PostgresQuestStore) GetQuestByID(id int64) (*Quest, error) {
+	query := `
+		SELECT id, user_id, title, COALESCE(description, ''), reward_usdt, created_at, updated_at
+		FROM quests
+		WHERE id = $1
+	`
+
+	var q Quest
+	err := pg.db.QueryRow(query, id).Scan(&q.ID, &q.UserID, &q.Title, &q.Description, &q.RewardUSDT, &q.CreatedAt, &q.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	q.Steps, err = getQuestSteps(pg.db, q.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+func (pg *PostgresQuestStore) DeleteQuest(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM quests WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("quest with id %d not found", id)
+	}
+
+	return nil
+}
+
+// computeQuestProgress works out how many leading steps of the quest the
+// user has verified. A later step never counts before its prerequisites.
+func computeQuestProgress(q dbtx, questID, userID int64) (*QuestProgress, error) {
+	query := `
+		SELECT qs.task_id, EXISTS (
+			SELECT 1 FROM task_participations p
+			WHERE p.task_id = qs.task_id AND p.user_id = $2 AND p.status = 'verified'
+		)
+		FROM quest_steps qs
+		WHERE qs.quest_id = $1
+		ORDER BY qs.position
+	`
+
+	rows, err := q.Query(query, questID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := &QuestProgress{QuestID: questID, UserID: userID}
+	blocked := false
+	for rows.Next() {
+		var taskID int64
+		var verified bool
+		if err := rows.Scan(&taskID, &verified); err != nil {
+			return nil, err
+		}
+
+		progress.TotalSteps++
+		if blocked {
+			continue
+		}
+		if verified {
+			progress.CompletedSteps++
+			continue
+		}
+		blocked = true
+		progress.NextTaskID = &taskID
+	}
+
+	return progress, rows.Err()
+}
+
+func (pg *PostgresQuestStore) GetQuestProgress(questID, userID int64) (*QuestProgress, error) {
+	progress, err := computeQuestProgress(pg.db, questID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if progress.TotalSteps == 0 {
+		return nil, nil
+	}
+
+	var completedAt sql.NullTime
+	err = pg.db.QueryRow(`SELECT completed_at, reward_usdt FROM quest_progress WHERE quest_id = $1 AND user_id = $2`, questID, userID).
+		Scan(&completedAt, &progress.RewardUSDT)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if completedAt.Valid {
+		progress.CompletedAt = &completedAt.Time
+	}
+
+	return progress, nil
+}
+
+// isTaskUnlocked reports whether every quest step that comes before taskID
+// has been verified for the user. Tasks outside any quest are always unlocked.
+func isTaskUnlocked(q dbtx, taskID, userID int64) (bool, error) {
+	query := `
+		SELECT NOT EXISTS (
+			SELECT 1
+			FROM quest_steps qs
+			JOIN quest_steps prev ON prev.quest_id = qs.quest_id AND prev.position < qs.position
+			WHERE qs.task_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM task_participations p
+				WHERE p.task_id = prev.task_id AND p.user_id = $2 AND p.status = 'verified'
+			)
+		)
+	`
+
+	var unlocked bool
+	err := q.QueryRow(query, taskID, userID).Scan(&unlocked)
+	return unlocked, err
+}
+
+// advanceQuestProgress refreshes the user's progress on every quest taskID is
+// a step of, and pays the quest reward the first time a quest is completed,
+// as a reward of taskID.
+func advanceQuestProgress(tx *sql.Tx, userID, taskID int64, at time.Time) ([]QuestProgress, error) {
+	rows, err := tx.Query(`SELECT quest_id FROM quest_steps WHERE task_id = $1 ORDER BY quest_id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	var questIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		questIDs = append(questIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	updated := []QuestProgress{}
+	for _, questID := range questIDs {
+		progress, err := computeQuestProgress(tx, questID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		var completedAt sql.NullTime
+		err = tx.QueryRow(`SELECT completed_at FROM quest_progress WHERE quest_id = $1 AND user_id = $2 FOR UPDATE`, questID, userID).Scan(&completedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		if !completedAt.Valid && progress.CompletedSteps == progress.TotalSteps {
+			reward := &Reward{UserID: userID, TaskID: taskID, QuestID: &questID}
+			err = insertReward(tx, reward)
+			if err != nil {
+				return nil, err
+			}
+
+			completedAt = sql.NullTime{Time: at, Valid: true}
+			progress.RewardUSDT = reward.RewardUSDT
+		}
+		if completedAt.Valid {
+			progress.CompletedAt = &completedAt.Time
+		}
+
+		query := `
+			INSERT INTO quest_progress (quest_id, user_id, completed_steps, completed_at, reward_usdt, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+			ON CONFLICT (quest_id, user_id)
+			DO UPDATE SET
+				completed_steps = EXCLUDED.completed_steps,
+				completed_at = COALESCE(quest_progress.completed_at, EXCLUDED.completed_at),
+				reward_usdt = CASE WHEN quest_progress.completed_at IS NULL THEN EXCLUDED.reward_usdt ELSE quest_progress.reward_usdt END,
+				updated_at = NOW()
+		`
+		_, err = tx.Exec(query, questID, userID, progress.CompletedSteps, completedAt, progress.RewardUSDT)
+		if err != nil {
+			return nil, err
+		}
+
+		updated = append(updated, *progress)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBQuest(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE quest_progress, quest_steps, quests, leaderboard_scores, rewards, task_events, outbox_events, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestQuestStore(t *testing.T) {
+	db := setupTestDBQuest(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	questStore := NewPostgresQuestStore(db)
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	owner := &User{Username: "quest-owner", Email: "quest-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	player := &User{Username: "quest-player", Email: "quest-player@gmail.com"}
+	player.PasswordHash.Set("password123")
+	player, err = userStore.CreateUser(player)
+	require.NoError(t, err)
+
+	var taskIDs []int64
+	for _, title := range []string{"Follow us", "Repost", "Join Discord"} {
+		task, err := taskStore.CreateTask(&Task{
+			Title:      title,
+			UserID:     owner.ID,
+			RewardUSDT: 1,
+			DueDate:    now.AddDate(0, 1, 0),
+		})
+		require.NoError(t, err)
+		taskIDs = append(taskIDs, int64(task.ID))
+	}
+
+	quest, err := questStore.CreateQuest(&Quest{
+		UserID:     owner.ID,
+		Title:      "Join the community",
+		RewardUSDT: 5,
+		Steps:      []QuestStep{{TaskID: taskIDs[0]}, {TaskID: taskIDs[1]}, {TaskID: taskIDs[2]}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, quest.Steps[2].Position)
+
+	t.Run("steps of another user's tasks are rejected", func(t *testing.T) {
+		_, err := questStore.CreateQuest(&Quest{
+			UserID: player.ID,
+			Title:  "Not mine",
+			Steps:  []QuestStep{{TaskID: taskIDs[0]}},
+		})
+		assert.ErrorIs(t, err, ErrQuestTaskNotFound)
+	})
+
+	t.Run("later steps stay locked until earlier ones are verified", func(t *testing.T) {
+		_, _, err := participationStore.Join(taskIDs[1], player.ID)
+		assert.Equal(t, ErrStepLocked, err)
+
+		p, _, err := participationStore.Join(taskIDs[0], player.ID)
+		require.NoError(t, err)
+
+		_, _, err = participationStore.Join(taskIDs[1], player.ID)
+		assert.Equal(t, ErrStepLocked, err)
+
+		_, progress, err := participationStore.VerifyParticipation(p.ID, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, progress, 1)
+		assert.Equal(t, 1, progress[0].CompletedSteps)
+		assert.Equal(t, taskIDs[1], *progress[0].NextTaskID)
+		assert.Nil(t, progress[0].CompletedAt)
+
+		_, _, err = participationStore.Join(taskIDs[1], player.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("completing every step pays the quest reward once", func(t *testing.T) {
+		p, err := participationStore.GetCurrentParticipation(taskIDs[1], player.ID)
+		require.NoError(t, err)
+		_, _, err = participationStore.VerifyParticipation(p.ID, owner.ID)
+		require.NoError(t, err)
+
+		p, _, err = participationStore.Join(taskIDs[2], player.ID)
+		require.NoError(t, err)
+		_, progress, err := participationStore.VerifyParticipation(p.ID, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, progress, 1)
+		assert.Equal(t, 3, progress[0].CompletedSteps)
+		assert.NotNil(t, progress[0].CompletedAt)
+		assert.Equal(t, 5.0, progress[0].RewardUSDT)
+
+		_, _, err = participationStore.VerifyParticipation(p.ID, owner.ID)
+		assert.Equal(t, ErrNotReviewable, err)
+
+		stored, err := questStore.GetQuestProgress(quest.ID, player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, stored.TotalSteps)
+		assert.Nil(t, stored.NextTaskID)
+		assert.Equal(t, 5.0, stored.RewardUSDT)
+
+		// three step rewards of 1 plus the quest reward of 5
+		var earnings float64
+		err = db.QueryRow(`SELECT earnings FROM leaderboard_scores WHERE scope = 'global' AND period = 'all_time' AND user_id = $1`, player.ID).Scan(&earnings)
+		require.NoError(t, err)
+		assert.Equal(t, 8.0, earnings)
+
+		// the quest reward is a reward of the last step's task, with its event
+		var rewardUSDT float64
+		var questID int64
+		err = db.QueryRow(`SELECT reward_usdt, quest_id FROM rewards WHERE user_id = $1 AND quest_id IS NOT NULL`, player.ID).Scan(&rewardUSDT, &questID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, rewardUSDT)
+		assert.Equal(t, quest.ID, questID)
+
+		writeOutboxTaskEvents(t, db)
+		var spend float64
+		err = db.QueryRow(`SELECT SUM(reward_usdt) FROM task_events WHERE task_id = $1 AND type = 'rewarded'`, taskIDs[2]).Scan(&spend)
+		require.NoError(t, err)
+		assert.Equal(t, 6.0, spend)
+	})
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"time"
+	// recurring tasks carry IANA timezone names, embed the database so
+	// period boundaries do not depend on the host's zoneinfo
+	_ "time/tzdata"
+)
+
+type Recurrence string
+
+const (
+	RecurrenceNone   Recurrence = "none"
+	RecurrenceDaily  Recurrence = "daily"
+	RecurrenceWeekly Recurrence = "weekly"
+)
+
+// Clock returns the current time. Stores that depend on "now" take a Clock so
+// tests can pin it.
+type Clock func() time.Time
+
+// oneShotPeriodStart is the period every participation of a non-recurring
+// task belongs to.
+var oneShotPeriodStart = time.Unix(0, 0).UTC()
+
+func (r Recurrence) IsRecurring() bool {
+	return r == RecurrenceDaily || r == RecurrenceWeekly
+}
+
+// PeriodStart returns the start of the period containing t. Daily periods
+// start at midnight and weekly periods on Monday at midnight, both in loc.
+func (r Recurrence) PeriodStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	switch r {
+	case RecurrenceDaily:
+		return day
+	case RecurrenceWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return oneShotPeriodStart
+	}
+}
+
+// NextPeriodStart returns the start of the period following the one starting
+// at start. Dates are added in loc so DST changes keep periods on midnight.
+func (r Recurrence) NextPeriodStart(start time.Time, loc *time.Location) time.Time {
+	start = start.In(loc)
+
+	switch r {
+	case RecurrenceDaily:
+		return start.AddDate(0, 0, 1)
+	case RecurrenceWeekly:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start
+	}
+}
+
+// advanceStreak records a check-in for period. The streak continues when
+// period directly follows the last counted one and restarts otherwise.
+func advanceStreak(s TaskStreak, period time.Time, r Recurrence, loc *time.Location) TaskStreak {
+	if !s.LastPeriodStart.IsZero() && s.LastPeriodStart.Equal(period) {
+		return s
+	}
+
+	if !s.LastPeriodStart.IsZero() && r.NextPeriodStart(s.LastPeriodStart, loc).Equal(period) {
+		s.CurrentStreak++
+	} else {
+		s.CurrentStreak = 1
+	}
+	if s.CurrentStreak > s.LongestStreak {
+		s.LongestStreak = s.CurrentStreak
+	}
+	s.LastPeriodStart = period
+
+	return s
+}
+
+// currentStreak returns the streak as seen at now. A streak whose last
+// check-in is older than the previous period has been missed and is reset.
+func currentStreak(s TaskStreak, now time.Time, r Recurrence, loc *time.Location) TaskStreak {
+	if s.LastPeriodStart.IsZero() {
+		return s
+	}
+
+	period := r.PeriodStart(now, loc)
+	if s.LastPeriodStart.Equal(period) || r.NextPeriodStart(s.LastPeriodStart, loc).Equal(period) {
+		return s
+	}
+
+	s.CurrentStreak = 0
+	return s
+}
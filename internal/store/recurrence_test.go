@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurrencePeriodStart(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+
+	// 2025-10-21 23:30 UTC is already Wednesday 06:30 in Jakarta
+	at := time.Date(2025, time.October, 21, 23, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, time.October, 22, 0, 0, 0, 0, jakarta), RecurrenceDaily.PeriodStart(at, jakarta))
+	assert.Equal(t, time.Date(2025, time.October, 21, 0, 0, 0, 0, time.UTC), RecurrenceDaily.PeriodStart(at, time.UTC))
+	assert.Equal(t, time.Date(2025, time.October, 20, 0, 0, 0, 0, jakarta), RecurrenceWeekly.PeriodStart(at, jakarta))
+	assert.Equal(t, oneShotPeriodStart, RecurrenceNone.PeriodStart(at, jakarta))
+}
+
+func TestRecurrenceNextPeriodStartAcrossDST(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	require.NoError(t, err)
+
+	// clocks go back on 2025-10-26, that day is 25 hours long
+	start := time.Date(2025, time.October, 26, 0, 0, 0, 0, amsterdam)
+	next := RecurrenceDaily.NextPeriodStart(start, amsterdam)
+
+	assert.Equal(t, time.Date(2025, time.October, 27, 0, 0, 0, 0, amsterdam), next)
+	assert.Equal(t, 25*time.Hour, next.Sub(start))
+}
+
+func TestStreak(t *testing.T) {
+	loc := time.UTC
+	day := func(d int) time.Time {
+		return time.Date(2025, time.October, d, 0, 0, 0, 0, loc)
+	}
+	// clock is the injectable "now" used to read the streak
+	clock := func(d, hour int) time.Time {
+		return time.Date(2025, time.October, d, hour, 0, 0, 0, loc)
+	}
+
+	var s TaskStreak
+	s = advanceStreak(s, day(1), RecurrenceDaily, loc)
+	s = advanceStreak(s, day(2), RecurrenceDaily, loc)
+	s = advanceStreak(s, day(2), RecurrenceDaily, loc)
+	s = advanceStreak(s, day(3), RecurrenceDaily, loc)
+	assert.Equal(t, int64(3), s.CurrentStreak)
+	assert.Equal(t, int64(3), s.LongestStreak)
+
+	// still alive during the next day, before the user checked in
+	assert.Equal(t, int64(3), currentStreak(s, clock(4, 22), RecurrenceDaily, loc).CurrentStreak)
+	// day 4 was missed
+	assert.Equal(t, int64(0), currentStreak(s, clock(5, 1), RecurrenceDaily, loc).CurrentStreak)
+
+	s = advanceStreak(s, day(5), RecurrenceDaily, loc)
+	assert.Equal(t, int64(1), s.CurrentStreak)
+	assert.Equal(t, int64(3), s.LongestStreak)
+}
+
+func TestWeeklyStreak(t *testing.T) {
+	loc := time.UTC
+	monday := time.Date(2025, time.October, 6, 0, 0, 0, 0, loc)
+
+	var s TaskStreak
+	s = advanceStreak(s, monday, RecurrenceWeekly, loc)
+	s = advanceStreak(s, monday.AddDate(0, 0, 7), RecurrenceWeekly, loc)
+	assert.Equal(t, int64(2), s.CurrentStreak)
+
+	// a week without a check-in resets the streak
+	s = advanceStreak(s, monday.AddDate(0, 0, 21), RecurrenceWeekly, loc)
+	assert.Equal(t, int64(1), s.CurrentStreak)
+	assert.Equal(t, int64(2), s.LongestStreak)
+}
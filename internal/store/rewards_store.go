@@ -3,14 +3,19 @@ package store
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// Reward is a payout to a user, for verifying a task or, when QuestID is
+// set, for completing a quest with the task as its last step.
 type Reward struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	TaskID    int64     `json:"task_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	TaskID     int64     `json:"task_id"`
+	QuestID    *int64    `json:"quest_id,omitempty"`
+	RewardUSDT float64   `json:"reward_usdt"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type PostgresRewardsStore struct {
@@ -41,13 +46,7 @@ func (pg *PostgresRewardsStore) Create(reward *Reward) (*Reward, error) {
 	}
 	defer tx.Rollback()
 
-	query := `
-	    INSERT INTO rewards (user_id, task_id)
-	    VALUES ($1, $2)
-	    RETURNING id, created_at
-       `
-
-	err = tx.QueryRow(query, reward.UserID, reward.TaskID).Scan(&reward.ID, &reward.CreatedAt)
+	err = insertReward(tx, reward)
 	if err != nil {
 		return nil, err
 	}
@@ -59,3 +58,50 @@ func (pg *PostgresRewardsStore) Create(reward *Reward) (*Reward, error) {
 
 	return reward, nil
 }
+
+// insertReward records a reward of the task's or the quest's amount, scores
+// it on the leaderboards and records the rewarded analytics event within tx.
+// Quest rewards only count on the global leaderboard, the task's is for the
+// task's own rewards.
+func insertReward(tx *sql.Tx, reward *Reward) error {
+	amountQuery, id := `SELECT reward_usdt FROM tasks WHERE id = $1`, reward.TaskID
+	scopes := taskLeaderboardScopes(reward.TaskID)
+	if reward.QuestID != nil {
+		amountQuery, id = `SELECT reward_usdt FROM quests WHERE id = $1`, *reward.QuestID
+		scopes = []leaderboardScopeRef{{LeaderboardScopeGlobal, 0}}
+	}
+	err := tx.QueryRow(amountQuery, id).Scan(&reward.RewardUSDT)
+	if err == sql.ErrNoRows && reward.QuestID != nil {
+		return fmt.Errorf("quest with id %d not found", id)
+	}
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task with id %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+	    INSERT INTO rewards (user_id, task_id, quest_id, reward_usdt)
+	    VALUES ($1, $2, $3, $4)
+	    RETURNING id, created_at
+       `
+
+	err = tx.QueryRow(query, reward.UserID, reward.TaskID, reward.QuestID, reward.RewardUSDT).Scan(&reward.ID, &reward.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = incrementLeaderboardScores(tx, reward.UserID, scopes, reward.RewardUSDT, reward.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return recordTaskEvent(tx, &TaskEvent{
+		TaskID:     reward.TaskID,
+		UserID:     reward.UserID,
+		Type:       TaskEventRewarded,
+		RewardUSDT: reward.RewardUSDT,
+		OccurredAt: reward.CreatedAt,
+	})
+}
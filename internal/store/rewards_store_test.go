@@ -3,6 +3,7 @@ package store
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
@@ -28,7 +29,7 @@ func TestCreateReward(t *testing.T) {
 
 	rewardsStore := NewPostgresRewardsStore(db)
 	userStore := NewPostgresUserStore(db)
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 
 	// Create initial user
 	initialUser := &User{
@@ -0,0 +1,444 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type SubmissionStatus string
+
+const (
+	SubmissionPending  SubmissionStatus = "pending"
+	SubmissionApproved SubmissionStatus = "approved"
+	SubmissionRejected SubmissionStatus = "rejected"
+)
+
+const (
+	MaxProofURLLength   = 2048
+	MaxProofTextLength  = 5000
+	MaxReviewReasonSize = 1000
+)
+
+var (
+	ErrNotJoined           = errors.New("join the task before submitting proof")
+	ErrSubmissionPending   = errors.New("a submission is already waiting for review")
+	ErrAlreadyVerified     = errors.New("participation has already been verified")
+	ErrSubmissionReviewed  = errors.New("submission has already been reviewed")
+	ErrProofAssetNotFound  = errors.New("asset_id must be the id of an image you uploaded")
+	errProofEmpty          = errors.New("proof needs at least one of proof_url, proof_text or asset_id")
+	errProofURLInvalid     = errors.New("proof_url must be an http or https URL")
+	errProofURLTooLong     = fmt.Errorf("proof_url must be at most %d characters", MaxProofURLLength)
+	errProofTextTooLong    = fmt.Errorf("proof_text must be at most %d characters", MaxProofTextLength)
+	errReviewReasonTooLong = fmt.Errorf("reason must be at most %d characters", MaxReviewReasonSize)
+)
+
+// Proof is the evidence a participant submits for actions that can not be
+// checked automatically.
+type Proof struct {
+	URL     string `json:"proof_url"`
+	Text    string `json:"proof_text"`
+	AssetID string `json:"asset_id"`
+}
+
+// Validate checks the proof's shape. Whether the asset belongs to the
+// submitter is checked when the proof is stored.
+func (p Proof) Validate() error {
+	if strings.TrimSpace(p.URL) == "" && strings.TrimSpace(p.Text) == "" && p.AssetID == "" {
+		return errProofEmpty
+	}
+
+	if p.URL != "" {
+		if len(p.URL) > MaxProofURLLength {
+			return errProofURLTooLong
+		}
+		if !isHTTPURL(p.URL) {
+			return errProofURLInvalid
+		}
+	}
+
+	if utf8.RuneCountInString(p.Text) > MaxProofTextLength {
+		return errProofTextTooLong
+	}
+
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ValidateReviewReason checks a reviewer's reason, which rejections must give.
+func ValidateReviewReason(reason string, required bool) error {
+	if required && strings.TrimSpace(reason) == "" {
+		return errors.New("reason is required when rejecting")
+	}
+	if utf8.RuneCountInString(reason) > MaxReviewReasonSize {
+		return errReviewReasonTooLong
+	}
+	return nil
+}
+
+type Submission struct {
+	ID              int64            `json:"id"`
+	ParticipationID int64            `json:"participation_id"`
+	TaskID          int64            `json:"task_id"`
+	UserID          int64            `json:"user_id"`
+	TaskOwnerID     int64            `json:"task_owner_id"`
+	ProofURL        string           `json:"proof_url"`
+	ProofText       string           `json:"proof_text"`
+	AssetID         *string          `json:"asset_id"`
+	Status          SubmissionStatus `json:"status"`
+	Reason          string           `json:"reason"`
+	ReviewedBy      *int64           `json:"reviewed_by"`
+	ReviewedAt      *time.Time       `json:"reviewed_at"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+type PostgresSubmissionStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresSubmissionStore(db *sql.DB, clock Clock) *PostgresSubmissionStore {
+	return &PostgresSubmissionStore{db: db, now: clock}
+}
+
+type SubmissionStore interface {
+	CreateSubmission(taskID, userID int64, proof Proof) (*Submission, error)
+	GetSubmissionByID(id int64) (*Submission, error)
+	GetSubmissions(taskID int64, status SubmissionStatus, page utils.PageParams) ([]Submission, utils.PageBounds, error)
+	ApproveSubmission(id, reviewerID int64, reason string) (*Submission, *Reward, []QuestProgress, error)
+	RejectSubmission(id, reviewerID int64, reason string) (*Submission, error)
+}
+
+// CreateSubmission attaches proof to the user's participation in the task's
+// current period and puts it in the review queue. A rejected participation
+// can submit again, one waiting for review or already verified can not.
+func (pg *PostgresSubmissionStore) CreateSubmission(taskID, userID int64, proof Proof) (*Submission, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	schedule, err := loadTaskSchedule(tx, taskID, false)
+	if err != nil {
+		return nil, err
+	}
+	period := schedule.recurrence.PeriodStart(now, schedule.loc)
+
+	var participationID int64
+	var status ParticipationStatus
+	query := `
+		SELECT id, status
+		FROM task_participations
+		WHERE task_id = $1 AND user_id = $2 AND period_start = $3
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, taskID, userID, period).Scan(&participationID, &status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotJoined
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case ParticipationSubmitted:
+		return nil, ErrSubmissionPending
+	case ParticipationVerified:
+		return nil, ErrAlreadyVerified
+	case ParticipationRejected:
+		// an appeal decides the rejection, new proof would race with it
+		open, err := hasOpenDispute(tx, participationID)
+		if err != nil {
+			return nil, err
+		}
+		if open {
+			return nil, ErrDisputeOpen
+		}
+	}
+
+	s := &Submission{
+		ParticipationID: participationID,
+		TaskID:          taskID,
+		UserID:          userID,
+		TaskOwnerID:     schedule.ownerID,
+		ProofURL:        proof.URL,
+		ProofText:       proof.Text,
+		Status:          SubmissionPending,
+	}
+	if proof.AssetID != "" {
+		owned, err := isAssetOwnedBy(tx, proof.AssetID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return nil, ErrProofAssetNotFound
+		}
+		s.AssetID = &proof.AssetID
+	}
+
+	query = `
+		INSERT INTO task_submissions (participation_id, task_id, user_id, proof_url, proof_text, asset_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, s.ParticipationID, s.TaskID, s.UserID, s.ProofURL, s.ProofText, s.AssetID, s.Status, now).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE task_participations SET status = $1, updated_at = $2 WHERE id = $3`, ParticipationSubmitted, now, participationID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordTaskEvent(tx, &TaskEvent{TaskID: taskID, UserID: userID, Type: TaskEventSubmission, OccurredAt: now})
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    userID,
+		Action:     AuditSubmissionCreated,
+		EntityType: AuditEntitySubmission,
+		EntityID:   s.ID,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+const submissionColumns = `
+	s.id, s.participation_id, s.task_id, s.user_id, t.user_id, s.proof_url, s.proof_text, s.asset_id,
+	s.status, s.reason, s.reviewed_by, s.reviewed_at, s.created_at, s.updated_at
+`
+
+func submissionFields(s *Submission) []any {
+	return []any{
+		&s.ID, &s.ParticipationID, &s.TaskID, &s.UserID, &s.TaskOwnerID, &s.ProofURL, &s.ProofText, &s.AssetID,
+		&s.Status, &s.Reason, &s.ReviewedBy, &s.ReviewedAt, &s.CreatedAt, &s.UpdatedAt,
+	}
+}
+
+// GetSubmissionByID returns nil when there is no submission with the id.
+func (pg *PostgresSubmissionStore) GetSubmissionByID(id int64) (*Submission, error) {
+	query := `SELECT ` + submissionColumns + `
+		FROM task_submissions s
+		JOIN tasks t ON t.id = s.task_id
+		WHERE s.id = $1
+	`
+
+	var s Submission
+	err := pg.db.QueryRow(query, id).Scan(submissionFields(&s)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetSubmissions returns a page of the review queue, oldest first. A zero
+// taskID lists every task's submissions and an empty status every status.
+func (pg *PostgresSubmissionStore) GetSubmissions(taskID int64, status SubmissionStatus, page utils.PageParams) ([]Submission, utils.PageBounds, error) {
+	ks := keyset{key: "s.created_at", cast: "timestamptz", id: "s.id"}
+	cond, orderBy, args := ks.clause(page, 3)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM task_submissions s
+		JOIN tasks t ON t.id = s.task_id
+		WHERE ($1 = 0 OR s.task_id = $1) AND ($2 = '' OR s.status = $2) %s
+		ORDER BY %s
+		LIMIT $%d
+	`, submissionColumns, ks.keyColumn(), cond, orderBy, len(args)+3)
+
+	args = append([]any{taskID, status}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Submission]
+	for rows.Next() {
+		var item keyed[Submission]
+		err := rows.Scan(append(submissionFields(&item.row), &item.key)...)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		item.id = item.row.ID
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	submissions, bounds := keysetPage(items, page)
+	return submissions, bounds, nil
+}
+
+// review locks a pending submission and stores the reviewer's decision on
+// it. It returns the submission as it is after the decision.
+func review(tx *sql.Tx, id, reviewerID int64, status SubmissionStatus, reason string, now time.Time) (*Submission, error) {
+	query := `SELECT ` + submissionColumns + `
+		FROM task_submissions s
+		JOIN tasks t ON t.id = s.task_id
+		WHERE s.id = $1
+		FOR UPDATE OF s
+	`
+
+	var s Submission
+	err := tx.QueryRow(query, id).Scan(submissionFields(&s)...)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != SubmissionPending {
+		return nil, ErrSubmissionReviewed
+	}
+
+	query = `
+		UPDATE task_submissions
+		SET status = $1, reason = $2, reviewed_by = $3, reviewed_at = $4, updated_at = $4
+		WHERE id = $5
+	`
+	_, err = tx.Exec(query, status, reason, reviewerID, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = status
+	s.Reason = reason
+	s.ReviewedBy = &reviewerID
+	s.ReviewedAt = &now
+	s.UpdatedAt = now
+
+	return &s, nil
+}
+
+// closePendingSubmission settles the participation's pending submission, if
+// any, when the participation is reviewed directly instead of through the
+// submission queue.
+func closePendingSubmission(tx *sql.Tx, participationID int64, status SubmissionStatus, reviewerID int64, now time.Time) error {
+	query := `
+		UPDATE task_submissions
+		SET status = $1, reviewed_by = $2, reviewed_at = $3, updated_at = $3
+		WHERE participation_id = $4 AND status = $5
+	`
+	_, err := tx.Exec(query, status, reviewerID, now, participationID, SubmissionPending)
+	return err
+}
+
+// ApproveSubmission accepts the proof and verifies its participation, which
+// grants the task reward and advances quests in the same transaction.
+func (pg *PostgresSubmissionStore) ApproveSubmission(id, reviewerID int64, reason string) (*Submission, *Reward, []QuestProgress, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	s, err := review(tx, id, reviewerID, SubmissionApproved, reason, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	reward, progress, err := verifyParticipation(tx, s.ParticipationID, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    reviewerID,
+		Action:     AuditSubmissionApproved,
+		EntityType: AuditEntitySubmission,
+		EntityID:   id,
+		Details:    map[string]any{"reason": reason, "reward_id": reward.ID},
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return s, reward, progress, nil
+}
+
+// RejectSubmission turns the proof down and marks its participation as
+// rejected, the participant may submit new proof afterwards.
+func (pg *PostgresSubmissionStore) RejectSubmission(id, reviewerID int64, reason string) (*Submission, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, err := review(tx, id, reviewerID, SubmissionRejected, reason, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE task_participations SET status = $1, updated_at = $2 WHERE id = $3`, ParticipationRejected, now, s.ParticipationID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordTaskEvent(tx, &TaskEvent{TaskID: s.TaskID, UserID: s.UserID, Type: TaskEventRejected, OccurredAt: now})
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    reviewerID,
+		Action:     AuditSubmissionRejected,
+		EntityType: AuditEntitySubmission,
+		EntityID:   id,
+		Details:    map[string]any{"reason": reason},
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
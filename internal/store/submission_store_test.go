@@ -0,0 +1,174 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		proof   Proof
+		wantErr error
+	}{
+		{name: "url", proof: Proof{URL: "https://example.com/post/1"}},
+		{name: "text", proof: Proof{Text: "my wallet is 0xabc"}},
+		{name: "asset", proof: Proof{AssetID: "a1b2c3"}},
+		{name: "empty", proof: Proof{Text: "   "}, wantErr: errProofEmpty},
+		{name: "relative url", proof: Proof{URL: "/post/1"}, wantErr: errProofURLInvalid},
+		{name: "other scheme", proof: Proof{URL: "javascript:alert(1)"}, wantErr: errProofURLInvalid},
+		{name: "long url", proof: Proof{URL: "https://example.com/" + strings.Repeat("a", MaxProofURLLength)}, wantErr: errProofURLTooLong},
+		{name: "long text", proof: Proof{Text: strings.Repeat("é", MaxProofTextLength+1)}, wantErr: errProofTextTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.proof.Validate())
+		})
+	}
+}
+
+func setupTestDBSubmission(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE audit_log, task_submissions, assets, leaderboard_scores, rewards, task_participations, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestSubmissionStore(t *testing.T) {
+	db := setupTestDBSubmission(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	submissionStore := NewPostgresSubmissionStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	auditStore := NewPostgresAuditStore(db)
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	owner := &User{Username: "submission-owner", Email: "submission-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	player := &User{Username: "submission-player", Email: "submission-player@gmail.com"}
+	player.PasswordHash.Set("password123")
+	player, err = userStore.CreateUser(player)
+	require.NoError(t, err)
+
+	task, err := taskStore.CreateTask(&Task{
+		Title:      "Write a blog post",
+		UserID:     owner.ID,
+		RewardUSDT: 3,
+		DueDate:    now.AddDate(0, 1, 0),
+	})
+	require.NoError(t, err)
+	taskID := int64(task.ID)
+
+	page := utils.PageParams{Limit: 10, Sort: string(TaskSortOldest)}
+
+	t.Run("proof needs a participation", func(t *testing.T) {
+		_, err := submissionStore.CreateSubmission(taskID, player.ID, Proof{URL: "https://blog.example.com/post"})
+		assert.Equal(t, ErrNotJoined, err)
+	})
+
+	_, _, err = participationStore.Join(taskID, player.ID)
+	require.NoError(t, err)
+
+	t.Run("assets must belong to the submitter", func(t *testing.T) {
+		_, err := submissionStore.CreateSubmission(taskID, player.ID, Proof{AssetID: "missing"})
+		assert.Equal(t, ErrProofAssetNotFound, err)
+	})
+
+	t.Run("rejected proof can be submitted again", func(t *testing.T) {
+		s, err := submissionStore.CreateSubmission(taskID, player.ID, Proof{URL: "https://blog.example.com/draft"})
+		require.NoError(t, err)
+		assert.Equal(t, SubmissionPending, s.Status)
+		assert.Equal(t, owner.ID, s.TaskOwnerID)
+
+		_, err = submissionStore.CreateSubmission(taskID, player.ID, Proof{URL: "https://blog.example.com/again"})
+		assert.Equal(t, ErrSubmissionPending, err)
+
+		queue, _, err := submissionStore.GetSubmissions(taskID, SubmissionPending, page)
+		require.NoError(t, err)
+		require.Len(t, queue, 1)
+		assert.Equal(t, s.ID, queue[0].ID)
+
+		rejected, err := submissionStore.RejectSubmission(s.ID, owner.ID, "the post is still a draft")
+		require.NoError(t, err)
+		assert.Equal(t, SubmissionRejected, rejected.Status)
+		assert.Equal(t, owner.ID, *rejected.ReviewedBy)
+
+		_, err = submissionStore.RejectSubmission(s.ID, owner.ID, "again")
+		assert.Equal(t, ErrSubmissionReviewed, err)
+
+		p, err := participationStore.GetCurrentParticipation(taskID, player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ParticipationRejected, p.Status)
+	})
+
+	t.Run("approving grants the reward and is audited", func(t *testing.T) {
+		s, err := submissionStore.CreateSubmission(taskID, player.ID, Proof{URL: "https://blog.example.com/post", Text: "published today"})
+		require.NoError(t, err)
+
+		approved, reward, _, err := submissionStore.ApproveSubmission(s.ID, owner.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, SubmissionApproved, approved.Status)
+		assert.Equal(t, player.ID, reward.UserID)
+
+		p, err := participationStore.GetCurrentParticipation(taskID, player.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ParticipationVerified, p.Status)
+
+		_, err = submissionStore.CreateSubmission(taskID, player.ID, Proof{Text: "one more"})
+		assert.Equal(t, ErrAlreadyVerified, err)
+
+		historyPage := utils.PageParams{Limit: 1, Sort: string(TaskSortOldest)}
+		history, bounds, err := auditStore.GetAuditEntries(AuditEntitySubmission, s.ID, historyPage)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.True(t, bounds.HasMore)
+		assert.Equal(t, AuditSubmissionCreated, history[0].Action)
+		assert.Equal(t, player.ID, history[0].ActorID)
+
+		historyPage.Cursor = bounds.Last
+		history, bounds, err = auditStore.GetAuditEntries(AuditEntitySubmission, s.ID, historyPage)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.False(t, bounds.HasMore)
+		assert.Equal(t, AuditSubmissionApproved, history[0].Action)
+		assert.Equal(t, owner.ID, history[0].ActorID)
+		assert.Equal(t, float64(reward.ID), history[0].Details["reward_id"])
+
+		queue, _, err := submissionStore.GetSubmissions(0, "", page)
+		require.NoError(t, err)
+		assert.Len(t, queue, 2)
+	})
+
+	t.Run("role changes are audited", func(t *testing.T) {
+		err := userStore.SetUserRole(player.ID, UserRoleModerator, owner.ID)
+		require.NoError(t, err)
+
+		stored, err := userStore.GetUserByID(player.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.IsModerator())
+
+		history, _, err := auditStore.GetAuditEntries(AuditEntityUser, player.ID, utils.PageParams{Limit: 10, Sort: string(TaskSortOldest)})
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "moderator", history[0].Details["to"])
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
 )
 
 type TypeAction string
@@ -14,6 +16,11 @@ const (
 	Type3 TypeAction = "type_3"
 )
 
+// IsValid reports whether t is one of the known action kinds.
+func (t TypeAction) IsValid() bool {
+	return t == Type1 || t == Type2 || t == Type3
+}
+
 type ActionTask struct {
 	ID          int        `json:"id"`
 	Type        TypeAction `json:"type"`
@@ -34,7 +41,7 @@ type TaskActionStore interface {
 	EditAction(req *ActionTask) error
 	DeleteAction(id int) error
 	GetActionByID(id int) (*ActionTask, error)
-	GetAction() ([]ActionTask, error)
+	GetAction(page utils.PageParams) ([]ActionTask, utils.PageBounds, error)
 }
 
 func (pg *PostgresTaskActionStore) CreateAction(req *ActionTask) (*int, error) {
@@ -95,24 +102,38 @@ func (pg *PostgresTaskActionStore) DeleteAction(id int) error {
 	return nil
 }
 
-func (pg *PostgresTaskActionStore) GetAction() ([]ActionTask, error) {
-	query := `SELECT id, name, type, description FROM task_actions`
+func (pg *PostgresTaskActionStore) GetAction(page utils.PageParams) ([]ActionTask, utils.PageBounds, error) {
+	ks := keyset{id: "id"}
+	cond, orderBy, args := ks.clause(page, 1)
+	if cond != "" {
+		cond = "WHERE " + cond
+	}
+
+	query := fmt.Sprintf(`SELECT id, name, type, description FROM task_actions %s ORDER BY %s LIMIT $%d`, cond, orderBy, len(args)+1)
+	args = append(args, page.Limit+1)
 
-	rows, err := pg.db.Query(query)
+	rows, err := pg.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, utils.PageBounds{}, err
 	}
-	var resp []ActionTask
+	defer rows.Close()
+
+	var items []keyed[ActionTask]
 	for rows.Next() {
-		var r ActionTask
+		var item keyed[ActionTask]
+		r := &item.row
 		if err := rows.Scan(&r.ID, &r.Name, &r.Type, &r.Description); err != nil {
-			return nil, err
+			return nil, utils.PageBounds{}, err
 		}
-		resp = append(resp, r)
+		item.id = int64(r.ID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
 	}
-	defer rows.Close()
 
-	return resp, nil
+	resp, bounds := keysetPage(items, page)
+	return resp, bounds, nil
 }
 
 func (pg *PostgresTaskActionStore) GetActionByID(id int) (*ActionTask, error) {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/harundarat/be-socialtask/internal/utils"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -66,7 +67,7 @@ func TestTaskActionStore(t *testing.T) {
 	})
 
 	t.Run("GetAction", func(t *testing.T) {
-		actions, err := store.GetAction()
+		actions, _, err := store.GetAction(utils.PageParams{Limit: 10})
 		require.NoError(t, err)
 		assert.Len(t, actions, 1)
 	})
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
 )
 
 type JenisCategory string
@@ -32,7 +34,7 @@ type TaskRewardStore interface {
 	CreateReward(req *RewardTask) (*int, error)
 	EditReward(req *RewardTask) error
 	DeleteReward(id int) error
-	GetReward() ([]RewardTask, error)
+	GetReward(page utils.PageParams) ([]RewardTask, utils.PageBounds, error)
 	GetRewardByID(id int) (*RewardTask, error)
 }
 
@@ -88,23 +90,38 @@ func (pg *PostgresTaskRewardStore) DeleteReward(id int) error {
 	return nil
 }
 
-func (pg *PostgresTaskRewardStore) GetReward() ([]RewardTask, error) {
-	query := `SELECT id, reward_type, reward_name FROM task_rewards`
-	rows, err := pg.db.Query(query)
+func (pg *PostgresTaskRewardStore) GetReward(page utils.PageParams) ([]RewardTask, utils.PageBounds, error) {
+	ks := keyset{id: "id"}
+	cond, orderBy, args := ks.clause(page, 1)
+	if cond != "" {
+		cond = "WHERE " + cond
+	}
+
+	query := fmt.Sprintf(`SELECT id, reward_type, reward_name FROM task_rewards %s ORDER BY %s LIMIT $%d`, cond, orderBy, len(args)+1)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, utils.PageBounds{}, err
 	}
-	var resp []RewardTask
+	defer rows.Close()
+
+	var items []keyed[RewardTask]
 	for rows.Next() {
-		var r RewardTask
+		var item keyed[RewardTask]
+		r := &item.row
 		if err := rows.Scan(&r.ID, &r.RewardType, &r.RewardName); err != nil {
-			return nil, err
+			return nil, utils.PageBounds{}, err
 		}
-		resp = append(resp, r)
+		item.id = int64(r.ID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
 	}
-	defer rows.Close()
 
-	return resp, nil
+	resp, bounds := keysetPage(items, page)
+	return resp, bounds, nil
 }
 
 func (pg *PostgresTaskRewardStore) GetRewardByID(id int) (*RewardTask, error) {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/harundarat/be-socialtask/internal/utils"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,7 +63,7 @@ func TestTaskRewardStore(t *testing.T) {
 	})
 
 	t.Run("GetReward", func(t *testing.T) {
-		rewards, err := store.GetReward()
+		rewards, _, err := store.GetReward(utils.PageParams{Limit: 10})
 		require.NoError(t, err)
 		assert.Len(t, rewards, 1)
 	})
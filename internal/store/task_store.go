@@ -6,42 +6,230 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+type TaskStatus string
+
+const (
+	TaskStatusPending   TaskStatus = "PENDING"
+	TaskStatusActive    TaskStatus = "ACTIVE"
+	TaskStatusCompleted TaskStatus = "COMPLETED"
+	// TaskStatusDraft tasks are only visible to their owner until they are
+	// published, by hand or by the scheduler once PublishAt has passed.
+	TaskStatusDraft TaskStatus = "DRAFT"
+)
+
+type TaskSort string
+
+const (
+	TaskSortNewest     TaskSort = "newest"
+	TaskSortOldest     TaskSort = "oldest"
+	TaskSortRewardHigh TaskSort = "reward_high"
+	TaskSortRewardLow  TaskSort = "reward_low"
+	TaskSortDueSoon    TaskSort = "due_soon"
+	// TaskSortRelevance ranks full-text matches, it needs Search to be set.
+	TaskSortRelevance TaskSort = "relevance"
 )
 
+// TaskFilter narrows and orders the task list. Zero values do not filter.
+type TaskFilter struct {
+	Status     TaskStatus
+	ActionType TypeAction
+	RewardType JenisCategory
+	CreatorID  int64
+	MinReward  *float64
+	MaxReward  *float64
+	DueBefore  *time.Time
+	DueAfter   *time.Time
+	// Search is matched against title and description, it accepts the
+	// websearch syntax: quoted phrases, "or" and a leading "-" to exclude.
+	Search string
+	Sort   TaskSort
+	Page   utils.PageParams
+	// ViewerID sees their own drafts and those of their organizations in the
+	// list, 0 for anonymous viewers.
+	ViewerID int64
+	// IncludeDrafts lists every draft, for callers that already checked the
+	// viewer may see them.
+	IncludeDrafts  bool
+	CampaignID     int64
+	OrganizationID int64
+}
+
 type Task struct {
 	ID             int       `json:"id"`
 	Title          string    `json:"title"`
 	Description    string    `json:"description"`
 	UserID         int64     `json:"user_id"`
-	RewardID       int       `json:"reward_task"`
 	RewardUSDT     float64   `json:"reward_usdt"` // total reward kah?
 	DueDate        time.Time `json:"due_date"`
 	MaxParticipant string    `json:"max_participant"`
 	CreatedAt      time.Time `json:"created_at"`
-	TaskImage      string    `json:"task_image"`
-	ActionID       int       `json:"action_id"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	// TaskImage is the id of an uploaded image asset, see AssetStore.
+	TaskImage string     `json:"task_image"`
+	Status    TaskStatus `json:"status"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	// ActionIDs and RewardIDs set the task's actions and rewards, in order,
+	// on create and edit. A nil slice leaves the current links untouched.
+	ActionIDs []int `json:"action_ids,omitempty"`
+	RewardIDs []int `json:"reward_ids,omitempty"`
+	// Actions and Rewards are the linked rows, filled in when a task is read.
+	Actions []ActionTask `json:"actions"`
+	Rewards []RewardTask `json:"rewards"`
+	// Recurrence opens a new participation window every day or week,
+	// with period boundaries computed in RecurrenceTimezone.
+	Recurrence         Recurrence `json:"recurrence"`
+	RecurrenceTimezone string     `json:"recurrence_timezone"`
+	// Eligibility restricts who can join. On edit a nil list leaves the
+	// current rules untouched and an empty one removes them.
+	Eligibility EligibilityRules `json:"eligibility"`
+	// PublishAt schedules a draft, the scheduler publishes it once the time
+	// has passed. Creating a task with it set makes the task a draft.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// CampaignID is the campaign the task runs in, managed through the
+	// campaign endpoints.
+	CampaignID *int64 `json:"campaign_id,omitempty"`
+	// OrganizationID is set on tasks an organization owns, its members
+	// manage them according to their role. It is fixed when the task is
+	// created.
+	OrganizationID *int64 `json:"organization_id,omitempty"`
+}
+
+// TaskCreator is the public profile of the user who created a task.
+type TaskCreator struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Fullname string `json:"fullname"`
+}
+
+// TaskDetail is the read model behind the task detail page. It adds what a
+// viewer needs to decide whether to take part on top of the task itself.
+type TaskDetail struct {
+	Task
+	Creator TaskCreator `json:"creator"`
+	// ParticipantCount is the number of distinct users who ever joined the task.
+	ParticipantCount int64 `json:"participant_count"`
+	// MyParticipation is the viewer's latest participation, nil for anonymous
+	// viewers and for users who never joined.
+	MyParticipation *Participation `json:"my_participation"`
 }
 
+var (
+	ErrActionNotFound = errors.New("action not found")
+	ErrRewardNotFound = errors.New("reward not found")
+	ErrImageNotFound  = errors.New("task_image must be the id of an image you uploaded")
+	ErrTaskNotDraft   = errors.New("task is already published")
+)
+
 type PostgresTaskStore struct {
-	db *sql.DB
+	db  *sql.DB
+	now Clock
 }
 
-func NewPostgresTaskStore(db *sql.DB) *PostgresTaskStore {
-	return &PostgresTaskStore{db: db}
+func NewPostgresTaskStore(db *sql.DB, clock Clock) *PostgresTaskStore {
+	return &PostgresTaskStore{db: db, now: clock}
 }
 
 type TaskStore interface {
 	CreateTask(task *Task) (*Task, error)
-	GetAllTask(limit, offset int64) ([]Task, int64, error)
+	CreateTasks(tasks []*Task, dryRun bool) ([]*Task, error)
+	GetAllTask(filter TaskFilter) ([]Task, utils.PageBounds, int64, error)
 	GetTaskByID(id int64) (*Task, error)
-	EditTask(t *Task) error
+	GetTaskDetail(id, viewerID int64) (*TaskDetail, error)
+	EditTask(t *Task, editorID int64) error
 	DeleteTask(id int64) error
+	PublishTask(id int64) error
+	PublishDueTasks(now time.Time) ([]int64, error)
+	CloneTask(id int64) (*Task, error)
 }
 
 func (pg *PostgresTaskStore) CreateTask(task *Task) (*Task, error) {
-	if task.UserID == 0 {
-		return nil, errors.New("user id is required and can not be zero")
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertTask(tx, task, pg.now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadTaskLinks(tx, []*Task{task})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// TaskBatchError is the task of a batch that could not be created, Index is
+// its position in the batch.
+type TaskBatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *TaskBatchError) Error() string {
+	return fmt.Sprintf("task %d: %v", e.Index+1, e.Err)
+}
+
+func (e *TaskBatchError) Unwrap() error {
+	return e.Err
+}
+
+// TaskBatchErrors are all the tasks of a batch that could not be created, a
+// task may be in it more than once.
+type TaskBatchErrors []*TaskBatchError
+
+func (e TaskBatchErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e TaskBatchErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// CreateTasks creates every task in one transaction, so either all of them
+// exist afterwards or none do. The actions, rewards and images of all tasks
+// are checked first, every one that does not exist is returned in
+// TaskBatchErrors. A task that fails later is returned as a *TaskBatchError.
+// With dryRun set nothing is written after the checks, the tasks come back
+// without an id.
+func (pg *PostgresTaskStore) CreateTasks(tasks []*Task, dryRun bool) ([]*Task, error) {
+	for i, task := range tasks {
+		err := prepareTask(task)
+		if err != nil {
+			return nil, &TaskBatchError{Index: i, Err: err}
+		}
+	}
+
+	links, err := checkTaskBatchLinks(pg.db, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		for _, task := range tasks {
+			task.ID = 0
+			links.fill(task)
+		}
+		return tasks, nil
 	}
 
 	tx, err := pg.db.Begin()
@@ -50,80 +238,153 @@ func (pg *PostgresTaskStore) CreateTask(task *Task) (*Task, error) {
 	}
 	defer tx.Rollback()
 
+	for i, task := range tasks {
+		err = insertTask(tx, task, pg.now())
+		if err != nil {
+			return nil, &TaskBatchError{Index: i, Err: err}
+		}
+	}
+
+	err = loadTaskLinks(tx, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// prepareTask fills in the defaults and the status of a new task.
+func prepareTask(task *Task) error {
+	if task.UserID == 0 {
+		return errors.New("user id is required and can not be zero")
+	}
+
+	if task.Recurrence == "" {
+		task.Recurrence = RecurrenceNone
+	}
+	if task.RecurrenceTimezone == "" {
+		task.RecurrenceTimezone = "UTC"
+	}
+
+	// a scheduled task is a draft until it is published, any other status
+	// the caller sent is ignored
+	if task.Status == TaskStatusDraft || task.PublishAt != nil {
+		task.Status = TaskStatusDraft
+	} else {
+		task.Status = TaskStatusPending
+	}
+
+	if task.Eligibility == nil {
+		task.Eligibility = EligibilityRules{}
+	}
+	return nil
+}
+
+// insertTask writes a new task with its actions and rewards, filling in the
+// defaults, its id and status.
+func insertTask(tx *sql.Tx, task *Task, now time.Time) error {
+	err := prepareTask(task)
+	if err != nil {
+		return err
+	}
+
+	err = checkTaskImage(tx, task.TaskImage, task.UserID)
+	if err != nil {
+		return err
+	}
+
 	query := `
 	INSERT INTO tasks (
 		title, 
 		description, 
 		user_id, 
-		reward_id, 
 		reward_usdt, 
 		due_date, 
 		max_participant, 
 		task_image, 
-		action_id
+		recurrence,
+		recurrence_timezone,
+		eligibility,
+		status,
+		publish_at,
+		organization_id
 	) 
 	VALUES (
-		$1, $2, $3, $4, $5, $6, $7, $8, $9
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 	)
 	RETURNING id
 `
 
-	err = tx.QueryRow(query, task.Title, task.Description, task.UserID, task.RewardID, task.RewardUSDT, task.DueDate, task.MaxParticipant, task.TaskImage, task.ActionID).Scan(&task.ID)
+	err = tx.QueryRow(query, task.Title, task.Description, task.UserID, task.RewardUSDT, nullDueDate(task.DueDate), task.MaxParticipant, task.TaskImage, task.Recurrence, task.RecurrenceTimezone, task.Eligibility, task.Status, task.PublishAt, task.OrganizationID).Scan(&task.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = tx.Commit()
+	err = replaceTaskLinks(tx, int64(task.ID), task.ActionIDs, task.RewardIDs)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return task, nil
+	_, err = enqueueTaskEvent(tx, OutboxTaskCreated, OutboxTaskData{TaskID: int64(task.ID), UserID: task.UserID}, now)
+	return err
+}
+
+// nullDueDate stores a zero due date, a task without a deadline, as NULL
+// so that the task never closes. It is read back as the zero time.
+func nullDueDate(dueDate time.Time) sql.NullTime {
+	return sql.NullTime{Time: dueDate, Valid: !dueDate.IsZero()}
 }
 
 func (pg *PostgresTaskStore) GetTaskByID(id int64) (*Task, error) {
 	task := &Task{}
-
-	tx, err := pg.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
+	var dueDate sql.NullTime
 
 	query := `
 		SELECT 
-			t.id, 
-			t.title, 
-			t.description, 
-			t.user_id, 
-			t.reward_id,
-			r.reward_type,
-			r.reward_name,
-			t.reward_usdt, 
-			t.due_date, 
-			t.max_participant, 
-			t.task_image, 
-			t.action_id, 
-			a.type AS action_type,
-			a.name AS action_name,
-			a.description AS action_description
-		FROM tasks t
-		LEFT JOIN task_rewards r ON t.reward_id = r.id
-		LEFT JOIN task_actions a ON t.action_id = a.id
-		WHERE t.id = $1
+			id, 
+			title, 
+			COALESCE(description, ''), 
+			user_id, 
+			reward_usdt, 
+			due_date, 
+			COALESCE(max_participant, ''), 
+			COALESCE(task_image, ''), 
+			status,
+			recurrence,
+			recurrence_timezone,
+			eligibility,
+			publish_at,
+			campaign_id,
+			organization_id,
+			created_at,
+			updated_at
+		FROM tasks
+		WHERE id = $1
 	`
 
-	err = tx.QueryRow(query, id).Scan(
+	err := pg.db.QueryRow(query, id).Scan(
 		&task.ID,
 		&task.Title,
 		&task.Description,
 		&task.UserID,
-		&task.RewardID,
 		&task.RewardUSDT,
-		&task.DueDate,
+		&dueDate,
 		&task.MaxParticipant,
 		&task.TaskImage,
-		&task.ActionID,
+		&task.Status,
+		&task.Recurrence,
+		&task.RecurrenceTimezone,
+		&task.Eligibility,
+		&task.PublishAt,
+		&task.CampaignID,
+		&task.OrganizationID,
+		&task.CreatedAt,
+		&task.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -132,65 +393,283 @@ func (pg *PostgresTaskStore) GetTaskByID(id int64) (*Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	task.DueDate = dueDate.Time
+
+	err = loadTaskLinks(pg.db, []*Task{task})
+	if err != nil {
+		return nil, err
+	}
 
 	return task, nil
 }
 
-func (pg *PostgresTaskStore) GetAllTask(limit, offset int64) ([]Task, int64, error) {
+// GetTaskDetail returns the task as seen by viewerID, pass 0 for anonymous
+// viewers. It returns nil when the task does not exist.
+func (pg *PostgresTaskStore) GetTaskDetail(id, viewerID int64) (*TaskDetail, error) {
+	task, err := pg.GetTaskByID(id)
+	if err != nil || task == nil {
+		return nil, err
+	}
+
+	detail := &TaskDetail{Task: *task}
+
+	query := `
+		SELECT
+			u.id,
+			u.username,
+			COALESCE(u.fullname, ''),
+			(SELECT COUNT(DISTINCT p.user_id) FROM task_participations p WHERE p.task_id = $1)
+		FROM users u
+		WHERE u.id = $2
+	`
+	err = pg.db.QueryRow(query, id, task.UserID).Scan(
+		&detail.Creator.ID,
+		&detail.Creator.Username,
+		&detail.Creator.Fullname,
+		&detail.ParticipantCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewerID != 0 {
+		query = participationSelect + `
+			WHERE p.task_id = $1 AND p.user_id = $2
+			ORDER BY p.period_start DESC
+			LIMIT 1
+		`
+		detail.MyParticipation, err = scanParticipation(pg.db.QueryRow(query, id, viewerID))
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	return detail, nil
+}
+
+// where builds the WHERE clause for the filter, numbering placeholders from 1.
+func (f TaskFilter) where() (string, []any) {
+	var conditions []string
+	var args []any
+	argCount := 1
+
+	// drafts are only listed for their owner, or the members of the
+	// organization owning them
+	if !f.IncludeDrafts {
+		conditions = append(conditions, fmt.Sprintf(`(t.status::text <> 'DRAFT'
+			OR (t.organization_id IS NULL AND t.user_id = $%[1]d)
+			OR t.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $%[1]d))`, argCount))
+		args = append(args, f.ViewerID)
+		argCount++
+	}
+
+	if f.CampaignID != 0 {
+		conditions = append(conditions, fmt.Sprintf("t.campaign_id = $%d", argCount))
+		args = append(args, f.CampaignID)
+		argCount++
+	}
+
+	if f.OrganizationID != 0 {
+		conditions = append(conditions, fmt.Sprintf("t.organization_id = $%d", argCount))
+		args = append(args, f.OrganizationID)
+		argCount++
+	}
+
+	if f.Status != "" {
+		conditions = append(conditions, fmt.Sprintf("t.status::text = $%d", argCount))
+		args = append(args, f.Status)
+		argCount++
+	}
+
+	if f.ActionType != "" {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM task_action_links l
+			JOIN task_actions a ON a.id = l.action_id
+			WHERE l.task_id = t.id AND a.type::text = $%d
+		)`, argCount))
+		args = append(args, f.ActionType)
+		argCount++
+	}
+
+	if f.RewardType != "" {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM task_reward_links l
+			JOIN task_rewards r ON r.id = l.reward_id
+			WHERE l.task_id = t.id AND r.reward_type::text = $%d
+		)`, argCount))
+		args = append(args, f.RewardType)
+		argCount++
+	}
+
+	if f.CreatorID != 0 {
+		conditions = append(conditions, fmt.Sprintf("t.user_id = $%d", argCount))
+		args = append(args, f.CreatorID)
+		argCount++
+	}
+
+	if f.MinReward != nil {
+		conditions = append(conditions, fmt.Sprintf("t.reward_usdt >= $%d", argCount))
+		args = append(args, *f.MinReward)
+		argCount++
+	}
+
+	if f.MaxReward != nil {
+		conditions = append(conditions, fmt.Sprintf("t.reward_usdt <= $%d", argCount))
+		args = append(args, *f.MaxReward)
+		argCount++
+	}
+
+	if f.DueBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("t.due_date < $%d", argCount))
+		args = append(args, *f.DueBefore)
+		argCount++
+	}
+
+	if f.DueAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("t.due_date > $%d", argCount))
+		args = append(args, *f.DueAfter)
+		argCount++
+	}
+
+	if f.Search != "" {
+		conditions = append(conditions, fmt.Sprintf("t.search_vector @@ websearch_to_tsquery('simple', $%d)", argCount))
+		args = append(args, f.Search)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// keyset returns the list ordering for the filter's sort. searchArg is the
+// placeholder number of the search term, which relevance ranks against.
+func (f TaskFilter) keyset(searchArg int) keyset {
+	switch f.Sort {
+	case TaskSortOldest:
+		return keyset{key: "t.created_at", cast: "timestamptz", id: "t.id"}
+	case TaskSortRewardHigh:
+		return keyset{key: "t.reward_usdt", cast: "float8", id: "t.id", desc: true}
+	case TaskSortRewardLow:
+		return keyset{key: "t.reward_usdt", cast: "float8", id: "t.id"}
+	case TaskSortDueSoon:
+		// tasks without a due date come last
+		return keyset{key: "COALESCE(t.due_date, 'infinity'::timestamptz)", cast: "timestamptz", id: "t.id"}
+	case TaskSortRelevance:
+		if f.Search != "" {
+			key := fmt.Sprintf("ts_rank(t.search_vector, websearch_to_tsquery('simple', $%d))::float8", searchArg)
+			return keyset{key: key, cast: "float8", id: "t.id", desc: true}
+		}
+	}
+	return keyset{key: "t.created_at", cast: "timestamptz", id: "t.id", desc: true}
+}
+
+// GetAllTask returns a page of tasks matching the filter, the bounds of that
+// page and the total number of matching tasks.
+func (pg *PostgresTaskStore) GetAllTask(filter TaskFilter) ([]Task, utils.PageBounds, int64, error) {
+	where, args := filter.where()
+
 	cQuery := `
 		SELECT COUNT(*)
-		FROM tasks 
-	`
-	var pageNum int64
-	err := pg.db.QueryRow(cQuery).Scan(&pageNum)
+		FROM tasks t
+	` + where
+	var total int64
+	err := pg.db.QueryRow(cQuery, args...).Scan(&total)
 	if err != nil {
-		return nil, 0, err
+		return nil, utils.PageBounds{}, 0, err
 	}
 
-	query := `
+	// the search term, when present, is always the last filter argument
+	ks := filter.keyset(len(args))
+	cond, orderBy, cursorArgs := ks.clause(filter.Page, len(args)+1)
+	if cond != "" {
+		if where == "" {
+			where = "WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+		args = append(args, cursorArgs...)
+	}
+
+	query := fmt.Sprintf(`
 		SELECT 
-			id, 
-			title, 
-			description, 
-			user_id, 
-			reward_id,
-			reward_usdt, 
-			due_date, 
-			max_participant, 
-			task_image, 
-			action_id 
-		FROM tasks 
-		LIMIT $1 OFFSET $2
-	`
+			t.id, 
+			t.title, 
+			t.description, 
+			t.user_id, 
+			t.reward_usdt, 
+			t.due_date, 
+			t.max_participant, 
+			t.task_image, 
+			t.status,
+			t.recurrence,
+			t.recurrence_timezone,
+			t.eligibility,
+			t.publish_at,
+			t.campaign_id,
+			t.organization_id,
+			%s
+		FROM tasks t
+		%s
+		ORDER BY %s
+		LIMIT $%d
+	`, ks.keyColumn(), where, orderBy, len(args)+1)
+	args = append(args, filter.Page.Limit+1)
 
-	rows, err := pg.db.Query(query, limit, offset)
+	rows, err := pg.db.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, utils.PageBounds{}, 0, err
 	}
+	defer rows.Close()
 
-	var tasks []Task
+	var items []keyed[Task]
 	for rows.Next() {
-		var t Task
+		var item keyed[Task]
+		var dueDate sql.NullTime
+		t := &item.row
 		if err := rows.Scan(&t.ID,
 			&t.Title,
 			&t.Description,
 			&t.UserID,
-			&t.RewardID,
 			&t.RewardUSDT,
-			&t.DueDate,
+			&dueDate,
 			&t.MaxParticipant,
 			&t.TaskImage,
-			&t.ActionID); err != nil {
-			return nil, 0, err
+			&t.Status,
+			&t.Recurrence,
+			&t.RecurrenceTimezone,
+			&t.Eligibility,
+			&t.PublishAt,
+			&t.CampaignID,
+			&t.OrganizationID,
+			&item.key); err != nil {
+			return nil, utils.PageBounds{}, 0, err
 		}
-		tasks = append(tasks, t)
+		t.DueDate = dueDate.Time
+		item.id = int64(t.ID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, 0, err
 	}
-	defer rows.Close()
 
-	return tasks, pageNum, nil
+	tasks, bounds := keysetPage(items, filter.Page)
+
+	refs := make([]*Task, len(tasks))
+	for i := range tasks {
+		refs[i] = &tasks[i]
+	}
+	err = loadTaskLinks(pg.db, refs)
+	if err != nil {
+		return nil, utils.PageBounds{}, 0, err
+	}
+
+	return tasks, bounds, total, nil
 }
 
-func (pg *PostgresTaskStore) EditTask(t *Task) error {
+// EditTask updates the fields set on t. A new image has to be one the task's
+// owner or editorID uploaded.
+func (pg *PostgresTaskStore) EditTask(t *Task, editorID int64) error {
 	var args []interface{}
 	var setClause []string
 	argCount := 1
@@ -207,12 +686,6 @@ func (pg *PostgresTaskStore) EditTask(t *Task) error {
 		argCount++
 	}
 
-	if t.RewardID != 0 {
-		setClause = append(setClause, fmt.Sprintf("reward_id = $%d", argCount))
-		args = append(args, t.RewardID)
-		argCount++
-	}
-
 	if t.RewardUSDT != 0 {
 		setClause = append(setClause, fmt.Sprintf("reward_usdt = $%d", argCount))
 		args = append(args, t.RewardUSDT)
@@ -237,13 +710,31 @@ func (pg *PostgresTaskStore) EditTask(t *Task) error {
 		argCount++
 	}
 
-	if t.ActionID != 0 {
-		setClause = append(setClause, fmt.Sprintf("action_id = $%d", argCount))
-		args = append(args, t.ActionID)
+	if t.Recurrence != "" {
+		setClause = append(setClause, fmt.Sprintf("recurrence = $%d", argCount))
+		args = append(args, t.Recurrence)
+		argCount++
+	}
+
+	if t.RecurrenceTimezone != "" {
+		setClause = append(setClause, fmt.Sprintf("recurrence_timezone = $%d", argCount))
+		args = append(args, t.RecurrenceTimezone)
+		argCount++
+	}
+
+	if t.Eligibility != nil {
+		setClause = append(setClause, fmt.Sprintf("eligibility = $%d", argCount))
+		args = append(args, t.Eligibility)
 		argCount++
 	}
 
-	if len(setClause) == 0 {
+	if t.PublishAt != nil {
+		setClause = append(setClause, fmt.Sprintf("publish_at = $%d", argCount))
+		args = append(args, *t.PublishAt)
+		argCount++
+	}
+
+	if len(setClause) == 0 && t.ActionIDs == nil && t.RewardIDs == nil {
 		return fmt.Errorf("no fields to update for task id %d", t.ID)
 	}
 
@@ -252,42 +743,465 @@ func (pg *PostgresTaskStore) EditTask(t *Task) error {
 		UPDATE tasks
 		SET %s
 		WHERE id = $%d
+		RETURNING user_id
 	`, strings.Join(setClause, ", "), argCount)
 
 	args = append(args, t.ID)
 
-	result, err := pg.db.Exec(query, args...)
+	tx, err := pg.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if t.TaskImage != "" || t.PublishAt != nil {
+		var ownerID int64
+		var status TaskStatus
+		err = tx.QueryRow(`SELECT user_id, status FROM tasks WHERE id = $1 FOR UPDATE`, t.ID).Scan(&ownerID, &status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("task with id %d not found", t.ID)
+		}
+		if err != nil {
+			return err
+		}
+		err = checkTaskImage(tx, t.TaskImage, ownerID, editorID)
+		if err != nil {
+			return err
+		}
+		// rescheduling only makes sense before the task went out
+		if t.PublishAt != nil && status != TaskStatusDraft {
+			return ErrTaskNotDraft
+		}
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	var userID int64
+	err = tx.QueryRow(query, args...).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task with id %d not found", t.ID)
+	}
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("task with id %d not found", t.ID)
+
+	err = replaceTaskLinks(tx, int64(t.ID), t.ActionIDs, t.RewardIDs)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	_, err = enqueueTaskEvent(tx, OutboxTaskUpdated, OutboxTaskData{TaskID: int64(t.ID), UserID: userID}, pg.now())
+	if err != nil {
+		return err
+	}
 
+	return tx.Commit()
 }
 
 func (pg *PostgresTaskStore) DeleteTask(id int64) error {
-	query := `DELETE FROM tasks WHERE id = $1`
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	result, err := pg.db.Exec(query, id)
+	var userID int64
+	err = tx.QueryRow(`DELETE FROM tasks WHERE id = $1 RETURNING user_id`, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task with id %d not found", id)
+	}
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	_, err = enqueueTaskEvent(tx, OutboxTaskDeleted, OutboxTaskData{TaskID: id, UserID: userID}, pg.now())
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("task with id %d not found", id)
+
+	return tx.Commit()
+}
+
+// PublishTask makes a draft visible to everyone right away. It returns
+// sql.ErrNoRows for a missing task and ErrTaskNotDraft once it is published.
+func (pg *PostgresTaskStore) PublishTask(id int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status TaskStatus
+	var userID int64
+	err = tx.QueryRow(`SELECT status, user_id FROM tasks WHERE id = $1 FOR UPDATE`, id).Scan(&status, &userID)
+	if err != nil {
+		return err
+	}
+	if status != TaskStatusDraft {
+		return ErrTaskNotDraft
+	}
+
+	_, err = tx.Exec(`
+		UPDATE tasks
+		SET status = 'ACTIVE', publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = enqueueTaskEvent(tx, OutboxTaskPublished, OutboxTaskData{TaskID: id, UserID: userID}, pg.now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PublishDueTasks activates every draft whose publish_at is not after now and
+// returns their ids.
+func (pg *PostgresTaskStore) PublishDueTasks(now time.Time) ([]int64, error) {
+	// the outbox events are written by the same statement, so they are
+	// committed with the tasks
+	rows, err := pg.db.Query(`
+		WITH published AS (
+			UPDATE tasks
+			SET status = 'ACTIVE', updated_at = CURRENT_TIMESTAMP
+			WHERE status::text = 'DRAFT' AND publish_at <= $1
+			RETURNING id, user_id
+		), events AS (
+			INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload, next_attempt_at, created_at)
+			SELECT $2::varchar, id, $3::varchar, jsonb_build_object('task_id', id, 'user_id', user_id), $1, $1
+			FROM published
+			ORDER BY id
+		)
+		SELECT id FROM published ORDER BY id`, now, AggregateTask, OutboxTaskPublished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CloneTask copies a task with its actions, rewards and eligibility rules
+// into a new unscheduled draft of the same owner, in the same campaign and
+// organization. It returns nil, nil when the task does not exist.
+func (pg *PostgresTaskStore) CloneTask(id int64) (*Task, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var cloneID, userID int64
+	err = tx.QueryRow(`
+		INSERT INTO tasks (
+			title,
+			description,
+			user_id,
+			reward_usdt,
+			due_date,
+			max_participant,
+			task_image,
+			recurrence,
+			recurrence_timezone,
+			eligibility,
+			campaign_id,
+			organization_id,
+			status
+		)
+		SELECT
+			title,
+			description,
+			user_id,
+			reward_usdt,
+			due_date,
+			max_participant,
+			task_image,
+			recurrence,
+			recurrence_timezone,
+			eligibility,
+			campaign_id,
+			organization_id,
+			'DRAFT'
+		FROM tasks
+		WHERE id = $1
+		RETURNING id, user_id`, id).Scan(&cloneID, &userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO task_action_links (task_id, action_id, position)
+		SELECT $2, action_id, position FROM task_action_links WHERE task_id = $1`, id, cloneID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO task_reward_links (task_id, reward_id, position)
+		SELECT $2, reward_id, position FROM task_reward_links WHERE task_id = $1`, id, cloneID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = enqueueTaskEvent(tx, OutboxTaskCreated, OutboxTaskData{TaskID: cloneID, UserID: userID}, pg.now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.GetTaskByID(cloneID)
+}
+
+// replaceTaskLinks sets the task's actions and rewards to the given ids, in
+// order. A nil slice keeps the current links, an empty one removes them all.
+func replaceTaskLinks(tx *sql.Tx, taskID int64, actionIDs, rewardIDs []int) error {
+	if actionIDs != nil {
+		_, err := tx.Exec(`DELETE FROM task_action_links WHERE task_id = $1`, taskID)
+		if err != nil {
+			return err
+		}
+
+		for i, actionID := range actionIDs {
+			var exists bool
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_actions WHERE id = $1)`, actionID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: id %d", ErrActionNotFound, actionID)
+			}
+
+			_, err = tx.Exec(`INSERT INTO task_action_links (task_id, action_id, position) VALUES ($1, $2, $3)`, taskID, actionID, i+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if rewardIDs != nil {
+		_, err := tx.Exec(`DELETE FROM task_reward_links WHERE task_id = $1`, taskID)
+		if err != nil {
+			return err
+		}
+
+		for i, rewardID := range rewardIDs {
+			var exists bool
+			err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_rewards WHERE id = $1)`, rewardID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: id %d", ErrRewardNotFound, rewardID)
+			}
+
+			_, err = tx.Exec(`INSERT INTO task_reward_links (task_id, reward_id, position) VALUES ($1, $2, $3)`, taskID, rewardID, i+1)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
+
+// taskBatchLinks are the actions, rewards and image owners a batch of tasks
+// refers to, by id.
+type taskBatchLinks struct {
+	actions     map[int]ActionTask
+	rewards     map[int]RewardTask
+	imageOwners map[string]int64
+}
+
+// fill sets the task's Actions and Rewards from the links, in order.
+func (l *taskBatchLinks) fill(task *Task) {
+	task.Actions = []ActionTask{}
+	for _, id := range task.ActionIDs {
+		task.Actions = append(task.Actions, l.actions[id])
+	}
+	task.Rewards = []RewardTask{}
+	for _, id := range task.RewardIDs {
+		task.Rewards = append(task.Rewards, l.rewards[id])
+	}
+}
+
+// checkTaskBatchLinks looks up the actions, rewards and images of all tasks
+// with one query each. Every link that does not exist, and every image the
+// task's owner did not upload, is returned in TaskBatchErrors.
+func checkTaskBatchLinks(q dbtx, tasks []*Task) (*taskBatchLinks, error) {
+	var actionIDs, rewardIDs []int64
+	var images []string
+	for _, task := range tasks {
+		for _, id := range task.ActionIDs {
+			actionIDs = append(actionIDs, int64(id))
+		}
+		for _, id := range task.RewardIDs {
+			rewardIDs = append(rewardIDs, int64(id))
+		}
+		if task.TaskImage != "" {
+			images = append(images, task.TaskImage)
+		}
+	}
+
+	links := &taskBatchLinks{
+		actions:     map[int]ActionTask{},
+		rewards:     map[int]RewardTask{},
+		imageOwners: map[string]int64{},
+	}
+
+	if len(actionIDs) > 0 {
+		rows, err := q.Query(`SELECT id, type, COALESCE(name, ''), COALESCE(description, '') FROM task_actions WHERE id = ANY($1)`, actionIDs)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var a ActionTask
+			if err := rows.Scan(&a.ID, &a.Type, &a.Name, &a.Description); err != nil {
+				return nil, err
+			}
+			links.actions[a.ID] = a
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rewardIDs) > 0 {
+		rows, err := q.Query(`SELECT id, reward_type, COALESCE(reward_name, '') FROM task_rewards WHERE id = ANY($1)`, rewardIDs)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r RewardTask
+			if err := rows.Scan(&r.ID, &r.RewardType, &r.RewardName); err != nil {
+				return nil, err
+			}
+			links.rewards[r.ID] = r
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(images) > 0 {
+		rows, err := q.Query(`SELECT id, user_id FROM assets WHERE id = ANY($1)`, images)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			var ownerID int64
+			if err := rows.Scan(&id, &ownerID); err != nil {
+				return nil, err
+			}
+			links.imageOwners[id] = ownerID
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var failed TaskBatchErrors
+	for i, task := range tasks {
+		for _, id := range task.ActionIDs {
+			if _, ok := links.actions[id]; !ok {
+				failed = append(failed, &TaskBatchError{Index: i, Err: fmt.Errorf("%w: id %d", ErrActionNotFound, id)})
+			}
+		}
+		for _, id := range task.RewardIDs {
+			if _, ok := links.rewards[id]; !ok {
+				failed = append(failed, &TaskBatchError{Index: i, Err: fmt.Errorf("%w: id %d", ErrRewardNotFound, id)})
+			}
+		}
+		if ownerID, ok := links.imageOwners[task.TaskImage]; task.TaskImage != "" && (!ok || ownerID != task.UserID) {
+			failed = append(failed, &TaskBatchError{Index: i, Err: ErrImageNotFound})
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed
+	}
+	return links, nil
+}
+
+// loadTaskLinks fills in Actions and Rewards for every task with one query
+// per link table.
+func loadTaskLinks(q dbtx, tasks []*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Task, len(tasks))
+	ids := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		t.Actions = []ActionTask{}
+		t.Rewards = []RewardTask{}
+		byID[int64(t.ID)] = t
+		ids = append(ids, int64(t.ID))
+	}
+
+	query := `
+		SELECT l.task_id, a.id, a.type, COALESCE(a.name, ''), COALESCE(a.description, '')
+		FROM task_action_links l
+		JOIN task_actions a ON a.id = l.action_id
+		WHERE l.task_id = ANY($1)
+		ORDER BY l.task_id, l.position
+	`
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID int64
+		var a ActionTask
+		if err := rows.Scan(&taskID, &a.ID, &a.Type, &a.Name, &a.Description); err != nil {
+			return err
+		}
+		byID[taskID].Actions = append(byID[taskID].Actions, a)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		SELECT l.task_id, r.id, r.reward_type, COALESCE(r.reward_name, '')
+		FROM task_reward_links l
+		JOIN task_rewards r ON r.id = l.reward_id
+		WHERE l.task_id = ANY($1)
+		ORDER BY l.task_id, l.position
+	`
+	rewardRows, err := q.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rewardRows.Close()
+
+	for rewardRows.Next() {
+		var taskID int64
+		var r RewardTask
+		if err := rewardRows.Scan(&taskID, &r.ID, &r.RewardType, &r.RewardName); err != nil {
+			return err
+		}
+		byID[taskID].Rewards = append(byID[taskID].Rewards, r)
+	}
+
+	return rewardRows.Err()
+}
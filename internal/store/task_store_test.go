@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/harundarat/be-socialtask/internal/utils"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,7 +35,7 @@ func TestCreateTask(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	initialUser := &User{
@@ -100,7 +102,7 @@ func TestGetTaskByID(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	// Create initial user for happy path
@@ -161,7 +163,7 @@ func TestGetAllTask(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	user := &User{
@@ -182,21 +184,114 @@ func TestGetAllTask(t *testing.T) {
 		})
 		require.NoError(t, err)
 	}
-	var page, limit int64
-	page, limit = 1, 5
-	offset := (page - 1) * limit
+	var limit int64 = 5
 
-	tasks, totalPage, err := taskStore.GetAllTask(limit, offset)
+	tasks, bounds, total, err := taskStore.GetAllTask(TaskFilter{Page: utils.PageParams{Limit: limit}})
 	require.NoError(t, err)
 	assert.LessOrEqual(t, len(tasks), limit)
-	assert.GreaterOrEqual(t, totalPage, 1)
+	assert.GreaterOrEqual(t, total, 1)
+	assert.True(t, bounds.HasMore)
+
+	t.Run("keyset pages do not overlap", func(t *testing.T) {
+		next := *bounds.Last
+		rest, restBounds, _, err := taskStore.GetAllTask(TaskFilter{Page: utils.PageParams{Limit: limit, Cursor: &next}})
+		require.NoError(t, err)
+		assert.Len(t, rest, 2)
+		assert.False(t, restBounds.HasMore)
+		assert.Equal(t, "Task-2", rest[0].Title)
+
+		prev := *restBounds.First
+		prev.Backward = true
+		back, _, _, err := taskStore.GetAllTask(TaskFilter{Page: utils.PageParams{Limit: limit, Cursor: &prev}})
+		require.NoError(t, err)
+		require.Len(t, back, 5)
+		assert.Equal(t, tasks[0].ID, back[0].ID)
+	})
+}
+
+func TestGetAllTaskFilters(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+
+	user := &User{
+		Username: "test-task-filters",
+		Email:    "test-task-filters@gmail.com",
+	}
+	user.PasswordHash.Set("password123")
+	createdUser, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	due := time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"Follow our X account", "Repost the launch post", "Join the Discord server"} {
+		_, err := taskStore.CreateTask(&Task{
+			Title:       title,
+			Description: "Community campaign",
+			UserID:      createdUser.ID,
+			RewardUSDT:  float64(i + 1),
+			DueDate:     due.AddDate(0, 0, i),
+		})
+		require.NoError(t, err)
+	}
+
+	minReward := 2.0
+	dueBefore := due.AddDate(0, 0, 2)
+
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		titles []string
+	}{
+		{
+			name:   "sort by reward",
+			filter: TaskFilter{Sort: TaskSortRewardHigh},
+			titles: []string{"Join the Discord server", "Repost the launch post", "Follow our X account"},
+		},
+		{
+			name:   "reward range and due date",
+			filter: TaskFilter{MinReward: &minReward, DueBefore: &dueBefore, Sort: TaskSortDueSoon},
+			titles: []string{"Repost the launch post"},
+		},
+		{
+			name:   "full-text search",
+			filter: TaskFilter{Search: "discord", Sort: TaskSortRelevance},
+			titles: []string{"Join the Discord server"},
+		},
+		{
+			name:   "search matches the description",
+			filter: TaskFilter{Search: "campaign -repost", Sort: TaskSortOldest},
+			titles: []string{"Follow our X account", "Join the Discord server"},
+		},
+		{
+			name:   "creator and status",
+			filter: TaskFilter{CreatorID: createdUser.ID, Status: TaskStatusActive},
+			titles: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Page.Limit = 10
+			tasks, _, total, err := taskStore.GetAllTask(tt.filter)
+			require.NoError(t, err)
+
+			var titles []string
+			for _, task := range tasks {
+				titles = append(titles, task.Title)
+			}
+			assert.Equal(t, tt.titles, titles)
+			assert.Equal(t, int64(len(tt.titles)), total)
+		})
+	}
 }
 
 func TestUpdateTask(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	user := &User{
@@ -218,7 +313,7 @@ func TestUpdateTask(t *testing.T) {
 
 	task.Title = "After Update"
 	task.Description = "Updated desc"
-	err = taskStore.EditTask(task)
+	err = taskStore.EditTask(task, createdUser.ID)
 	require.NoError(t, err)
 
 	updated, err := taskStore.GetTaskByID(int64(task.ID))
@@ -231,7 +326,7 @@ func TestDeleteTask(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	user := &User{
@@ -258,3 +353,270 @@ func TestDeleteTask(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, deleted)
 }
+
+func TestTaskLinks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+	actionStore := NewPostgresTaskActionStore(db)
+	rewardStore := NewPostgresTaskRewardStore(db)
+
+	user := &User{
+		Username: "test-task-links",
+		Email:    "test-task-links@gmail.com",
+	}
+	user.PasswordHash.Set("password123")
+	createdUser, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	follow, err := actionStore.CreateAction(&ActionTask{Type: Type1, Name: "Follow"})
+	require.NoError(t, err)
+	repost, err := actionStore.CreateAction(&ActionTask{Type: Type2, Name: "Repost"})
+	require.NoError(t, err)
+	usdt, err := rewardStore.CreateReward(&RewardTask{RewardType: CryptoUsdt1, RewardName: "USDT"})
+	require.NoError(t, err)
+
+	task, err := taskStore.CreateTask(&Task{
+		Title:     "Follow and repost",
+		UserID:    createdUser.ID,
+		ActionIDs: []int{*repost, *follow},
+		RewardIDs: []int{*usdt},
+	})
+	require.NoError(t, err)
+	require.Len(t, task.Actions, 2)
+	assert.Equal(t, *repost, task.Actions[0].ID)
+	assert.Equal(t, *follow, task.Actions[1].ID)
+
+	t.Run("links are returned in order", func(t *testing.T) {
+		retrieved, err := taskStore.GetTaskByID(int64(task.ID))
+		require.NoError(t, err)
+		require.Len(t, retrieved.Actions, 2)
+		assert.Equal(t, "Repost", retrieved.Actions[0].Name)
+		require.Len(t, retrieved.Rewards, 1)
+		assert.Equal(t, CryptoUsdt1, retrieved.Rewards[0].RewardType)
+	})
+
+	t.Run("edit replaces only the given links", func(t *testing.T) {
+		err := taskStore.EditTask(&Task{ID: task.ID, ActionIDs: []int{*follow}}, createdUser.ID)
+		require.NoError(t, err)
+
+		retrieved, err := taskStore.GetTaskByID(int64(task.ID))
+		require.NoError(t, err)
+		require.Len(t, retrieved.Actions, 1)
+		assert.Equal(t, *follow, retrieved.Actions[0].ID)
+		assert.Len(t, retrieved.Rewards, 1)
+	})
+
+	t.Run("unknown ids are rejected", func(t *testing.T) {
+		err := taskStore.EditTask(&Task{ID: task.ID, RewardIDs: []int{99999}}, createdUser.ID)
+		assert.ErrorIs(t, err, ErrRewardNotFound)
+	})
+}
+
+func TestGetTaskDetail(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+
+	creator := &User{Username: "test-detail-creator", Email: "test-detail-creator@gmail.com"}
+	creator.PasswordHash.Set("password123")
+	creator, err := userStore.CreateUser(creator)
+	require.NoError(t, err)
+
+	viewer := &User{Username: "test-detail-viewer", Email: "test-detail-viewer@gmail.com"}
+	viewer.PasswordHash.Set("password123")
+	viewer, err = userStore.CreateUser(viewer)
+	require.NoError(t, err)
+
+	task, err := taskStore.CreateTask(&Task{
+		Title:   "Detail",
+		UserID:  creator.ID,
+		DueDate: now.AddDate(0, 1, 0),
+	})
+	require.NoError(t, err)
+
+	_, _, err = participationStore.Join(int64(task.ID), viewer.ID)
+	require.NoError(t, err)
+
+	t.Run("viewer sees their own participation", func(t *testing.T) {
+		detail, err := taskStore.GetTaskDetail(int64(task.ID), viewer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Detail", detail.Title)
+		assert.Equal(t, creator.Username, detail.Creator.Username)
+		assert.Equal(t, int64(1), detail.ParticipantCount)
+		require.NotNil(t, detail.MyParticipation)
+		assert.Equal(t, ParticipationJoined, detail.MyParticipation.Status)
+	})
+
+	t.Run("anonymous viewer has no participation", func(t *testing.T) {
+		detail, err := taskStore.GetTaskDetail(int64(task.ID), 0)
+		require.NoError(t, err)
+		assert.Nil(t, detail.MyParticipation)
+	})
+
+	t.Run("missing task", func(t *testing.T) {
+		detail, err := taskStore.GetTaskDetail(99999, viewer.ID)
+		require.NoError(t, err)
+		assert.Nil(t, detail)
+	})
+}
+
+func TestTaskDrafts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	actionStore := NewPostgresTaskActionStore(db)
+
+	owner := &User{Username: "test-draft-owner", Email: "test-draft-owner@gmail.com"}
+	owner.PasswordHash.Set("password123")
+	owner, err := userStore.CreateUser(owner)
+	require.NoError(t, err)
+
+	other := &User{Username: "test-draft-other", Email: "test-draft-other@gmail.com"}
+	other.PasswordHash.Set("password123")
+	other, err = userStore.CreateUser(other)
+	require.NoError(t, err)
+
+	follow, err := actionStore.CreateAction(&ActionTask{Type: Type1, Name: "Follow"})
+	require.NoError(t, err)
+
+	publishAt := now.Add(time.Hour)
+	draft, err := taskStore.CreateTask(&Task{
+		Title:       "Scheduled",
+		UserID:      owner.ID,
+		DueDate:     now.AddDate(0, 1, 0),
+		ActionIDs:   []int{*follow},
+		Eligibility: EligibilityRules{{Kind: EligibilityAllowlist, UserIDs: []int64{other.ID}}},
+		PublishAt:   &publishAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, TaskStatusDraft, draft.Status)
+
+	t.Run("drafts are only listed for their owner", func(t *testing.T) {
+		page := utils.PageParams{Limit: 10, Sort: string(TaskSortNewest)}
+
+		tasks, _, _, err := taskStore.GetAllTask(TaskFilter{Sort: TaskSortNewest, Page: page, ViewerID: other.ID})
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+
+		tasks, _, _, err = taskStore.GetAllTask(TaskFilter{Sort: TaskSortNewest, Page: page, ViewerID: owner.ID})
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, publishAt.Unix(), tasks[0].PublishAt.Unix())
+	})
+
+	t.Run("drafts can not be joined", func(t *testing.T) {
+		_, _, err := participationStore.Join(int64(draft.ID), other.ID)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("clone copies links and rules into an unscheduled draft", func(t *testing.T) {
+		clone, err := taskStore.CloneTask(int64(draft.ID))
+		require.NoError(t, err)
+		assert.NotEqual(t, draft.ID, clone.ID)
+		assert.Equal(t, "Scheduled", clone.Title)
+		assert.Equal(t, TaskStatusDraft, clone.Status)
+		assert.Nil(t, clone.PublishAt)
+		require.Len(t, clone.Actions, 1)
+		assert.Equal(t, *follow, clone.Actions[0].ID)
+		assert.Equal(t, draft.Eligibility, clone.Eligibility)
+
+		missing, err := taskStore.CloneTask(99999)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("the scheduler publishes due drafts only", func(t *testing.T) {
+		ids, err := taskStore.PublishDueTasks(now)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		ids, err = taskStore.PublishDueTasks(publishAt)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{int64(draft.ID)}, ids)
+
+		published, err := taskStore.GetTaskByID(int64(draft.ID))
+		require.NoError(t, err)
+		assert.Equal(t, TaskStatusActive, published.Status)
+	})
+
+	t.Run("published tasks can not be published or rescheduled", func(t *testing.T) {
+		assert.Equal(t, ErrTaskNotDraft, taskStore.PublishTask(int64(draft.ID)))
+
+		later := publishAt.Add(time.Hour)
+		assert.Equal(t, ErrTaskNotDraft, taskStore.EditTask(&Task{ID: draft.ID, PublishAt: &later}, owner.ID))
+	})
+}
+
+func TestCreateTasks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	taskStore := NewPostgresTaskStore(db, time.Now)
+	userStore := NewPostgresUserStore(db)
+
+	user := &User{Username: "test-create-tasks", Email: "test-create-tasks@gmail.com"}
+	user.PasswordHash.Set("password123")
+	user, err := userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	countTasks := func() int {
+		var count int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1`, user.ID).Scan(&count))
+		return count
+	}
+
+	t.Run("a dry run creates nothing", func(t *testing.T) {
+		tasks, err := taskStore.CreateTasks([]*Task{
+			{Title: "First", UserID: user.ID},
+			{Title: "Second", UserID: user.ID, Status: TaskStatusDraft},
+		}, true)
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+		assert.Zero(t, tasks[0].ID)
+		assert.Equal(t, TaskStatusPending, tasks[0].Status)
+		assert.Equal(t, TaskStatusDraft, tasks[1].Status)
+		assert.Zero(t, countTasks())
+	})
+
+	t.Run("every task with a missing link fails", func(t *testing.T) {
+		for _, dryRun := range []bool{true, false} {
+			_, err := taskStore.CreateTasks([]*Task{
+				{Title: "First", UserID: user.ID},
+				{Title: "Second", UserID: user.ID, ActionIDs: []int{999999}},
+				{Title: "Third", UserID: user.ID, RewardIDs: []int{999999}, TaskImage: "missing"},
+			}, dryRun)
+
+			var batchErrs TaskBatchErrors
+			require.ErrorAs(t, err, &batchErrs)
+			require.Len(t, batchErrs, 3)
+			assert.Equal(t, 1, batchErrs[0].Index)
+			assert.ErrorIs(t, batchErrs[0], ErrActionNotFound)
+			assert.Equal(t, 2, batchErrs[1].Index)
+			assert.ErrorIs(t, batchErrs[1], ErrRewardNotFound)
+			assert.ErrorIs(t, batchErrs[2], ErrImageNotFound)
+			assert.Zero(t, countTasks())
+		}
+	})
+
+	t.Run("every task is created", func(t *testing.T) {
+		tasks, err := taskStore.CreateTasks([]*Task{
+			{Title: "First", UserID: user.ID},
+			{Title: "Second", UserID: user.ID},
+		}, false)
+		require.NoError(t, err)
+		assert.NotZero(t, tasks[0].ID)
+		assert.NotZero(t, tasks[1].ID)
+		assert.Equal(t, 2, countTasks())
+	})
+}
@@ -0,0 +1,509 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// MaxTemplateValueSize bounds a single placeholder value, in characters.
+const MaxTemplateValueSize = 280
+
+// placeholderPattern matches a {{variable}} placeholder, names are snake_case.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z][a-z0-9_]*)\s*\}\}`)
+
+var (
+	xHandlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	hashtagPattern = regexp.MustCompile(`^\w{1,100}$`)
+)
+
+var ErrTemplateActionKind = errors.New("action_ids must only reference actions of a known kind")
+
+// TemplateScope narrows a template list down to system or own templates.
+type TemplateScope string
+
+const (
+	TemplateScopeAll    TemplateScope = ""
+	TemplateScopeSystem TemplateScope = "system"
+	TemplateScopeMine   TemplateScope = "mine"
+)
+
+// TemplateLinks are the action or reward ids a task created from the template
+// links to, in order.
+type TemplateLinks []int
+
+func (l TemplateLinks) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *TemplateLinks) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into TemplateLinks", src)
+	}
+}
+
+// TaskTemplate is a reusable starting point for tasks. Title and description
+// may hold {{variable}} placeholders that are filled in by Render.
+type TaskTemplate struct {
+	ID int64 `json:"id"`
+	// UserID is the creator who saved the template, nil for system templates.
+	UserID             *int64           `json:"user_id"`
+	System             bool             `json:"system"`
+	Name               string           `json:"name"`
+	Title              string           `json:"title"`
+	Description        string           `json:"description"`
+	ActionIDs          TemplateLinks    `json:"action_ids"`
+	RewardIDs          TemplateLinks    `json:"reward_ids"`
+	RewardUSDT         float64          `json:"reward_usdt"`
+	MaxParticipant     string           `json:"max_participant"`
+	Recurrence         Recurrence       `json:"recurrence"`
+	RecurrenceTimezone string           `json:"recurrence_timezone"`
+	Eligibility        EligibilityRules `json:"eligibility"`
+	// Variables lists the placeholders used, filled in when reading.
+	Variables []string  `json:"variables"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// templateVariables returns the distinct placeholder names in the texts, sorted.
+func templateVariables(texts ...string) []string {
+	seen := map[string]bool{}
+	vars := []string{}
+	for _, text := range texts {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				vars = append(vars, m[1])
+			}
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// checkPlaceholders reports braces that are not part of a valid placeholder.
+func checkPlaceholders(field, text string) error {
+	rest := placeholderPattern.ReplaceAllString(text, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return fmt.Errorf("%s has a malformed placeholder, use {{name}} with a lowercase snake_case name", field)
+	}
+	return nil
+}
+
+func checkTemplateLinks(field string, ids TemplateLinks) error {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return fmt.Errorf("%s must only contain valid ids", field)
+		}
+		if seen[id] {
+			return fmt.Errorf("%s must not contain the same id twice", field)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// Validate reports the first problem with the template's fields. Whether the
+// linked actions and rewards exist is checked when the template is saved.
+func (t *TaskTemplate) Validate() error {
+	if strings.TrimSpace(t.Name) == "" || utf8.RuneCountInString(t.Name) > 100 {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+	if strings.TrimSpace(t.Title) == "" || utf8.RuneCountInString(t.Title) > 255 {
+		return errors.New("title is required and must be at most 255 characters")
+	}
+	if err := checkPlaceholders("title", t.Title); err != nil {
+		return err
+	}
+	if err := checkPlaceholders("description", t.Description); err != nil {
+		return err
+	}
+	if err := checkTemplateLinks("action_ids", t.ActionIDs); err != nil {
+		return err
+	}
+	if err := checkTemplateLinks("reward_ids", t.RewardIDs); err != nil {
+		return err
+	}
+	if t.RewardUSDT < 0 {
+		return errors.New("reward_usdt must not be negative")
+	}
+	switch t.Recurrence {
+	case "", RecurrenceNone, RecurrenceDaily, RecurrenceWeekly:
+	default:
+		return errors.New("recurrence must be one of none, daily or weekly")
+	}
+	if t.RecurrenceTimezone != "" {
+		if _, err := time.LoadLocation(t.RecurrenceTimezone); err != nil {
+			return errors.New("recurrence_timezone must be a valid IANA timezone")
+		}
+	}
+	return t.Eligibility.Validate()
+}
+
+// normalizeTemplateValue checks a placeholder value. handle, hashtag and
+// tweet_url have a fixed shape, a leading @ or # is dropped since the
+// template writes those itself. Other variables take any short text.
+func normalizeTemplateValue(name, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("variables.%s must not be empty", name)
+	}
+	if utf8.RuneCountInString(value) > MaxTemplateValueSize {
+		return "", fmt.Errorf("variables.%s must be at most %d characters", name, MaxTemplateValueSize)
+	}
+
+	switch name {
+	case "handle":
+		value = strings.TrimPrefix(value, "@")
+		if !xHandlePattern.MatchString(value) {
+			return "", errors.New("variables.handle must be an X handle")
+		}
+	case "hashtag":
+		value = strings.TrimPrefix(value, "#")
+		if !hashtagPattern.MatchString(value) {
+			return "", errors.New("variables.hashtag must be a single hashtag")
+		}
+	case "tweet_url":
+		if !isHTTPURL(value) {
+			return "", errors.New("variables.tweet_url must be an http or https URL")
+		}
+	}
+	return value, nil
+}
+
+// Render fills in the placeholders and returns the task to create. Every
+// variable the template uses needs a value and unknown ones are rejected.
+func (t *TaskTemplate) Render(values map[string]string) (*Task, error) {
+	used := templateVariables(t.Title, t.Description)
+
+	var missing []string
+	filled := make(map[string]string, len(used))
+	for _, name := range used {
+		value, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		normalized, err := normalizeTemplateValue(name, value)
+		if err != nil {
+			return nil, err
+		}
+		filled[name] = normalized
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("variables is missing %s", strings.Join(missing, ", "))
+	}
+	for name := range values {
+		if _, ok := filled[name]; !ok {
+			return nil, fmt.Errorf("variables.%s is not used by this template", name)
+		}
+	}
+
+	render := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+			return filled[placeholderPattern.FindStringSubmatch(p)[1]]
+		})
+	}
+
+	task := &Task{
+		Title:              render(t.Title),
+		Description:        render(t.Description),
+		RewardUSDT:         t.RewardUSDT,
+		MaxParticipant:     t.MaxParticipant,
+		Recurrence:         t.Recurrence,
+		RecurrenceTimezone: t.RecurrenceTimezone,
+		Eligibility:        append(EligibilityRules{}, t.Eligibility...),
+		ActionIDs:          append([]int{}, t.ActionIDs...),
+		RewardIDs:          append([]int{}, t.RewardIDs...),
+	}
+	if utf8.RuneCountInString(task.Title) > 255 {
+		return nil, errors.New("title is longer than 255 characters once the variables are filled in")
+	}
+	return task, nil
+}
+
+type PostgresTaskTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTaskTemplateStore(db *sql.DB) *PostgresTaskTemplateStore {
+	return &PostgresTaskTemplateStore{db: db}
+}
+
+type TaskTemplateStore interface {
+	CreateTemplate(t *TaskTemplate) (*TaskTemplate, error)
+	GetTemplateByID(id int64) (*TaskTemplate, error)
+	GetTemplates(userID int64, scope TemplateScope, page utils.PageParams) ([]TaskTemplate, utils.PageBounds, error)
+	UpdateTemplate(t *TaskTemplate) error
+	DeleteTemplate(id int64) error
+}
+
+// checkTemplateActions makes sure every linked action exists and is of a
+// known kind, and every linked reward exists.
+func checkTemplateActions(q dbtx, t *TaskTemplate) error {
+	for _, id := range t.ActionIDs {
+		// the type column is nullable, actions without one have no kind
+		var kind sql.NullString
+		err := q.QueryRow(`SELECT type FROM task_actions WHERE id = $1`, id).Scan(&kind)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrActionNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		if !TypeAction(kind.String).IsValid() {
+			return fmt.Errorf("%w: action %d has none", ErrTemplateActionKind, id)
+		}
+	}
+
+	for _, id := range t.RewardIDs {
+		var exists bool
+		err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_rewards WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %d", ErrRewardNotFound, id)
+		}
+	}
+
+	return nil
+}
+
+func (t *TaskTemplate) setDefaults() {
+	if t.Recurrence == "" {
+		t.Recurrence = RecurrenceNone
+	}
+	if t.RecurrenceTimezone == "" {
+		t.RecurrenceTimezone = "UTC"
+	}
+	if t.ActionIDs == nil {
+		t.ActionIDs = TemplateLinks{}
+	}
+	if t.RewardIDs == nil {
+		t.RewardIDs = TemplateLinks{}
+	}
+	if t.Eligibility == nil {
+		t.Eligibility = EligibilityRules{}
+	}
+	t.System = t.UserID == nil
+	t.Variables = templateVariables(t.Title, t.Description)
+}
+
+// CreateTemplate saves a creator's template, or a system template when
+// UserID is nil.
+func (pg *PostgresTaskTemplateStore) CreateTemplate(t *TaskTemplate) (*TaskTemplate, error) {
+	t.setDefaults()
+
+	err := checkTemplateActions(pg.db, t)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO task_templates (
+			user_id,
+			name,
+			title,
+			description,
+			action_ids,
+			reward_ids,
+			reward_usdt,
+			max_participant,
+			recurrence,
+			recurrence_timezone,
+			eligibility
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`
+	err = pg.db.QueryRow(query, t.UserID, t.Name, t.Title, t.Description, t.ActionIDs, t.RewardIDs,
+		t.RewardUSDT, t.MaxParticipant, t.Recurrence, t.RecurrenceTimezone, t.Eligibility).
+		Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+const templateColumns = `
+	id,
+	user_id,
+	name,
+	title,
+	description,
+	action_ids,
+	reward_ids,
+	reward_usdt,
+	max_participant,
+	recurrence,
+	recurrence_timezone,
+	eligibility,
+	created_at,
+	updated_at
+`
+
+func scanTemplate(row interface{ Scan(dest ...any) error }) (*TaskTemplate, error) {
+	var t TaskTemplate
+	var userID sql.NullInt64
+	err := row.Scan(
+		&t.ID,
+		&userID,
+		&t.Name,
+		&t.Title,
+		&t.Description,
+		&t.ActionIDs,
+		&t.RewardIDs,
+		&t.RewardUSDT,
+		&t.MaxParticipant,
+		&t.Recurrence,
+		&t.RecurrenceTimezone,
+		&t.Eligibility,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		t.UserID = &userID.Int64
+	}
+	t.setDefaults()
+	return &t, nil
+}
+
+func (pg *PostgresTaskTemplateStore) GetTemplateByID(id int64) (*TaskTemplate, error) {
+	t, err := scanTemplate(pg.db.QueryRow(`SELECT `+templateColumns+` FROM task_templates WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// GetTemplates returns a page of the templates the user can use, the system
+// ones and their own, in the order they were created.
+func (pg *PostgresTaskTemplateStore) GetTemplates(userID int64, scope TemplateScope, page utils.PageParams) ([]TaskTemplate, utils.PageBounds, error) {
+	var where string
+	var args []any
+	switch scope {
+	case TemplateScopeSystem:
+		where = "user_id IS NULL"
+	case TemplateScopeMine:
+		where = "user_id = $1"
+		args = append(args, userID)
+	default:
+		where = "(user_id IS NULL OR user_id = $1)"
+		args = append(args, userID)
+	}
+
+	ks := keyset{id: "id"}
+	cond, orderBy, cursorArgs := ks.clause(page, len(args)+1)
+	if cond != "" {
+		where += " AND " + cond
+		args = append(args, cursorArgs...)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM task_templates
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+	`, templateColumns, where, orderBy, len(args)+1)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[TaskTemplate]
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[TaskTemplate]{row: *t, id: t.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	templates, bounds := keysetPage(items, page)
+	return templates, bounds, nil
+}
+
+// UpdateTemplate replaces every editable field of the template.
+func (pg *PostgresTaskTemplateStore) UpdateTemplate(t *TaskTemplate) error {
+	t.setDefaults()
+
+	err := checkTemplateActions(pg.db, t)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE task_templates
+		SET name = $1,
+			title = $2,
+			description = $3,
+			action_ids = $4,
+			reward_ids = $5,
+			reward_usdt = $6,
+			max_participant = $7,
+			recurrence = $8,
+			recurrence_timezone = $9,
+			eligibility = $10,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $11
+		RETURNING updated_at
+	`
+	err = pg.db.QueryRow(query, t.Name, t.Title, t.Description, t.ActionIDs, t.RewardIDs, t.RewardUSDT,
+		t.MaxParticipant, t.Recurrence, t.RecurrenceTimezone, t.Eligibility, t.ID).Scan(&t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("template with id %d not found", t.ID)
+	}
+	return err
+}
+
+func (pg *PostgresTaskTemplateStore) DeleteTemplate(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM task_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("template with id %d not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBTemplate(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("DELETE FROM task_templates WHERE user_id IS NOT NULL")
+	require.NoError(t, err, "clearing saved templates")
+	_, err = db.Exec("TRUNCATE task_actions, task_rewards, tasks, users CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestTemplateValidate(t *testing.T) {
+	valid := TaskTemplate{
+		Name:        "Follow",
+		Title:       "Follow @{{handle}}",
+		Description: "Repost {{ tweet_url }} with #{{hashtag}}",
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		mutate func(*TaskTemplate)
+	}{
+		{"missing name", func(t *TaskTemplate) { t.Name = " " }},
+		{"missing title", func(t *TaskTemplate) { t.Title = "" }},
+		{"unclosed placeholder", func(t *TaskTemplate) { t.Title = "Follow @{{handle" }},
+		{"uppercase placeholder", func(t *TaskTemplate) { t.Description = "Repost {{TweetURL}}" }},
+		{"duplicate action", func(t *TaskTemplate) { t.ActionIDs = TemplateLinks{1, 1} }},
+		{"invalid reward id", func(t *TaskTemplate) { t.RewardIDs = TemplateLinks{0} }},
+		{"negative reward", func(t *TaskTemplate) { t.RewardUSDT = -1 }},
+		{"unknown recurrence", func(t *TaskTemplate) { t.Recurrence = "monthly" }},
+		{"unknown timezone", func(t *TaskTemplate) { t.RecurrenceTimezone = "Mars/Olympus" }},
+		{"bad eligibility", func(t *TaskTemplate) { t.Eligibility = EligibilityRules{{Kind: "karma"}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := valid
+			tt.mutate(&template)
+			assert.Error(t, template.Validate())
+		})
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	template := TaskTemplate{
+		Title:              "Follow @{{handle}} and reply with #{{hashtag}}",
+		Description:        "Repost {{tweet_url}}, then reply with #{{ hashtag }}",
+		ActionIDs:          TemplateLinks{2, 1},
+		RewardUSDT:         5,
+		Recurrence:         RecurrenceDaily,
+		RecurrenceTimezone: "Asia/Jakarta",
+	}
+	values := map[string]string{
+		"handle":    "@socialtask",
+		"hashtag":   "#launch",
+		"tweet_url": "https://x.com/socialtask/status/1",
+	}
+
+	t.Run("fills in every placeholder", func(t *testing.T) {
+		task, err := template.Render(values)
+		require.NoError(t, err)
+		assert.Equal(t, "Follow @socialtask and reply with #launch", task.Title)
+		assert.Equal(t, "Repost https://x.com/socialtask/status/1, then reply with #launch", task.Description)
+		assert.Equal(t, []int{2, 1}, task.ActionIDs)
+		assert.Equal(t, 5.0, task.RewardUSDT)
+		assert.Equal(t, RecurrenceDaily, task.Recurrence)
+	})
+
+	t.Run("variables are listed once", func(t *testing.T) {
+		assert.Equal(t, []string{"handle", "hashtag", "tweet_url"}, templateVariables(template.Title, template.Description))
+	})
+
+	invalid := map[string]map[string]string{
+		"missing variable": {"handle": "socialtask", "hashtag": "launch"},
+		"unknown variable": {"handle": "socialtask", "hashtag": "launch", "tweet_url": "https://x.com/a", "emoji": "🚀"},
+		"empty value":      {"handle": " ", "hashtag": "launch", "tweet_url": "https://x.com/a"},
+		"bad handle":       {"handle": "social task", "hashtag": "launch", "tweet_url": "https://x.com/a"},
+		"bad hashtag":      {"handle": "socialtask", "hashtag": "two tags", "tweet_url": "https://x.com/a"},
+		"bad url":          {"handle": "socialtask", "hashtag": "launch", "tweet_url": "javascript:alert(1)"},
+	}
+	for name, values := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := template.Render(values)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTaskTemplateStore(t *testing.T) {
+	db := setupTestDBTemplate(t)
+	defer db.Close()
+
+	templateStore := NewPostgresTaskTemplateStore(db)
+	userStore := NewPostgresUserStore(db)
+	actionStore := NewPostgresTaskActionStore(db)
+
+	creator := &User{Username: "test-template-creator", Email: "test-template-creator@gmail.com"}
+	creator.PasswordHash.Set("password123")
+	creator, err := userStore.CreateUser(creator)
+	require.NoError(t, err)
+
+	other := &User{Username: "test-template-other", Email: "test-template-other@gmail.com"}
+	other.PasswordHash.Set("password123")
+	other, err = userStore.CreateUser(other)
+	require.NoError(t, err)
+
+	follow, err := actionStore.CreateAction(&ActionTask{Type: Type1, Name: "Follow"})
+	require.NoError(t, err)
+
+	saved, err := templateStore.CreateTemplate(&TaskTemplate{
+		UserID:    &creator.ID,
+		Name:      "My follow",
+		Title:     "Follow @{{handle}}",
+		ActionIDs: TemplateLinks{*follow},
+	})
+	require.NoError(t, err)
+	assert.False(t, saved.System)
+	assert.Equal(t, []string{"handle"}, saved.Variables)
+
+	page := utils.PageParams{Limit: 50, Sort: "id"}
+
+	t.Run("system templates are seeded", func(t *testing.T) {
+		templates, _, err := templateStore.GetTemplates(other.ID, TemplateScopeSystem, page)
+		require.NoError(t, err)
+		require.NotEmpty(t, templates)
+		for _, template := range templates {
+			assert.True(t, template.System)
+			assert.Nil(t, template.UserID)
+		}
+	})
+
+	t.Run("saved templates are only listed for their creator", func(t *testing.T) {
+		templates, _, err := templateStore.GetTemplates(creator.ID, TemplateScopeMine, page)
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		assert.Equal(t, saved.ID, templates[0].ID)
+
+		templates, _, err = templateStore.GetTemplates(other.ID, TemplateScopeAll, page)
+		require.NoError(t, err)
+		for _, template := range templates {
+			assert.NotEqual(t, saved.ID, template.ID)
+		}
+	})
+
+	t.Run("unknown actions are rejected", func(t *testing.T) {
+		_, err := templateStore.CreateTemplate(&TaskTemplate{UserID: &creator.ID, Name: "Broken", Title: "Broken", ActionIDs: TemplateLinks{99999}})
+		assert.ErrorIs(t, err, ErrActionNotFound)
+
+		var untyped int
+		err = db.QueryRow(`INSERT INTO task_actions (name) VALUES ('Untyped') RETURNING id`).Scan(&untyped)
+		require.NoError(t, err)
+		_, err = templateStore.CreateTemplate(&TaskTemplate{UserID: &creator.ID, Name: "Odd", Title: "Odd", ActionIDs: TemplateLinks{untyped}})
+		assert.ErrorIs(t, err, ErrTemplateActionKind)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		saved.Title = "Follow @{{handle}} today"
+		saved.ActionIDs = TemplateLinks{}
+		require.NoError(t, templateStore.UpdateTemplate(saved))
+
+		retrieved, err := templateStore.GetTemplateByID(saved.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Follow @{{handle}} today", retrieved.Title)
+		assert.Empty(t, retrieved.ActionIDs)
+
+		require.NoError(t, templateStore.DeleteTemplate(saved.ID))
+		retrieved, err = templateStore.GetTemplateByID(saved.ID)
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+}
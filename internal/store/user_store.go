@@ -50,6 +50,7 @@ type User struct {
 	Fullname      sql.NullString `json:"fullname"`
 	WalletAddress sql.NullString `json:"wallet_address"`
 	XID           sql.NullString `json:"x_id"`
+	Role          string         `json:"role"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 }
@@ -60,6 +61,26 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+const (
+	UserRoleUser      = "user"
+	UserRoleModerator = "moderator"
+	UserRoleAdmin     = "admin"
+)
+
+func IsValidUserRole(role string) bool {
+	return role == UserRoleUser || role == UserRoleModerator || role == UserRoleAdmin
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// IsModerator reports whether the user may review any task's proofs, admins
+// included.
+func (u *User) IsModerator() bool {
+	return u.Role == UserRoleModerator || u.IsAdmin()
+}
+
 type PostgresUserStore struct {
 	db *sql.DB
 }
@@ -75,8 +96,20 @@ type UserStore interface {
 	GetUserByEmail(string) (*User, error)
 	UpdateUser(*User) error
 	GetUserByID(int64) (*User, error)
-	GetUserTasks(userID int64) (*[]Task, error)
+	GetUserTasks(userID int64, page utils.PageParams) ([]Task, utils.PageBounds, error)
 	FindEmailForGoogle(userID, email, username string) (*User, error)
+	UpdateXProfile(userID int64, profile XProfile) error
+	MarkEmailVerified(userID int64) error
+	SetUserRole(userID int64, role string, actorID int64) error
+}
+
+// XProfile is the part of a user's X account that eligibility rules check,
+// and the handle exports show.
+type XProfile struct {
+	ID        string
+	Username  string
+	CreatedAt time.Time
+	Followers int64
 }
 
 func (s *PostgresUserStore) CreateUser(user *User) (*User, error) {
@@ -143,6 +176,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 			bio, 
 			fullname,
 			wallet_address,
+			role,
 			created_at, 
 			updated_at
 		FROM users
@@ -157,6 +191,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 		&user.Bio,
 		&user.Fullname,
 		&user.WalletAddress,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -184,6 +219,7 @@ func (s *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 			bio,
 			fullname,
 			wallet_address,
+			role,
 			created_at,
 			updated_at
 		FROM users
@@ -198,6 +234,7 @@ func (s *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 		&user.Bio,
 		&user.Fullname,
 		&user.WalletAddress,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -211,37 +248,51 @@ func (s *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 	return user, nil
 }
 
-func (s *PostgresUserStore) GetUserTasks(userID int64) (*[]Task, error) {
-	var tasks []Task
+// GetUserTasks returns a page of the tasks the user published, newest first.
+// Drafts are left out, owners see them in the task list.
+func (s *PostgresUserStore) GetUserTasks(userID int64, page utils.PageParams) ([]Task, utils.PageBounds, error) {
+	ks := keyset{key: "created_at", cast: "timestamptz", id: "id", desc: true}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
 
-	query := `
-	SELECT id, user_id, title, description, reward_usdt, created_at, updated_at
+	query := fmt.Sprintf(`
+	SELECT id, user_id, title, description, reward_usdt, created_at, updated_at, %s
 	FROM tasks
-	WHERE user_id = $1
-	`
+	WHERE user_id = $1 AND status::text <> 'DRAFT' %s
+	ORDER BY %s
+	LIMIT $%d
+	`, ks.keyColumn(), cond, orderBy, len(args)+2)
+
+	args = append([]any{userID}, args...)
+	args = append(args, page.Limit+1)
 
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, utils.PageBounds{}, err
 	}
 	defer rows.Close()
 
+	var items []keyed[Task]
 	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.RewardUSDT, &task.CreatedAt, &task.UpdatedAt)
+		var item keyed[Task]
+		task := &item.row
+		err := rows.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.RewardUSDT, &task.CreatedAt, &task.UpdatedAt, &item.key)
 		if err != nil {
-			return nil, err
+			return nil, utils.PageBounds{}, err
 		}
-		tasks = append(tasks, task)
+		item.id = int64(task.ID)
+		items = append(items, item)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, err
+		return nil, utils.PageBounds{}, err
 	}
 
-	return &tasks, nil
-
+	tasks, bounds := keysetPage(items, page)
+	return tasks, bounds, nil
 }
 
 func (s *PostgresUserStore) FindEmailForGoogle(userID, email, username string) (*User, error) {
@@ -284,3 +335,75 @@ func (s *PostgresUserStore) FindEmailForGoogle(userID, email, username string) (
 
 	return &u, nil
 }
+
+// UpdateXProfile links the X account to the user and refreshes its handle,
+// age and follower count, it runs on every X login.
+func (s *PostgresUserStore) UpdateXProfile(userID int64, profile XProfile) error {
+	query := `
+		UPDATE users
+		SET x_id = $1, x_account_created_at = $2, x_followers_count = $3, x_username = $4, updated_at = NOW()
+		WHERE id = $5
+	`
+
+	result, err := s.db.Exec(query, profile.ID, profile.CreatedAt, profile.Followers, profile.Username, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that a login provider confirmed the user's
+// email, keeping the time it was first confirmed.
+func (s *PostgresUserStore) MarkEmailVerified(userID int64) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1`
+
+	_, err := s.db.Exec(query, userID)
+	return err
+}
+
+// SetUserRole changes the user's role and records who changed it in the
+// audit log.
+func (s *PostgresUserStore) SetUserRole(userID int64, role string, actorID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow(`SELECT role FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&previous)
+	if err != nil {
+		return err
+	}
+	if previous == role {
+		return nil
+	}
+
+	var now time.Time
+	err = tx.QueryRow(`UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`, role, userID).Scan(&now)
+	if err != nil {
+		return err
+	}
+
+	err = recordAudit(tx, &AuditEntry{
+		ActorID:    actorID,
+		Action:     AuditUserRoleChanged,
+		EntityType: AuditEntityUser,
+		EntityID:   userID,
+		Details:    map[string]any{"from": previous, "to": role},
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
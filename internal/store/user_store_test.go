@@ -3,7 +3,9 @@ package store
 import (
 	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/harundarat/be-socialtask/internal/utils"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -163,7 +165,7 @@ func TestGetUserTasks(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	taskStore := NewPostgresTaskStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
 	userStore := NewPostgresUserStore(db)
 
 	// Create initial user and tasks for happy path
@@ -221,17 +223,17 @@ func TestGetUserTasks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tasks, err := userStore.GetUserTasks(tt.userID)
+			tasks, _, err := userStore.GetUserTasks(tt.userID, utils.PageParams{Limit: 10})
 			if !tt.userExist {
 				assert.NoError(t, err)
 				assert.NotNil(t, tasks)
-				assert.Equal(t, 0, len(*tasks))
+				assert.Equal(t, 0, len(tasks))
 				return
 			}
 
 			require.NoError(t, err)
 			require.NotNil(t, tasks)
-			assert.Equal(t, 2, len(*tasks))
+			assert.Equal(t, 2, len(tasks))
 		})
 
 	}
@@ -0,0 +1,651 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+)
+
+// WebhookEventType is what a webhook delivery announces.
+type WebhookEventType string
+
+const (
+	WebhookTaskJoined    WebhookEventType = "task.joined"
+	WebhookTaskSubmitted WebhookEventType = "task.submitted"
+	// WebhookTaskCompleted is sent when a participation is verified.
+	WebhookTaskCompleted WebhookEventType = "task.completed"
+	WebhookTaskRejected  WebhookEventType = "task.rejected"
+	WebhookRewardCreated WebhookEventType = "reward.created"
+)
+
+// webhookEvents maps the analytics events that are also sent to webhooks.
+var webhookEvents = map[TaskEventType]WebhookEventType{
+	TaskEventJoin:       WebhookTaskJoined,
+	TaskEventSubmission: WebhookTaskSubmitted,
+	TaskEventVerified:   WebhookTaskCompleted,
+	TaskEventRejected:   WebhookTaskRejected,
+	TaskEventRewarded:   WebhookRewardCreated,
+}
+
+func (t WebhookEventType) IsValid() bool {
+	for _, known := range webhookEvents {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEventTypes are the events a webhook subscribes to.
+type WebhookEventTypes []WebhookEventType
+
+func (types WebhookEventTypes) Value() (driver.Value, error) {
+	if types == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(types)
+}
+
+func (types *WebhookEventTypes) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, types)
+	case string:
+		return json.Unmarshal([]byte(v), types)
+	default:
+		return fmt.Errorf("cannot scan %T into WebhookEventTypes", src)
+	}
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDeliveryRetention is how long deliveries stay in the log.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+const (
+	// WebhookDeliveryJob is the kind of the jobs that send deliveries, one
+	// is queued with every delivery. Its payload is a
+	// WebhookDeliveryJobPayload.
+	WebhookDeliveryJob = "webhooks.deliver"
+	// WebhookDeliveryQueue is the job queue of the deliveries.
+	WebhookDeliveryQueue = "webhooks"
+	// WebhookMaxAttempts is how often a delivery is sent before it fails
+	// for good.
+	WebhookMaxAttempts = 10
+)
+
+// WebhookDeliveryJobPayload names the delivery a job sends.
+type WebhookDeliveryJobPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// maxWebhookResponseBody is how much of a receiver's answer is logged.
+const maxWebhookResponseBody = 4096
+
+// MinWebhookSecretSize is the shortest secret a webhook can be given.
+const MinWebhookSecretSize = 16
+
+// Webhook sends the events of its owner's tasks to URL. Webhooks of an
+// organization get the events of the organization's tasks instead.
+type Webhook struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// OrganizationID is fixed when the webhook is created.
+	OrganizationID *int64 `json:"organization_id,omitempty"`
+	URL            string `json:"url"`
+	// Secret signs the deliveries. It is only ever returned when it is set.
+	Secret     string            `json:"secret,omitempty"`
+	EventTypes WebhookEventTypes `json:"event_types"`
+	Active     bool              `json:"active"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Validate reports the first problem with the webhook's fields, an empty
+// secret is left for the caller to generate.
+func (w *Webhook) Validate() error {
+	if !isHTTPURL(w.URL) || utf8.RuneCountInString(w.URL) > 2000 {
+		return errors.New("url must be an http or https URL of at most 2000 characters")
+	}
+	if w.Secret != "" && (len(w.Secret) < MinWebhookSecretSize || len(w.Secret) > 255) {
+		return fmt.Errorf("secret must be between %d and 255 characters", MinWebhookSecretSize)
+	}
+	if len(w.EventTypes) == 0 {
+		return errors.New("event_types must name at least one event")
+	}
+	seen := make(map[WebhookEventType]bool, len(w.EventTypes))
+	for _, t := range w.EventTypes {
+		if !t.IsValid() {
+			return fmt.Errorf("event type %q is not supported", t)
+		}
+		if seen[t] {
+			return fmt.Errorf("event_types must not contain %s twice", t)
+		}
+		seen[t] = true
+	}
+	return nil
+}
+
+// WebhookEvent is the body of a delivery. ID is the same for every delivery
+// of the event, receivers use it to ignore repeats.
+type WebhookEvent struct {
+	ID        int64            `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	TaskID int64 `json:"task_id"`
+	UserID int64 `json:"user_id"`
+	// RewardUSDT is set on task.completed and reward.created.
+	RewardUSDT float64 `json:"reward_usdt,omitempty"`
+}
+
+// WebhookDelivery is one entry of a webhook's delivery log.
+type WebhookDelivery struct {
+	ID        int64                 `json:"id"`
+	WebhookID int64                 `json:"webhook_id"`
+	EventID   int64                 `json:"event_id"`
+	EventType WebhookEventType      `json:"event_type"`
+	Payload   json.RawMessage       `json:"payload"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int                   `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried next.
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	// ResponseStatus and ResponseBody are the receiver's last answer,
+	// Error why the last attempt got none.
+	ResponseStatus *int   `json:"response_status"`
+	ResponseBody   string `json:"response_body"`
+	Error          string `json:"error"`
+	// RedeliveryOf is the delivery this one repeats.
+	RedeliveryOf *int64     `json:"redelivery_of"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+// WebhookDispatch is a delivery with what is needed to send it.
+type WebhookDispatch struct {
+	ID        int64
+	WebhookID int64
+	EventType WebhookEventType
+	Payload   []byte
+	Status    WebhookDeliveryStatus
+	URL       string
+	Secret    string
+	// Active is false while the webhook is turned off.
+	Active bool
+}
+
+// WebhookAttempt is the outcome of sending a delivery. RetryAt schedules
+// another attempt after a failure, nil gives up.
+type WebhookAttempt struct {
+	Succeeded      bool
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+	RetryAt        *time.Time
+}
+
+type PostgresWebhookStore struct {
+	db  *sql.DB
+	now Clock
+}
+
+func NewPostgresWebhookStore(db *sql.DB, clock Clock) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db, now: clock}
+}
+
+type WebhookStore interface {
+	CreateWebhook(w *Webhook) (*Webhook, error)
+	GetWebhookByID(id int64) (*Webhook, error)
+	GetWebhooks(userID, orgID int64, page utils.PageParams) ([]Webhook, utils.PageBounds, error)
+	UpdateWebhook(w *Webhook) error
+	DeleteWebhook(id int64) error
+	GetWebhookDeliveries(webhookID int64, page utils.PageParams) ([]WebhookDelivery, utils.PageBounds, error)
+	GetWebhookDelivery(id int64) (*WebhookDelivery, error)
+	RedeliverWebhookDelivery(id int64) (*WebhookDelivery, error)
+	GetWebhookDispatch(id int64) (*WebhookDispatch, error)
+	RecordWebhookAttempt(id int64, attempt WebhookAttempt) error
+	DeleteOldWebhookDeliveries() (int64, error)
+}
+
+// enqueueWebhookDeliveries queues a delivery of the analytics event, under
+// the id of its outbox event, to every active webhook of the task's owner
+// that subscribes to it, with the job that sends it. Like the event it runs in the transaction of the change, so
+// a rolled back change is never announced.
+func enqueueWebhookDeliveries(q dbtx, eventID int64, e *TaskEvent) error {
+	eventType, ok := webhookEvents[e.Type]
+	if !ok {
+		return nil
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: e.OccurredAt,
+		Data:      WebhookEventData{TaskID: e.TaskID, UserID: e.UserID, RewardUSDT: e.RewardUSDT},
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at, created_at)
+		SELECT w.id, $2, $3, $4, $5, $5
+		FROM webhooks w
+		JOIN tasks t ON t.id = $1
+		WHERE w.active
+			AND w.event_types ? $6
+			AND (w.organization_id = t.organization_id
+				OR (w.organization_id IS NULL AND t.organization_id IS NULL AND w.user_id = t.user_id))
+		RETURNING id
+	`
+	ids, err := queryWebhookDeliveryIDs(q, query, e.TaskID, eventID, eventType, payload, e.OccurredAt, string(eventType))
+	if err != nil {
+		return err
+	}
+	return enqueueWebhookDeliveryJobs(q, ids, e.OccurredAt)
+}
+
+func queryWebhookDeliveryIDs(q dbtx, query string, args ...any) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// enqueueWebhookDeliveryJobs queues a job to send each of the deliveries.
+func enqueueWebhookDeliveryJobs(q dbtx, ids []int64, now time.Time) error {
+	for _, id := range ids {
+		payload, err := json.Marshal(WebhookDeliveryJobPayload{DeliveryID: id})
+		if err != nil {
+			return err
+		}
+		_, err = enqueueJob(q, &Job{
+			Queue:       WebhookDeliveryQueue,
+			Kind:        WebhookDeliveryJob,
+			Payload:     payload,
+			MaxAttempts: WebhookMaxAttempts,
+		}, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const webhookColumns = `w.id, w.user_id, w.organization_id, w.url, w.event_types, w.active, w.created_at, w.updated_at`
+
+func scanWebhook(row interface{ Scan(dest ...any) error }, extra ...any) (*Webhook, error) {
+	var w Webhook
+	dest := append([]any{&w.ID, &w.UserID, &w.OrganizationID, &w.URL, &w.EventTypes, &w.Active, &w.CreatedAt, &w.UpdatedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (pg *PostgresWebhookStore) CreateWebhook(w *Webhook) (*Webhook, error) {
+	if w.UserID == 0 {
+		return nil, errors.New("user id is required and can not be zero")
+	}
+
+	now := pg.now()
+	query := `
+		INSERT INTO webhooks (user_id, organization_id, url, secret, event_types, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id, created_at, updated_at
+	`
+	err := pg.db.QueryRow(query, w.UserID, w.OrganizationID, w.URL, w.Secret, w.EventTypes, w.Active, now).
+		Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWebhookByID returns the webhook without its secret.
+func (pg *PostgresWebhookStore) GetWebhookByID(id int64) (*Webhook, error) {
+	w, err := scanWebhook(pg.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks w WHERE w.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// GetWebhooks returns a page of the organization's webhooks, or of the
+// user's own webhooks when orgID is 0, newest first.
+func (pg *PostgresWebhookStore) GetWebhooks(userID, orgID int64, page utils.PageParams) ([]Webhook, utils.PageBounds, error) {
+	ks := keyset{key: "w.created_at", cast: "timestamptz", id: "w.id", desc: true}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	owner := "w.organization_id = $1"
+	ownerArg := orgID
+	if orgID == 0 {
+		owner = "w.organization_id IS NULL AND w.user_id = $1"
+		ownerArg = userID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM webhooks w
+		WHERE %s %s
+		ORDER BY %s
+		LIMIT $%d
+	`, webhookColumns, ks.keyColumn(), owner, cond, orderBy, len(args)+2)
+
+	args = append([]any{ownerArg}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[Webhook]
+	for rows.Next() {
+		var key string
+		w, err := scanWebhook(rows, &key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[Webhook]{row: *w, key: key, id: w.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	webhooks, bounds := keysetPage(items, page)
+	return webhooks, bounds, nil
+}
+
+// UpdateWebhook replaces the URL, event types and active flag, and the
+// secret when one is set. An active webhook sends the deliveries that waited
+// while it was turned off.
+func (pg *PostgresWebhookStore) UpdateWebhook(w *Webhook) error {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhooks
+		SET url = $1,
+			event_types = $2,
+			active = $3,
+			secret = COALESCE(NULLIF($4, ''), secret),
+			updated_at = $5
+		WHERE id = $6
+		RETURNING updated_at
+	`
+	err = tx.QueryRow(query, w.URL, w.EventTypes, w.Active, w.Secret, now, w.ID).Scan(&w.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if w.Active {
+		// deliveries whose job found the webhook turned off, those still
+		// being retried keep their job
+		query = `
+			SELECT d.id
+			FROM webhook_deliveries d
+			WHERE d.webhook_id = $1 AND d.status = 'pending'
+				AND NOT EXISTS (
+					SELECT 1
+					FROM jobs j
+					WHERE j.kind = $2 AND j.status IN ('pending', 'running')
+						AND j.payload->>'delivery_id' = d.id::text
+				)
+		`
+		ids, err := queryWebhookDeliveryIDs(tx, query, w.ID, WebhookDeliveryJob)
+		if err != nil {
+			return err
+		}
+		err = enqueueWebhookDeliveryJobs(tx, ids, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWebhook deletes the webhook with its delivery log, pending
+// deliveries are never sent.
+func (pg *PostgresWebhookStore) DeleteWebhook(id int64) error {
+	_, err := pg.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	return err
+}
+
+const webhookDeliveryColumns = `
+	d.id,
+	d.webhook_id,
+	d.event_id,
+	d.event_type,
+	d.payload,
+	d.status,
+	d.attempts,
+	d.next_attempt_at,
+	d.response_status,
+	d.response_body,
+	d.error,
+	d.redelivery_of,
+	d.created_at,
+	d.completed_at
+`
+
+func scanWebhookDelivery(row interface{ Scan(dest ...any) error }, extra ...any) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload []byte
+	var nextAttemptAt time.Time
+	dest := append([]any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &nextAttemptAt,
+		&d.ResponseStatus, &d.ResponseBody, &d.Error, &d.RedeliveryOf, &d.CreatedAt, &d.CompletedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = payload
+	if d.Status == WebhookDeliveryPending {
+		d.NextAttemptAt = &nextAttemptAt
+	}
+	return &d, nil
+}
+
+// GetWebhookDeliveries returns a page of the webhook's delivery log, newest
+// first.
+func (pg *PostgresWebhookStore) GetWebhookDeliveries(webhookID int64, page utils.PageParams) ([]WebhookDelivery, utils.PageBounds, error) {
+	ks := keyset{key: "d.created_at", cast: "timestamptz", id: "d.id", desc: true}
+	cond, orderBy, args := ks.clause(page, 2)
+	if cond != "" {
+		cond = "AND " + cond
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1 %s
+		ORDER BY %s
+		LIMIT $%d
+	`, webhookDeliveryColumns, ks.keyColumn(), cond, orderBy, len(args)+2)
+
+	args = append([]any{webhookID}, args...)
+	args = append(args, page.Limit+1)
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+	defer rows.Close()
+
+	var items []keyed[WebhookDelivery]
+	for rows.Next() {
+		var key string
+		d, err := scanWebhookDelivery(rows, &key)
+		if err != nil {
+			return nil, utils.PageBounds{}, err
+		}
+		items = append(items, keyed[WebhookDelivery]{row: *d, key: key, id: d.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PageBounds{}, err
+	}
+
+	deliveries, bounds := keysetPage(items, page)
+	return deliveries, bounds, nil
+}
+
+func (pg *PostgresWebhookStore) GetWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(pg.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// RedeliverWebhookDelivery queues the delivery's event again as a new
+// delivery, sent right away. It returns nil, nil when the delivery does not
+// exist.
+func (pg *PostgresWebhookStore) RedeliverWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	now := pg.now()
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at, redelivery_of, created_at)
+		SELECT webhook_id, event_id, event_type, payload, $2, id, $2
+		FROM webhook_deliveries
+		WHERE id = $1
+		RETURNING id
+	`
+	var redeliveryID int64
+	err = tx.QueryRow(query, id, now).Scan(&redeliveryID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhookDeliveryJobs(tx, []int64{redeliveryID}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return pg.GetWebhookDelivery(redeliveryID)
+}
+
+// GetWebhookDispatch returns the delivery with its webhook's URL and secret,
+// nil, nil when the delivery or its webhook was deleted.
+func (pg *PostgresWebhookStore) GetWebhookDispatch(id int64) (*WebhookDispatch, error) {
+	query := `
+		SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, w.url, w.secret, w.active
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1
+	`
+	var d WebhookDispatch
+	err := pg.db.QueryRow(query, id).Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.URL, &d.Secret, &d.Active)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// RecordWebhookAttempt logs the outcome of sending a pending delivery and
+// either completes it or records when it is tried next.
+func (pg *PostgresWebhookStore) RecordWebhookAttempt(id int64, attempt WebhookAttempt) error {
+	now := pg.now()
+
+	var responseStatus *int
+	if attempt.ResponseStatus != 0 {
+		responseStatus = &attempt.ResponseStatus
+	}
+	body := attempt.ResponseBody
+	if len(body) > maxWebhookResponseBody {
+		body = body[:maxWebhookResponseBody]
+	}
+	// receivers answer with anything, Postgres only stores valid UTF-8
+	// without NUL bytes
+	body = strings.ToValidUTF8(strings.ReplaceAll(body, "\x00", ""), "\uFFFD")
+
+	status, nextAttemptAt, completedAt := WebhookDeliveryPending, now, (*time.Time)(nil)
+	switch {
+	case attempt.Succeeded:
+		status, completedAt = WebhookDeliverySucceeded, &now
+	case attempt.RetryAt == nil:
+		status, completedAt = WebhookDeliveryFailed, &now
+	default:
+		nextAttemptAt = *attempt.RetryAt
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1,
+			attempts = attempts + 1,
+			next_attempt_at = $2,
+			response_status = $3,
+			response_body = $4,
+			error = $5,
+			completed_at = $6
+		WHERE id = $7 AND status = 'pending'
+	`
+	_, err := pg.db.Exec(query, status, nextAttemptAt, responseStatus, body, attempt.Error, completedAt, id)
+	return err
+}
+
+// DeleteOldWebhookDeliveries trims the delivery logs to
+// WebhookDeliveryRetention and returns how many deliveries it deleted.
+// Pending deliveries are kept however old they are.
+func (pg *PostgresWebhookStore) DeleteOldWebhookDeliveries() (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`
+	res, err := pg.db.Exec(query, pg.now().Add(-WebhookDeliveryRetention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/utils"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDBWebhook(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	require.NoError(t, err, "opening test db")
+
+	err = Migrate(db, "../../migrations/")
+	require.NoError(t, err, "migration test db error")
+
+	_, err = db.Exec("TRUNCATE webhook_deliveries, webhooks, task_events, tasks, users, jobs CASCADE")
+	require.NoError(t, err, "truncating table")
+
+	return db
+}
+
+func TestWebhookStore(t *testing.T) {
+	db := setupTestDBWebhook(t)
+	defer db.Close()
+
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	webhookStore := NewPostgresWebhookStore(db, fixedClock(&now))
+	participationStore := NewPostgresParticipationStore(db, fixedClock(&now))
+	userStore := NewPostgresUserStore(db)
+	taskStore := NewPostgresTaskStore(db, time.Now)
+
+	var users []*User
+	for _, name := range []string{"webhook-creator", "webhook-a", "webhook-other"} {
+		user := &User{Username: name, Email: name + "@gmail.com"}
+		user.PasswordHash.Set("password123")
+		user, err := userStore.CreateUser(user)
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+	creator, a, other := users[0], users[1], users[2]
+
+	task, err := taskStore.CreateTask(&Task{Title: "Hooked", UserID: creator.ID, RewardUSDT: 4, DueDate: now.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	taskID := int64(task.ID)
+
+	webhook, err := webhookStore.CreateWebhook(&Webhook{UserID: creator.ID, URL: "https://example.com/hook", Secret: "0123456789abcdef", EventTypes: WebhookEventTypes{WebhookTaskJoined}, Active: true})
+	require.NoError(t, err)
+	// subscribed to other events, or to the tasks of another creator
+	_, err = webhookStore.CreateWebhook(&Webhook{UserID: creator.ID, URL: "https://example.com/rewards", Secret: "0123456789abcdef", EventTypes: WebhookEventTypes{WebhookRewardCreated}, Active: true})
+	require.NoError(t, err)
+	_, err = webhookStore.CreateWebhook(&Webhook{UserID: other.ID, URL: "https://example.com/other", Secret: "0123456789abcdef", EventTypes: WebhookEventTypes{WebhookTaskJoined}, Active: true})
+	require.NoError(t, err)
+
+	t.Run("secrets are only read by the dispatcher", func(t *testing.T) {
+		got, err := webhookStore.GetWebhookByID(webhook.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Secret)
+		assert.Equal(t, WebhookEventTypes{WebhookTaskJoined}, got.EventTypes)
+
+		webhooks, _, err := webhookStore.GetWebhooks(creator.ID, 0, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, webhooks, 2)
+	})
+
+	_, _, err = participationStore.Join(taskID, a.ID)
+	require.NoError(t, err)
+
+	// deliveryJobs returns the deliveries that have a job waiting to send
+	// them
+	deliveryJobs := func(t *testing.T) []int64 {
+		rows, err := db.Query(`SELECT (payload->>'delivery_id')::bigint FROM jobs WHERE kind = $1 AND status = 'pending' ORDER BY id`, WebhookDeliveryJob)
+		require.NoError(t, err)
+		defer rows.Close()
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		require.NoError(t, rows.Err())
+		return ids
+	}
+
+	var delivery *WebhookDispatch
+	t.Run("events are queued for subscribed webhooks", func(t *testing.T) {
+		ids := deliveryJobs(t)
+		require.Len(t, ids, 1)
+
+		var err error
+		delivery, err = webhookStore.GetWebhookDispatch(ids[0])
+		require.NoError(t, err)
+		assert.Equal(t, webhook.ID, delivery.WebhookID)
+		assert.Equal(t, "0123456789abcdef", delivery.Secret)
+		assert.Equal(t, WebhookDeliveryPending, delivery.Status)
+		assert.True(t, delivery.Active)
+
+		var event WebhookEvent
+		require.NoError(t, json.Unmarshal(delivery.Payload, &event))
+		assert.Equal(t, WebhookTaskJoined, event.Type)
+		assert.Equal(t, taskID, event.Data.TaskID)
+		assert.Equal(t, a.ID, event.Data.UserID)
+
+		missing, err := webhookStore.GetWebhookDispatch(delivery.ID + 100)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("failed attempts are retried and then give up", func(t *testing.T) {
+		retryAt := now.Add(time.Minute)
+		err := webhookStore.RecordWebhookAttempt(delivery.ID, WebhookAttempt{ResponseStatus: 500, ResponseBody: "oops\x00", Error: "receiver answered 500", RetryAt: &retryAt})
+		require.NoError(t, err)
+
+		got, err := webhookStore.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, WebhookDeliveryPending, got.Status)
+		assert.Equal(t, 1, got.Attempts)
+		assert.Equal(t, retryAt, got.NextAttemptAt.UTC())
+		assert.Equal(t, "oops", got.ResponseBody)
+		assert.Equal(t, 500, *got.ResponseStatus)
+
+		require.NoError(t, webhookStore.RecordWebhookAttempt(delivery.ID, WebhookAttempt{Error: "connection refused"}))
+		got, err = webhookStore.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, WebhookDeliveryFailed, got.Status)
+		assert.Equal(t, 2, got.Attempts)
+		assert.Nil(t, got.ResponseStatus)
+		assert.NotNil(t, got.CompletedAt)
+
+		// a job run again after the delivery finished changes nothing
+		require.NoError(t, webhookStore.RecordWebhookAttempt(delivery.ID, WebhookAttempt{Succeeded: true}))
+		got, err = webhookStore.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, WebhookDeliveryFailed, got.Status)
+	})
+
+	t.Run("deliveries of a turned off webhook are sent once it is on again", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE jobs SET status = 'succeeded' WHERE kind = $1`, WebhookDeliveryJob)
+		require.NoError(t, err)
+
+		webhook.Active = false
+		require.NoError(t, webhookStore.UpdateWebhook(webhook))
+		_, _, err = participationStore.Join(taskID, other.ID)
+		require.NoError(t, err)
+		ids := deliveryJobs(t)
+		require.Len(t, ids, 0, "inactive webhooks get no new deliveries")
+
+		// a delivery queued while it was on, whose job found it off
+		_, err = db.Exec(`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at) VALUES ($1, 1, $2, '{}', $3)`, webhook.ID, WebhookTaskJoined, now)
+		require.NoError(t, err)
+
+		webhook.Active = true
+		require.NoError(t, webhookStore.UpdateWebhook(webhook))
+		require.Len(t, deliveryJobs(t), 1)
+
+		// updating it again does not queue the delivery twice
+		require.NoError(t, webhookStore.UpdateWebhook(webhook))
+		require.Len(t, deliveryJobs(t), 1)
+	})
+
+	t.Run("redelivery repeats the event", func(t *testing.T) {
+		redelivery, err := webhookStore.RedeliverWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, WebhookDeliveryPending, redelivery.Status)
+		assert.Equal(t, delivery.ID, *redelivery.RedeliveryOf)
+		assert.Contains(t, deliveryJobs(t), redelivery.ID)
+
+		original, err := webhookStore.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, original.EventID, redelivery.EventID)
+
+		require.NoError(t, webhookStore.RecordWebhookAttempt(redelivery.ID, WebhookAttempt{Succeeded: true, ResponseStatus: 204}))
+		deliveries, _, err := webhookStore.GetWebhookDeliveries(webhook.ID, utils.PageParams{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 3)
+
+		missing, err := webhookStore.RedeliverWebhookDelivery(delivery.ID + 100)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("old deliveries are trimmed", func(t *testing.T) {
+		now = now.Add(WebhookDeliveryRetention + time.Hour)
+		deleted, err := webhookStore.DeleteOldWebhookDeliveries()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted, "the pending delivery is kept")
+	})
+}
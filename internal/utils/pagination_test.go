@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	cursor := Cursor{Sort: "newest", Key: "2025-10-20 09:00:00+00", ID: 42}
+
+	t.Run("round trip", func(t *testing.T) {
+		decoded, err := codec.Decode(codec.Encode(cursor))
+		require.NoError(t, err)
+		assert.Equal(t, cursor, *decoded)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		_, signature, _ := strings.Cut(codec.Encode(cursor), ".")
+		payload, _, _ := strings.Cut(codec.Encode(Cursor{Sort: "newest", ID: 1}), ".")
+		_, err := codec.Decode(payload + "." + signature)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		_, err := NewCursorCodec("other").Decode(codec.Encode(cursor))
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, err := codec.Decode("not-a-cursor")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestReadPageParams(t *testing.T) {
+	codec := NewCursorCodec("secret")
+
+	p, err := codec.ReadPageParams(httptest.NewRequest("GET", "/tasks", nil), "newest")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPageLimit, p.Limit)
+	assert.Nil(t, p.Cursor)
+
+	token := codec.Encode(Cursor{Sort: "newest", ID: 7})
+	p, err = codec.ReadPageParams(httptest.NewRequest("GET", "/tasks?limit=5&cursor="+token, nil), "newest")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), p.Limit)
+	assert.Equal(t, int64(7), p.Cursor.ID)
+
+	_, err = codec.ReadPageParams(httptest.NewRequest("GET", "/tasks?cursor="+token, nil), "oldest")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = codec.ReadPageParams(httptest.NewRequest("GET", "/tasks?limit=0", nil), "newest")
+	assert.Error(t, err)
+}
+
+func TestLinks(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	first := &Cursor{Sort: "id", ID: 1}
+	last := &Cursor{Sort: "id", ID: 10}
+
+	decode := func(token *string) *Cursor {
+		require.NotNil(t, token)
+		c, err := codec.Decode(*token)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		next, prev := codec.Links(PageParams{Sort: "id"}, PageBounds{First: first, Last: last, HasMore: true})
+		assert.Equal(t, int64(10), decode(next).ID)
+		assert.Nil(t, prev)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		next, prev := codec.Links(PageParams{Sort: "id", Cursor: &Cursor{ID: 0}}, PageBounds{First: first, Last: last})
+		assert.Nil(t, next)
+		assert.True(t, decode(prev).Backward)
+		assert.Equal(t, int64(1), decode(prev).ID)
+	})
+
+	t.Run("reading backwards to the start", func(t *testing.T) {
+		next, prev := codec.Links(PageParams{Sort: "id", Cursor: &Cursor{ID: 11, Backward: true}}, PageBounds{First: first, Last: last})
+		assert.False(t, decode(next).Backward)
+		assert.Nil(t, prev)
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		next, prev := codec.Links(PageParams{Sort: "id"}, PageBounds{})
+		assert.Nil(t, next)
+		assert.Nil(t, prev)
+	})
+}
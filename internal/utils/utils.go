@@ -22,37 +22,123 @@ const (
 	StatusError   Status = "error"
 )
 const (
-	MessageLoginSuccess       Message = "login successful"
-	MessageLoginFailed        Message = "login failed"
-	MessageRegisterSuccess    Message = "registration successful"
-	MessageRegisterFailed     Message = "registration failed"
-	MessageTaskCreated        Message = "task created successfully"
-	MessageTaskRetrieved      Message = "task retrieved successfully"
-	MessageTasksFetched       Message = "tasks fetched successfully"
-	MessageTasksUpdated       Message = "tasks updated successfully"
-	MessageTasksDeleted       Message = "tasks deleted successfully"
-	MessageActionInvalidType  Message = "invalid action type"
-	MessageActionCreated      Message = "action created successfully"
-	MessageActionRetrieved    Message = "action retrieved successfully"
-	MessageActionsFetched     Message = "actions fetched successfully"
-	MessageActionsDelete      Message = "actions deleted successfully"
-	MessageActionsUpdated     Message = "actions updated successfully"
-	MessageRewardCreated      Message = "reward created successfully"
-	MessageRewardRetrieved    Message = "reward retrieved successfully"
-	MessageRewardsFetched     Message = "rewards fetched successfully"
-	MessageRewardsDelete      Message = "rewards deleted successfully"
-	MessageRewardsUpdated     Message = "rewards updated successfully"
-	MessageInvalidRequest     Message = "invalid request"
-	MessageInternalError      Message = "internal server error"
-	MessageUnauthorized       Message = "unauthorized access"
-	MessageNotFound           Message = "resource not found"
-	MessageInvalidCredentials Message = "invalid credentials"
-	MessageTokenGenerated     Message = "token generated successfully"
-	MessageValidationFailed   Message = "validation failed"
-	MessageOAuthFailed        Message = "oauth authentication failed"
-	MessageOAuthSuccess       Message = "oauth authentication successful"
-	MessageBadRequest         Message = "bad request"
-	MessageUserRetrieved      Message = "user retrieved successfully"
+	MessageLoginSuccess           Message = "login successful"
+	MessageLoginFailed            Message = "login failed"
+	MessageRegisterSuccess        Message = "registration successful"
+	MessageRegisterFailed         Message = "registration failed"
+	MessageTaskCreated            Message = "task created successfully"
+	MessageTaskRetrieved          Message = "task retrieved successfully"
+	MessageTasksFetched           Message = "tasks fetched successfully"
+	MessageTasksUpdated           Message = "tasks updated successfully"
+	MessageTasksDeleted           Message = "tasks deleted successfully"
+	MessageTaskPublished          Message = "task published successfully"
+	MessageTaskCloned             Message = "task cloned successfully"
+	MessageTaskNotDraft           Message = "task is already published"
+	MessageTasksImported          Message = "tasks imported successfully"
+	MessageTasksValidated         Message = "tasks validated successfully"
+	MessageTemplateCreated        Message = "template created successfully"
+	MessageTemplateRetrieved      Message = "template retrieved successfully"
+	MessageTemplatesFetched       Message = "templates fetched successfully"
+	MessageTemplateUpdated        Message = "template updated successfully"
+	MessageTemplateDeleted        Message = "template deleted successfully"
+	MessageCampaignCreated        Message = "campaign created successfully"
+	MessageCampaignRetrieved      Message = "campaign retrieved successfully"
+	MessageCampaignsFetched       Message = "campaigns fetched successfully"
+	MessageCampaignUpdated        Message = "campaign updated successfully"
+	MessageCampaignDeleted        Message = "campaign deleted successfully"
+	MessageCampaignTasksAdded     Message = "tasks added to campaign successfully"
+	MessageCampaignTaskRemoved    Message = "task removed from campaign successfully"
+	MessageParticipantsFetched    Message = "participants fetched successfully"
+	MessageAnalyticsFetched       Message = "analytics fetched successfully"
+	MessageEventsRecorded         Message = "events recorded successfully"
+	MessageTooManyRequests        Message = "too many requests"
+	MessageExportQueued           Message = "export queued successfully"
+	MessageExportRetrieved        Message = "export retrieved successfully"
+	MessageExportNotReady         Message = "export is not ready"
+	MessageWebhookCreated         Message = "webhook created successfully"
+	MessageWebhookRetrieved       Message = "webhook retrieved successfully"
+	MessageWebhooksFetched        Message = "webhooks fetched successfully"
+	MessageWebhookUpdated         Message = "webhook updated successfully"
+	MessageWebhookDeleted         Message = "webhook deleted successfully"
+	MessageDeliveriesFetched      Message = "deliveries fetched successfully"
+	MessageDeliveryRetrieved      Message = "delivery retrieved successfully"
+	MessageDeliveryQueued         Message = "delivery queued successfully"
+	MessageNotificationsFetched   Message = "notifications fetched successfully"
+	MessageNotificationRead       Message = "notification marked as read"
+	MessageNotificationsRead      Message = "notifications marked as read"
+	MessagePreferencesRetrieved   Message = "preferences retrieved successfully"
+	MessagePreferencesUpdated     Message = "preferences updated successfully"
+	MessageOrganizationCreated    Message = "organization created successfully"
+	MessageOrganizationRetrieved  Message = "organization retrieved successfully"
+	MessageOrganizationsFetched   Message = "organizations fetched successfully"
+	MessageOrganizationUpdated    Message = "organization updated successfully"
+	MessageOrganizationDeleted    Message = "organization deleted successfully"
+	MessageMembersFetched         Message = "members fetched successfully"
+	MessageMemberUpdated          Message = "member updated successfully"
+	MessageMemberRemoved          Message = "member removed successfully"
+	MessageMemberUpdateFailed     Message = "unable to update member"
+	MessageInvitationCreated      Message = "invitation sent successfully"
+	MessageInvitationsFetched     Message = "invitations fetched successfully"
+	MessageInvitationRevoked      Message = "invitation revoked successfully"
+	MessageInvitationAccepted     Message = "invitation accepted successfully"
+	MessageInvitationDeclined     Message = "invitation declined successfully"
+	MessageInvitationFailed       Message = "unable to update invitation"
+	MessageActionInvalidType      Message = "invalid action type"
+	MessageActionCreated          Message = "action created successfully"
+	MessageActionRetrieved        Message = "action retrieved successfully"
+	MessageActionsFetched         Message = "actions fetched successfully"
+	MessageActionsDelete          Message = "actions deleted successfully"
+	MessageActionsUpdated         Message = "actions updated successfully"
+	MessageRewardCreated          Message = "reward created successfully"
+	MessageRewardRetrieved        Message = "reward retrieved successfully"
+	MessageRewardsFetched         Message = "rewards fetched successfully"
+	MessageRewardsDelete          Message = "rewards deleted successfully"
+	MessageRewardsUpdated         Message = "rewards updated successfully"
+	MessageInvalidRequest         Message = "invalid request"
+	MessageInternalError          Message = "internal server error"
+	MessageUnauthorized           Message = "unauthorized access"
+	MessageNotFound               Message = "resource not found"
+	MessageInvalidCredentials     Message = "invalid credentials"
+	MessageTokenGenerated         Message = "token generated successfully"
+	MessageValidationFailed       Message = "validation failed"
+	MessageOAuthFailed            Message = "oauth authentication failed"
+	MessageOAuthSuccess           Message = "oauth authentication successful"
+	MessageBadRequest             Message = "bad request"
+	MessageUserRetrieved          Message = "user retrieved successfully"
+	MessageLeaderboardFetched     Message = "leaderboard fetched successfully"
+	MessageForbidden              Message = "forbidden"
+	MessageBadgeCreated           Message = "badge created successfully"
+	MessageBadgeRetrieved         Message = "badge retrieved successfully"
+	MessageBadgesFetched          Message = "badges fetched successfully"
+	MessageBadgeUpdated           Message = "badge updated successfully"
+	MessageBadgeDeleted           Message = "badge deleted successfully"
+	MessageTaskJoined             Message = "task joined successfully"
+	MessageTaskJoinFailed         Message = "unable to join task"
+	MessageParticipationRetrieved Message = "participation retrieved successfully"
+	MessageParticipationsFetched  Message = "participations fetched successfully"
+	MessageParticipationVerified  Message = "participation verified successfully"
+	MessageParticipationRejected  Message = "participation rejected successfully"
+	MessageQuestCreated           Message = "quest created successfully"
+	MessageQuestRetrieved         Message = "quest retrieved successfully"
+	MessageQuestsFetched          Message = "quests fetched successfully"
+	MessageQuestDeleted           Message = "quest deleted successfully"
+	MessageQuestProgressRetrieved Message = "quest progress retrieved successfully"
+	MessageFeedFetched            Message = "feed fetched successfully"
+	MessageNotEligible            Message = "not eligible to join this task"
+	MessageUploadCreated          Message = "file uploaded successfully"
+	MessageSubmissionCreated      Message = "proof submitted successfully"
+	MessageSubmissionRetrieved    Message = "submission retrieved successfully"
+	MessageSubmissionsFetched     Message = "submissions fetched successfully"
+	MessageSubmissionApproved     Message = "submission approved successfully"
+	MessageSubmissionRejected     Message = "submission rejected successfully"
+	MessageSubmissionFailed       Message = "unable to submit proof"
+	MessageUserRoleUpdated        Message = "user role updated successfully"
+	MessageDisputeOpened          Message = "dispute opened successfully"
+	MessageDisputeRetrieved       Message = "dispute retrieved successfully"
+	MessageDisputesFetched        Message = "disputes fetched successfully"
+	MessageDisputeResponded       Message = "dispute response recorded successfully"
+	MessageDisputeResolved        Message = "dispute resolved successfully"
+	MessageDisputeFailed          Message = "unable to update dispute"
 )
 
 func WriteJSON(w http.ResponseWriter, status Status, message Message, statusCode int, data Envelope, errorsList []string) error {
@@ -71,6 +157,10 @@ func WriteJSON(w http.ResponseWriter, status Status, message Message, statusCode
 			"message": message,
 			"errors":  errorsList,
 		}
+		// errors that need more than a message carry details in data
+		if data != nil {
+			response["data"] = data
+		}
 	}
 
 	js, err := json.MarshalIndent(response, "", " ")
@@ -86,7 +176,13 @@ func WriteJSON(w http.ResponseWriter, status Status, message Message, statusCode
 }
 
 func ReadIDParam(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "id")
+	return ReadNamedIDParam(r, "id")
+}
+
+// ReadNamedIDParam reads an id from the URL parameter called name, for routes
+// with more than one id.
+func ReadNamedIDParam(r *http.Request, name string) (int64, error) {
+	idParam := chi.URLParam(r, name)
 	if idParam == "" {
 		return 0, errors.New("invalid id parameter")
 	}
@@ -99,17 +195,6 @@ func ReadIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-func ReadPageParam(r *http.Request) int64 {
-	pageParam := chi.URLParam(r, "page")
-	if pageParam == "" {
-		return 1
-	}
-
-	page, _ := strconv.ParseInt(pageParam, 10, 64)
-
-	return page
-}
-
 // GenerateRandomString creates a cryptographically secure random string of a given length.
 func GenerateRandomString(n int) string {
 	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-"
@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+)
+
+const (
+	// DeleteOldInterval is how often the delivery logs are trimmed.
+	DeleteOldInterval = time.Hour
+	// concurrency is how many deliveries a worker sends at the same time.
+	concurrency = 20
+)
+
+var deliver = jobs.Kind[store.WebhookDeliveryJobPayload]{
+	Name:        store.WebhookDeliveryJob,
+	Queue:       store.WebhookDeliveryQueue,
+	MaxAttempts: store.WebhookMaxAttempts,
+}
+
+// Dispatcher sends the queued webhook deliveries as jobs and records every
+// attempt in the delivery log. A delivery succeeds when the receiver answers
+// with a 2xx status, anything else is retried by the worker with
+// jobs.DefaultBackoff until store.WebhookMaxAttempts.
+type Dispatcher struct {
+	webhookStore store.WebhookStore
+	client       *http.Client
+	now          store.Clock
+	logger       *log.Logger
+}
+
+func NewDispatcher(webhookStore store.WebhookStore, client *http.Client, clock store.Clock, logger *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		webhookStore: webhookStore,
+		client:       client,
+		now:          clock,
+		logger:       logger,
+	}
+}
+
+// Register makes w send the deliveries and trim the delivery logs every
+// DeleteOldInterval.
+func (d *Dispatcher) Register(w *jobs.Worker) error {
+	w.Queue(store.WebhookDeliveryQueue, concurrency)
+	jobs.Handle(w, deliver, d.Deliver)
+	return w.Every("webhooks.delete_old", DeleteOldInterval, func(ctx context.Context) error {
+		_, err := d.webhookStore.DeleteOldWebhookDeliveries()
+		return err
+	})
+}
+
+// Deliver sends the delivery of a job and records the outcome. Deliveries
+// that are done or gone are skipped, as are those of an inactive webhook,
+// which are queued again once it is turned on. A failed attempt returns its
+// error so that the worker retries the job.
+func (d *Dispatcher) Deliver(ctx context.Context, p store.WebhookDeliveryJobPayload) error {
+	dispatch, err := d.webhookStore.GetWebhookDispatch(p.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if dispatch == nil || dispatch.Status != store.WebhookDeliveryPending || !dispatch.Active {
+		return nil
+	}
+
+	attempt := d.send(ctx, dispatch)
+	n, last := jobs.Attempt(ctx)
+	if !attempt.Succeeded && !last {
+		retryAt := d.now().Add(jobs.DefaultBackoff.After(n))
+		attempt.RetryAt = &retryAt
+	}
+
+	err = d.webhookStore.RecordWebhookAttempt(dispatch.ID, attempt)
+	if err != nil {
+		return err
+	}
+	if !attempt.Succeeded {
+		return errors.New(attempt.Error)
+	}
+	return nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, dispatch *store.WebhookDispatch) store.WebhookAttempt {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(dispatch.Payload))
+	if err != nil {
+		return store.WebhookAttempt{Error: err.Error()}
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SocialTask-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, string(dispatch.EventType))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(dispatch.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dispatch.Secret, timestamp, dispatch.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return store.WebhookAttempt{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	attempt := store.WebhookAttempt{
+		Succeeded:      resp.StatusCode >= 200 && resp.StatusCode < 300,
+		ResponseStatus: resp.StatusCode,
+		ResponseBody:   string(body),
+	}
+	if err != nil {
+		attempt.Error = fmt.Sprintf("reading response: %v", err)
+	} else if !attempt.Succeeded {
+		attempt.Error = fmt.Sprintf("receiver answered %d", resp.StatusCode)
+	}
+	return attempt
+}
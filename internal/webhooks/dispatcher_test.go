@@ -0,0 +1,193 @@
+package webhooks_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/jobs"
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/webhooks"
+	"github.com/harundarat/be-socialtask/internal/webhooks/webhookstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookStore keeps deliveries in memory like the Postgres store.
+type fakeWebhookStore struct {
+	store.WebhookStore
+	deliveries []*fakeDelivery
+}
+
+type fakeDelivery struct {
+	dispatch store.WebhookDispatch
+	attempts []store.WebhookAttempt
+}
+
+func (f *fakeWebhookStore) add(url, secret string, event store.WebhookEvent) *fakeDelivery {
+	payload, _ := json.Marshal(event)
+	d := &fakeDelivery{
+		dispatch: store.WebhookDispatch{ID: int64(len(f.deliveries) + 1), EventType: event.Type, Payload: payload, Status: store.WebhookDeliveryPending, URL: url, Secret: secret, Active: true},
+	}
+	f.deliveries = append(f.deliveries, d)
+	return d
+}
+
+func (f *fakeWebhookStore) GetWebhookDispatch(id int64) (*store.WebhookDispatch, error) {
+	if id < 1 || int(id) > len(f.deliveries) {
+		return nil, nil
+	}
+	dispatch := f.deliveries[id-1].dispatch
+	return &dispatch, nil
+}
+
+func (f *fakeWebhookStore) RecordWebhookAttempt(id int64, attempt store.WebhookAttempt) error {
+	d := f.deliveries[id-1]
+	d.attempts = append(d.attempts, attempt)
+	switch {
+	case attempt.Succeeded:
+		d.dispatch.Status = store.WebhookDeliverySucceeded
+	case attempt.RetryAt == nil:
+		d.dispatch.Status = store.WebhookDeliveryFailed
+	}
+	return nil
+}
+
+func (f *fakeWebhookStore) DeleteOldWebhookDeliveries() (int64, error) {
+	return 0, nil
+}
+
+// fakeJobStore records the jobs enqueued by Register.
+type fakeJobStore struct {
+	store.JobStore
+	jobs []*store.Job
+}
+
+func (f *fakeJobStore) EnqueueJob(job *store.Job) (*store.Job, error) {
+	f.jobs = append(f.jobs, job)
+	return job, nil
+}
+
+func TestDispatcher(t *testing.T) {
+	now := time.Now()
+	webhookStore := &fakeWebhookStore{}
+	var logs bytes.Buffer
+	dispatcher := webhooks.NewDispatcher(webhookStore, webhooks.NewClient(true), func() time.Time { return now }, log.New(&logs, "", 0))
+
+	receiver := webhookstest.NewReceiver("whsec_0123456789abcdef")
+	server := receiver.Start()
+	defer server.Close()
+
+	event := store.WebhookEvent{ID: 41, Type: store.WebhookTaskCompleted, CreatedAt: now.UTC(), Data: store.WebhookEventData{TaskID: 7, UserID: 3, RewardUSDT: 2.5}}
+
+	// deliver runs the job of d as its attempt-th attempt
+	deliver := func(d *fakeDelivery, attempt int) error {
+		ctx := jobs.WithAttempt(context.Background(), attempt, store.WebhookMaxAttempts)
+		return dispatcher.Deliver(ctx, store.WebhookDeliveryJobPayload{DeliveryID: d.dispatch.ID})
+	}
+
+	t.Run("signed deliveries are accepted", func(t *testing.T) {
+		d := webhookStore.add(server.URL, "whsec_0123456789abcdef", event)
+
+		require.NoError(t, deliver(d, 1))
+		assert.Equal(t, store.WebhookDeliverySucceeded, d.dispatch.Status)
+		assert.Equal(t, http.StatusNoContent, d.attempts[0].ResponseStatus)
+
+		accepted := receiver.Accepted()
+		require.Len(t, accepted, 1)
+		assert.Equal(t, "1", accepted[0].DeliveryID)
+		assert.Equal(t, store.WebhookTaskCompleted, accepted[0].Event.Type)
+		assert.Equal(t, int64(41), accepted[0].Event.ID)
+		assert.Equal(t, 2.5, accepted[0].Event.Data.RewardUSDT)
+
+		// a job run again after the delivery succeeded sends nothing
+		require.NoError(t, deliver(d, 2))
+		assert.Len(t, d.attempts, 1)
+		assert.Len(t, receiver.Accepted(), 1)
+	})
+
+	t.Run("a wrong secret is rejected and retried", func(t *testing.T) {
+		d := webhookStore.add(server.URL, "whsec_not-the-right-one", event)
+
+		assert.ErrorContains(t, deliver(d, 1), "receiver answered 401")
+		assert.Equal(t, 1, receiver.Rejected())
+		require.Len(t, d.attempts, 1)
+		assert.Equal(t, http.StatusUnauthorized, d.attempts[0].ResponseStatus)
+		assert.Contains(t, d.attempts[0].ResponseBody, "signature")
+		assert.Equal(t, store.WebhookDeliveryPending, d.dispatch.Status)
+	})
+
+	t.Run("failed attempts are retried until the receiver recovers", func(t *testing.T) {
+		receiver.FailNext(3, http.StatusServiceUnavailable)
+		d := webhookStore.add(server.URL, "whsec_0123456789abcdef", event)
+
+		for attempt := 1; attempt <= 3; attempt++ {
+			assert.Error(t, deliver(d, attempt))
+			require.Len(t, d.attempts, attempt)
+			assert.Equal(t, http.StatusServiceUnavailable, d.attempts[attempt-1].ResponseStatus)
+			assert.Equal(t, now.Add(jobs.DefaultBackoff.After(attempt)), *d.attempts[attempt-1].RetryAt)
+		}
+
+		require.NoError(t, deliver(d, 4))
+		assert.Equal(t, store.WebhookDeliverySucceeded, d.dispatch.Status)
+		assert.Len(t, receiver.Accepted(), 2)
+	})
+
+	t.Run("deliveries fail after the last attempt", func(t *testing.T) {
+		receiver.FailNext(1, http.StatusInternalServerError)
+		d := webhookStore.add(server.URL, "whsec_0123456789abcdef", event)
+
+		assert.Error(t, deliver(d, store.WebhookMaxAttempts))
+		assert.Equal(t, store.WebhookDeliveryFailed, d.dispatch.Status)
+		require.Len(t, d.attempts, 1)
+		assert.Nil(t, d.attempts[0].RetryAt)
+	})
+
+	t.Run("unreachable receivers are retried", func(t *testing.T) {
+		d := webhookStore.add("http://127.0.0.1:1/hook", "whsec_0123456789abcdef", event)
+
+		assert.Error(t, deliver(d, 1))
+		require.Len(t, d.attempts, 1)
+		assert.Zero(t, d.attempts[0].ResponseStatus)
+		assert.NotEmpty(t, d.attempts[0].Error)
+		assert.NotNil(t, d.attempts[0].RetryAt)
+	})
+
+	t.Run("deliveries of inactive webhooks wait", func(t *testing.T) {
+		d := webhookStore.add(server.URL, "whsec_0123456789abcdef", event)
+		d.dispatch.Active = false
+
+		require.NoError(t, deliver(d, 1))
+		assert.Empty(t, d.attempts)
+		assert.Equal(t, store.WebhookDeliveryPending, d.dispatch.Status)
+	})
+
+	t.Run("missing deliveries are skipped", func(t *testing.T) {
+		err := dispatcher.Deliver(context.Background(), store.WebhookDeliveryJobPayload{DeliveryID: 1000})
+		assert.NoError(t, err)
+	})
+
+	t.Run("deliveries and trimming are registered", func(t *testing.T) {
+		jobStore := &fakeJobStore{}
+		worker := jobs.NewWorker(jobStore, time.Second, time.Second, func() time.Time { return now }, log.New(&logs, "", 0))
+		require.NoError(t, dispatcher.Register(worker))
+		require.Len(t, jobStore.jobs, 1)
+		assert.Equal(t, "webhooks.delete_old", jobStore.jobs[0].Kind)
+		assert.True(t, jobStore.jobs[0].RunAt.After(now))
+	})
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	receiver := webhookstest.NewReceiver("whsec_0123456789abcdef")
+	server := receiver.Start()
+	defer server.Close()
+
+	_, err := webhooks.NewClient(false).Post(server.URL, "application/json", strings.NewReader("{}"))
+	assert.ErrorContains(t, err, "private address")
+	assert.Zero(t, receiver.Requests())
+}
@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers of every delivery. The signature covers the timestamp and the
+// body, see Sign.
+const (
+	HeaderEvent     = "X-SocialTask-Event"
+	HeaderDelivery  = "X-SocialTask-Delivery"
+	HeaderTimestamp = "X-SocialTask-Timestamp"
+	HeaderSignature = "X-SocialTask-Signature"
+)
+
+// DefaultTolerance is how old a delivery's timestamp may be for Verify,
+// older ones could be replayed.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrBadSignature = errors.New("webhook signature does not match")
+	ErrBadTimestamp = errors.New("webhook timestamp is missing or too old")
+)
+
+// Sign returns the signature header of a delivery sent at timestamp, in Unix
+// seconds: "sha256=" and the hex HMAC-SHA256, keyed with the webhook's
+// secret, of the timestamp, a dot and the body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery the way
+// receivers should: the signature must match and the timestamp must be
+// within tolerance of now.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadTimestamp
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrBadTimestamp
+	}
+
+	if !strings.HasPrefix(signature, "sha256=") {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package webhooks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1762765200.{"id":1}' | openssl dgst -sha256 -hmac whsec_0123456789abcdef
+	assert.Equal(t,
+		"sha256=9d80b970240b04418404fda622148eb06bd6a625f4a936964dc6aa60b33198f8",
+		Sign("whsec_0123456789abcdef", 1762765200, []byte(`{"id":1}`)),
+	)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2025, time.November, 10, 9, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret-secret-secret", now.Unix(), body)
+
+	assert.NoError(t, Verify("secret-secret-secret", signature, ts, body, now.Add(time.Minute), DefaultTolerance))
+	assert.Equal(t, ErrBadSignature, Verify("another-secret-secret", signature, ts, body, now, DefaultTolerance))
+	assert.Equal(t, ErrBadSignature, Verify("secret-secret-secret", signature, ts, []byte(`{"id":2}`), now, DefaultTolerance))
+	assert.Equal(t, ErrBadSignature, Verify("secret-secret-secret", "", ts, body, now, DefaultTolerance))
+	assert.Equal(t, ErrBadTimestamp, Verify("secret-secret-secret", signature, ts, body, now.Add(10*time.Minute), DefaultTolerance))
+	assert.Equal(t, ErrBadTimestamp, Verify("secret-secret-secret", signature, "", body, now, DefaultTolerance))
+
+	// the timestamp is signed, so an old delivery can not be given a new one
+	later := now.Add(time.Hour)
+	assert.Equal(t, ErrBadSignature, Verify("secret-secret-secret", signature, strconv.FormatInt(later.Unix(), 10), body, later, DefaultTolerance))
+}
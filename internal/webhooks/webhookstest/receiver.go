@@ -0,0 +1,109 @@
+// Package webhookstest provides a webhook receiver for tests and local
+// development.
+package webhookstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/harundarat/be-socialtask/internal/store"
+	"github.com/harundarat/be-socialtask/internal/webhooks"
+)
+
+// Delivery is a request the receiver accepted.
+type Delivery struct {
+	DeliveryID string
+	Event      store.WebhookEvent
+	Body       []byte
+}
+
+// Receiver checks deliveries the way a partner should: a bad signature or
+// timestamp is answered with 401. It can be told to fail the next requests
+// to exercise retries.
+type Receiver struct {
+	secret string
+	now    store.Clock
+
+	mu         sync.Mutex
+	failNext   int
+	failStatus int
+	accepted   []Delivery
+	rejected   int
+	requests   int
+}
+
+// NewReceiver returns a receiver verifying deliveries with secret.
+func NewReceiver(secret string) *Receiver {
+	return &Receiver{secret: secret, now: time.Now}
+}
+
+// Start serves the receiver on a local port until the returned server is
+// closed.
+func (rc *Receiver) Start() *httptest.Server {
+	return httptest.NewServer(rc)
+}
+
+// FailNext answers the next n requests with status before they are checked.
+func (rc *Receiver) FailNext(n, status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.failNext, rc.failStatus = n, status
+}
+
+// Accepted returns the deliveries that were accepted, in order.
+func (rc *Receiver) Accepted() []Delivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Delivery(nil), rc.accepted...)
+}
+
+// Requests counts every request, Rejected those with a bad signature.
+func (rc *Receiver) Requests() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.requests
+}
+
+func (rc *Receiver) Rejected() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.rejected
+}
+
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests++
+
+	if rc.failNext > 0 {
+		rc.failNext--
+		http.Error(w, "failing on purpose", rc.failStatus)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unreadable body", http.StatusBadRequest)
+		return
+	}
+
+	err = webhooks.Verify(rc.secret, r.Header.Get(webhooks.HeaderSignature), r.Header.Get(webhooks.HeaderTimestamp), body, rc.now(), webhooks.DefaultTolerance)
+	if err != nil {
+		rc.rejected++
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var event store.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "body is not an event", http.StatusBadRequest)
+		return
+	}
+
+	rc.accepted = append(rc.accepted, Delivery{DeliveryID: r.Header.Get(webhooks.HeaderDelivery), Event: event, Body: body})
+	w.WriteHeader(http.StatusNoContent)
+}
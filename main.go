@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/harundarat/be-socialtask/internal/app"
@@ -12,7 +18,10 @@ import (
 
 func main() {
 	var port int
+	var runJobs bool
 	flag.IntVar(&port, "port", 8080, "Go backend server port")
+	flag.BoolVar(&runJobs, "jobs", true, "run background jobs in this process, disable when cmd/worker runs them")
+	flag.Parse()
 
 	app, err := app.NewApplication()
 	if err != nil {
@@ -20,6 +29,28 @@ func main() {
 	}
 	defer app.DB.Close()
 
+	// SIGINT and SIGTERM stop the server and let the background work drain
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	runners := []func(context.Context){
+		app.Scheduler.Run,
+		app.Rollup.Run,
+		app.Events.Run,
+		app.Outbox.Run,
+	}
+	if runJobs {
+		runners = append(runners, app.Jobs.Run)
+	}
+	for _, run := range runners {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
+
 	r := routes.SetupRoutes(app)
 
 	server := &http.Server{
@@ -30,11 +61,23 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			app.Logger.Printf("ERROR: shutdown: %v", err)
+		}
+	}()
+
 	app.Logger.Printf("We are running on port %d\n", port)
 
 	err = server.ListenAndServe()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 
 		app.Logger.Fatal(err)
 	}
+
+	background.Wait()
 }
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS leaderboard_scores(
+    id BIGSERIAL PRIMARY KEY,
+    -- scope is 'global' (scope_id = 0) or 'task' (scope_id = tasks.id)
+    scope VARCHAR(16) NOT NULL,
+    scope_id BIGINT NOT NULL DEFAULT 0,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- period is 'daily', 'weekly' or 'all_time'
+    period VARCHAR(16) NOT NULL,
+    period_start DATE NOT NULL,
+    points BIGINT NOT NULL DEFAULT 0,
+    earnings FLOAT NOT NULL DEFAULT 0,
+    last_scored_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (scope, scope_id, user_id, period, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_leaderboard_scores_points
+    ON leaderboard_scores (scope, scope_id, period, period_start, points DESC, last_scored_at, user_id);
+
+CREATE INDEX IF NOT EXISTS idx_leaderboard_scores_earnings
+    ON leaderboard_scores (scope, scope_id, period, period_start, earnings DESC, last_scored_at, user_id);
+
+-- backfill scores from rewards granted before this migration (10 points per reward)
+INSERT INTO leaderboard_scores (scope, scope_id, user_id, period, period_start, points, earnings, last_scored_at)
+SELECT
+    s.scope,
+    s.scope_id,
+    r.user_id,
+    p.period,
+    CASE p.period
+        WHEN 'daily' THEN (r.created_at AT TIME ZONE 'UTC')::date
+        WHEN 'weekly' THEN date_trunc('week', r.created_at AT TIME ZONE 'UTC')::date
+        ELSE DATE '1970-01-01'
+    END,
+    COUNT(*) * 10,
+    SUM(t.reward_usdt),
+    MAX(r.created_at)
+FROM rewards r
+JOIN tasks t ON t.id = r.task_id
+CROSS JOIN (VALUES ('daily'), ('weekly'), ('all_time')) AS p(period)
+CROSS JOIN LATERAL (VALUES ('global', 0::BIGINT), ('task', r.task_id)) AS s(scope, scope_id)
+WHERE r.user_id IS NOT NULL
+GROUP BY 1, 2, 3, 4, 5
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS leaderboard_scores;
+-- +goose StatementEnd
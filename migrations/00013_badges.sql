@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS badges(
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    image_url TEXT,
+    -- declarative rule, see store.BadgeRule
+    rule JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_badges(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    badge_id BIGINT NOT NULL REFERENCES badges(id) ON DELETE CASCADE,
+    awarded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, badge_id)
+);
+
+INSERT INTO badges (code, name, description, rule) VALUES
+    ('first_task', 'First Task Completed', 'Complete your first task', '{"kind": "tasks_completed", "threshold": 1}'),
+    ('streak_7', '7-Day Streak', 'Complete a task seven days in a row', '{"kind": "streak_days", "threshold": 7}')
+ON CONFLICT (code) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_badges;
+DROP TABLE IF EXISTS badges;
+ALTER TABLE users DROP COLUMN role;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS quests(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    -- paid once on top of the rewards of every step
+    reward_usdt FLOAT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS quest_steps(
+    quest_id BIGINT NOT NULL REFERENCES quests(id) ON DELETE CASCADE,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    -- every step requires all steps with a lower position to be verified
+    position INT NOT NULL,
+    PRIMARY KEY (quest_id, position),
+    UNIQUE (quest_id, task_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quest_steps_task ON quest_steps (task_id);
+
+CREATE TABLE IF NOT EXISTS quest_progress(
+    quest_id BIGINT NOT NULL REFERENCES quests(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    completed_steps INT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    reward_usdt FLOAT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (quest_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quest_progress;
+DROP TABLE IF EXISTS quest_steps;
+DROP TABLE IF EXISTS quests;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS task_action_links(
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    action_id BIGINT NOT NULL REFERENCES task_actions(id) ON DELETE CASCADE,
+    -- order in which the actions are shown to and done by the user
+    position INT NOT NULL,
+    PRIMARY KEY (task_id, position),
+    UNIQUE (task_id, action_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_action_links_action ON task_action_links (action_id);
+
+CREATE TABLE IF NOT EXISTS task_reward_links(
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    reward_id BIGINT NOT NULL REFERENCES task_rewards(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (task_id, position),
+    UNIQUE (task_id, reward_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_reward_links_reward ON task_reward_links (reward_id);
+
+-- carry over the single action and reward, ids that point nowhere are dropped
+INSERT INTO task_action_links (task_id, action_id, position)
+SELECT t.id, t.action_id, 1
+FROM tasks t
+JOIN task_actions a ON a.id = t.action_id;
+
+INSERT INTO task_reward_links (task_id, reward_id, position)
+SELECT t.id, t.reward_id, 1
+FROM tasks t
+JOIN task_rewards r ON r.id = t.reward_id;
+
+ALTER TABLE tasks DROP COLUMN action_id, DROP COLUMN reward_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks ADD COLUMN reward_id INT, ADD COLUMN action_id INT;
+
+UPDATE tasks t SET action_id = l.action_id
+FROM task_action_links l
+WHERE l.task_id = t.id AND l.position = 1;
+
+UPDATE tasks t SET reward_id = l.reward_id
+FROM task_reward_links l
+WHERE l.task_id = t.id AND l.position = 1;
+
+DROP TABLE IF EXISTS task_reward_links;
+DROP TABLE IF EXISTS task_action_links;
+-- +goose StatementEnd
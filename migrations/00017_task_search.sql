@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the 'simple' configuration does no stemming, task copy is written in
+-- several languages and an english stemmer would mangle the others
+ALTER TABLE tasks
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_tasks_reward_usdt ON tasks (reward_usdt);
+CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks (due_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tasks_due_date;
+DROP INDEX IF EXISTS idx_tasks_reward_usdt;
+DROP INDEX IF EXISTS idx_tasks_created_at;
+DROP INDEX IF EXISTS idx_tasks_search_vector;
+ALTER TABLE tasks DROP COLUMN search_vector;
+-- +goose StatementEnd
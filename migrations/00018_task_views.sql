@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS task_views(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    view_count INT NOT NULL DEFAULT 1,
+    first_viewed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_viewed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, task_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_views;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- declarative join rules, see store.EligibilityRules
+ALTER TABLE tasks
+ADD COLUMN eligibility JSONB NOT NULL DEFAULT '[]';
+
+-- what eligibility rules are checked against, filled in on X and Google login
+ALTER TABLE users
+ADD COLUMN x_account_created_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN x_followers_count BIGINT,
+ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN x_account_created_at, DROP COLUMN x_followers_count, DROP COLUMN email_verified_at;
+ALTER TABLE tasks DROP COLUMN eligibility;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS task_submissions(
+    id BIGSERIAL PRIMARY KEY,
+    participation_id BIGINT NOT NULL REFERENCES task_participations(id) ON DELETE CASCADE,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    proof_url TEXT NOT NULL DEFAULT '',
+    proof_text TEXT NOT NULL DEFAULT '',
+    asset_id VARCHAR(64) REFERENCES assets(id) ON DELETE SET NULL,
+    -- pending, approved or rejected
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT NOT NULL DEFAULT '',
+    reviewed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_submissions_queue ON task_submissions (task_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_task_submissions_status ON task_submissions (status, created_at);
+
+-- at most one submission per participation waits for review
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_submissions_pending ON task_submissions (participation_id) WHERE status = 'pending';
+
+-- append only, entries are never updated or deleted by the application
+CREATE TABLE IF NOT EXISTS audit_log(
+    id BIGSERIAL PRIMARY KEY,
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS task_submissions;
+UPDATE users SET role = 'user' WHERE role = 'moderator';
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS disputes(
+    id BIGSERIAL PRIMARY KEY,
+    -- a rejection can be appealed once
+    participation_id BIGINT NOT NULL UNIQUE REFERENCES task_participations(id) ON DELETE CASCADE,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- awaiting_creator, awaiting_moderator, upheld or overturned
+    status VARCHAR(20) NOT NULL DEFAULT 'awaiting_creator',
+    message TEXT NOT NULL,
+    evidence_url TEXT NOT NULL DEFAULT '',
+    evidence_asset_id VARCHAR(64) REFERENCES assets(id) ON DELETE SET NULL,
+    creator_response TEXT NOT NULL DEFAULT '',
+    responded_at TIMESTAMP WITH TIME ZONE,
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    reward_id BIGINT REFERENCES rewards(id) ON DELETE SET NULL,
+    response_due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    decision_due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_status ON disputes (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_disputes_task ON disputes (task_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS disputes;
+-- +goose StatementEnd
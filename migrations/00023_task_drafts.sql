@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the new value can not be used in this transaction, drafts are only
+-- written by the application afterwards
+ALTER TYPE status_type ADD VALUE IF NOT EXISTS 'DRAFT';
+
+ALTER TABLE tasks
+ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_publish_at ON tasks (publish_at) WHERE publish_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- enum values can not be dropped, drafts become regular pending tasks
+UPDATE tasks SET status = 'PENDING' WHERE status::text = 'DRAFT';
+DROP INDEX IF EXISTS idx_tasks_publish_at;
+ALTER TABLE tasks DROP COLUMN publish_at;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS task_templates(
+    id BIGSERIAL PRIMARY KEY,
+    -- NULL for system templates offered to every creator
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- title and description may hold {{variable}} placeholders
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    action_ids JSONB NOT NULL DEFAULT '[]',
+    reward_ids JSONB NOT NULL DEFAULT '[]',
+    reward_usdt FLOAT NOT NULL DEFAULT 0,
+    max_participant VARCHAR(50) NOT NULL DEFAULT '',
+    recurrence VARCHAR(10) NOT NULL DEFAULT 'none',
+    recurrence_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    eligibility JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_templates_user ON task_templates (user_id, id);
+
+INSERT INTO task_templates (name, title, description) VALUES
+(
+    'Follow, repost and reply',
+    'Follow @{{handle}}, repost and reply with #{{hashtag}}',
+    E'1. Follow @{{handle}} on X\n2. Repost {{tweet_url}}\n3. Reply to it with #{{hashtag}}'
+),
+(
+    'Follow',
+    'Follow @{{handle}} on X',
+    'Follow @{{handle}} and keep following until the task is verified.'
+),
+(
+    'Repost',
+    'Repost our post',
+    'Repost {{tweet_url}} from your own account.'
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_templates;
+-- +goose StatementEnd
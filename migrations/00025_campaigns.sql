@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS campaigns(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- brand shown on the campaign's tasks
+    brand_name VARCHAR(100) NOT NULL DEFAULT '',
+    brand_color VARCHAR(7) NOT NULL DEFAULT '',
+    website_url TEXT NOT NULL DEFAULT '',
+    logo_asset_id VARCHAR(64) REFERENCES assets(id) ON DELETE SET NULL,
+    banner_asset_id VARCHAR(64) REFERENCES assets(id) ON DELETE SET NULL,
+    budget_usdt FLOAT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at),
+    CHECK (budget_usdt >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_user ON campaigns (user_id, created_at);
+
+-- a task belongs to at most one campaign
+ALTER TABLE tasks
+ADD COLUMN campaign_id BIGINT REFERENCES campaigns(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_campaign ON tasks (campaign_id) WHERE campaign_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tasks DROP COLUMN campaign_id;
+DROP TABLE IF EXISTS campaigns;
+-- +goose StatementEnd
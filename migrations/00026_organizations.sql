@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS organizations(
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members(
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'reviewer', 'viewer')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members (user_id);
+
+-- invitations name either an existing user or an email address, which is
+-- matched when its owner signs in
+CREATE TABLE IF NOT EXISTS organization_invitations(
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'reviewer', 'viewer')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (user_id IS NOT NULL OR email IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_org ON organization_invitations (organization_id, status);
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_user ON organization_invitations (user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_email ON organization_invitations (LOWER(email)) WHERE status = 'pending';
+
+-- one open invitation per person and organization
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_pending_user
+ON organization_invitations (organization_id, user_id) WHERE status = 'pending' AND user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_pending_email
+ON organization_invitations (organization_id, LOWER(email)) WHERE status = 'pending' AND email IS NOT NULL;
+
+-- tasks and campaigns of an organization go back to their creator when it
+-- is deleted
+ALTER TABLE tasks
+ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_organization ON tasks (organization_id) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE campaigns
+ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_organization ON campaigns (organization_id, created_at) WHERE organization_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE campaigns DROP COLUMN organization_id;
+ALTER TABLE tasks DROP COLUMN organization_id;
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+-- +goose StatementEnd
@@ -0,0 +1,74 @@
+-- +goose Up
+-- +goose StatementBegin
+-- raw analytics events, folded into the daily tables below by the rollup
+CREATE TABLE IF NOT EXISTS task_events(
+    id BIGSERIAL PRIMARY KEY,
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('view', 'join', 'submission', 'verified', 'rejected')),
+    -- verified events only: the reward paid and the time since joining
+    reward_usdt FLOAT NOT NULL DEFAULT 0,
+    duration_seconds BIGINT,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    rolled_up BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_events_pending ON task_events (task_id, occurred_at) WHERE NOT rolled_up;
+
+-- one row per task and UTC day
+CREATE TABLE IF NOT EXISTS task_daily_stats(
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    joins BIGINT NOT NULL DEFAULT 0,
+    submissions BIGINT NOT NULL DEFAULT 0,
+    verified BIGINT NOT NULL DEFAULT 0,
+    rejected BIGINT NOT NULL DEFAULT 0,
+    reward_usdt FLOAT NOT NULL DEFAULT 0,
+    completion_seconds BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, day)
+);
+
+-- verifications per task, UTC day and time-to-complete bucket
+CREATE TABLE IF NOT EXISTS task_completion_stats(
+    task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    bucket SMALLINT NOT NULL,
+    completions BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, day, bucket)
+);
+
+-- history from before events were recorded, earlier views only kept a
+-- count per user so they are all placed at the first view
+INSERT INTO task_events (task_id, user_id, type, occurred_at)
+SELECT v.task_id, v.user_id, 'view', v.first_viewed_at
+FROM task_views v, generate_series(1, v.view_count);
+
+INSERT INTO task_events (task_id, user_id, type, occurred_at)
+SELECT task_id, user_id, 'join', created_at
+FROM task_participations
+WHERE created_at IS NOT NULL;
+
+INSERT INTO task_events (task_id, user_id, type, occurred_at)
+SELECT task_id, user_id, 'submission', created_at
+FROM task_submissions
+WHERE created_at IS NOT NULL;
+
+INSERT INTO task_events (task_id, user_id, type, reward_usdt, duration_seconds, occurred_at)
+SELECT p.task_id, p.user_id, 'verified', t.reward_usdt, GREATEST(EXTRACT(EPOCH FROM p.updated_at - p.created_at), 0)::BIGINT, p.updated_at
+FROM task_participations p
+JOIN tasks t ON t.id = p.task_id
+WHERE p.status = 'verified' AND p.created_at IS NOT NULL AND p.updated_at IS NOT NULL;
+
+INSERT INTO task_events (task_id, user_id, type, occurred_at)
+SELECT task_id, user_id, 'rejected', updated_at
+FROM task_participations
+WHERE status = 'rejected' AND updated_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS task_completion_stats;
+DROP TABLE IF EXISTS task_daily_stats;
+DROP TABLE IF EXISTS task_events;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- anonymous events carry a hashed session id instead of a user
+ALTER TABLE task_events ADD COLUMN IF NOT EXISTS session_id VARCHAR(64);
+
+ALTER TABLE task_events DROP CONSTRAINT IF EXISTS task_events_type_check;
+ALTER TABLE task_events ADD CONSTRAINT task_events_type_check
+    CHECK (type IN ('view', 'impression', 'click', 'join', 'submission', 'verified', 'rejected', 'rewarded'));
+
+ALTER TABLE task_daily_stats ADD COLUMN IF NOT EXISTS impressions BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE task_daily_stats ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE task_daily_stats DROP COLUMN IF EXISTS clicks;
+ALTER TABLE task_daily_stats DROP COLUMN IF EXISTS impressions;
+
+DELETE FROM task_events WHERE type IN ('impression', 'click', 'rewarded');
+ALTER TABLE task_events DROP CONSTRAINT IF EXISTS task_events_type_check;
+ALTER TABLE task_events ADD CONSTRAINT task_events_type_check
+    CHECK (type IN ('view', 'join', 'submission', 'verified', 'rejected'));
+
+ALTER TABLE task_events DROP COLUMN IF EXISTS session_id;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the X handle is exported with participants, it is filled in on the next X login
+ALTER TABLE users ADD COLUMN IF NOT EXISTS x_username VARCHAR(255);
+
+-- participant exports generated in the background
+CREATE TABLE IF NOT EXISTS export_jobs(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- exactly one of task_id and campaign_id is set
+    task_id BIGINT REFERENCES tasks(id) ON DELETE CASCADE,
+    campaign_id BIGINT REFERENCES campaigns(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'xlsx')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    row_count BIGINT NOT NULL DEFAULT 0,
+    blob_key TEXT,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((task_id IS NULL) <> (campaign_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_queue ON export_jobs (created_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_export_jobs_expiry ON export_jobs (expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS export_jobs;
+ALTER TABLE users DROP COLUMN IF EXISTS x_username;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a job with a unique key is only enqueued once, like the run of a
+-- scheduled job for a time slot
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS unique_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs (unique_key) WHERE unique_key IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_jobs_unique_key;
+ALTER TABLE jobs DROP COLUMN IF EXISTS unique_key;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- tasks created without a due date were stored with the zero time and
+-- closed right away, NULL keeps them open
+UPDATE tasks SET due_date = NULL WHERE due_date < '0002-01-01';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the tasks keep their NULL due date, which is what they meant
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- exports and webhook deliveries are sent by jobs now, those left waiting
+-- get one
+INSERT INTO jobs (queue, kind, payload, max_attempts, run_at)
+SELECT 'exports', 'exports.generate', jsonb_build_object('export_id', id), 3, CURRENT_TIMESTAMP
+FROM export_jobs
+WHERE status IN ('pending', 'running');
+
+INSERT INTO jobs (queue, kind, payload, max_attempts, run_at)
+SELECT 'webhooks', 'webhooks.deliver', jsonb_build_object('delivery_id', id), 10, next_attempt_at
+FROM webhook_deliveries
+WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM jobs WHERE kind IN ('exports.generate', 'webhooks.deliver');
+-- +goose StatementEnd
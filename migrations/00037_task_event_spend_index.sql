@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- campaign spend adds up the rewards of the verified events of its tasks
+CREATE INDEX IF NOT EXISTS idx_task_events_verified ON task_events (task_id) WHERE type = 'verified';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_events_verified;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- quest rewards are rewards of the task that completed the quest, and every
+-- reward keeps its amount. The quest id stays when the quest is deleted, like
+-- the reward does.
+ALTER TABLE rewards
+    ADD COLUMN IF NOT EXISTS quest_id BIGINT,
+    ADD COLUMN IF NOT EXISTS reward_usdt FLOAT NOT NULL DEFAULT 0;
+
+-- the amount paid is not known any more, the task's current one is closest
+UPDATE rewards r SET reward_usdt = t.reward_usdt FROM tasks t WHERE t.id = r.task_id;
+
+-- spend adds up the rewarded events, quest rewards included
+DROP INDEX IF EXISTS idx_task_events_verified;
+CREATE INDEX IF NOT EXISTS idx_task_events_rewarded ON task_events (task_id) WHERE type = 'rewarded';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_events_rewarded;
+CREATE INDEX IF NOT EXISTS idx_task_events_verified ON task_events (task_id) WHERE type = 'verified';
+DELETE FROM rewards WHERE quest_id IS NOT NULL;
+ALTER TABLE rewards DROP COLUMN IF EXISTS quest_id, DROP COLUMN IF EXISTS reward_usdt;
+-- +goose StatementEnd
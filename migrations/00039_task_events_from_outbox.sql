@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- server events reach task_events through the outbox, which publishes an
+-- event at least once, so an event is written once per outbox event
+ALTER TABLE task_events ADD COLUMN IF NOT EXISTS outbox_event_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_events_outbox_event ON task_events (outbox_event_id) WHERE outbox_event_id IS NOT NULL;
+
+-- events still waiting in the outbox were already written, link them so
+-- they are not written again
+UPDATE task_events te
+SET outbox_event_id = m.outbox_event_id
+FROM (
+    SELECT DISTINCT ON (o.id) o.id AS outbox_event_id, te.id AS task_event_id
+    FROM outbox_events o
+    JOIN task_events te
+        ON te.task_id = o.aggregate_id
+        AND te.user_id = (o.payload->>'user_id')::bigint
+        AND te.occurred_at = o.created_at
+        AND te.type = CASE o.event_type
+            WHEN 'task.joined' THEN 'join'
+            WHEN 'task.submitted' THEN 'submission'
+            WHEN 'task.completed' THEN 'verified'
+            WHEN 'task.rejected' THEN 'rejected'
+            WHEN 'reward.created' THEN 'rewarded'
+        END
+    WHERE o.aggregate_type = 'task' AND o.published_at IS NULL
+    ORDER BY o.id, te.id
+) m
+WHERE te.id = m.task_event_id;
+
+-- webhook events are numbered by their outbox event now, start past the ids
+-- receivers have already seen
+SELECT setval(pg_get_serial_sequence('outbox_events', 'id'), GREATEST(
+    (SELECT COALESCE(MAX(id), 0) FROM outbox_events),
+    (SELECT COALESCE(MAX(event_id), 0) FROM webhook_deliveries),
+    1
+));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_events_outbox_event;
+ALTER TABLE task_events DROP COLUMN IF EXISTS outbox_event_id;
+-- +goose StatementEnd